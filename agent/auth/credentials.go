@@ -9,15 +9,20 @@ import (
 	"net/http"
 )
 
+// ClientID identifies the agent to the server so it issues a refresh token
+const ClientID = "edictflow-cli"
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	ClientID string `json:"client_id"`
 }
 
 type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresIn int    `json:"expires_in"`
-	User      struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         struct {
 		ID     string  `json:"id"`
 		Email  string  `json:"email"`
 		Name   string  `json:"name"`
@@ -41,6 +46,7 @@ func (c *CredentialsClient) Login(email, password string) (LoginResponse, error)
 	reqBody := LoginRequest{
 		Email:    email,
 		Password: password,
+		ClientID: ClientID,
 	}
 
 	body, err := json.Marshal(reqBody)
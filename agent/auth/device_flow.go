@@ -19,9 +19,10 @@ type DeviceAuthResponse struct {
 }
 
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 type ErrorResponse struct {
@@ -41,7 +42,7 @@ func NewDeviceFlowClient(serverURL string) *DeviceFlowClient {
 }
 
 func (c *DeviceFlowClient) InitiateDeviceAuth() (DeviceAuthResponse, error) {
-	body := bytes.NewBufferString(`{"client_id":"edictflow-cli"}`)
+	body := bytes.NewBufferString(`{"client_id":"` + ClientID + `"}`)
 	resp, err := c.client.Post(c.serverURL+"/api/v1/auth/device", "application/json", body)
 	if err != nil {
 		return DeviceAuthResponse{}, err
@@ -68,7 +69,7 @@ func (c *DeviceFlowClient) PollForToken(deviceCode string, interval, expiresIn i
 		body := bytes.NewBuffer(nil)
 		_ = json.NewEncoder(body).Encode(map[string]string{
 			"device_code": deviceCode,
-			"client_id":   "edictflow-cli",
+			"client_id":   ClientID,
 		})
 
 		resp, err := c.client.Post(c.serverURL+"/api/v1/auth/device/token", "application/json", body)
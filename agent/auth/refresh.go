@@ -0,0 +1,62 @@
+// agent/auth/refresh.go
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrRefreshRejected is returned when the server refuses a refresh token
+// because it is unknown, expired, revoked or was already used
+var ErrRefreshRejected = errors.New("refresh token rejected")
+
+type RefreshClient struct {
+	serverURL string
+	client    *http.Client
+}
+
+func NewRefreshClient(serverURL string) *RefreshClient {
+	return &RefreshClient{
+		serverURL: serverURL,
+		client:    sharedHTTPClient,
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token. The response
+// carries a rotated refresh token that replaces the one presented.
+func (c *RefreshClient) Refresh(refreshToken string) (TokenResponse, error) {
+	body, err := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	resp, err := c.client.Post(c.serverURL+"/api/v1/auth/refresh", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return TokenResponse{}, ErrRefreshRejected
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if json.Unmarshal(bodyBytes, &errResp) == nil && errResp.Error != "" {
+			return TokenResponse{}, fmt.Errorf("refresh failed: %s", errResp.Error)
+		}
+		return TokenResponse{}, fmt.Errorf("refresh failed: %s", string(bodyBytes))
+	}
+
+	var result TokenResponse
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result, nil
+}
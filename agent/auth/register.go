@@ -0,0 +1,82 @@
+// agent/auth/register.go
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrAgentRevoked is returned when an admin revoked this machine's agent
+var ErrAgentRevoked = errors.New("agent revoked")
+
+// RegisterRequest describes the machine the agent runs on
+type RegisterRequest struct {
+	MachineID string `json:"machine_id"`
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Version   string `json:"version"`
+	// RefreshToken lets the server end this login when the agent is revoked
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// RegisterResponse carries the agent ID the server keeps for the machine
+type RegisterResponse struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+type RegisterClient struct {
+	serverURL string
+	client    *http.Client
+}
+
+func NewRegisterClient(serverURL string) *RegisterClient {
+	return &RegisterClient{
+		serverURL: serverURL,
+		client:    sharedHTTPClient,
+	}
+}
+
+// Register returns the agent ID of this machine for the logged in user. The
+// server keeps the same ID for every login from the machine.
+func (c *RegisterClient) Register(accessToken string, reg RegisterRequest) (RegisterResponse, error) {
+	body, err := json.Marshal(reg)
+	if err != nil {
+		return RegisterResponse{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.serverURL+"/api/v1/agents/register", bytes.NewBuffer(body))
+	if err != nil {
+		return RegisterResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return RegisterResponse{}, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusForbidden {
+		return RegisterResponse{}, ErrAgentRevoked
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return RegisterResponse{}, fmt.Errorf("agent registration failed: %s", strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var result RegisterResponse
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return RegisterResponse{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kamilrybacki/edictflow/agent/renderer"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/agent/watcher"
+)
+
+var errNoPendingChange = errors.New("no matching pending change")
+
+// recordPendingChange stores the original and modified content of a detected
+// change so it can be restored if the server rejects it. A file has at most one
+// open pending change; repeated edits only update its modified content.
+func (d *Daemon) recordPendingChange(path, ruleID string) error {
+	if d.fileWatcher == nil {
+		return fmt.Errorf("file watcher not running")
+	}
+
+	original, ok := d.fileWatcher.Snapshot(path)
+	if !ok {
+		return fmt.Errorf("%s is not watched", path)
+	}
+
+	modified, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	change, err := d.store.GetPendingChangeByFile(path)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		change = storage.PendingChange{
+			ID:              uuid.New().String(),
+			RuleID:          ruleID,
+			FilePath:        path,
+			OriginalContent: string(original),
+			Status:          "pending",
+			CreatedAt:       time.Now(),
+		}
+	}
+	change.ModifiedContent = string(modified)
+
+	return d.store.SavePendingChange(change)
+}
+
+// changedRule returns the ID of the cached rule whose part of a managed
+// file's section was edited. ok is false when the edit is outside the
+// managed section.
+func (d *Daemon) changedRule(path string) (string, bool) {
+	d.mu.RLock()
+	file, managed := d.managedFiles[path]
+	d.mu.RUnlock()
+	if !managed {
+		return "", false
+	}
+
+	expected, rules, err := renderer.ManagedSectionForFile(d.store, file.Target, file.Level, path)
+	if err != nil {
+		log.Printf("Failed to render the managed section of %s: %v", path, err)
+		return "", false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read %s: %v", path, err)
+		return "", false
+	}
+	rule, ok := renderer.ForTarget(file.Target).ChangedRule(string(content), expected, rules)
+	return rule.ID, ok
+}
+
+// findPendingChange locates the local pending change a server message refers to.
+// The server assigns its own change IDs, so fall back to the file path and the
+// hash of the original content.
+func (d *Daemon) findPendingChange(changeID, filePath, originalHash string) (storage.PendingChange, error) {
+	if change, err := d.store.GetPendingChange(changeID); err == nil {
+		return change, nil
+	}
+
+	if filePath != "" {
+		if change, err := d.store.GetPendingChangeByFile(filePath); err == nil {
+			return change, nil
+		}
+	}
+
+	if originalHash != "" {
+		changes, err := d.store.GetPendingChanges()
+		if err != nil {
+			return storage.PendingChange{}, err
+		}
+		// Ordered newest first
+		for _, c := range changes {
+			if c.Status == "pending" && hashContent(c.OriginalContent) == originalHash {
+				return c, nil
+			}
+		}
+	}
+
+	return storage.PendingChange{}, errNoPendingChange
+}
+
+// revertChange atomically restores the original content of a pending change
+func (d *Daemon) revertChange(change storage.PendingChange) error {
+	original := []byte(change.OriginalContent)
+
+	var err error
+	if d.fileWatcher != nil {
+		err = d.fileWatcher.RevertFile(change.FilePath, original)
+	} else {
+		err = watcher.WriteFileAtomic(change.FilePath, original)
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.store.UpdateChangeStatus(change.ID, "reverted")
+}
+
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
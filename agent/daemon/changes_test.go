@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/detect"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/agent/watcher"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+// newTestDaemon returns a daemon with its own storage and the default
+// targets, not connected to any server
+func newTestDaemon(t *testing.T) *Daemon {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	store, err := storage.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return &Daemon{
+		store:        store,
+		wsClient:     ws.NewClient("ws://127.0.0.1:0", ""),
+		managedFiles: make(map[string]ManagedFile),
+		targets:      markdown.ResolveTargets(nil),
+		detectors:    detect.Default(),
+		fingerprints: make(map[string]string),
+		shutdown:     make(chan struct{}),
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDaemon_ChangeDetectedNamesTheEditedRule(t *testing.T) {
+	d := newTestDaemon(t)
+	rules := []storage.CachedRule{
+		{ID: "rule-1", Name: "Use Go", Content: "Write services in Go", TargetLayer: "project", CategoryName: "Style"},
+		{ID: "rule-2", Name: "Tests", Content: "Write table tests", TargetLayer: "project", CategoryName: "Testing"},
+	}
+	if err := d.store.SaveRules(rules, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := watcher.NewWithPolling(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.fileWatcher = w
+	d.setupFileWatcher()
+
+	project := t.TempDir()
+	for _, f := range d.registerProject(project) {
+		if err := d.syncFile(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WatchFile(f.Path, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Start()
+	defer w.Stop()
+
+	path := markdown.ResolveTargets(nil)[0].ProjectFilePath(project)
+	synced, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Edits outside the managed section are the user's own
+	notes := "# Notes\n\n" + string(synced)
+	if err := os.WriteFile(path, []byte(notes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the notes to become the baseline", func() bool {
+		snapshot, _ := w.Snapshot(path)
+		return string(snapshot) == notes
+	})
+	if queued, _ := d.store.GetPendingMessages(); len(queued) != 0 {
+		t.Fatalf("expected no change_detected for an edit outside the managed section, got %+v", queued)
+	}
+
+	edited := strings.Replace(notes, "Write table tests", "Tests are optional", 1)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var queued []storage.QueuedMessage
+	waitFor(t, "change_detected", func() bool {
+		queued, _ = d.store.GetPendingMessages()
+		return len(queued) == 1
+	})
+
+	var msg struct {
+		Type    ws.MessageType           `json:"type"`
+		Payload ws.ChangeDetectedPayload `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(queued[0].Payload), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != ws.TypeChangeDetected || msg.Payload.RuleID != "rule-2" || msg.Payload.FilePath != path {
+		t.Errorf("expected change_detected for rule-2, got %s %+v", msg.Type, msg.Payload)
+	}
+
+	change, err := d.store.GetPendingChangeByFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.RuleID != "rule-2" || change.OriginalContent != notes {
+		t.Errorf("unexpected pending change: %+v", change)
+	}
+}
@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+// Remote commands the server can send
+const (
+	CommandResync            = "resync"
+	CommandTamperCheck       = "tamper_check"
+	CommandDiagnostics       = "diagnostics"
+	CommandRotateCredentials = "rotate_credentials"
+	CommandShutdown          = "shutdown"
+)
+
+// KnownCommands lists every remote command the daemon can run
+var KnownCommands = []string{
+	CommandResync, CommandTamperCheck, CommandDiagnostics, CommandRotateCredentials, CommandShutdown,
+}
+
+// DefaultAllowedCommands are the commands the server may run until the user
+// changes the allowlist. Shutting the daemon down needs the user's consent.
+var DefaultAllowedCommands = []string{
+	CommandResync, CommandTamperCheck, CommandDiagnostics, CommandRotateCredentials,
+}
+
+// diagnosticsLogLines is how many recent log lines diagnostics include
+const diagnosticsLogLines = 50
+
+// Diagnostics describes the daemon's state for an admin
+type Diagnostics struct {
+	Version       string   `json:"version"`
+	OS            string   `json:"os"`
+	Hostname      string   `json:"hostname"`
+	ConnectedAt   string   `json:"connected_at"`
+	CachedVersion int      `json:"cached_version"`
+	Projects      []string `json:"projects"`
+	QueueDepth    int      `json:"queue_depth"`
+	DeadLetters   int      `json:"dead_letters"`
+	LogTail       []string `json:"log_tail"`
+}
+
+// allowedCommands returns the commands the user allows the server to run
+func (d *Daemon) allowedCommands() map[string]bool {
+	names, err := d.store.GetAllowedCommands()
+	if err != nil {
+		log.Printf("Failed to load allowed commands, using defaults: %v", err)
+	}
+	if names == nil {
+		names = DefaultAllowedCommands
+	}
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	return allowed
+}
+
+// handleCommand runs a remote command if the allowlist permits it and
+// queues the result for the server
+func (d *Daemon) handleCommand(msg ws.Message) {
+	var payload ws.CommandPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Invalid command: %v", err)
+		return
+	}
+
+	result := ws.CommandResultPayload{CommandID: payload.CommandID}
+	var output interface{}
+	var err error
+	if !d.allowedCommands()[payload.Command] {
+		err = fmt.Errorf("command %q is not allowed on this machine", payload.Command)
+	} else {
+		log.Printf("Running remote command %s (%s)", payload.Command, payload.CommandID)
+		output, err = d.runCommand(payload.Command)
+	}
+	if err != nil {
+		log.Printf("Remote command %s failed: %v", payload.Command, err)
+		result.Error = err.Error()
+	} else if output != nil {
+		result.Output, _ = json.Marshal(output)
+	}
+
+	reply, _ := ws.NewMessage(ws.TypeCommandResult, result)
+	if err := d.enqueue(reply); err != nil {
+		log.Printf("Failed to queue result of command %s: %v", payload.CommandID, err)
+	}
+
+	if payload.Command == CommandShutdown && result.Error == "" {
+		// Send the result before stopping; it is replayed on the next start
+		// if the connection drops first
+		d.drainQueue()
+		d.requestShutdown()
+	}
+}
+
+func (d *Daemon) runCommand(command string) (interface{}, error) {
+	switch command {
+	case CommandResync:
+		// Ask for a full snapshot; the managed files are rewritten when it
+		// arrives
+		msg, _ := ws.NewMessage(ws.TypeSyncRequest, ws.SyncRequestPayload{SinceVersion: 0})
+		if err := d.wsClient.Send(msg); err != nil {
+			return nil, err
+		}
+		return map[string]int{"cached_version": d.store.GetCachedVersion()}, nil
+
+	case CommandTamperCheck:
+		restored := d.CheckAndRestoreTamperedFiles()
+		return map[string]interface{}{
+			"checked":  len(d.snapshotManagedFiles()),
+			"restored": restored,
+		}, nil
+
+	case CommandDiagnostics:
+		return d.diagnostics(), nil
+
+	case CommandRotateCredentials:
+		if err := d.refreshAccessToken(); err != nil {
+			return nil, err
+		}
+		info, err := d.store.GetAuth()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"expires_at": info.ExpiresAt.Format(time.RFC3339)}, nil
+
+	case CommandShutdown:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", command)
+}
+
+func (d *Daemon) diagnostics() Diagnostics {
+	projects, _ := d.store.GetProjects()
+	paths := make([]string, len(projects))
+	for i, p := range projects {
+		paths[i] = p.Path
+	}
+	sort.Strings(paths)
+
+	pending, _ := d.store.GetPendingMessages()
+	dead, _ := d.store.GetDeadLetters()
+
+	return Diagnostics{
+		Version:       Version,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Hostname:      d.hostname,
+		ConnectedAt:   d.connectedAt.Format(time.RFC3339),
+		CachedVersion: d.store.GetCachedVersion(),
+		Projects:      paths,
+		QueueDepth:    len(pending),
+		DeadLetters:   len(dead),
+		LogTail:       d.logs.Lines(diagnosticsLogLines),
+	}
+}
+
+// requestShutdown stops the daemon as if it received SIGTERM
+func (d *Daemon) requestShutdown() {
+	d.shutdownOnce.Do(func() { close(d.shutdown) })
+}
@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/detect"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+// contextCheckInterval is how often watched projects are checked for changes
+// to the files that context detection reads
+const contextCheckInterval = 30 * time.Second
+
+// detectProjectContext re-runs context and tag detection for a project when
+// its marker files changed since the last run. A changed result is stored,
+// reported to the server and the project's files are re-rendered.
+func (d *Daemon) detectProjectContext(project storage.WatchedProject) {
+	d.detectMu.Lock()
+	defer d.detectMu.Unlock()
+
+	fingerprint := d.detectors.Fingerprint(project.Path)
+	if d.fingerprints[project.Path] == fingerprint {
+		return
+	}
+
+	result, err := d.detectors.Detect(project.Path)
+	if err != nil {
+		log.Printf("Context detection for %s: %v", project.Path, err)
+		// An unreadable project yields no result at all; a failing
+		// detector still leaves what the others found
+		if result.Contexts == nil {
+			return
+		}
+	}
+	d.fingerprints[project.Path] = fingerprint
+
+	stored := detect.Result{
+		Contexts:   project.DetectedContext,
+		Tags:       project.DetectedTags,
+		Repository: project.Repository,
+		Branch:     project.Branch,
+	}
+	if project.DetectedContext != nil && result.Equal(stored) {
+		return
+	}
+
+	if err := d.store.UpdateProjectContext(project.Path, result.Contexts, result.Tags, result.Repository, result.Branch); err != nil {
+		log.Printf("Failed to store context for %s: %v", project.Path, err)
+		return
+	}
+	log.Printf("Detected context %v and tags %v for %s", result.Contexts, result.Tags, project.Path)
+	if result.Repository != "" {
+		log.Printf("Detected repository %s on branch %q for %s", result.Repository, result.Branch, project.Path)
+	}
+
+	// Context, tag and repository triggers decide which rules the project gets
+	for _, f := range d.snapshotManagedFiles() {
+		if root, ok := f.Target.ProjectRoot(f.Path); !ok || f.Level != "project" || root != project.Path {
+			continue
+		}
+		if err := d.syncFile(f); err != nil {
+			log.Printf("Failed to sync %s after context change: %v", f.Path, err)
+		}
+	}
+
+	msg, _ := ws.NewMessage(ws.TypeContextDetected, ws.ContextDetectedPayload{
+		ProjectPath:     project.Path,
+		DetectedContext: result.Contexts,
+		DetectedTags:    result.Tags,
+		Repository:      result.Repository,
+		Branch:          result.Branch,
+	})
+	if err := d.enqueue(msg); err != nil {
+		log.Printf("Failed to queue context_detected for %s: %v", project.Path, err)
+	}
+}
+
+// forgetProjectContext drops the detection state of an unwatched project
+func (d *Daemon) forgetProjectContext(projectPath string) {
+	d.detectMu.Lock()
+	defer d.detectMu.Unlock()
+	delete(d.fingerprints, projectPath)
+}
+
+// refreshProjectContexts checks every watched project for context changes
+func (d *Daemon) refreshProjectContexts() {
+	projects, err := d.store.GetProjects()
+	if err != nil {
+		log.Printf("Failed to list projects for context detection: %v", err)
+		return
+	}
+	for _, p := range projects {
+		d.detectProjectContext(p)
+	}
+}
+
+// runContextDetection detects project contexts on start and then every
+// contextCheckInterval until stop is closed
+func (d *Daemon) runContextDetection(stop <-chan struct{}) {
+	d.refreshProjectContexts()
+
+	ticker := time.NewTicker(contextCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.refreshProjectContexts()
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -12,31 +13,29 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/kamilrybacki/edictflow/agent/detect"
 	"github.com/kamilrybacki/edictflow/agent/notify"
 	"github.com/kamilrybacki/edictflow/agent/renderer"
 	"github.com/kamilrybacki/edictflow/agent/storage"
 	"github.com/kamilrybacki/edictflow/agent/watcher"
 	"github.com/kamilrybacki/edictflow/agent/ws"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
 )
 
 const (
 	// Version is the current agent version
 	Version = "0.1.0"
-	// EnterpriseFilePath is the fixed path for enterprise rules
-	EnterpriseFilePath = "/etc/claude-code/CLAUDE.md"
-	// UserFileName is the filename for user rules (in ~/.claude/)
-	UserFileName = "CLAUDE.md"
-	// ProjectFileName is the filename for project rules
-	ProjectFileName = "CLAUDE.md"
 )
 
-// ManagedFile represents a CLAUDE.md file managed by the daemon
+// ManagedFile represents an output target's file managed by the daemon
 type ManagedFile struct {
-	Level string
-	Path  string
+	Level  string
+	Path   string
+	Target markdown.Target
 }
 
 type Daemon struct {
@@ -45,13 +44,22 @@ type Daemon struct {
 	serverURL    string
 	listener     net.Listener
 	fileWatcher  *watcher.Watcher
-	renderer     *renderer.Renderer
-	managedFiles map[string]ManagedFile // path -> level
+	managedFiles map[string]ManagedFile // path -> level and target
 	projectDirs  []string               // watched project directories
+	targets      []markdown.Target      // enabled output targets
+	mu           sync.RWMutex           // guards managedFiles, projectDirs and targets
+	drainMu      sync.Mutex             // serializes outbound queue drains
+	authMu       sync.Mutex             // serializes token refreshes
+	detectors    *detect.Registry       // project context and tag detectors
+	fingerprints map[string]string      // project path -> fingerprint of detection inputs
+	detectMu     sync.Mutex             // serializes context detection, guards fingerprints
 	connectedAt  time.Time              // when the daemon connected
 	hostname     string                 // cached hostname
-	userID       string                 // user ID for agent identification
+	agentID      string                 // registered agent ID, or the user ID for older logins
 	teamID       string                 // team ID for routing
+	logs         *logTail               // recent log lines for diagnostics
+	shutdown     chan struct{}          // closed when a remote command stops the daemon
+	shutdownOnce sync.Once
 }
 
 func GetPIDFile() (string, error) {
@@ -154,13 +162,16 @@ func Stop() error {
 }
 
 func runDaemon(serverURL string, pollInterval time.Duration) error {
+	logs := newLogTail(logTailSize)
+	log.SetOutput(io.MultiWriter(os.Stderr, logs))
+
 	store, err := storage.New()
 	if err != nil {
 		return fmt.Errorf("failed to open storage: %w", err)
 	}
 	defer store.Close()
 
-	auth, err := store.GetAuth()
+	authInfo, err := store.GetAuth()
 	if err != nil {
 		return fmt.Errorf("not logged in: %w", err)
 	}
@@ -176,7 +187,7 @@ func runDaemon(serverURL string, pollInterval time.Duration) error {
 	defer os.Remove(pidFile)
 
 	// Create WebSocket client
-	wsClient := ws.NewClient(serverURL, auth.AccessToken)
+	wsClient := ws.NewClient(serverURL, authInfo.AccessToken)
 
 	// Get hostname for heartbeat
 	hostname, _ := os.Hostname()
@@ -185,12 +196,25 @@ func runDaemon(serverURL string, pollInterval time.Duration) error {
 		store:        store,
 		wsClient:     wsClient,
 		serverURL:    serverURL,
-		renderer:     renderer.New(),
 		managedFiles: make(map[string]ManagedFile),
+		detectors:    detect.Default(),
+		fingerprints: make(map[string]string),
 		connectedAt:  time.Now(),
 		hostname:     hostname,
-		userID:       auth.UserID,
-		teamID:       auth.TeamID,
+		teamID:       authInfo.TeamID,
+		logs:         logs,
+		shutdown:     make(chan struct{}),
+	}
+
+	d.agentID, err = d.resolveAgentID(authInfo)
+	if err != nil {
+		return fmt.Errorf("cannot start: %w", err)
+	}
+	wsClient.SetAgentID(d.agentID)
+
+	// A new start may follow an upgrade; the server says again if not
+	if err := store.SaveUpgradeRequired(""); err != nil {
+		log.Printf("Failed to clear upgrade notice: %v", err)
 	}
 
 	// Initialize managed file paths
@@ -232,9 +256,18 @@ func runDaemon(serverURL string, pollInterval time.Duration) error {
 
 	// Connect to server with context for graceful shutdown
 	go wsClient.ConnectWithContext(ctx)
+	go d.runQueueRetries(ctx.Done())
+	go d.runTokenRefresh(ctx.Done())
+	go d.runDriftReports(ctx.Done())
+	go d.runContextDetection(ctx.Done())
+	go d.runHeartbeats(ctx.Done())
 
 	log.Println("Daemon running...")
-	<-sigChan
+	select {
+	case <-sigChan:
+	case <-d.shutdown:
+		log.Println("Shutdown requested by the server")
+	}
 	log.Println("Shutting down...")
 
 	// Cancel context to stop reconnection loop, then close connection
@@ -247,7 +280,11 @@ func (d *Daemon) setupHandlers() {
 	d.wsClient.OnConnect(func() {
 		log.Println("Connected to server")
 		notify.ConnectionRestored()
+		d.sendHello()
 		d.sendHeartbeat()
+		d.sendSyncRequest()
+		go d.sendDriftReport()
+		go d.replayQueue()
 	})
 
 	d.wsClient.OnDisconnect(func() {
@@ -255,10 +292,18 @@ func (d *Daemon) setupHandlers() {
 		notify.ConnectionLost()
 	})
 
+	d.wsClient.OnUnauthorized(d.refreshAccessToken)
+	d.wsClient.OnForbidden(d.stopRevoked)
+
 	d.wsClient.OnMessage(ws.TypeConfigUpdate, d.handleConfigUpdate)
 	d.wsClient.OnMessage(ws.TypeAck, d.handleAck)
+	d.wsClient.OnMessage(ws.TypeNack, d.handleNack)
 	d.wsClient.OnMessage(ws.TypeChangeApproved, d.handleChangeApproved)
 	d.wsClient.OnMessage(ws.TypeChangeRejected, d.handleChangeRejected)
+	d.wsClient.OnMessage(ws.TypeCommand, d.handleCommand)
+	d.wsClient.OnMessage(ws.TypeAgentRevoked, d.handleAgentRevoked)
+	d.wsClient.OnMessage(ws.TypeWelcome, d.handleWelcome)
+	d.wsClient.OnMessage(ws.TypeUpgradeRequired, d.handleUpgradeRequired)
 }
 
 func (d *Daemon) sendHeartbeat() {
@@ -268,18 +313,26 @@ func (d *Daemon) sendHeartbeat() {
 		paths[i] = p.Path
 	}
 
+	ruleVersions, err := d.store.GetRuleVersions()
+	if err != nil {
+		log.Printf("Failed to read rule versions: %v", err)
+	}
+
 	payload := ws.HeartbeatPayload{
 		Status:         "online",
 		CachedVersion:  d.store.GetCachedVersion(),
 		ActiveProjects: paths,
-		AgentID:        d.userID, // Use userID as agent identifier
+		AgentID:        d.agentID,
 		TeamID:         d.teamID,
 		Hostname:       d.hostname,
 		Version:        Version,
 		OS:             runtime.GOOS + "/" + runtime.GOARCH,
 		ConnectedAt:    d.connectedAt.Format(time.RFC3339),
+		RuleVersions:   ruleVersions,
 	}
 
+	// Heartbeats describe current state and are not worth replaying, so
+	// they bypass the outbound queue
 	msg, _ := ws.NewMessage(ws.TypeHeartbeat, payload)
 	_ = d.wsClient.Send(msg)
 }
@@ -291,6 +344,19 @@ func (d *Daemon) handleConfigUpdate(msg ws.Message) {
 		return
 	}
 
+	if payload.Delta {
+		if cached := d.store.GetCachedVersion(); cached != payload.SinceVersion {
+			// The delta does not apply to what is cached; catch up from
+			// the cached version instead
+			log.Printf("Config update from version %d does not apply to cached version %d", payload.SinceVersion, cached)
+			d.sendSyncRequest()
+			return
+		}
+		if payload.Version == payload.SinceVersion {
+			return // Already up to date
+		}
+	}
+
 	rules := make([]storage.CachedRule, len(payload.Rules))
 	for i, r := range payload.Rules {
 		rules[i] = storage.CachedRule{
@@ -298,19 +364,66 @@ func (d *Daemon) handleConfigUpdate(msg ws.Message) {
 			Name:                  r.Name,
 			Content:               r.Content,
 			TargetLayer:           r.TargetLayer,
+			CategoryID:            r.CategoryID,
+			CategoryName:          r.CategoryName,
+			PriorityWeight:        r.PriorityWeight,
+			Overridable:           r.Overridable,
+			EffectiveStart:        r.EffectiveStart,
+			EffectiveEnd:          r.EffectiveEnd,
 			Triggers:              r.Triggers,
 			EnforcementMode:       r.EnforcementMode,
 			TemporaryTimeoutHours: r.TemporaryTimeoutHours,
 			Version:               payload.Version,
+			RuleVersion:           r.RuleVersion,
 		}
 	}
 
-	if err := d.store.SaveRules(rules, payload.Version); err != nil {
-		log.Printf("Failed to save rules: %v", err)
+	if payload.Categories != nil {
+		categories := make([]storage.CachedCategory, len(payload.Categories))
+		for i, c := range payload.Categories {
+			categories[i] = storage.CachedCategory{
+				ID:           c.ID,
+				Name:         c.Name,
+				IsSystem:     c.IsSystem,
+				DisplayOrder: c.DisplayOrder,
+			}
+		}
+		if err := d.store.SaveCategories(categories); err != nil {
+			log.Printf("Failed to save categories: %v", err)
+		}
+	}
+
+	var saveErr error
+	if payload.Delta {
+		saveErr = d.store.ApplyRuleChanges(rules, payload.RemovedRuleIDs, payload.Version)
+	} else {
+		saveErr = d.store.SaveRules(rules, payload.Version)
+	}
+	if saveErr != nil {
+		log.Printf("Failed to save rules: %v", saveErr)
 	} else {
 		log.Printf("Updated rules to version %d", payload.Version)
 		notify.ConfigUpdated(payload.Version)
 	}
+
+	if payload.Targets != nil {
+		d.applyOutputTargets(payload.Targets)
+	}
+
+	// Rewrite the managed files so they match the new rules
+	if saveErr == nil {
+		_ = d.SyncAllFiles()
+	}
+}
+
+// sendSyncRequest asks the server for the configuration changes since the
+// cached version. Like heartbeats it bypasses the outbound queue, since a
+// replayed request would only repeat a later one.
+func (d *Daemon) sendSyncRequest() {
+	msg, _ := ws.NewMessage(ws.TypeSyncRequest, ws.SyncRequestPayload{
+		SinceVersion: d.store.GetCachedVersion(),
+	})
+	_ = d.wsClient.Send(msg)
 }
 
 func (d *Daemon) handleAck(msg ws.Message) {
@@ -321,14 +434,43 @@ func (d *Daemon) handleAck(msg ws.Message) {
 	_ = d.store.DeleteMessage(payload.RefID)
 }
 
+// handleNack keeps a message the server failed to process queued for its
+// next retry, or dead-letters it when the server will never accept it
+func (d *Daemon) handleNack(msg ws.Message) {
+	var payload ws.NackPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return
+	}
+	if payload.Retry {
+		log.Printf("Server could not process message %s, will retry: %s", payload.RefID, payload.Reason)
+		return
+	}
+	log.Printf("Server rejected message %s, moving to dead letters: %s", payload.RefID, payload.Reason)
+	_ = d.store.MarkDeadLetter(payload.RefID)
+}
+
 func (d *Daemon) handleChangeApproved(msg ws.Message) {
 	var payload ws.ChangeApprovedPayload
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 		return
 	}
 	log.Printf("Change %s approved", payload.ChangeID)
-	_ = d.store.UpdateChangeStatus(payload.ChangeID, "approved")
-	notify.ChangeApproved(payload.ChangeID)
+
+	change, err := d.findPendingChange(payload.ChangeID, payload.FilePath, "")
+	if err != nil {
+		log.Printf("No local pending change for approved change %s: %v", payload.ChangeID, err)
+		notify.ChangeApproved(payload.ChangeID)
+		return
+	}
+
+	_ = d.store.UpdateChangeStatus(change.ID, "approved")
+	// The approved content becomes the new baseline for future diffs
+	if d.fileWatcher != nil {
+		if err := d.fileWatcher.Rebaseline(change.FilePath); err != nil {
+			log.Printf("Failed to rebaseline %s: %v", change.FilePath, err)
+		}
+	}
+	notify.ChangeApproved(change.FilePath)
 }
 
 func (d *Daemon) handleChangeRejected(msg ws.Message) {
@@ -336,17 +478,52 @@ func (d *Daemon) handleChangeRejected(msg ws.Message) {
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 		return
 	}
-	log.Printf("Change %s rejected", payload.ChangeID)
-	_ = d.store.UpdateChangeStatus(payload.ChangeID, "rejected")
-	notify.ChangeRejected(payload.ChangeID)
-	// TODO: Revert file to original content
+	log.Printf("Change %s rejected (reason: %s)", payload.ChangeID, payload.Reason)
+
+	change, err := d.findPendingChange(payload.ChangeID, payload.FilePath, payload.RevertToHash)
+	if err != nil {
+		log.Printf("No local pending change for rejected change %s: %v", payload.ChangeID, err)
+		return
+	}
+
+	if err := d.revertChange(change); err != nil {
+		log.Printf("Failed to revert %s: %v", change.FilePath, err)
+		_ = d.store.UpdateChangeStatus(change.ID, "rejected")
+		return
+	}
+
+	if payload.Reason == ws.RevertReasonExpired {
+		notify.ChangeReverted(change.FilePath)
+	} else {
+		notify.ChangeRejected(change.FilePath)
+	}
+
+	reply, _ := ws.NewMessage(ws.TypeRevertComplete, ws.RevertCompletePayload{ChangeID: payload.ChangeID})
+	if err := d.enqueue(reply); err != nil {
+		log.Printf("Failed to queue revert_complete for %s: %v", payload.ChangeID, err)
+	}
 }
 
 func (d *Daemon) setupFileWatcher() {
 	d.fileWatcher.OnChange(func(path, ruleID, originalHash, newHash, diff string) {
+		if ruleID == "" {
+			var ok bool
+			if ruleID, ok = d.changedRule(path); !ok {
+				// Content outside the managed section is the user's own
+				if err := d.fileWatcher.Rebaseline(path); err != nil {
+					log.Printf("Failed to rebaseline %s: %v", path, err)
+				}
+				return
+			}
+		}
+
 		log.Printf("Change detected in %s", path)
 		notify.ChangeBlocked(path)
 
+		if err := d.recordPendingChange(path, ruleID); err != nil {
+			log.Printf("Failed to record pending change for %s: %v", path, err)
+		}
+
 		// Send change_detected message to server
 		payload := ws.ChangeDetectedPayload{
 			RuleID:       ruleID,
@@ -356,60 +533,183 @@ func (d *Daemon) setupFileWatcher() {
 			Diff:         diff,
 		}
 		msg, _ := ws.NewMessage(ws.TypeChangeDetected, payload)
-		_ = d.wsClient.Send(msg)
+		if err := d.enqueue(msg); err != nil {
+			log.Printf("Failed to queue change_detected for %s: %v", path, err)
+		}
 	})
 
 	// Watch all projects from storage
 	projects, _ := d.store.GetProjects()
 	for _, p := range projects {
-		if err := d.fileWatcher.WatchProject(p.Path, ""); err != nil {
-			log.Printf("Failed to watch project %s: %v", p.Path, err)
+		for _, f := range d.registerProject(p.Path) {
+			if err := d.fileWatcher.WatchFile(f.Path, ""); err != nil {
+				log.Printf("Failed to watch %s: %v", f.Path, err)
+			}
 		}
 	}
 }
 
-// initManagedFiles sets up the three fixed-location CLAUDE.md files
+// initManagedFiles sets up the fixed-location files of the enabled targets.
+// Project files are added dynamically when watching directories.
 func (d *Daemon) initManagedFiles() {
-	// Enterprise file (system-wide)
-	d.managedFiles[EnterpriseFilePath] = ManagedFile{
-		Level: "enterprise",
-		Path:  EnterpriseFilePath,
-	}
-
-	// User file (~/.claude/CLAUDE.md)
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		userPath := filepath.Join(homeDir, ".claude", UserFileName)
-		d.managedFiles[userPath] = ManagedFile{
-			Level: "user",
-			Path:  userPath,
+	names, err := d.store.GetOutputTargets()
+	if err != nil {
+		log.Printf("Failed to load output targets, using defaults: %v", err)
+	}
+	d.targets = markdown.ResolveTargets(names)
+
+	for _, f := range ManagedFiles(d.targets, nil) {
+		d.managedFiles[f.Path] = f
+	}
+}
+
+// ManagedFiles returns the enterprise and user files of the targets followed
+// by the targets' files in each given project directory
+func ManagedFiles(targets []markdown.Target, projectPaths []string) []ManagedFile {
+	var files []ManagedFile
+
+	// Enterprise files (system-wide)
+	for _, t := range targets {
+		if t.EnterpriseFile != "" {
+			files = append(files, ManagedFile{Level: "enterprise", Path: t.EnterpriseFile, Target: t})
 		}
 	}
 
-	// Project files are added dynamically when watching directories
+	// User files (e.g. ~/.claude/CLAUDE.md)
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		for _, t := range targets {
+			if t.UserFile != "" {
+				path := filepath.Join(homeDir, filepath.FromSlash(t.UserFile))
+				files = append(files, ManagedFile{Level: "user", Path: path, Target: t})
+			}
+		}
+	}
+
+	for _, p := range projectPaths {
+		files = append(files, ProjectFiles(targets, p)...)
+	}
+	return files
 }
 
-// AddProjectDirectory registers a project directory for watching
+// ProjectFiles returns the targets' files in a project directory
+func ProjectFiles(targets []markdown.Target, projectPath string) []ManagedFile {
+	files := make([]ManagedFile, 0, len(targets))
+	for _, t := range targets {
+		files = append(files, ManagedFile{
+			Level:  "project",
+			Path:   t.ProjectFilePath(projectPath),
+			Target: t,
+		})
+	}
+	return files
+}
+
+// AddProjectDirectory registers a project directory, syncs its managed
+// sections and starts watching its files
 func (d *Daemon) AddProjectDirectory(projectPath string) error {
-	claudePath := filepath.Join(projectPath, ProjectFileName)
-	d.managedFiles[claudePath] = ManagedFile{
-		Level: "project",
-		Path:  claudePath,
+	files := d.registerProject(projectPath)
+
+	// Sync the files immediately
+	for _, f := range files {
+		if err := d.syncFile(f); err != nil {
+			d.unregisterProject(projectPath)
+			return err
+		}
+	}
+
+	if d.fileWatcher != nil {
+		for _, f := range files {
+			if err := d.fileWatcher.WatchFile(f.Path, ""); err != nil {
+				d.unwatchFiles(d.unregisterProject(projectPath))
+				return fmt.Errorf("failed to watch %s: %w", f.Path, err)
+			}
+		}
+	}
+
+	log.Printf("Watching project %s", projectPath)
+	go d.detectProjectContext(storage.WatchedProject{Path: projectPath})
+	return nil
+}
+
+// RemoveProjectDirectory stops watching a project directory.
+// The project's files are left on disk as-is.
+func (d *Daemon) RemoveProjectDirectory(projectPath string) {
+	d.unwatchFiles(d.unregisterProject(projectPath))
+	d.forgetProjectContext(projectPath)
+	log.Printf("Stopped watching project %s", projectPath)
+}
+
+// registerProject manages the enabled targets' files in a project directory
+// and returns them
+func (d *Daemon) registerProject(projectPath string) []ManagedFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files := ProjectFiles(d.targets, projectPath)
+	for _, f := range files {
+		d.managedFiles[f.Path] = f
+	}
+	for _, dir := range d.projectDirs {
+		if dir == projectPath {
+			return files
+		}
 	}
 	d.projectDirs = append(d.projectDirs, projectPath)
+	return files
+}
+
+// unregisterProject stops managing a project's files and returns them
+func (d *Daemon) unregisterProject(projectPath string) []ManagedFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var files []ManagedFile
+	for path, f := range d.managedFiles {
+		if root, ok := f.Target.ProjectRoot(path); ok && f.Level == "project" && root == projectPath {
+			files = append(files, f)
+			delete(d.managedFiles, path)
+		}
+	}
+	for i, dir := range d.projectDirs {
+		if dir == projectPath {
+			d.projectDirs = append(d.projectDirs[:i], d.projectDirs[i+1:]...)
+			break
+		}
+	}
+	return files
+}
+
+// unwatchFiles stops the file watcher from watching the files
+func (d *Daemon) unwatchFiles(files []ManagedFile) {
+	if d.fileWatcher == nil {
+		return
+	}
+	for _, f := range files {
+		d.fileWatcher.UnwatchFile(f.Path)
+	}
+}
+
+// snapshotManagedFiles returns a copy of the managed files safe to iterate
+func (d *Daemon) snapshotManagedFiles() map[string]ManagedFile {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	// Sync the file immediately
-	return d.syncFile("project", claudePath)
+	files := make(map[string]ManagedFile, len(d.managedFiles))
+	for path, file := range d.managedFiles {
+		files[path] = file
+	}
+	return files
 }
 
-// syncFile renders and writes the managed section for a specific level/path
-func (d *Daemon) syncFile(level string, path string) error {
-	rules, err := d.store.GetRulesByLayer(level)
+// syncFile renders and writes the managed section of a managed file
+func (d *Daemon) syncFile(file ManagedFile) error {
+	path := file.Path
+	managed, _, err := renderer.ManagedSectionForFile(d.store, file.Target, file.Level, path)
 	if err != nil {
-		return fmt.Errorf("failed to get rules for %s: %w", level, err)
+		return fmt.Errorf("failed to get rules for %s: %w", file.Level, err)
 	}
 
-	managed := d.renderer.RenderManagedSection(rules)
+	r := renderer.ForTarget(file.Target)
 
 	// Read existing content (if any)
 	existing, err := os.ReadFile(path)
@@ -418,7 +718,7 @@ func (d *Daemon) syncFile(level string, path string) error {
 	}
 
 	// Merge with existing content
-	merged := d.renderer.MergeWithFile(string(existing), managed)
+	merged := r.MergeWithFile(string(existing), managed)
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(path)
@@ -426,19 +726,24 @@ func (d *Daemon) syncFile(level string, path string) error {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	// Write the file
-	if err := os.WriteFile(path, []byte(merged), 0644); err != nil {
+	// Write the file, updating the watcher baseline so the sync isn't reported as a change
+	if d.fileWatcher != nil {
+		err = d.fileWatcher.WriteFile(path, []byte(merged))
+	} else {
+		err = watcher.WriteFileAtomic(path, []byte(merged))
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write %s: %w", path, err)
 	}
 
-	log.Printf("Synced %s CLAUDE.md at %s", level, path)
+	log.Printf("Synced %s %s file at %s", file.Level, file.Target.Name, path)
 	return nil
 }
 
-// SyncAllFiles syncs all managed CLAUDE.md files
+// SyncAllFiles syncs all managed files
 func (d *Daemon) SyncAllFiles() error {
-	for path, file := range d.managedFiles {
-		if err := d.syncFile(file.Level, path); err != nil {
+	for path, file := range d.snapshotManagedFiles() {
+		if err := d.syncFile(file); err != nil {
 			// Log but continue - enterprise path might not be writable
 			log.Printf("Failed to sync %s: %v", path, err)
 		}
@@ -446,28 +751,32 @@ func (d *Daemon) SyncAllFiles() error {
 	return nil
 }
 
-// CheckAndRestoreTamperedFiles checks if any managed sections were modified and restores them
-func (d *Daemon) CheckAndRestoreTamperedFiles() {
-	for path, file := range d.managedFiles {
-		rules, err := d.store.GetRulesByLayer(file.Level)
+// CheckAndRestoreTamperedFiles checks if any managed sections were modified,
+// restores them and returns the paths of the restored files
+func (d *Daemon) CheckAndRestoreTamperedFiles() []string {
+	restored := []string{}
+	for path, file := range d.snapshotManagedFiles() {
+		expected, _, err := renderer.ManagedSectionForFile(d.store, file.Target, file.Level, path)
 		if err != nil {
 			continue
 		}
 
-		expected := d.renderer.RenderManagedSection(rules)
+		r := renderer.ForTarget(file.Target)
 
 		content, err := os.ReadFile(path)
 		if err != nil {
 			continue
 		}
 
-		if d.renderer.DetectManagedSectionTampering(string(content), expected) {
+		if r.DetectManagedSectionTampering(string(content), expected) {
 			log.Printf("Tampering detected in %s, restoring...", path)
-			if err := d.syncFile(file.Level, path); err != nil {
+			if err := d.syncFile(file); err != nil {
 				log.Printf("Failed to restore %s: %v", path, err)
 			} else {
 				notify.ManagedSectionRestored(path)
+				restored = append(restored, path)
 			}
 		}
 	}
+	return restored
 }
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/drift"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+// driftReportInterval is how often the state of every managed file is reported
+const driftReportInterval = 5 * time.Minute
+
+// buildDriftReport checks every managed file against the cached rules
+func (d *Daemon) buildDriftReport() ws.DriftReportPayload {
+	files := d.snapshotManagedFiles()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	checker := drift.NewChecker(d.store)
+	report := ws.DriftReportPayload{
+		Files:         make([]ws.DriftFile, 0, len(paths)),
+		CachedVersion: d.store.GetCachedVersion(),
+	}
+	for _, path := range paths {
+		file := files[path]
+		result := checker.Check(file.Target, file.Level, path)
+		report.Files = append(report.Files, ws.DriftFile{
+			Path:         result.Path,
+			Level:        result.Level,
+			Status:       string(result.Status),
+			ExpectedHash: result.ExpectedHash,
+			ActualHash:   result.ActualHash,
+			RuleIDs:      result.RuleIDs,
+		})
+	}
+	return report
+}
+
+// sendDriftReport reports the current state of every managed file. Like
+// heartbeats, reports are snapshots superseded by the next one, so they
+// bypass the outbound queue.
+func (d *Daemon) sendDriftReport() {
+	if d.wsClient.State() != ws.StateConnected {
+		return
+	}
+	msg, err := ws.NewMessage(ws.TypeDriftReport, d.buildDriftReport())
+	if err != nil {
+		log.Printf("Failed to build drift report: %v", err)
+		return
+	}
+	_ = d.wsClient.Send(msg)
+}
+
+// runDriftReports sends a drift report every driftReportInterval until stop is closed
+func (d *Daemon) runDriftReports(stop <-chan struct{}) {
+	ticker := time.NewTicker(driftReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.sendDriftReport()
+		}
+	}
+}
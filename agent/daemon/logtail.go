@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"strings"
+	"sync"
+)
+
+// logTailSize is how many log lines the daemon keeps in memory
+const logTailSize = 200
+
+// logTail keeps the most recent log lines so they can be reported without a
+// log file. It is written to by the log package, one line per write.
+type logTail struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogTail(size int) *logTail {
+	return &logTail{lines: make([]string, size)}
+}
+
+func (t *logTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		t.lines[t.next] = line
+		t.next = (t.next + 1) % len(t.lines)
+		if t.next == 0 {
+			t.full = true
+		}
+	}
+	return len(p), nil
+}
+
+// Lines returns up to n of the most recent lines, oldest first
+func (t *logTail) Lines(n int) []string {
+	if t == nil {
+		return []string{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.next
+	if t.full {
+		count = len(t.lines)
+	}
+	if n > count {
+		n = count
+	}
+	result := make([]string, n)
+	for i := range result {
+		result[i] = t.lines[(t.next-n+i+len(t.lines))%len(t.lines)]
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+const (
+	// MaxDeliveryAttempts is how many times a message is sent without an ack
+	// before it is dead-lettered
+	MaxDeliveryAttempts = 8
+	// retryBaseDelay is the wait for an ack after the first delivery attempt
+	retryBaseDelay = 5 * time.Second
+	// retryMaxDelay caps the exponential backoff between attempts
+	retryMaxDelay = 5 * time.Minute
+	// drainInterval is how often unacknowledged messages are retried
+	drainInterval = 10 * time.Second
+)
+
+// enqueue persists an outbound message before attempting delivery. The
+// message stays queued until the server acks its ID, so anything produced
+// while offline or lost with a dropped connection is replayed.
+func (d *Daemon) enqueue(msg ws.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := d.store.EnqueueMessage(msg.ID, string(msg.Type), string(data)); err != nil {
+		return err
+	}
+	go d.drainQueue()
+	return nil
+}
+
+// drainQueue sends every due message in the order it was enqueued. Messages
+// sent but not yet acked are skipped until their retry time.
+func (d *Daemon) drainQueue() {
+	d.drainMu.Lock()
+	defer d.drainMu.Unlock()
+
+	if d.wsClient.State() != ws.StateConnected {
+		return
+	}
+
+	messages, err := d.store.GetPendingMessages()
+	if err != nil {
+		log.Printf("Failed to read outbound queue: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range messages {
+		if m.NextAttemptAt.After(now) {
+			continue
+		}
+
+		if m.Attempts >= MaxDeliveryAttempts {
+			log.Printf("Message %s (%s) unacknowledged after %d attempts, moving to dead letters", m.RefID, m.MsgType, m.Attempts)
+			_ = d.store.MarkDeadLetter(m.RefID)
+			continue
+		}
+
+		var msg ws.Message
+		if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+			log.Printf("Dropping unreadable queued message %s: %v", m.RefID, err)
+			_ = d.store.MarkDeadLetter(m.RefID)
+			continue
+		}
+
+		if err := d.wsClient.Send(msg); err != nil {
+			// Connection dropped or buffer full; later messages must wait
+			// so they aren't delivered ahead of this one
+			if !errors.Is(err, ws.ErrNotConnected) {
+				log.Printf("Failed to send queued message %s: %v", m.RefID, err)
+			}
+			return
+		}
+
+		if err := d.store.RecordAttempt(m.RefID, now.Add(retryDelay(m.Attempts+1))); err != nil {
+			log.Printf("Failed to record delivery attempt for %s: %v", m.RefID, err)
+		}
+	}
+}
+
+// replayQueue makes every pending message due and drains the queue.
+// Called on connect, when nothing is in flight on the new connection.
+func (d *Daemon) replayQueue() {
+	if err := d.store.ResetSchedule(); err != nil {
+		log.Printf("Failed to reset outbound queue: %v", err)
+	}
+	d.drainQueue()
+}
+
+// runQueueRetries periodically retries unacknowledged messages until stop is closed
+func (d *Daemon) runQueueRetries(stop <-chan struct{}) {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.drainQueue()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// retryDelay returns the backoff before retrying a message sent attempts times
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/kamilrybacki/edictflow/agent/notify"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+// capabilities lists the message shapes this agent understands
+var capabilities = []string{
+	ws.CapabilityDelta, ws.CapabilityRevert, ws.CapabilityTargets, ws.CapabilityCompression,
+}
+
+// sendHello tells the server which protocol version and capabilities the
+// agent has. It is sent before anything else on each connection.
+func (d *Daemon) sendHello() {
+	msg, _ := ws.NewMessage(ws.TypeHello, ws.HelloPayload{
+		ProtocolVersion: ws.ProtocolVersion,
+		AgentVersion:    Version,
+		Capabilities:    capabilities,
+	})
+	_ = d.wsClient.Send(msg)
+}
+
+func (d *Daemon) handleWelcome(msg ws.Message) {
+	var payload ws.WelcomePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Invalid welcome: %v", err)
+		return
+	}
+	log.Printf("Server speaks protocol %d with capabilities %v", payload.ProtocolVersion, payload.Capabilities)
+}
+
+// handleUpgradeRequired stops the daemon when the server no longer accepts
+// this version, keeping the reason for 'edictflow status'
+func (d *Daemon) handleUpgradeRequired(msg ws.Message) {
+	var payload ws.UpgradeRequiredPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("Invalid upgrade notice: %v", err)
+		return
+	}
+	log.Printf("Upgrade required: %s", payload.Reason)
+	if err := d.store.SaveUpgradeRequired(payload.Reason); err != nil {
+		log.Printf("Failed to record upgrade notice: %v", err)
+	}
+	notify.UpgradeRequired(payload.MinVersion)
+	d.requestShutdown()
+}
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/auth"
+	"github.com/kamilrybacki/edictflow/agent/notify"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+)
+
+// heartbeatInterval is how often the daemon reports its state. The server
+// marks agents stale when heartbeats stop.
+const heartbeatInterval = time.Minute
+
+// RegisterAgent asks the server for this machine's agent ID and stores it
+// with the login
+func RegisterAgent(store *storage.Storage, apiURL string) (storage.AuthInfo, error) {
+	info, err := store.GetAuth()
+	if err != nil {
+		return storage.AuthInfo{}, err
+	}
+	machineID, err := store.MachineID()
+	if err != nil {
+		return storage.AuthInfo{}, err
+	}
+
+	hostname, _ := os.Hostname()
+	agent, err := auth.NewRegisterClient(apiURL).Register(info.AccessToken, auth.RegisterRequest{
+		MachineID:    machineID,
+		Hostname:     hostname,
+		OS:           runtime.GOOS + "/" + runtime.GOARCH,
+		Version:      Version,
+		RefreshToken: info.RefreshToken,
+	})
+	if err != nil {
+		return storage.AuthInfo{}, err
+	}
+
+	info.AgentID = agent.ID
+	if agent.UserID != "" {
+		info.UserID = agent.UserID
+	}
+	if err := store.SaveAuth(info); err != nil {
+		return storage.AuthInfo{}, err
+	}
+	return info, nil
+}
+
+// resolveAgentID returns the registered agent ID, registering the machine if
+// the login predates the registry. Servers refuse agents without one.
+func (d *Daemon) resolveAgentID(info storage.AuthInfo) (string, error) {
+	if info.AgentID != "" {
+		return info.AgentID, nil
+	}
+
+	apiURL, _ := d.store.GetServerURL()
+	if apiURL == "" {
+		apiURL = d.serverURL
+	}
+	registered, err := RegisterAgent(d.store, apiURL)
+	if err != nil {
+		if errors.Is(err, auth.ErrAgentRevoked) {
+			notify.AgentRevoked()
+		}
+		return "", err
+	}
+	log.Printf("Registered as agent %s", registered.AgentID)
+	return registered.AgentID, nil
+}
+
+// runHeartbeats sends a heartbeat every heartbeatInterval until stop is closed
+func (d *Daemon) runHeartbeats(stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if d.wsClient.State() == ws.StateConnected {
+				d.sendHeartbeat()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handleAgentRevoked stops the daemon once an admin revoked this machine.
+// The server closes the connection and refuses new ones.
+func (d *Daemon) handleAgentRevoked(ws.Message) {
+	d.stopRevoked()
+}
+
+func (d *Daemon) stopRevoked() {
+	log.Printf("Agent %s was revoked by an administrator", d.agentID)
+	notify.AgentRevoked()
+	d.requestShutdown()
+}
@@ -3,6 +3,8 @@ package daemon
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 )
@@ -13,6 +15,13 @@ type StatusResponse struct {
 	CachedVersion int      `json:"cached_version"`
 	Projects      []string `json:"projects"`
 	PendingMsgs   int      `json:"pending_messages"`
+	DeadLetters   int      `json:"dead_letters"`
+}
+
+// CommandResponse is the daemon's reply to a command that can fail
+type CommandResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
 
 func (d *Daemon) startSocket() error {
@@ -70,6 +79,12 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 		d.handleStatusRequest(conn)
 	case "sync":
 		d.handleSyncRequest(conn)
+	case "watch":
+		d.handleWatchRequest(conn, request["path"])
+	case "unwatch":
+		d.handleUnwatchRequest(conn, request["path"])
+	default:
+		writeCommandResponse(conn, errors.New("unknown command: "+request["command"]))
 	}
 }
 
@@ -81,6 +96,7 @@ func (d *Daemon) handleStatusRequest(conn net.Conn) {
 	}
 
 	pending, _ := d.store.GetPendingMessages()
+	dead, _ := d.store.GetDeadLetters()
 
 	response := StatusResponse{
 		Running:       true,
@@ -88,6 +104,7 @@ func (d *Daemon) handleStatusRequest(conn net.Conn) {
 		CachedVersion: d.store.GetCachedVersion(),
 		Projects:      paths,
 		PendingMsgs:   len(pending),
+		DeadLetters:   len(dead),
 	}
 
 	data, _ := json.Marshal(response)
@@ -99,7 +116,65 @@ func (d *Daemon) handleSyncRequest(conn net.Conn) {
 	_, _ = conn.Write([]byte(`{"status":"sync_requested"}` + "\n"))
 }
 
+func (d *Daemon) handleWatchRequest(conn net.Conn, path string) {
+	if path == "" {
+		writeCommandResponse(conn, errors.New("path is required"))
+		return
+	}
+	// Syncing would create a missing project directory
+	info, err := os.Stat(path)
+	if err != nil {
+		writeCommandResponse(conn, fmt.Errorf("path not found: %s", path))
+		return
+	}
+	if !info.IsDir() {
+		writeCommandResponse(conn, fmt.Errorf("path is not a directory: %s", path))
+		return
+	}
+	writeCommandResponse(conn, d.AddProjectDirectory(path))
+}
+
+func (d *Daemon) handleUnwatchRequest(conn net.Conn, path string) {
+	if path == "" {
+		writeCommandResponse(conn, errors.New("path is required"))
+		return
+	}
+	d.RemoveProjectDirectory(path)
+	writeCommandResponse(conn, nil)
+}
+
+func writeCommandResponse(conn net.Conn, err error) {
+	response := CommandResponse{Status: "ok"}
+	if err != nil {
+		response = CommandResponse{Status: "error", Error: err.Error()}
+	}
+	data, _ := json.Marshal(response)
+	_, _ = conn.Write(append(data, '\n'))
+}
+
 func QueryDaemon(command string) ([]byte, error) {
+	return queryDaemon(map[string]string{"command": command})
+}
+
+// SendCommand sends a command with a path argument to the running daemon.
+// A failure reported by the daemon is returned as an error.
+func SendCommand(command, path string) error {
+	data, err := queryDaemon(map[string]string{"command": command, "path": path})
+	if err != nil {
+		return err
+	}
+
+	var response CommandResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return err
+	}
+	if response.Status != "ok" {
+		return errors.New(response.Error)
+	}
+	return nil
+}
+
+func queryDaemon(request map[string]string) ([]byte, error) {
 	socketPath, err := GetSocketPath()
 	if err != nil {
 		return nil, err
@@ -111,7 +186,6 @@ func QueryDaemon(command string) ([]byte, error) {
 	}
 	defer conn.Close()
 
-	request := map[string]string{"command": command}
 	data, _ := json.Marshal(request)
 	_, _ = conn.Write(append(data, '\n'))
 
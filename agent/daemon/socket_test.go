@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/watcher"
+	"github.com/kamilrybacki/edictflow/agent/ws"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+// newSocketDaemon returns a test daemon listening on its control socket
+func newSocketDaemon(t *testing.T) *Daemon {
+	t.Helper()
+	d := newTestDaemon(t)
+
+	w, err := watcher.NewWithPolling(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.fileWatcher = w
+
+	if err := d.startSocket(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.stopSocket)
+	return d
+}
+
+func TestSocket_WatchAndUnwatch(t *testing.T) {
+	d := newSocketDaemon(t)
+	project := t.TempDir()
+	path := markdown.ResolveTargets(nil)[0].ProjectFilePath(project)
+
+	if err := SendCommand("watch", project); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if _, ok := d.fileWatcher.Snapshot(path); !ok {
+		t.Errorf("expected %s to be watched", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the managed file to be written: %v", err)
+	}
+	// Context detection runs after the reply and queues its result
+	waitFor(t, "context_detected", func() bool {
+		queued, _ := d.store.GetPendingMessages()
+		return len(queued) == 1 && strings.Contains(queued[0].Payload, string(ws.TypeContextDetected))
+	})
+
+	if err := SendCommand("unwatch", project); err != nil {
+		t.Fatalf("unwatch: %v", err)
+	}
+	if _, ok := d.fileWatcher.Snapshot(path); ok {
+		t.Errorf("expected %s to be unwatched", path)
+	}
+	if len(d.snapshotManagedFiles()) != 0 {
+		t.Errorf("expected no managed files, got %+v", d.snapshotManagedFiles())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the managed file to be left on disk: %v", err)
+	}
+}
+
+func TestSocket_WatchUnknownPath(t *testing.T) {
+	d := newSocketDaemon(t)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	if err := SendCommand("watch", missing); err == nil || !strings.HasPrefix(err.Error(), "path not found") {
+		t.Errorf("expected watching a missing directory to fail, got %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("expected the missing directory not to be created")
+	}
+	if len(d.snapshotManagedFiles()) != 0 {
+		t.Errorf("expected no managed files, got %+v", d.snapshotManagedFiles())
+	}
+
+	// Unwatching a project that is not watched is a no-op
+	if err := SendCommand("unwatch", missing); err != nil {
+		t.Errorf("expected unwatching an unknown project to succeed, got %v", err)
+	}
+}
+
+func TestSocket_RejectsBadRequests(t *testing.T) {
+	newSocketDaemon(t)
+
+	for _, tc := range []struct {
+		command, path, want string
+	}{
+		{"watch", "", "path is required"},
+		{"unwatch", "", "path is required"},
+		{"frobnicate", "", "unknown command: frobnicate"},
+	} {
+		err := SendCommand(tc.command, tc.path)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s %q: expected %q, got %v", tc.command, tc.path, tc.want, err)
+		}
+	}
+}
+
+func TestSocket_MalformedRequestIsDropped(t *testing.T) {
+	newSocketDaemon(t)
+	socketPath, err := GetSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("watch /tmp\n")); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+		t.Errorf("expected the connection to be closed without a reply, got %q", reply)
+	}
+
+	// The daemon keeps serving later requests
+	data, err := QueryDaemon("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status StatusResponse
+	if err := json.Unmarshal(data, &status); err != nil || !status.Running {
+		t.Errorf("expected a running status, got %s (%v)", data, err)
+	}
+}
@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"log"
+
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+// applyOutputTargets switches to the output targets enabled for the team.
+// Files of newly enabled targets are synced and watched; files of disabled
+// targets are no longer managed and are left on disk as-is.
+func (d *Daemon) applyOutputTargets(names []string) {
+	if err := d.store.SaveOutputTargets(names); err != nil {
+		log.Printf("Failed to save output targets: %v", err)
+	}
+	targets := markdown.ResolveTargets(names)
+
+	d.mu.Lock()
+	if sameTargets(d.targets, targets) {
+		d.mu.Unlock()
+		return
+	}
+	previous := d.managedFiles
+	d.targets = targets
+	d.managedFiles = make(map[string]ManagedFile, len(previous))
+	for _, f := range ManagedFiles(targets, d.projectDirs) {
+		d.managedFiles[f.Path] = f
+	}
+	current := make(map[string]ManagedFile, len(d.managedFiles))
+	for path, f := range d.managedFiles {
+		current[path] = f
+	}
+	d.mu.Unlock()
+
+	var removed []ManagedFile
+	for path, f := range previous {
+		if _, ok := current[path]; !ok {
+			removed = append(removed, f)
+		}
+	}
+	d.unwatchFiles(removed)
+
+	for path, f := range current {
+		if _, ok := previous[path]; ok {
+			continue
+		}
+		if err := d.syncFile(f); err != nil {
+			log.Printf("Failed to sync %s: %v", path, err)
+			continue
+		}
+		if f.Level == "project" && d.fileWatcher != nil {
+			if err := d.fileWatcher.WatchFile(path, ""); err != nil {
+				log.Printf("Failed to watch %s: %v", path, err)
+			}
+		}
+	}
+	log.Printf("Output targets set to %v", names)
+}
+
+func sameTargets(a, b []markdown.Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/agent/auth"
+	"github.com/kamilrybacki/edictflow/agent/notify"
+)
+
+const (
+	// tokenRefreshMargin is how long before expiry the access token is refreshed
+	tokenRefreshMargin = 10 * time.Minute
+	// tokenCheckInterval is how often the access token expiry is checked
+	tokenCheckInterval = time.Minute
+)
+
+var errNoRefreshToken = errors.New("no refresh token stored; log in again")
+
+// refreshAccessToken exchanges the stored refresh token for a new token pair,
+// persists it and hands the access token to the WebSocket client. The open
+// connection stays authenticated; the new token is used on the next dial.
+func (d *Daemon) refreshAccessToken() error {
+	d.authMu.Lock()
+	defer d.authMu.Unlock()
+
+	info, err := d.store.GetAuth()
+	if err != nil {
+		return err
+	}
+	if info.RefreshToken == "" {
+		return errNoRefreshToken
+	}
+
+	apiURL, _ := d.store.GetServerURL()
+	if apiURL == "" {
+		apiURL = d.serverURL
+	}
+
+	token, err := auth.NewRefreshClient(apiURL).Refresh(info.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshRejected) {
+			// The token is dead (or was reused and its family revoked);
+			// forget it so we stop retrying and ask the user to log in
+			info.RefreshToken = ""
+			_ = d.store.SaveAuth(info)
+			notify.LoginRequired()
+		}
+		return err
+	}
+
+	info.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		info.RefreshToken = token.RefreshToken
+	}
+	info.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if err := d.store.SaveAuth(info); err != nil {
+		return err
+	}
+
+	d.wsClient.SetToken(token.AccessToken)
+	log.Printf("Access token refreshed, expires at %s", info.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// runTokenRefresh refreshes the access token shortly before it expires until stop is closed
+func (d *Daemon) runTokenRefresh(stop <-chan struct{}) {
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := d.store.GetAuth()
+			if err != nil || info.RefreshToken == "" {
+				continue
+			}
+			if time.Until(info.ExpiresAt) > tokenRefreshMargin {
+				continue
+			}
+			if err := d.refreshAccessToken(); err != nil {
+				log.Printf("Failed to refresh access token: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
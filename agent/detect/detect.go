@@ -0,0 +1,166 @@
+// agent/detect/detect.go
+package detect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result holds the contexts (languages and platforms) and tags (frameworks
+// and project traits) detected in a project, and the git repository and
+// branch it is checked out from
+type Result struct {
+	Contexts   []string `json:"contexts"`
+	Tags       []string `json:"tags"`
+	Repository string   `json:"repository,omitempty"`
+	Branch     string   `json:"branch,omitempty"`
+}
+
+// Equal reports whether two results contain the same contexts, tags,
+// repository and branch
+func (r Result) Equal(other Result) bool {
+	return equalSets(r.Contexts, other.Contexts) && equalSets(r.Tags, other.Tags) &&
+		r.Repository == other.Repository && r.Branch == other.Branch
+}
+
+// Detector derives contexts and tags from the files of a project
+type Detector interface {
+	// Markers returns glob patterns, relative to the project root, of the
+	// files the detector inspects. Changes to these files trigger re-detection.
+	Markers() []string
+	// Detect inspects the project rooted at project
+	Detect(project fs.FS) (Result, error)
+}
+
+// Registry runs a set of detectors against a project and merges their results
+type Registry struct {
+	mu        sync.RWMutex
+	detectors []Detector
+}
+
+func NewRegistry(detectors ...Detector) *Registry {
+	return &Registry{detectors: detectors}
+}
+
+// Default returns a registry with the built-in detectors
+func Default() *Registry {
+	return NewRegistry(builtinDetectors()...)
+}
+
+// Register adds a detector to the registry
+func (r *Registry) Register(d Detector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append(r.detectors, d)
+}
+
+func (r *Registry) snapshot() []Detector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Detector(nil), r.detectors...)
+}
+
+// Detect runs every detector against the project at root. Contexts and tags
+// are lowercased, deduplicated and sorted. A failing detector does not stop
+// the others; the first error is returned alongside the merged result.
+func (r *Registry) Detect(root string) (Result, error) {
+	if info, err := os.Stat(root); err != nil {
+		return Result{}, err
+	} else if !info.IsDir() {
+		return Result{}, fmt.Errorf("%s is not a directory", root)
+	}
+
+	project := os.DirFS(root)
+	contexts := make(map[string]struct{})
+	tags := make(map[string]struct{})
+
+	var firstErr error
+	for _, d := range r.snapshot() {
+		result, err := d.Detect(project)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		addAll(contexts, result.Contexts)
+		addAll(tags, result.Tags)
+	}
+
+	repository, branch := Repository(root)
+	return Result{Contexts: sortedKeys(contexts), Tags: sortedKeys(tags), Repository: repository, Branch: branch}, firstErr
+}
+
+// Fingerprint summarizes the size and modification time of every marker
+// file in the project and of the git HEAD and config. It changes whenever a
+// file relevant to detection is created, modified or removed, so detection
+// only reruns when needed.
+func (r *Registry) Fingerprint(root string) string {
+	project := os.DirFS(root)
+	seen := make(map[string]struct{})
+	var entries []string
+
+	for _, d := range r.snapshot() {
+		for _, pattern := range d.Markers() {
+			matches, _ := fs.Glob(project, pattern)
+			for _, name := range matches {
+				if _, ok := seen[name]; ok {
+					continue
+				}
+				seen[name] = struct{}{}
+				info, err := fs.Stat(project, name)
+				if err != nil {
+					continue
+				}
+				entries = append(entries, fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano()))
+			}
+		}
+	}
+
+	for _, name := range gitFiles(root) {
+		if info, err := os.Stat(name); err == nil {
+			entries = append(entries, fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func addAll(set map[string]struct{}, values []string) {
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			set[v] = struct{}{}
+		}
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]struct{}, len(a))
+	addAll(set, a)
+	for _, v := range b {
+		if _, ok := set[strings.ToLower(v)]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package detect
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDefault_DetectsLanguagesAndFrameworks(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":           "module example.com/app\n\nrequire github.com/go-chi/chi/v5 v5.0.0\n",
+		"web/package.json": `{"dependencies":{"react":"^18.0.0","next":"14.0.0"},"devDependencies":{"typescript":"^5"}}`,
+		"Dockerfile":       "FROM golang:1.22\n",
+		"infra/main.tf":    "provider \"aws\" {}\n",
+	})
+
+	result, err := Default().Detect(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantContexts := []string{"docker", "go", "node", "terraform", "typescript"}
+	if !reflect.DeepEqual(result.Contexts, wantContexts) {
+		t.Errorf("expected contexts %v, got %v", wantContexts, result.Contexts)
+	}
+	wantTags := []string{"chi", "nextjs", "react"}
+	if !reflect.DeepEqual(result.Tags, wantTags) {
+		t.Errorf("expected tags %v, got %v", wantTags, result.Tags)
+	}
+}
+
+func TestDefault_DetectsPythonPackagesAsWholeWords(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"pyproject.toml": "[project]\ndependencies = [\n  \"fastapi>=0.100\",\n  \"flask-cors\",\n]\n\n[tool.pytest.ini_options]\n",
+	})
+
+	result, err := Default().Detect(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Contexts, []string{"python"}) {
+		t.Errorf("expected python context, got %v", result.Contexts)
+	}
+	if !reflect.DeepEqual(result.Tags, []string{"fastapi"}) {
+		t.Errorf("expected only fastapi tag, got %v", result.Tags)
+	}
+}
+
+func TestDefault_DetectsMonorepo(t *testing.T) {
+	tests := map[string]map[string]string{
+		"workspace tool":  {"pnpm-workspace.yaml": "packages:\n  - packages/*\n"},
+		"npm workspaces":  {"package.json": `{"workspaces":["packages/*"]}`},
+		"cargo workspace": {"Cargo.toml": "[workspace]\nmembers = [\"a\", \"b\"]\n"},
+		"several packages": {
+			"services/api/go.mod":   "module api\n",
+			"apps/web/package.json": `{}`,
+		},
+	}
+
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := Default().Detect(writeProject(t, files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsString(result.Tags, TagMonorepo) {
+				t.Errorf("expected monorepo tag, got %v", result.Tags)
+			}
+		})
+	}
+
+	single, err := Default().Detect(writeProject(t, map[string]string{"go.mod": "module app\n"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsString(single.Tags, TagMonorepo) {
+		t.Error("expected single-module project not to be tagged monorepo")
+	}
+}
+
+type staticDetector struct {
+	result Result
+}
+
+func (d staticDetector) Markers() []string                    { return []string{"custom.yaml"} }
+func (d staticDetector) Detect(project fs.FS) (Result, error) { return d.result, nil }
+
+func TestRegistry_RegisterMergesCustomDetectors(t *testing.T) {
+	registry := NewRegistry(&FileDetector{Files: []string{"go.mod"}, Contexts: []string{"go"}})
+	registry.Register(staticDetector{Result{Contexts: []string{"Go", "internal"}, Tags: []string{"Platform"}}})
+
+	result, err := registry.Detect(writeProject(t, map[string]string{"go.mod": "module app\n"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Contexts, []string{"go", "internal"}) {
+		t.Errorf("expected merged, lowercased contexts, got %v", result.Contexts)
+	}
+	if !reflect.DeepEqual(result.Tags, []string{"platform"}) {
+		t.Errorf("expected custom tag, got %v", result.Tags)
+	}
+}
+
+func TestRegistry_FingerprintTracksMarkerFiles(t *testing.T) {
+	root := writeProject(t, map[string]string{"go.mod": "module app\n", "README.md": "hello\n"})
+	registry := Default()
+
+	before := registry.Fingerprint(root)
+	if err := os.WriteFile(filepath.Join(root, "README.md"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if after := registry.Fingerprint(root); after != before {
+		t.Error("expected unrelated file not to change the fingerprint")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if after := registry.Fingerprint(root); after == before {
+		t.Error("expected new marker file to change the fingerprint")
+	}
+}
+
+func TestDefault_DetectsRepositoryAndBranch(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":      "module app\n",
+		".git/HEAD":   "ref: refs/heads/feature/billing\n",
+		".git/config": "[core]\n\tbare = false\n[remote \"upstream\"]\n\turl = https://github.com/acme/fork.git\n[remote \"origin\"]\n\turl = git@github.com:Acme/payments-api.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n",
+	})
+	registry := Default()
+
+	result, err := registry.Detect(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Repository != "github.com/acme/payments-api" || result.Branch != "feature/billing" {
+		t.Errorf("expected origin and branch, got %q on %q", result.Repository, result.Branch)
+	}
+
+	before := registry.Fingerprint(root)
+	if err := os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("3f2a9c1e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if registry.Fingerprint(root) == before {
+		t.Error("expected a branch switch to change the fingerprint")
+	}
+	if _, branch := Repository(root); branch != "" {
+		t.Errorf("expected no branch for a detached HEAD, got %q", branch)
+	}
+}
+
+func TestRepository_Worktree(t *testing.T) {
+	primary := writeProject(t, map[string]string{
+		".git/HEAD":                   "ref: refs/heads/main\n",
+		".git/config":                 "[remote \"origin\"]\n\turl = https://gitlab.com/acme/platform/billing\n",
+		".git/worktrees/wt/HEAD":      "ref: refs/heads/hotfix\n",
+		".git/worktrees/wt/commondir": "../..\n",
+	})
+	worktree := writeProject(t, map[string]string{
+		".git": "gitdir: " + filepath.Join(primary, ".git", "worktrees", "wt") + "\n",
+	})
+
+	repository, branch := Repository(worktree)
+	if repository != "gitlab.com/acme/platform/billing" || branch != "hotfix" {
+		t.Errorf("expected the main checkout's remote and the worktree's branch, got %q on %q", repository, branch)
+	}
+	if repository, _ := Repository(t.TempDir()); repository != "" {
+		t.Errorf("expected no repository outside a checkout, got %q", repository)
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
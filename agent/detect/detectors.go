@@ -0,0 +1,319 @@
+// agent/detect/detectors.go
+package detect
+
+import (
+	"encoding/json"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// Tag added to projects that contain several packages or workspaces
+const TagMonorepo = "monorepo"
+
+// subprojectDirs are the directory globs searched for nested manifests, so
+// languages used only inside a monorepo's packages are still detected
+var subprojectDirs = []string{"*", "packages/*", "apps/*", "services/*", "libs/*"}
+
+func builtinDetectors() []Detector {
+	return []Detector{
+		goDetector{},
+		nodeDetector{},
+		pythonDetector{},
+		rustDetector{},
+		&FileDetector{
+			Files:    []string{"pom.xml", "build.gradle", "build.gradle.kts"},
+			Contexts: []string{"java"},
+		},
+		&FileDetector{
+			Files:    []string{"Dockerfile", "*.Dockerfile", "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"},
+			Contexts: []string{"docker"},
+		},
+		&FileDetector{
+			Files:    []string{"*.tf", "*/*.tf", "terraform/*/*.tf"},
+			Contexts: []string{"terraform"},
+		},
+		monorepoDetector{},
+	}
+}
+
+// manifestPatterns returns the globs for a manifest at the project root and
+// in the usual subproject directories
+func manifestPatterns(name string) []string {
+	patterns := []string{name}
+	for _, dir := range subprojectDirs {
+		patterns = append(patterns, dir+"/"+name)
+	}
+	return patterns
+}
+
+// readMatches returns the contents of every file matching the patterns
+func readMatches(project fs.FS, patterns []string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(project, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if _, ok := files[name]; ok {
+				continue
+			}
+			data, err := fs.ReadFile(project, name)
+			if err != nil {
+				continue
+			}
+			files[name] = data
+		}
+	}
+	return files, nil
+}
+
+// FileDetector reports fixed contexts and tags when any of its files exist.
+// It covers ecosystems recognizable from file names alone.
+type FileDetector struct {
+	Files    []string
+	Contexts []string
+	Tags     []string
+}
+
+func (d *FileDetector) Markers() []string {
+	return d.Files
+}
+
+func (d *FileDetector) Detect(project fs.FS) (Result, error) {
+	for _, pattern := range d.Files {
+		matches, err := fs.Glob(project, pattern)
+		if err != nil {
+			return Result{}, err
+		}
+		if len(matches) > 0 {
+			return Result{Contexts: d.Contexts, Tags: d.Tags}, nil
+		}
+	}
+	return Result{}, nil
+}
+
+// goModuleTags maps Go module path prefixes to tags
+var goModuleTags = map[string]string{
+	"github.com/gin-gonic/gin":       "gin",
+	"github.com/labstack/echo":       "echo",
+	"github.com/go-chi/chi":          "chi",
+	"github.com/gofiber/fiber":       "fiber",
+	"github.com/spf13/cobra":         "cli",
+	"google.golang.org/grpc":         "grpc",
+	"k8s.io/client-go":               "kubernetes",
+	"sigs.k8s.io/controller-runtime": "kubernetes",
+}
+
+type goDetector struct{}
+
+func (goDetector) Markers() []string {
+	return manifestPatterns("go.mod")
+}
+
+func (goDetector) Detect(project fs.FS) (Result, error) {
+	files, err := readMatches(project, manifestPatterns("go.mod"))
+	if err != nil || len(files) == 0 {
+		return Result{}, err
+	}
+
+	result := Result{Contexts: []string{"go"}}
+	for _, data := range files {
+		content := string(data)
+		for module, tag := range goModuleTags {
+			if strings.Contains(content, module) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	return result, nil
+}
+
+// nodePackageTags maps npm package names to tags
+var nodePackageTags = map[string]string{
+	"react":         "react",
+	"next":          "nextjs",
+	"vue":           "vue",
+	"nuxt":          "nuxt",
+	"@angular/core": "angular",
+	"svelte":        "svelte",
+	"express":       "express",
+	"@nestjs/core":  "nestjs",
+	"electron":      "electron",
+}
+
+type nodeDetector struct{}
+
+func (nodeDetector) Markers() []string {
+	return append(manifestPatterns("package.json"), "tsconfig.json")
+}
+
+func (nodeDetector) Detect(project fs.FS) (Result, error) {
+	files, err := readMatches(project, manifestPatterns("package.json"))
+	if err != nil || len(files) == 0 {
+		return Result{}, err
+	}
+
+	result := Result{Contexts: []string{"node"}}
+	if _, err := fs.Stat(project, "tsconfig.json"); err == nil {
+		result.Contexts = append(result.Contexts, "typescript")
+	}
+
+	for name, data := range files {
+		var pkg struct {
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+			Workspaces      json.RawMessage   `json:"workspaces"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			continue
+		}
+		if name == "package.json" && len(pkg.Workspaces) > 0 {
+			result.Tags = append(result.Tags, TagMonorepo)
+		}
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+			if _, ok := deps["typescript"]; ok {
+				result.Contexts = append(result.Contexts, "typescript")
+			}
+			for dep, tag := range nodePackageTags {
+				if _, ok := deps[dep]; ok {
+					result.Tags = append(result.Tags, tag)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// pythonPackageTags lists Python packages reported as tags under their own name
+var pythonPackageTags = []string{"django", "flask", "fastapi", "pytest", "pandas", "numpy", "torch", "tensorflow"}
+
+var pythonManifests = []string{"pyproject.toml", "requirements.txt", "setup.py", "setup.cfg", "Pipfile"}
+
+type pythonDetector struct{}
+
+func (pythonDetector) patterns() []string {
+	var patterns []string
+	for _, name := range pythonManifests {
+		patterns = append(patterns, manifestPatterns(name)...)
+	}
+	return patterns
+}
+
+func (d pythonDetector) Markers() []string {
+	return d.patterns()
+}
+
+func (d pythonDetector) Detect(project fs.FS) (Result, error) {
+	files, err := readMatches(project, d.patterns())
+	if err != nil || len(files) == 0 {
+		return Result{}, err
+	}
+
+	result := Result{Contexts: []string{"python"}}
+	for _, data := range files {
+		content := strings.ToLower(string(data))
+		for _, pkg := range pythonPackageTags {
+			if mentionsPackage(content, pkg) {
+				result.Tags = append(result.Tags, pkg)
+			}
+		}
+	}
+	return result, nil
+}
+
+// mentionsPackage reports whether a dependency manifest names the package as
+// a whole word, so e.g. "flask" does not match "flask-cors" alone
+func mentionsPackage(content, pkg string) bool {
+	re := regexp.MustCompile(`(^|[\s"'=\[,])` + regexp.QuoteMeta(pkg) + `($|[\s"'<>=~!\[,;])`)
+	for _, line := range strings.Split(content, "\n") {
+		if re.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// rustCrateTags maps crate names to tags
+var rustCrateTags = map[string]string{
+	"tokio":     "tokio",
+	"actix-web": "actix",
+	"axum":      "axum",
+	"rocket":    "rocket",
+	"bevy":      "bevy",
+}
+
+type rustDetector struct{}
+
+func (rustDetector) Markers() []string {
+	return manifestPatterns("Cargo.toml")
+}
+
+func (rustDetector) Detect(project fs.FS) (Result, error) {
+	files, err := readMatches(project, manifestPatterns("Cargo.toml"))
+	if err != nil || len(files) == 0 {
+		return Result{}, err
+	}
+
+	result := Result{Contexts: []string{"rust"}}
+	for name, data := range files {
+		content := string(data)
+		if name == "Cargo.toml" && strings.Contains(content, "[workspace]") {
+			result.Tags = append(result.Tags, TagMonorepo)
+		}
+		for crate, tag := range rustCrateTags {
+			if mentionsPackage(content, crate) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	return result, nil
+}
+
+// workspaceFiles mark a monorepo managed by a workspace tool
+var workspaceFiles = []string{"go.work", "pnpm-workspace.yaml", "lerna.json", "nx.json", "turbo.json", "rush.json"}
+
+// subprojectManifests are the manifests that make a directory a package
+var subprojectManifests = []string{"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "pom.xml"}
+
+// monorepoDetector tags projects that use a workspace tool or contain
+// several packages in subdirectories
+type monorepoDetector struct{}
+
+func (monorepoDetector) subprojectPatterns() []string {
+	var patterns []string
+	for _, dir := range subprojectDirs {
+		for _, name := range subprojectManifests {
+			patterns = append(patterns, dir+"/"+name)
+		}
+	}
+	return patterns
+}
+
+func (d monorepoDetector) Markers() []string {
+	return append(append([]string{}, workspaceFiles...), d.subprojectPatterns()...)
+}
+
+func (d monorepoDetector) Detect(project fs.FS) (Result, error) {
+	for _, name := range workspaceFiles {
+		if _, err := fs.Stat(project, name); err == nil {
+			return Result{Tags: []string{TagMonorepo}}, nil
+		}
+	}
+
+	packages := make(map[string]struct{})
+	for _, pattern := range d.subprojectPatterns() {
+		matches, err := fs.Glob(project, pattern)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, name := range matches {
+			packages[name[:strings.LastIndex(name, "/")]] = struct{}{}
+		}
+	}
+	if len(packages) >= 2 {
+		return Result{Tags: []string{TagMonorepo}}, nil
+	}
+	return Result{}, nil
+}
@@ -0,0 +1,123 @@
+// agent/detect/git.go
+package detect
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kamilrybacki/edictflow/pkg/triggers"
+)
+
+// Repository returns the git remote of the checkout containing root, as
+// host/owner/name, and its current branch. The origin remote is preferred,
+// otherwise the first one configured. Both are empty outside a git checkout,
+// and the branch is empty when HEAD is detached.
+func Repository(root string) (repository, branch string) {
+	dir, common, ok := gitDirs(root)
+	if !ok {
+		return "", ""
+	}
+	return triggers.NormalizeRemote(remoteURL(filepath.Join(common, "config"))), headBranch(filepath.Join(dir, "HEAD"))
+}
+
+// gitFiles returns the files the repository identity is read from, so
+// switching branches or remotes triggers re-detection
+func gitFiles(root string) []string {
+	dir, common, ok := gitDirs(root)
+	if !ok {
+		return nil
+	}
+	return []string{filepath.Join(dir, "HEAD"), filepath.Join(common, "config")}
+}
+
+// gitDirs finds the git directory of the checkout containing root, looking
+// in root and its parents. Worktrees and submodules have a .git file pointing
+// at their git directory, and worktrees share the config of the main one.
+func gitDirs(root string) (dir, common string, ok bool) {
+	for current := filepath.Clean(root); ; current = filepath.Dir(current) {
+		dotGit := filepath.Join(current, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return dotGit, dotGit, true
+			}
+			dir = readGitFile(dotGit, current)
+			if dir == "" {
+				return "", "", false
+			}
+			return dir, readGitFile(filepath.Join(dir, "commondir"), dir), true
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return "", "", false
+		}
+	}
+}
+
+// readGitFile resolves the directory named by a .git or commondir file,
+// relative to base. A commondir file that does not exist means the
+// directory is its own common directory.
+func readGitFile(path, base string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if filepath.Base(path) == "commondir" {
+			return base
+		}
+		return ""
+	}
+	target := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+	if target == "" {
+		return ""
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base, target)
+	}
+	return filepath.Clean(target)
+}
+
+// remoteURL reads the URL of the origin remote, or of the first remote,
+// from a git config file
+func remoteURL(configPath string) string {
+	f, err := os.Open(configPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var section, first string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		if !strings.HasPrefix(section, "remote ") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if section == `remote "origin"` {
+			return value
+		}
+		if first == "" {
+			first = value
+		}
+	}
+	return first
+}
+
+func headBranch(headPath string) string {
+	data, err := os.ReadFile(headPath)
+	if err != nil {
+		return ""
+	}
+	branch, found := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !found {
+		return ""
+	}
+	return branch
+}
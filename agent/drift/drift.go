@@ -0,0 +1,103 @@
+// agent/drift/drift.go
+package drift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	"github.com/kamilrybacki/edictflow/agent/renderer"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+// Status is the outcome of checking one managed file
+type Status string
+
+const (
+	StatusOK             Status = "ok"
+	StatusMissingFile    Status = "missing_file"
+	StatusMissingMarkers Status = Status(markdown.SectionMissing)
+	StatusStale          Status = Status(markdown.SectionStale)
+	StatusTampered       Status = Status(markdown.SectionTampered)
+	StatusError          Status = "error"
+)
+
+// Result describes how a managed file on disk compares to the rules it should contain
+type Result struct {
+	Path         string   `json:"path"`
+	Target       string   `json:"target"`
+	Level        string   `json:"level"`
+	Status       Status   `json:"status"`
+	ExpectedHash string   `json:"expected_hash"`
+	ActualHash   string   `json:"actual_hash,omitempty"`
+	RuleIDs      []string `json:"rule_ids"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Drifted reports whether the file does not match its expected content
+func (r Result) Drifted() bool {
+	return r.Status != StatusOK
+}
+
+// Checker compares managed files against the managed section rendered from cached rules
+type Checker struct {
+	rules renderer.RuleStore
+}
+
+func NewChecker(rules renderer.RuleStore) *Checker {
+	return &Checker{rules: rules}
+}
+
+// Check renders the expected managed section for a target's file and compares it to disk
+func (c *Checker) Check(target markdown.Target, level, path string) Result {
+	result := Result{
+		Path:    path,
+		Target:  target.Name,
+		Level:   level,
+		RuleIDs: []string{},
+	}
+	r := renderer.ForTarget(target)
+
+	expected, rules, err := renderer.ManagedSectionForFile(c.rules, target, level, path)
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		return result
+	}
+	for _, rule := range rules {
+		if renderer.IsEffective(rule) {
+			result.RuleIDs = append(result.RuleIDs, rule.ID)
+		}
+	}
+
+	result.ExpectedHash = Hash(expected)
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		result.Status = StatusOK
+		if expected != "" {
+			result.Status = StatusMissingFile
+		}
+		return result
+	}
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	if section, ok := r.ExtractManagedSection(string(content)); ok {
+		result.ActualHash = Hash(section)
+	}
+	result.Status = Status(r.CheckManagedSection(string(content), expected))
+	return result
+}
+
+// Hash returns the hex SHA-256 of a managed section, or "" for an empty one
+func Hash(section string) string {
+	if section == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(section))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/agent/renderer"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+var claude, _ = markdown.LookupTarget(markdown.TargetClaude)
+
+type fakeRules map[string][]storage.CachedRule
+
+func (f fakeRules) GetRulesByLayer(layer string) ([]storage.CachedRule, error) {
+	return f[layer], nil
+}
+
+func (f fakeRules) GetCategories() ([]storage.CachedCategory, error) {
+	return nil, nil
+}
+
+func (f fakeRules) GetProjects() ([]storage.WatchedProject, error) {
+	return nil, nil
+}
+
+func TestChecker_Check(t *testing.T) {
+	r := renderer.New()
+	rules := fakeRules{
+		"project": {{ID: "rule-1", Name: "Style", Content: "Use tabs", TargetLayer: "project"}},
+	}
+	expected := r.RenderManagedSection(rules["project"])
+	stale := r.RenderManagedSection([]storage.CachedRule{{ID: "rule-1", Name: "Style", Content: "Use spaces", TargetLayer: "project"}})
+
+	tests := []struct {
+		name    string
+		content *string
+		want    Status
+	}{
+		{"matching file", strPtr("# Notes\n\n" + expected), StatusOK},
+		{"missing file", nil, StatusMissingFile},
+		{"no managed section", strPtr("# Notes\n"), StatusMissingMarkers},
+		{"stale section", strPtr(stale), StatusStale},
+		{"tampered section", strPtr(strings.Replace(expected, "Use tabs", "Use nothing", 1)), StatusTampered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "CLAUDE.md")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result := NewChecker(rules).Check(claude, "project", path)
+
+			if result.Status != tt.want {
+				t.Errorf("expected status %s, got %s", tt.want, result.Status)
+			}
+			if result.ExpectedHash != Hash(expected) {
+				t.Error("expected hash of rendered section")
+			}
+			if len(result.RuleIDs) != 1 || result.RuleIDs[0] != "rule-1" {
+				t.Errorf("expected rule-1, got %v", result.RuleIDs)
+			}
+			if tt.want == StatusOK && result.ActualHash != result.ExpectedHash {
+				t.Error("expected actual hash to match for compliant file")
+			}
+		})
+	}
+}
+
+func TestChecker_NoRulesAndNoFile(t *testing.T) {
+	result := NewChecker(fakeRules{}).Check(claude, "user", filepath.Join(t.TempDir(), "CLAUDE.md"))
+
+	if result.Status != StatusOK {
+		t.Errorf("expected ok when no rules apply and file is absent, got %s", result.Status)
+	}
+}
+
+func TestChecker_CursorTarget(t *testing.T) {
+	cursor, _ := markdown.LookupTarget(markdown.TargetCursor)
+	rules := fakeRules{
+		"enterprise": {{ID: "org-1", Name: "Secrets", Content: "No secrets", TargetLayer: "enterprise"}},
+	}
+
+	project := t.TempDir()
+	path := cursor.ProjectFilePath(project)
+	r := renderer.ForTarget(cursor)
+	expected := r.RenderManagedSection(rules["enterprise"])
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(r.MergeWithFile("", expected)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := NewChecker(rules).Check(cursor, "project", path)
+
+	if result.Status != StatusOK {
+		t.Errorf("expected ok, got %s", result.Status)
+	}
+	if result.Target != markdown.TargetCursor {
+		t.Errorf("expected cursor target, got %q", result.Target)
+	}
+	if len(result.RuleIDs) != 1 || result.RuleIDs[0] != "org-1" {
+		t.Errorf("expected folded enterprise rule, got %v", result.RuleIDs)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
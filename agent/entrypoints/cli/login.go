@@ -3,6 +3,7 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/kamilrybacki/edictflow/agent/auth"
+	"github.com/kamilrybacki/edictflow/agent/daemon"
 	"github.com/kamilrybacki/edictflow/agent/storage"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -143,17 +145,21 @@ func loginWithCredentials(cmd *cobra.Command, serverURL string, store *storage.S
 	}
 
 	authInfo := storage.AuthInfo{
-		AccessToken: resp.Token,
-		ExpiresAt:   expiresAt,
-		UserID:      resp.User.ID,
-		UserEmail:   resp.User.Email,
-		UserName:    resp.User.Name,
-		TeamID:      teamID,
+		AccessToken:  resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    expiresAt,
+		UserID:       resp.User.ID,
+		UserEmail:    resp.User.Email,
+		UserName:     resp.User.Name,
+		TeamID:       teamID,
 	}
 
 	if err := store.SaveAuth(authInfo); err != nil {
 		return fmt.Errorf("failed to save auth: %w", err)
 	}
+	if err := registerAgent(serverURL, store); err != nil {
+		return err
+	}
 
 	fmt.Printf("\nLogin successful! Welcome, %s\n", resp.User.Name)
 	return nil
@@ -184,22 +190,38 @@ func loginWithDeviceFlow(serverURL string, store *storage.Storage) error {
 	}
 
 	authInfo := storage.AuthInfo{
-		AccessToken: token.AccessToken,
-		ExpiresAt:   time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
-		UserID:      "pending",
-		UserEmail:   "pending",
-		UserName:    "User",
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+		UserID:       "pending",
+		UserEmail:    "pending",
+		UserName:     "User",
 	}
 
 	if err := store.SaveAuth(authInfo); err != nil {
 		return fmt.Errorf("failed to save auth: %w", err)
 	}
+	if err := registerAgent(serverURL, store); err != nil {
+		return err
+	}
 
 	fmt.Println()
 	fmt.Println("Login successful!")
 	return nil
 }
 
+// registerAgent gets this machine's agent ID from the server. Other failures
+// than a revoked agent are retried when the daemon starts.
+func registerAgent(serverURL string, store *storage.Storage) error {
+	if _, err := daemon.RegisterAgent(store, serverURL); err != nil {
+		if errors.Is(err, auth.ErrAgentRevoked) {
+			return fmt.Errorf("an administrator revoked this machine's agent; ask them to restore access")
+		}
+		fmt.Printf("Warning: failed to register this machine: %v\n", err)
+	}
+	return nil
+}
+
 func openBrowser(url string) {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/kamilrybacki/edictflow/agent/daemon"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(remoteCommandsCmd)
+	remoteCommandsCmd.AddCommand(remoteCommandsAllowCmd)
+	remoteCommandsCmd.AddCommand(remoteCommandsDenyCmd)
+}
+
+var remoteCommandsCmd = &cobra.Command{
+	Use:   "remote-commands",
+	Short: "Show which commands the server may run on this machine",
+	Long: `Show which remote commands administrators may run on this machine
+through the server. Use 'allow' and 'deny' to change the list.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.New()
+		if err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer store.Close()
+
+		allowed, err := loadAllowedCommands(store)
+		if err != nil {
+			return err
+		}
+		for _, name := range daemon.KnownCommands {
+			state := "denied"
+			if allowed[name] {
+				state = "allowed"
+			}
+			fmt.Printf("  %-20s %s\n", name, state)
+		}
+		return nil
+	},
+}
+
+var remoteCommandsAllowCmd = &cobra.Command{
+	Use:   "allow <command>...",
+	Short: "Allow the server to run commands",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAllowedCommands(args, true)
+	},
+}
+
+var remoteCommandsDenyCmd = &cobra.Command{
+	Use:   "deny <command>...",
+	Short: "Stop the server from running commands",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAllowedCommands(args, false)
+	},
+}
+
+func loadAllowedCommands(store *storage.Storage) (map[string]bool, error) {
+	names, err := store.GetAllowedCommands()
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		names = daemon.DefaultAllowedCommands
+	}
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	return allowed, nil
+}
+
+// updateAllowedCommands allows or denies the commands. The running daemon
+// reads the list for every command, so no restart is needed.
+func updateAllowedCommands(commands []string, allow bool) error {
+	for _, name := range commands {
+		if !isKnownCommand(name) {
+			return fmt.Errorf("unknown command %q", name)
+		}
+	}
+
+	store, err := storage.New()
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
+
+	allowed, err := loadAllowedCommands(store)
+	if err != nil {
+		return err
+	}
+	for _, name := range commands {
+		allowed[name] = allow
+	}
+
+	names := []string{}
+	for _, name := range daemon.KnownCommands {
+		if allowed[name] {
+			names = append(names, name)
+		}
+	}
+	if err := store.SaveAllowedCommands(names); err != nil {
+		return err
+	}
+
+	fmt.Printf("Allowed commands: %v\n", names)
+	return nil
+}
+
+func isKnownCommand(name string) bool {
+	for _, known := range daemon.KnownCommands {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
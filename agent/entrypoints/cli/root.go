@@ -16,6 +16,16 @@ It watches for project changes, detects context, and applies
 the appropriate rules to maintain consistent Claude behavior.`,
 }
 
+// ExitError asks the process to exit with a specific code. The command has
+// already reported the outcome, so no error message is printed.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/kamilrybacki/edictflow/agent/daemon"
+	"github.com/kamilrybacki/edictflow/agent/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +22,11 @@ var statusCmd = &cobra.Command{
 		pid, running := daemon.IsRunning()
 		if !running {
 			fmt.Println("Status: Daemon not running")
+			if reason := upgradeRequired(); reason != "" {
+				fmt.Printf("Stopped by the server: %s\n", reason)
+				fmt.Println("Upgrade edictflow, then run 'edictflow start'")
+				return nil
+			}
 			fmt.Println("Run 'edictflow start' to start the daemon")
 			return nil
 		}
@@ -53,7 +59,21 @@ var statusCmd = &cobra.Command{
 		if status.PendingMsgs > 0 {
 			fmt.Printf("Pending messages: %d\n", status.PendingMsgs)
 		}
+		if status.DeadLetters > 0 {
+			fmt.Printf("Undeliverable messages: %d (unacknowledged after %d attempts)\n", status.DeadLetters, daemon.MaxDeliveryAttempts)
+		}
 
 		return nil
 	},
 }
+
+// upgradeRequired returns why the server last turned the agent away
+func upgradeRequired() string {
+	store, err := storage.New()
+	if err != nil {
+		return ""
+	}
+	defer store.Close()
+	reason, _ := store.GetUpgradeRequired()
+	return reason
+}
@@ -4,13 +4,11 @@ import (
 	"fmt"
 
 	"github.com/kamilrybacki/edictflow/agent/daemon"
-	"github.com/kamilrybacki/edictflow/agent/storage"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(syncCmd)
-	rootCmd.AddCommand(validateCmd)
 }
 
 var syncCmd = &cobra.Command{
@@ -32,33 +30,3 @@ var syncCmd = &cobra.Command{
 		return nil
 	},
 }
-
-var validateCmd = &cobra.Command{
-	Use:   "validate [path]",
-	Short: "Check drift for a project",
-	Long:  `Validate that local CLAUDE.md files match expected content.`,
-	Args:  cobra.MaximumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		store, err := storage.New()
-		if err != nil {
-			return fmt.Errorf("failed to open storage: %w", err)
-		}
-		defer store.Close()
-
-		rules, err := store.GetRules()
-		if err != nil {
-			return err
-		}
-
-		if len(rules) == 0 {
-			fmt.Println("No rules cached. Run sync first.")
-			return nil
-		}
-
-		fmt.Printf("Cached rules version: %d\n", store.GetCachedVersion())
-		fmt.Printf("Rules count: %d\n", len(rules))
-		// TODO: Check actual CLAUDE.md files against rules
-		fmt.Println("Validation complete.")
-		return nil
-	},
-}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kamilrybacki/edictflow/agent/daemon"
+	"github.com/kamilrybacki/edictflow/agent/drift"
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes for validate, ordered by severity. When several files drift,
+// the most severe code is returned.
+const (
+	ExitValidateError    = 1
+	ExitValidateStale    = 2
+	ExitValidateMissing  = 3
+	ExitValidateTampered = 4
+)
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().Bool("json", false, "Output results as JSON")
+	validateCmd.Flags().Bool("all", false, "Check the enterprise, user and all watched project files")
+}
+
+type validateReport struct {
+	CachedVersion int            `json:"cached_version"`
+	Compliant     bool           `json:"compliant"`
+	Files         []drift.Result `json:"files"`
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check drift for a project",
+	Long: `Validate that local CLAUDE.md and other output target files match the
+rules cached from the server.
+
+Checks the files of every enabled output target in the given project
+directory (default: current directory). With --all, the enterprise, user
+and all watched project files are checked instead.
+
+Exit codes:
+  0  all files match
+  1  a file could not be checked
+  2  a managed section is stale (rendered from different rules)
+  3  a file or its managed section markers are missing
+  4  a managed section was edited by hand`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		all, _ := cmd.Flags().GetBool("all")
+		if all && len(args) > 0 {
+			return fmt.Errorf("--all cannot be combined with a path")
+		}
+
+		store, err := storage.New()
+		if err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer store.Close()
+
+		version := store.GetCachedVersion()
+		rules, err := store.GetRules()
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 && version == 0 {
+			return fmt.Errorf("no rules cached; start the daemon or run 'edictflow sync' first")
+		}
+
+		names, err := store.GetOutputTargets()
+		if err != nil {
+			return err
+		}
+		targets := markdown.ResolveTargets(names)
+
+		var files []daemon.ManagedFile
+		if all {
+			projects, err := store.GetProjects()
+			if err != nil {
+				return err
+			}
+			paths := make([]string, len(projects))
+			for i, p := range projects {
+				paths[i] = p.Path
+			}
+			files = daemon.ManagedFiles(targets, paths)
+		} else {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			absPath, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			files = daemon.ProjectFiles(targets, absPath)
+		}
+
+		checker := drift.NewChecker(store)
+		report := validateReport{
+			CachedVersion: version,
+			Compliant:     true,
+		}
+		exitCode := 0
+		for _, f := range files {
+			result := checker.Check(f.Target, f.Level, f.Path)
+			report.Files = append(report.Files, result)
+			if result.Drifted() {
+				report.Compliant = false
+			}
+			if code := validateExitCode(result.Status); code > exitCode {
+				exitCode = code
+			}
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			printValidateReport(report)
+		}
+
+		if exitCode != 0 {
+			cmd.SilenceErrors = true
+			return &ExitError{Code: exitCode}
+		}
+		return nil
+	},
+}
+
+func validateExitCode(status drift.Status) int {
+	switch status {
+	case drift.StatusTampered:
+		return ExitValidateTampered
+	case drift.StatusMissingFile, drift.StatusMissingMarkers:
+		return ExitValidateMissing
+	case drift.StatusStale:
+		return ExitValidateStale
+	case drift.StatusError:
+		return ExitValidateError
+	default:
+		return 0
+	}
+}
+
+func printValidateReport(report validateReport) {
+	fmt.Printf("Cached rules version: %d\n", report.CachedVersion)
+
+	drifted := 0
+	for _, r := range report.Files {
+		fmt.Printf("  %-16s %-11s %s\n", r.Status, r.Level, r.Path)
+		if r.Error != "" {
+			fmt.Printf("    %s\n", r.Error)
+		}
+		if r.Drifted() {
+			drifted++
+		}
+	}
+
+	if drifted == 0 {
+		fmt.Printf("All %d files match the cached rules.\n", len(report.Files))
+		return
+	}
+	fmt.Printf("%d of %d files drifted.\n", drifted, len(report.Files))
+}
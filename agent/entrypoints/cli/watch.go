@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/kamilrybacki/edictflow/agent/daemon"
 	"github.com/kamilrybacki/edictflow/agent/storage"
 	"github.com/spf13/cobra"
 )
@@ -45,8 +46,18 @@ var watchCmd = &cobra.Command{
 			return fmt.Errorf("failed to add project: %w", err)
 		}
 
+		if _, running := daemon.IsRunning(); !running {
+			fmt.Printf("Now watching: %s\n", path)
+			fmt.Println("Daemon not running; the project will be watched when it starts.")
+			return nil
+		}
+
+		if err := daemon.SendCommand("watch", path); err != nil {
+			_ = store.RemoveProject(path)
+			return fmt.Errorf("daemon failed to watch project: %w", err)
+		}
+
 		fmt.Printf("Now watching: %s\n", path)
-		fmt.Println("Restart daemon to pick up new projects.")
 		return nil
 	},
 }
@@ -72,6 +83,12 @@ var unwatchCmd = &cobra.Command{
 			return fmt.Errorf("failed to remove project: %w", err)
 		}
 
+		if _, running := daemon.IsRunning(); running {
+			if err := daemon.SendCommand("unwatch", path); err != nil {
+				return fmt.Errorf("daemon failed to stop watching project: %w", err)
+			}
+		}
+
 		fmt.Printf("Stopped watching: %s\n", path)
 		return nil
 	},
@@ -108,6 +125,13 @@ var listCmd = &cobra.Command{
 			if len(p.DetectedTags) > 0 {
 				fmt.Printf("    Tags: %v\n", p.DetectedTags)
 			}
+			if p.Repository != "" {
+				fmt.Printf("    Repository: %s", p.Repository)
+				if p.Branch != "" {
+					fmt.Printf(" (%s)", p.Branch)
+				}
+				fmt.Println()
+			}
 		}
 		return nil
 	},
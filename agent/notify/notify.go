@@ -57,3 +57,15 @@ func ConnectionRestored() {
 func ManagedSectionRestored(filePath string) {
 	notifyAsync("CLAUDE.md Restored", "Managed content restored. Use WebUI to modify rules.\n"+filePath)
 }
+
+func LoginRequired() {
+	notifyAsync("Sign-in Required", "Session expired. Run 'edictflow login' to reconnect.")
+}
+
+func UpgradeRequired(minVersion string) {
+	notifyAsync("Upgrade Required", "The server requires agent version "+minVersion+" or newer. The agent has stopped.")
+}
+
+func AgentRevoked() {
+	notifyAsync("Agent Revoked", "An administrator revoked this machine. The agent has stopped.")
+}
@@ -14,12 +14,19 @@ const (
 	ManagedSectionEnd   = markdown.ManagedSectionEnd
 )
 
-// Renderer generates managed content for CLAUDE.md files
-type Renderer struct{}
+// Renderer generates managed content for one output target's files
+type Renderer struct {
+	format markdown.Format
+}
 
-// New creates a new Renderer
+// New creates a new Renderer for CLAUDE.md files
 func New() *Renderer {
-	return &Renderer{}
+	return &Renderer{format: markdown.DefaultFormat}
+}
+
+// ForTarget creates a Renderer using the target's format
+func ForTarget(target markdown.Target) *Renderer {
+	return &Renderer{format: target.Format}
 }
 
 // RenderManagedSection generates the managed content from cached rules.
@@ -30,90 +37,89 @@ func (r *Renderer) RenderManagedSection(rules []storage.CachedRule) string {
 
 // RenderManagedSectionWithCategories generates managed content using proper category ordering.
 // When categories is nil or empty, it falls back to alphabetical sorting by category name.
+// Categories of rules missing from categories are listed last.
 func (r *Renderer) RenderManagedSectionWithCategories(rules []storage.CachedRule, categories []storage.CachedCategory) string {
-	// Convert cached rules to shared markdown types
 	// Filter by effective dates during conversion
-	now := time.Now().Unix()
 	var mdRules []markdown.Rule
-	categorySet := make(map[string]bool)
-
 	for _, rule := range rules {
-		if rule.EffectiveStart != nil && now < *rule.EffectiveStart {
-			continue
-		}
-		if rule.EffectiveEnd != nil && now > *rule.EffectiveEnd {
+		if !IsEffective(rule) {
 			continue
 		}
+		mdRules = append(mdRules, toMarkdownRule(rule))
+	}
 
-		catID := rule.CategoryID
-		catName := rule.CategoryName
-		if catName == "" {
-			catName = "Uncategorized"
-			catID = ""
-		}
+	mdCategories := make([]markdown.Category, 0, len(categories))
+	for _, c := range categories {
+		mdCategories = append(mdCategories, markdown.Category{
+			ID:           c.ID,
+			Name:         c.Name,
+			DisplayOrder: c.DisplayOrder,
+		})
+	}
 
-		categorySet[catID] = true
+	return r.format.RenderManagedSectionWithCategories(mdRules, mdCategories)
+}
 
-		mdRules = append(mdRules, markdown.Rule{
-			Name:         rule.Name,
-			Content:      rule.Content,
-			TargetLayer:  rule.TargetLayer,
-			CategoryID:   catID,
-			CategoryName: catName,
-			Overridable:  rule.Overridable,
-		})
+func toMarkdownRule(rule storage.CachedRule) markdown.Rule {
+	return markdown.Rule{
+		Name:           rule.Name,
+		Content:        rule.Content,
+		TargetLayer:    rule.TargetLayer,
+		CategoryID:     rule.CategoryID,
+		CategoryName:   rule.CategoryName,
+		Overridable:    rule.Overridable,
+		PriorityWeight: rule.PriorityWeight,
 	}
+}
 
-	// Build categories from provided categories or fall back to alphabetical
-	var mdCategories []markdown.Category
-	if len(categories) > 0 {
-		// Use provided categories with proper display order
-		for _, c := range categories {
-			if categorySet[c.ID] {
-				mdCategories = append(mdCategories, markdown.Category{
-					ID:           c.ID,
-					Name:         c.Name,
-					DisplayOrder: c.DisplayOrder,
-				})
-			}
-		}
-		// Add uncategorized if needed
-		if categorySet[""] {
-			mdCategories = append(mdCategories, markdown.Category{
-				ID:           "",
-				Name:         "Uncategorized",
-				DisplayOrder: 9999, // Sort last
-			})
-		}
-	} else {
-		// Fallback: build categories from rules, all with DisplayOrder 0 (alphabetical)
-		for catID := range categorySet {
-			catName := catID
-			if catName == "" {
-				catName = "Uncategorized"
-			}
-			mdCategories = append(mdCategories, markdown.Category{
-				ID:           catID,
-				Name:         catName,
-				DisplayOrder: 0,
-			})
-		}
+// ChangedRule returns the rule whose part of the expected managed section
+// was edited in fileContent. ok is false when the edit is outside the
+// managed section.
+func (r *Renderer) ChangedRule(fileContent, expectedManaged string, rules []storage.CachedRule) (storage.CachedRule, bool) {
+	mdRules := make([]markdown.Rule, len(rules))
+	for i, rule := range rules {
+		mdRules[i] = toMarkdownRule(rule)
 	}
+	i, ok := r.format.ChangedRule(fileContent, expectedManaged, mdRules)
+	if !ok {
+		return storage.CachedRule{}, false
+	}
+	return rules[i], true
+}
 
-	return markdown.RenderManagedSection(mdRules, mdCategories)
+// IsEffective reports whether a cached rule is within its effective dates
+func IsEffective(rule storage.CachedRule) bool {
+	now := time.Now().Unix()
+	if rule.EffectiveStart != nil && now < *rule.EffectiveStart {
+		return false
+	}
+	if rule.EffectiveEnd != nil && now > *rule.EffectiveEnd {
+		return false
+	}
+	return true
 }
 
 // MergeWithFile combines managed section with existing file content
 func (r *Renderer) MergeWithFile(existing, managed string) string {
-	return markdown.MergeWithExisting(existing, managed)
+	return r.format.MergeWithExisting(existing, managed)
 }
 
 // DetectManagedSectionTampering checks if managed section was modified
 func (r *Renderer) DetectManagedSectionTampering(fileContent, expectedManaged string) bool {
-	return markdown.DetectTampering(fileContent, expectedManaged)
+	return r.format.DetectTampering(fileContent, expectedManaged)
+}
+
+// CheckManagedSection classifies the file's managed section against the expected one
+func (r *Renderer) CheckManagedSection(fileContent, expectedManaged string) markdown.SectionStatus {
+	return r.format.CheckManagedSection(fileContent, expectedManaged)
+}
+
+// ExtractManagedSection returns the managed section of a file including its markers
+func (r *Renderer) ExtractManagedSection(fileContent string) (string, bool) {
+	return r.format.ExtractManagedSection(fileContent)
 }
 
 // ExtractManualContent returns content outside the managed section
 func (r *Renderer) ExtractManualContent(content string) (before, after string) {
-	return markdown.ExtractManualContent(content)
+	return r.format.ExtractManualContent(content)
 }
@@ -177,3 +177,43 @@ func TestRenderer_EffectiveDateFiltering(t *testing.T) {
 		t.Error("expected Expired Rule to be excluded")
 	}
 }
+
+func TestRenderer_RenderManagedSectionWithCategories(t *testing.T) {
+	rules := []storage.CachedRule{
+		{Name: "Coverage", Content: "c", CategoryID: "cat-testing", CategoryName: "Testing", TargetLayer: "project"},
+		{Name: "Low", Content: "l", CategoryID: "cat-security", CategoryName: "Security", TargetLayer: "project", PriorityWeight: 1},
+		{Name: "High", Content: "h", CategoryID: "cat-security", CategoryName: "Security", TargetLayer: "project", PriorityWeight: 10},
+		{Name: "Loose", Content: "x", TargetLayer: "project"},
+	}
+	categories := []storage.CachedCategory{
+		{ID: "cat-testing", Name: "Testing", DisplayOrder: 1},
+		{ID: "cat-security", Name: "Security", DisplayOrder: 2},
+	}
+
+	result := New().RenderManagedSectionWithCategories(rules, categories)
+
+	order := []string{"## Testing", "## Security", "**High**", "**Low**", "## Uncategorized"}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(result, s)
+		if i < 0 {
+			t.Fatalf("expected %q in output:\n%s", s, result)
+		}
+		if i < last {
+			t.Errorf("expected %q after the previous entries:\n%s", s, result)
+		}
+		last = i
+	}
+}
+
+func TestRenderer_RenderManagedSection_CategoryNamesWithoutOrder(t *testing.T) {
+	rules := []storage.CachedRule{
+		{Name: "No Secrets", Content: "n", CategoryID: "cat-security", CategoryName: "Security", TargetLayer: "project"},
+	}
+
+	result := New().RenderManagedSection(rules)
+
+	if !strings.Contains(result, "## Security") {
+		t.Errorf("expected the category name as heading:\n%s", result)
+	}
+}
@@ -0,0 +1,136 @@
+// agent/renderer/resolve.go
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+	"github.com/kamilrybacki/edictflow/pkg/triggers"
+)
+
+// RuleStore provides the cached rules and categories and the watched projects
+// rules are resolved for
+type RuleStore interface {
+	GetRulesByLayer(targetLayer string) ([]storage.CachedRule, error)
+	GetCategories() ([]storage.CachedCategory, error)
+	GetProjects() ([]storage.WatchedProject, error)
+}
+
+// ManagedSectionForFile renders the managed section expected in the target's
+// file at path, ordered by the cached categories, along with the rules it
+// was rendered from
+func ManagedSectionForFile(store RuleStore, target markdown.Target, level, path string) (string, []storage.CachedRule, error) {
+	rules, err := RulesForFile(store, target, level, path)
+	if err != nil {
+		return "", nil, err
+	}
+	categories, err := store.GetCategories()
+	if err != nil {
+		return "", nil, err
+	}
+	return ForTarget(target).RenderManagedSectionWithCategories(rules, categories), rules, nil
+}
+
+// RulesForFile returns the cached rules that belong in the target's managed
+// file at path. Project files only get the project rules that apply to their
+// project, preceded by the enterprise and user rules when the target has no
+// file of its own for those layers.
+func RulesForFile(store RuleStore, target markdown.Target, level, path string) ([]storage.CachedRule, error) {
+	if level != "project" {
+		return store.GetRulesByLayer(level)
+	}
+
+	var rules []storage.CachedRule
+	for _, layer := range []string{"enterprise", "user"} {
+		if !target.FoldsLayer(layer) {
+			continue
+		}
+		layerRules, err := store.GetRulesByLayer(layer)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, layerRules...)
+	}
+
+	projectRules, err := store.GetRulesByLayer(level)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := store.GetProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := target.ProjectRoot(path)
+	if !ok {
+		root = filepath.Dir(path)
+	}
+	project := storage.WatchedProject{Path: root}
+	for _, p := range projects {
+		if p.Path == project.Path {
+			project = p
+			break
+		}
+	}
+	return append(rules, MatchProjectRules(projectRules, project)...), nil
+}
+
+// MatchProjectRules returns the rules whose triggers match the project, most
+// specific first, using the same matching as the server. Rules without
+// triggers are not scoped to any project and apply everywhere, after the
+// matched ones. Rules whose triggers cannot be decoded match no project.
+// Glob and file_exists triggers are checked against the project's files on
+// disk.
+func MatchProjectRules(rules []storage.CachedRule, project storage.WatchedProject) []storage.CachedRule {
+	ctx := triggers.Context{
+		ProjectPath:      project.Path,
+		DetectedContexts: project.DetectedContext,
+		Tags:             project.DetectedTags,
+		Repository:       project.Repository,
+		Branch:           project.Branch,
+		Files:            os.DirFS(project.Path),
+	}
+
+	type scopedRule struct {
+		rule     storage.CachedRule
+		triggers []triggers.Trigger
+	}
+	var scoped []scopedRule
+	var unscoped []storage.CachedRule
+	for _, rule := range rules {
+		ts, err := RuleTriggers(rule)
+		if err != nil {
+			log.Printf("Skipping rule %s: %v", rule.ID, err)
+			continue
+		}
+		if len(ts) == 0 {
+			unscoped = append(unscoped, rule)
+		} else {
+			scoped = append(scoped, scopedRule{rule: rule, triggers: ts})
+		}
+	}
+
+	matched := triggers.Match(scoped, func(r scopedRule) []triggers.Trigger { return r.triggers }, ctx)
+	result := make([]storage.CachedRule, 0, len(matched)+len(unscoped))
+	for _, r := range matched {
+		result = append(result, r.rule)
+	}
+	return append(result, unscoped...)
+}
+
+// RuleTriggers decodes the triggers cached with a rule
+func RuleTriggers(rule storage.CachedRule) ([]triggers.Trigger, error) {
+	if len(rule.Triggers) == 0 {
+		return nil, nil
+	}
+	var ts []triggers.Trigger
+	if err := json.Unmarshal(rule.Triggers, &ts); err != nil {
+		return nil, fmt.Errorf("invalid triggers: %w", err)
+	}
+	return ts, nil
+}
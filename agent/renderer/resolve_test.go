@@ -0,0 +1,181 @@
+package renderer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/agent/storage"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+)
+
+type fakeStore struct {
+	rules    map[string][]storage.CachedRule
+	projects []storage.WatchedProject
+}
+
+func (f fakeStore) GetRulesByLayer(layer string) ([]storage.CachedRule, error) {
+	return f.rules[layer], nil
+}
+
+func (f fakeStore) GetCategories() ([]storage.CachedCategory, error) {
+	return nil, nil
+}
+
+func (f fakeStore) GetProjects() ([]storage.WatchedProject, error) {
+	return f.projects, nil
+}
+
+func ruleWithTriggers(id, triggers string) storage.CachedRule {
+	return storage.CachedRule{ID: id, TargetLayer: "project", Triggers: json.RawMessage(triggers)}
+}
+
+var claude, _ = markdown.LookupTarget(markdown.TargetClaude)
+
+func ruleIDs(rules []storage.CachedRule) []string {
+	ids := []string{}
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestRulesForFile_Project(t *testing.T) {
+	store := fakeStore{
+		rules: map[string][]storage.CachedRule{
+			"project": {
+				ruleWithTriggers("everywhere", "null"),
+				ruleWithTriggers("go", `[{"type":"context","context_types":["go"]}]`),
+				ruleWithTriggers("react", `[{"type":"tag","tags":["react"]}]`),
+				ruleWithTriggers("api-path", `[{"type":"path","pattern":"/work/api"}]`),
+				ruleWithTriggers("web-path", `[{"type":"path","pattern":"/work/web"}]`),
+			},
+		},
+		projects: []storage.WatchedProject{
+			{Path: "/work/api", DetectedContext: []string{"go"}, DetectedTags: []string{"grpc"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"matching project ordered by specificity", "/work/api/CLAUDE.md", []string{"api-path", "go", "everywhere"}},
+		{"project without detected context", "/work/web/CLAUDE.md", []string{"web-path", "everywhere"}},
+		{"unmatched project keeps untriggered rules", "/work/other/CLAUDE.md", []string{"everywhere"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := RulesForFile(store, claude, "project", tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ruleIDs(rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchProjectRules_FileAndRepositoryTriggers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "web", "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"go.mod", "web/src/index.ts"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := []storage.CachedRule{
+		ruleWithTriggers("python", `[{"type":"glob","pattern":"**/*.py"}]`),
+		ruleWithTriggers("not-node", `[{"type":"file_exists","pattern":"package.json","negate":true}]`),
+		ruleWithTriggers("go-module", `[{"type":"file_exists","pattern":"go.mod"}]`),
+		ruleWithTriggers("typescript", `[{"type":"glob","pattern":"web/**/*.ts"}]`),
+		ruleWithTriggers("payments", `[{"type":"repository","pattern":"github.com/acme/payments-*","branches":["main"]}]`),
+		ruleWithTriggers("other-branch", `[{"type":"repository","pattern":"github.com/acme/*","branches":["release/*"]}]`),
+	}
+
+	project := storage.WatchedProject{Path: dir, Repository: "github.com/acme/payments-api", Branch: "main"}
+	got := ruleIDs(MatchProjectRules(rules, project))
+	want := []string{"payments", "typescript", "go-module", "not-node"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRulesForFile_OtherLevelsAreNotFiltered(t *testing.T) {
+	store := fakeStore{
+		rules: map[string][]storage.CachedRule{
+			"user": {ruleWithTriggers("go", `[{"type":"context","context_types":["go"]}]`)},
+		},
+	}
+
+	rules, err := RulesForFile(store, claude, "user", "/home/me/.claude/CLAUDE.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleIDs(rules); !reflect.DeepEqual(got, []string{"go"}) {
+		t.Errorf("expected user rules unfiltered, got %v", got)
+	}
+}
+
+func TestRulesForFile_FoldsLayersWithoutOwnFile(t *testing.T) {
+	store := fakeStore{
+		rules: map[string][]storage.CachedRule{
+			"enterprise": {{ID: "org", TargetLayer: "enterprise"}},
+			"user":       {{ID: "mine", TargetLayer: "user"}},
+			"project":    {ruleWithTriggers("go", `[{"type":"context","context_types":["go"]}]`)},
+		},
+		projects: []storage.WatchedProject{{Path: "/work/api", DetectedContext: []string{"go"}}},
+	}
+	cursor, _ := markdown.LookupTarget(markdown.TargetCursor)
+	gemini, _ := markdown.LookupTarget(markdown.TargetGemini)
+
+	tests := []struct {
+		name   string
+		target markdown.Target
+		want   []string
+	}{
+		{"claude has its own files", claude, []string{"go"}},
+		{"gemini has a user file", gemini, []string{"org", "go"}},
+		{"cursor has only project files", cursor, []string{"org", "mine", "go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := RulesForFile(store, tt.target, "project", tt.target.ProjectFilePath("/work/api"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ruleIDs(rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRuleTriggers_InvalidJSON(t *testing.T) {
+	if got, err := RuleTriggers(ruleWithTriggers("bad", "{")); err == nil || got != nil {
+		t.Errorf("expected an error for invalid JSON, got %v, %v", got, err)
+	}
+}
+
+func TestMatchProjectRules_SkipsMalformedTriggers(t *testing.T) {
+	rules := []storage.CachedRule{
+		ruleWithTriggers("everywhere", "null"),
+		ruleWithTriggers("malformed", `[{"type":"path","pattern":`),
+		ruleWithTriggers("wrong-shape", `{"type":"path"}`),
+		ruleWithTriggers("api-path", `[{"type":"path","pattern":"/work/api"}]`),
+	}
+
+	got := ruleIDs(MatchProjectRules(rules, storage.WatchedProject{Path: "/work/api"}))
+	if want := []string{"api-path", "everywhere"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -17,22 +17,24 @@ type AuthInfo struct {
 	UserEmail    string
 	UserName     string
 	TeamID       string
+	// AgentID is the ID the server registered for this machine at login
+	AgentID string
 }
 
 func (s *Storage) SaveAuth(auth AuthInfo) error {
 	query := `
-		INSERT OR REPLACE INTO auth (id, access_token, refresh_token, expires_at, user_id, user_email, user_name, team_id)
-		VALUES (1, ?, ?, ?, ?, ?, ?, ?)
+		INSERT OR REPLACE INTO auth (id, access_token, refresh_token, expires_at, user_id, user_email, user_name, team_id, agent_id)
+		VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, auth.AccessToken, auth.RefreshToken, auth.ExpiresAt.Unix(), auth.UserID, auth.UserEmail, auth.UserName, auth.TeamID)
+	_, err := s.db.Exec(query, auth.AccessToken, auth.RefreshToken, auth.ExpiresAt.Unix(), auth.UserID, auth.UserEmail, auth.UserName, auth.TeamID, auth.AgentID)
 	return err
 }
 
 func (s *Storage) GetAuth() (AuthInfo, error) {
-	query := `SELECT access_token, refresh_token, expires_at, user_id, user_email, user_name, COALESCE(team_id, '') FROM auth WHERE id = 1`
+	query := `SELECT access_token, refresh_token, expires_at, user_id, user_email, user_name, COALESCE(team_id, ''), COALESCE(agent_id, '') FROM auth WHERE id = 1`
 	var auth AuthInfo
 	var expiresAt int64
-	err := s.db.QueryRow(query).Scan(&auth.AccessToken, &auth.RefreshToken, &expiresAt, &auth.UserID, &auth.UserEmail, &auth.UserName, &auth.TeamID)
+	err := s.db.QueryRow(query).Scan(&auth.AccessToken, &auth.RefreshToken, &expiresAt, &auth.UserID, &auth.UserEmail, &auth.UserName, &auth.TeamID, &auth.AgentID)
 	if err == sql.ErrNoRows {
 		return AuthInfo{}, ErrNotLoggedIn
 	}
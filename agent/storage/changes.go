@@ -53,6 +53,21 @@ func (s *Storage) GetPendingChanges() ([]PendingChange, error) {
 	return changes, nil
 }
 
+// GetPendingChangeByFile returns the most recent unresolved change for a file.
+// Returns sql.ErrNoRows if the file has no pending change.
+func (s *Storage) GetPendingChangeByFile(filePath string) (PendingChange, error) {
+	query := `SELECT id, rule_id, file_path, original_content, modified_content, status, created_at
+		FROM pending_changes WHERE file_path = ? AND status = 'pending' ORDER BY created_at DESC LIMIT 1`
+	var c PendingChange
+	var createdAt int64
+	err := s.db.QueryRow(query, filePath).Scan(&c.ID, &c.RuleID, &c.FilePath, &c.OriginalContent, &c.ModifiedContent, &c.Status, &createdAt)
+	if err != nil {
+		return PendingChange{}, err
+	}
+	c.CreatedAt = time.Unix(createdAt, 0)
+	return c, nil
+}
+
 func (s *Storage) UpdateChangeStatus(id, status string) error {
 	_, err := s.db.Exec("UPDATE pending_changes SET status = ? WHERE id = ?", status, id)
 	return err
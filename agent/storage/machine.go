@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// machineIDSources hold the operating system's machine ID on Linux
+var machineIDSources = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// MachineID returns the ID the server uses to tell this machine's agent apart
+// from the user's other machines. It is generated once and kept in the
+// config, so it survives logging out and in again.
+func (s *Storage) MachineID() (string, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT value FROM config WHERE key = 'machine_id'`).Scan(&id)
+	if err == nil && id != "" {
+		return id, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	id = deriveMachineID()
+	_, err = s.db.Exec(`
+		INSERT INTO config (key, value) VALUES ('machine_id', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// deriveMachineID hashes the operating system's machine ID, which must not be
+// sent as is, or falls back to a random ID where there is none
+func deriveMachineID() string {
+	for _, path := range machineIDSources {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if raw := strings.TrimSpace(string(data)); raw != "" {
+			sum := sha256.Sum256([]byte("edictflow:" + raw))
+			return hex.EncodeToString(sum[:16])
+		}
+	}
+	return uuid.New().String()
+}
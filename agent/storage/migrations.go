@@ -19,7 +19,9 @@ CREATE TABLE IF NOT EXISTS message_queue (
     msg_type TEXT NOT NULL,
     payload TEXT NOT NULL,
     created_at INTEGER NOT NULL,
-    attempts INTEGER DEFAULT 0
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending'
 );
 
 CREATE TABLE IF NOT EXISTS cached_rules (
@@ -78,7 +80,64 @@ CREATE INDEX IF NOT EXISTS idx_pending_changes_rule_id ON pending_changes(rule_i
 CREATE INDEX IF NOT EXISTS idx_watched_projects_last_sync ON watched_projects(last_sync_at);
 `
 
+// columnMigrations adds columns introduced after a table was first created.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so columns that exist are skipped.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"message_queue", "next_attempt_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"message_queue", "status", "TEXT NOT NULL DEFAULT 'pending'"},
+	{"cached_rules", "priority_weight", "INTEGER NOT NULL DEFAULT 0"},
+	{"cached_rules", "rule_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"auth", "agent_id", "TEXT DEFAULT ''"},
+	{"watched_projects", "repository", "TEXT NOT NULL DEFAULT ''"},
+	{"watched_projects", "branch", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// postColumnSchema holds statements that depend on migrated columns
+const postColumnSchema = `
+CREATE INDEX IF NOT EXISTS idx_message_queue_status ON message_queue(status, id);
+`
+
 func (s *Storage) migrate() error {
-	_, err := s.db.Exec(schema)
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	for _, m := range columnMigrations {
+		exists, err := s.columnExists(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.db.Exec("ALTER TABLE " + m.table + " ADD COLUMN " + m.column + " " + m.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(postColumnSchema)
 	return err
 }
+
+func (s *Storage) columnExists(table, column string) (bool, error) {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -10,6 +10,8 @@ type WatchedProject struct {
 	Path            string    `json:"path"`
 	DetectedContext []string  `json:"detected_context"`
 	DetectedTags    []string  `json:"detected_tags"`
+	Repository      string    `json:"repository,omitempty"`
+	Branch          string    `json:"branch,omitempty"`
 	LastSyncAt      time.Time `json:"last_sync_at"`
 }
 
@@ -25,7 +27,7 @@ func (s *Storage) RemoveProject(path string) error {
 }
 
 func (s *Storage) GetProjects() ([]WatchedProject, error) {
-	query := `SELECT path, detected_context, detected_tags, last_sync_at FROM watched_projects`
+	query := `SELECT path, detected_context, detected_tags, repository, branch, last_sync_at FROM watched_projects`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -37,7 +39,7 @@ func (s *Storage) GetProjects() ([]WatchedProject, error) {
 		var p WatchedProject
 		var context, tags *string
 		var lastSyncAt int64
-		if err := rows.Scan(&p.Path, &context, &tags, &lastSyncAt); err != nil {
+		if err := rows.Scan(&p.Path, &context, &tags, &p.Repository, &p.Branch, &lastSyncAt); err != nil {
 			return nil, err
 		}
 		if context != nil {
@@ -52,10 +54,12 @@ func (s *Storage) GetProjects() ([]WatchedProject, error) {
 	return projects, nil
 }
 
-func (s *Storage) UpdateProjectContext(path string, context, tags []string) error {
+// UpdateProjectContext stores what detection found in the project: its
+// contexts and tags and the git repository and branch it is checked out from
+func (s *Storage) UpdateProjectContext(path string, context, tags []string, repository, branch string) error {
 	contextJSON, _ := json.Marshal(context)
 	tagsJSON, _ := json.Marshal(tags)
-	query := `UPDATE watched_projects SET detected_context = ?, detected_tags = ?, last_sync_at = ? WHERE path = ?`
-	_, err := s.db.Exec(query, string(contextJSON), string(tagsJSON), time.Now().Unix(), path)
+	query := `UPDATE watched_projects SET detected_context = ?, detected_tags = ?, repository = ?, branch = ?, last_sync_at = ? WHERE path = ?`
+	_, err := s.db.Exec(query, string(contextJSON), string(tagsJSON), repository, branch, time.Now().Unix(), path)
 	return err
 }
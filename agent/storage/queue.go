@@ -3,29 +3,48 @@ package storage
 
 import (
 	"time"
+)
 
-	"github.com/google/uuid"
+// Queue message states
+const (
+	QueueStatusPending = "pending"
+	QueueStatusDead    = "dead"
 )
 
 type QueuedMessage struct {
-	ID        int64
-	RefID     string
-	MsgType   string
-	Payload   string
-	CreatedAt time.Time
-	Attempts  int
+	ID            int64
+	RefID         string
+	MsgType       string
+	Payload       string
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time
+	Status        string
 }
 
-func (s *Storage) EnqueueMessage(msgType, payload string) (string, error) {
-	refID := uuid.New().String()
-	query := `INSERT INTO message_queue (ref_id, msg_type, payload, created_at, attempts) VALUES (?, ?, ?, ?, 0)`
-	_, err := s.db.Exec(query, refID, msgType, payload, time.Now().Unix())
-	return refID, err
+// EnqueueMessage persists an outbound message. refID is the message ID the
+// server echoes back in its ack; payload is the serialized message.
+func (s *Storage) EnqueueMessage(refID, msgType, payload string) error {
+	query := `INSERT OR IGNORE INTO message_queue (ref_id, msg_type, payload, created_at, attempts, next_attempt_at, status)
+		VALUES (?, ?, ?, ?, 0, 0, ?)`
+	_, err := s.db.Exec(query, refID, msgType, payload, time.Now().Unix(), QueueStatusPending)
+	return err
 }
 
+// GetPendingMessages returns undelivered messages in the order they were enqueued
 func (s *Storage) GetPendingMessages() ([]QueuedMessage, error) {
-	query := `SELECT id, ref_id, msg_type, payload, created_at, attempts FROM message_queue WHERE attempts < 3 ORDER BY created_at`
-	rows, err := s.db.Query(query)
+	return s.getMessages(QueueStatusPending)
+}
+
+// GetDeadLetters returns messages that exhausted their delivery attempts
+func (s *Storage) GetDeadLetters() ([]QueuedMessage, error) {
+	return s.getMessages(QueueStatusDead)
+}
+
+func (s *Storage) getMessages(status string) ([]QueuedMessage, error) {
+	query := `SELECT id, ref_id, msg_type, payload, created_at, attempts, next_attempt_at, status
+		FROM message_queue WHERE status = ? ORDER BY id`
+	rows, err := s.db.Query(query, status)
 	if err != nil {
 		return nil, err
 	}
@@ -34,18 +53,33 @@ func (s *Storage) GetPendingMessages() ([]QueuedMessage, error) {
 	var messages []QueuedMessage
 	for rows.Next() {
 		var m QueuedMessage
-		var createdAt int64
-		if err := rows.Scan(&m.ID, &m.RefID, &m.MsgType, &m.Payload, &createdAt, &m.Attempts); err != nil {
+		var createdAt, nextAttemptAt int64
+		if err := rows.Scan(&m.ID, &m.RefID, &m.MsgType, &m.Payload, &createdAt, &m.Attempts, &nextAttemptAt, &m.Status); err != nil {
 			return nil, err
 		}
 		m.CreatedAt = time.Unix(createdAt, 0)
+		m.NextAttemptAt = time.Unix(nextAttemptAt, 0)
 		messages = append(messages, m)
 	}
-	return messages, nil
+	return messages, rows.Err()
+}
+
+// RecordAttempt increments a message's delivery attempts and schedules the next one
+func (s *Storage) RecordAttempt(refID string, nextAttemptAt time.Time) error {
+	_, err := s.db.Exec("UPDATE message_queue SET attempts = attempts + 1, next_attempt_at = ? WHERE ref_id = ?", nextAttemptAt.Unix(), refID)
+	return err
+}
+
+// ResetSchedule makes all pending messages due immediately. Used after a
+// reconnect, since anything in flight on the old connection was lost.
+func (s *Storage) ResetSchedule() error {
+	_, err := s.db.Exec("UPDATE message_queue SET next_attempt_at = 0 WHERE status = ?", QueueStatusPending)
+	return err
 }
 
-func (s *Storage) IncrementAttempts(refID string) error {
-	_, err := s.db.Exec("UPDATE message_queue SET attempts = attempts + 1 WHERE ref_id = ?", refID)
+// MarkDeadLetter stops delivery attempts for a message but keeps it for inspection
+func (s *Storage) MarkDeadLetter(refID string) error {
+	_, err := s.db.Exec("UPDATE message_queue SET status = ? WHERE ref_id = ?", QueueStatusDead, refID)
 	return err
 }
 
@@ -54,8 +88,9 @@ func (s *Storage) DeleteMessage(refID string) error {
 	return err
 }
 
+// DeleteOldMessages removes dead-lettered messages older than maxAge
 func (s *Storage) DeleteOldMessages(maxAge time.Duration) error {
 	cutoff := time.Now().Add(-maxAge).Unix()
-	_, err := s.db.Exec("DELETE FROM message_queue WHERE created_at < ? OR attempts >= 3", cutoff)
+	_, err := s.db.Exec("DELETE FROM message_queue WHERE status = ? AND created_at < ?", QueueStatusDead, cutoff)
 	return err
 }
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestQueue_PendingMessagesKeepEnqueueOrder(t *testing.T) {
+	s := newTestStorage(t)
+
+	for _, id := range []string{"c", "a", "b"} {
+		if err := s.EnqueueMessage(id, "change_detected", `{"id":"`+id+`"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, err := s.GetPendingMessages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	for i, id := range []string{"c", "a", "b"} {
+		if messages[i].RefID != id {
+			t.Errorf("message %d: expected %s, got %s", i, id, messages[i].RefID)
+		}
+	}
+}
+
+func TestQueue_AttemptsAndDeadLetters(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.EnqueueMessage("m1", "change_detected", "{}"); err != nil {
+		t.Fatal(err)
+	}
+	next := time.Now().Add(time.Minute)
+	if err := s.RecordAttempt("m1", next); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _ := s.GetPendingMessages()
+	if messages[0].Attempts != 1 || messages[0].NextAttemptAt.Unix() != next.Unix() {
+		t.Errorf("expected attempt recorded, got %+v", messages[0])
+	}
+
+	if err := s.ResetSchedule(); err != nil {
+		t.Fatal(err)
+	}
+	messages, _ = s.GetPendingMessages()
+	if messages[0].NextAttemptAt.After(time.Now()) {
+		t.Error("expected message due after schedule reset")
+	}
+
+	if err := s.MarkDeadLetter("m1"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := s.GetPendingMessages(); len(pending) != 0 {
+		t.Errorf("expected no pending messages, got %d", len(pending))
+	}
+	if dead, _ := s.GetDeadLetters(); len(dead) != 1 {
+		t.Errorf("expected 1 dead letter, got %d", len(dead))
+	}
+}
+
+func TestQueue_DeleteOnAck(t *testing.T) {
+	s := newTestStorage(t)
+
+	_ = s.EnqueueMessage("m1", "revert_complete", "{}")
+	_ = s.EnqueueMessage("m2", "revert_complete", "{}")
+	if err := s.DeleteMessage("m1"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _ := s.GetPendingMessages()
+	if len(messages) != 1 || messages[0].RefID != "m2" {
+		t.Errorf("expected only m2 pending, got %+v", messages)
+	}
+}
@@ -2,7 +2,9 @@
 package storage
 
 import (
+	"database/sql"
 	"encoding/json"
+	"strconv"
 	"time"
 )
 
@@ -14,6 +16,7 @@ type CachedRule struct {
 	TargetLayer           string          `json:"target_layer"`
 	CategoryID            string          `json:"category_id"`
 	CategoryName          string          `json:"category_name"`
+	PriorityWeight        int             `json:"priority_weight"`
 	Overridable           bool            `json:"overridable"`
 	EffectiveStart        *int64          `json:"effective_start,omitempty"`
 	EffectiveEnd          *int64          `json:"effective_end,omitempty"`
@@ -23,6 +26,9 @@ type CachedRule struct {
 	TemporaryTimeoutHours int             `json:"temporary_timeout_hours"`
 	Version               int             `json:"version"`
 	CachedAt              time.Time       `json:"cached_at"`
+	// RuleVersion is the rule's approved version on the server, 0 when the
+	// server did not send it
+	RuleVersion int `json:"rule_version"`
 }
 
 type CachedCategory struct {
@@ -32,6 +38,7 @@ type CachedCategory struct {
 	DisplayOrder int    `json:"display_order"`
 }
 
+// SaveRules replaces the cached rules with a full rule set at version
 func (s *Storage) SaveRules(rules []CachedRule, version int) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -43,12 +50,46 @@ func (s *Storage) SaveRules(rules []CachedRule, version int) error {
 	if _, err := tx.Exec("DELETE FROM cached_rules"); err != nil {
 		return err
 	}
+	if err := upsertRules(tx, rules, version); err != nil {
+		return err
+	}
+	if err := setCachedVersion(tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplyRuleChanges updates the cache with the rules changed and removed
+// since the cached version, moving it to version
+func (s *Storage) ApplyRuleChanges(changed []CachedRule, removedIDs []string, version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, id := range removedIDs {
+		if _, err := tx.Exec("DELETE FROM cached_rules WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	if err := upsertRules(tx, changed, version); err != nil {
+		return err
+	}
+	if err := setCachedVersion(tx, version); err != nil {
+		return err
+	}
 
-	query := `INSERT INTO cached_rules (
+	return tx.Commit()
+}
+
+func upsertRules(tx *sql.Tx, rules []CachedRule, version int) error {
+	query := `INSERT OR REPLACE INTO cached_rules (
 		id, name, content, description, target_layer, category_id, category_name,
-		overridable, effective_start, effective_end, tags, triggers,
-		enforcement_mode, temporary_timeout_hours, version, cached_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		priority_weight, overridable, effective_start, effective_end, tags, triggers,
+		enforcement_mode, temporary_timeout_hours, version, cached_at, rule_version
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	for _, r := range rules {
 		triggers, _ := json.Marshal(r.Triggers)
@@ -59,20 +100,27 @@ func (s *Storage) SaveRules(rules []CachedRule, version int) error {
 		}
 		if _, err := tx.Exec(query,
 			r.ID, r.Name, r.Content, r.Description, r.TargetLayer, r.CategoryID, r.CategoryName,
-			overridable, r.EffectiveStart, r.EffectiveEnd, string(tags), string(triggers),
-			r.EnforcementMode, r.TemporaryTimeoutHours, version, time.Now().Unix(),
+			r.PriorityWeight, overridable, r.EffectiveStart, r.EffectiveEnd, string(tags), string(triggers),
+			r.EnforcementMode, r.TemporaryTimeoutHours, version, time.Now().Unix(), r.RuleVersion,
 		); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+func setCachedVersion(tx *sql.Tx, version int) error {
+	_, err := tx.Exec(`
+		INSERT INTO config (key, value) VALUES ('config_version', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, strconv.Itoa(version))
+	return err
 }
 
 func (s *Storage) GetRules() ([]CachedRule, error) {
 	query := `SELECT id, name, content, description, target_layer, category_id, category_name,
-		overridable, effective_start, effective_end, tags, triggers,
-		enforcement_mode, temporary_timeout_hours, version, cached_at FROM cached_rules`
+		priority_weight, overridable, effective_start, effective_end, tags, triggers,
+		enforcement_mode, temporary_timeout_hours, version, cached_at, rule_version FROM cached_rules`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -87,8 +135,8 @@ func (s *Storage) GetRules() ([]CachedRule, error) {
 		var overridable int
 		if err := rows.Scan(
 			&r.ID, &r.Name, &r.Content, &r.Description, &r.TargetLayer, &r.CategoryID, &r.CategoryName,
-			&overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
-			&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt,
+			&r.PriorityWeight, &overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
+			&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt, &r.RuleVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -103,8 +151,8 @@ func (s *Storage) GetRules() ([]CachedRule, error) {
 
 func (s *Storage) GetRulesByLayer(targetLayer string) ([]CachedRule, error) {
 	query := `SELECT id, name, content, description, target_layer, category_id, category_name,
-		overridable, effective_start, effective_end, tags, triggers,
-		enforcement_mode, temporary_timeout_hours, version, cached_at
+		priority_weight, overridable, effective_start, effective_end, tags, triggers,
+		enforcement_mode, temporary_timeout_hours, version, cached_at, rule_version
 		FROM cached_rules WHERE target_layer = ?`
 	rows, err := s.db.Query(query, targetLayer)
 	if err != nil {
@@ -120,8 +168,8 @@ func (s *Storage) GetRulesByLayer(targetLayer string) ([]CachedRule, error) {
 		var overridable int
 		if err := rows.Scan(
 			&r.ID, &r.Name, &r.Content, &r.Description, &r.TargetLayer, &r.CategoryID, &r.CategoryName,
-			&overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
-			&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt,
+			&r.PriorityWeight, &overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
+			&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt, &r.RuleVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -136,16 +184,16 @@ func (s *Storage) GetRulesByLayer(targetLayer string) ([]CachedRule, error) {
 
 func (s *Storage) GetRuleByID(id string) (CachedRule, error) {
 	query := `SELECT id, name, content, description, target_layer, category_id, category_name,
-		overridable, effective_start, effective_end, tags, triggers,
-		enforcement_mode, temporary_timeout_hours, version, cached_at FROM cached_rules WHERE id = ?`
+		priority_weight, overridable, effective_start, effective_end, tags, triggers,
+		enforcement_mode, temporary_timeout_hours, version, cached_at, rule_version FROM cached_rules WHERE id = ?`
 	var r CachedRule
 	var triggers, tags string
 	var cachedAt int64
 	var overridable int
 	err := s.db.QueryRow(query, id).Scan(
 		&r.ID, &r.Name, &r.Content, &r.Description, &r.TargetLayer, &r.CategoryID, &r.CategoryName,
-		&overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
-		&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt,
+		&r.PriorityWeight, &overridable, &r.EffectiveStart, &r.EffectiveEnd, &tags, &triggers,
+		&r.EnforcementMode, &r.TemporaryTimeoutHours, &r.Version, &cachedAt, &r.RuleVersion,
 	)
 	if err != nil {
 		return CachedRule{}, err
@@ -203,7 +251,39 @@ func (s *Storage) GetCategories() ([]CachedCategory, error) {
 	return categories, nil
 }
 
+// GetRuleVersions returns the approved version of each cached rule, as
+// reported in heartbeats
+func (s *Storage) GetRuleVersions() (map[string]int, error) {
+	rows, err := s.db.Query("SELECT id, rule_version FROM cached_rules")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var version int
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, err
+		}
+		versions[id] = version
+	}
+	return versions, rows.Err()
+}
+
+// GetCachedVersion returns the configuration version the cache is at, 0 when
+// nothing has been received yet
 func (s *Storage) GetCachedVersion() int {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM config WHERE key = 'config_version'").Scan(&value)
+	if err == nil {
+		if version, err := strconv.Atoi(value); err == nil {
+			return version
+		}
+	}
+
+	// Caches written before the version was stored keep it on each rule
 	var version int
 	_ = s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM cached_rules").Scan(&version)
 	return version
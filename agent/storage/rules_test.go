@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRules_SaveAndLoad(t *testing.T) {
+	s := newTestStorage(t)
+
+	end := int64(1900000000)
+	rules := []CachedRule{{
+		ID:                    "rule-1",
+		Name:                  "No Secrets",
+		Content:               "Never commit secrets",
+		TargetLayer:           "enterprise",
+		CategoryID:            "cat-security",
+		CategoryName:          "Security",
+		PriorityWeight:        7,
+		Overridable:           true,
+		EffectiveEnd:          &end,
+		Triggers:              json.RawMessage(`[]`),
+		EnforcementMode:       "warning",
+		TemporaryTimeoutHours: 4,
+		RuleVersion:           2,
+	}}
+	if err := s.SaveRules(rules, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetRulesByLayer("enterprise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(got))
+	}
+	r := got[0]
+	if r.PriorityWeight != 7 || !r.Overridable || r.CategoryName != "Security" {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+	if r.EffectiveEnd == nil || *r.EffectiveEnd != end || r.EffectiveStart != nil {
+		t.Errorf("unexpected effective dates: %v-%v", r.EffectiveStart, r.EffectiveEnd)
+	}
+	if r.EnforcementMode != "warning" || r.TemporaryTimeoutHours != 4 || r.Version != 3 {
+		t.Errorf("unexpected enforcement or version: %+v", r)
+	}
+	if s.GetCachedVersion() != 3 {
+		t.Errorf("expected cached version 3, got %d", s.GetCachedVersion())
+	}
+
+	versions, err := s.GetRuleVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions["rule-1"] != 2 {
+		t.Errorf("expected rule-1 at version 2, got %v", versions)
+	}
+}
+
+func TestCategories_SaveReplaces(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.SaveCategories([]CachedCategory{{ID: "a", Name: "A", DisplayOrder: 2}, {ID: "b", Name: "B", DisplayOrder: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCategories([]CachedCategory{{ID: "c", Name: "C", IsSystem: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "c" || !got[0].IsSystem {
+		t.Errorf("expected categories to be replaced, got %+v", got)
+	}
+}
+
+func TestRules_ApplyRuleChanges(t *testing.T) {
+	s := newTestStorage(t)
+
+	if s.GetCachedVersion() != 0 {
+		t.Errorf("expected version 0 for an empty cache, got %d", s.GetCachedVersion())
+	}
+
+	full := []CachedRule{
+		{ID: "a", Name: "A", Content: "a", TargetLayer: "project", Triggers: json.RawMessage(`[]`)},
+		{ID: "b", Name: "B", Content: "b", TargetLayer: "project", Triggers: json.RawMessage(`[]`)},
+	}
+	if err := s.SaveRules(full, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := []CachedRule{
+		{ID: "a", Name: "A", Content: "a2", TargetLayer: "project", Triggers: json.RawMessage(`[]`)},
+		{ID: "c", Name: "C", Content: "c", TargetLayer: "project", Triggers: json.RawMessage(`[]`)},
+	}
+	if err := s.ApplyRuleChanges(changed, []string{"b"}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := s.GetRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, r := range rules {
+		contents[r.ID] = r.Content
+	}
+	if len(contents) != 2 || contents["a"] != "a2" || contents["c"] != "c" {
+		t.Errorf("unexpected rules after delta: %v", contents)
+	}
+	if s.GetCachedVersion() != 2 {
+		t.Errorf("expected cached version 2, got %d", s.GetCachedVersion())
+	}
+
+	// Removing every rule still moves the version forward
+	if err := s.ApplyRuleChanges(nil, []string{"a", "c"}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if s.GetCachedVersion() != 3 {
+		t.Errorf("expected cached version 3, got %d", s.GetCachedVersion())
+	}
+}
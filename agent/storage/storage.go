@@ -3,6 +3,7 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"sync"
@@ -111,3 +112,91 @@ func (s *Storage) GetWSServerURL() (string, error) {
 	}
 	return url, err
 }
+
+// SaveOutputTargets saves the names of the output targets enabled for the agent's team
+func (s *Storage) SaveOutputTargets(names []string) error {
+	value, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO config (key, value) VALUES ('output_targets', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, string(value))
+	return err
+}
+
+// GetOutputTargets retrieves the enabled output target names, nil if the
+// server has not sent any
+func (s *Storage) GetOutputTargets() ([]string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM config WHERE key = 'output_targets'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(value), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// SaveAllowedCommands saves the names of the remote commands the server may
+// run on this machine
+func (s *Storage) SaveAllowedCommands(names []string) error {
+	value, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO config (key, value) VALUES ('allowed_commands', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, string(value))
+	return err
+}
+
+// SaveUpgradeRequired records why the server turned the agent away; an
+// empty reason clears it
+func (s *Storage) SaveUpgradeRequired(reason string) error {
+	if reason == "" {
+		_, err := s.db.Exec(`DELETE FROM config WHERE key = 'upgrade_required'`)
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO config (key, value) VALUES ('upgrade_required', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, reason)
+	return err
+}
+
+// GetUpgradeRequired returns why the server last turned the agent away,
+// empty if it did not
+func (s *Storage) GetUpgradeRequired() (string, error) {
+	var reason string
+	err := s.db.QueryRow(`SELECT value FROM config WHERE key = 'upgrade_required'`).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return reason, err
+}
+
+// GetAllowedCommands retrieves the allowed remote command names, nil if the
+// user never changed them
+func (s *Storage) GetAllowedCommands() ([]string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM config WHERE key = 'allowed_commands'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(value), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllowedCommands_NilUntilSaved(t *testing.T) {
+	s := newTestStorage(t)
+
+	names, err := s.GetAllowedCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names != nil {
+		t.Errorf("expected nil before saving, got %v", names)
+	}
+
+	// An empty list denies everything and must not read back as unset
+	if err := s.SaveAllowedCommands([]string{}); err != nil {
+		t.Fatal(err)
+	}
+	names, _ = s.GetAllowedCommands()
+	if names == nil || len(names) != 0 {
+		t.Errorf("expected empty list, got %#v", names)
+	}
+
+	if err := s.SaveAllowedCommands([]string{"diagnostics", "shutdown"}); err != nil {
+		t.Fatal(err)
+	}
+	names, _ = s.GetAllowedCommands()
+	if !reflect.DeepEqual(names, []string{"diagnostics", "shutdown"}) {
+		t.Errorf("unexpected allowed commands: %v", names)
+	}
+}
+
+func TestMachineID_StableOnceGenerated(t *testing.T) {
+	s := newTestStorage(t)
+
+	first, err := s.MachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == "" {
+		t.Fatal("expected a machine ID")
+	}
+	second, _ := s.MachineID()
+	if first != second {
+		t.Errorf("expected the same machine ID, got %s and %s", first, second)
+	}
+}
+
+func TestUpgradeRequired_SaveAndClear(t *testing.T) {
+	s := newTestStorage(t)
+
+	if reason, err := s.GetUpgradeRequired(); err != nil || reason != "" {
+		t.Fatalf("expected no reason before saving, got %q (%v)", reason, err)
+	}
+	if err := s.SaveUpgradeRequired("agent version 0.1.0 is older than the minimum 0.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if reason, _ := s.GetUpgradeRequired(); reason != "agent version 0.1.0 is older than the minimum 0.2.0" {
+		t.Errorf("unexpected reason: %q", reason)
+	}
+	if err := s.SaveUpgradeRequired(""); err != nil {
+		t.Fatal(err)
+	}
+	if reason, _ := s.GetUpgradeRequired(); reason != "" {
+		t.Errorf("expected the reason to be cleared, got %q", reason)
+	}
+}
@@ -12,5 +12,4 @@ import (
 	_ "github.com/google/uuid"
 	_ "github.com/gorilla/websocket"
 	_ "github.com/mattn/go-sqlite3"
-	_ "github.com/sergi/go-diff/diffmatchpatch"
 )
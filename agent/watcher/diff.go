@@ -0,0 +1,11 @@
+// agent/watcher/diff.go
+package watcher
+
+import "github.com/kamilrybacki/edictflow/pkg/diff"
+
+// UnifiedDiff returns a unified diff of a managed file between the content
+// the agent wrote and the user's modification. Returns an empty string when
+// the contents are identical.
+func UnifiedDiff(path, original, modified string) string {
+	return diff.Unified(path+" (original)", path+" (modified)", original, modified)
+}
@@ -0,0 +1,32 @@
+package watcher
+
+import "testing"
+
+func TestUnifiedDiff_IdenticalContent(t *testing.T) {
+	if diff := UnifiedDiff("CLAUDE.md", "a\nb\n", "a\nb\n"); diff != "" {
+		t.Errorf("expected empty diff, got %q", diff)
+	}
+}
+
+func TestUnifiedDiff_SingleLineChange(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	modified := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\n"
+
+	diff := UnifiedDiff("/repo/CLAUDE.md", original, modified)
+
+	expected := "--- /repo/CLAUDE.md (original)\n" +
+		"+++ /repo/CLAUDE.md (modified)\n" +
+		"@@ -2,7 +2,7 @@\n" +
+		" two\n" +
+		" three\n" +
+		" four\n" +
+		"-five\n" +
+		"+FIVE\n" +
+		" six\n" +
+		" seven\n" +
+		" eight\n"
+
+	if diff != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", diff, expected)
+	}
+}
@@ -2,6 +2,7 @@
 package watcher
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
@@ -18,10 +19,22 @@ type FileInfo struct {
 	Path         string
 	OriginalHash string
 	RuleID       string
+	// Snapshot is the last-known-good content that diffs are generated against
+	Snapshot []byte
+	// LastSeenHash is the most recently reported hash, so the same
+	// modification is not reported more than once
+	LastSeenHash string
 }
 
 type ChangeHandler func(path, ruleID, originalHash, newHash, diff string)
 
+// Editors that save in place truncate the file before writing it, so a
+// changed file is read again until two reads settleDelay apart agree
+const (
+	settleDelay = 20 * time.Millisecond
+	settleReads = 10
+)
+
 type Watcher struct {
 	fsWatcher        *fsnotify.Watcher
 	files            map[string]FileInfo
@@ -58,47 +71,52 @@ func (w *Watcher) OnChange(handler ChangeHandler) {
 	w.onChangeDetected = handler
 }
 
-func (w *Watcher) WatchProject(projectPath, ruleID string) error {
-	claudeMDPath := filepath.Join(projectPath, "CLAUDE.md")
-
-	if _, err := os.Stat(claudeMDPath); os.IsNotExist(err) {
-		// File doesn't exist yet, just watch the directory (fsnotify mode only)
+// WatchFile starts watching a managed file. A file that does not exist yet
+// is not watched; its directory is, in fsnotify mode.
+func (w *Watcher) WatchFile(path, ruleID string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(dir); err != nil {
+			return nil
+		}
 		if w.fsWatcher != nil {
-			return w.fsWatcher.Add(projectPath)
+			return w.fsWatcher.Add(dir)
 		}
 		return nil
 	}
 
-	hash, err := hashFile(claudeMDPath)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
+	hash := hashBytes(content)
 
 	w.filesMu.Lock()
-	w.files[claudeMDPath] = FileInfo{
-		Path:         claudeMDPath,
+	w.files[path] = FileInfo{
+		Path:         path,
 		OriginalHash: hash,
 		RuleID:       ruleID,
+		Snapshot:     content,
+		LastSeenHash: hash,
 	}
 	w.filesMu.Unlock()
 
 	// In polling mode, we don't need to add to fsWatcher
 	if w.fsWatcher != nil {
-		return w.fsWatcher.Add(claudeMDPath)
+		return w.fsWatcher.Add(path)
 	}
 	return nil
 }
 
-func (w *Watcher) UnwatchProject(projectPath string) {
-	claudeMDPath := filepath.Join(projectPath, "CLAUDE.md")
-
+// UnwatchFile stops watching a managed file
+func (w *Watcher) UnwatchFile(path string) {
 	if w.fsWatcher != nil {
-		_ = w.fsWatcher.Remove(claudeMDPath)
-		_ = w.fsWatcher.Remove(projectPath)
+		_ = w.fsWatcher.Remove(path)
+		_ = w.fsWatcher.Remove(filepath.Dir(path))
 	}
 
 	w.filesMu.Lock()
-	delete(w.files, claudeMDPath)
+	delete(w.files, path)
 	w.filesMu.Unlock()
 }
 
@@ -172,17 +190,22 @@ func (w *Watcher) pollFiles() {
 
 	for path, info := range files {
 		newHash, err := hashFile(path)
-		if err != nil {
+		if err != nil || newHash == info.LastSeenHash {
 			continue
 		}
 
+		modified, err := readSettled(path)
+		if err != nil {
+			continue
+		}
+		newHash = hashBytes(modified)
+		if newHash == info.LastSeenHash {
+			continue
+		}
 		if newHash != info.OriginalHash {
-			if w.onChangeDetected != nil {
-				// TODO: Generate actual diff
-				w.onChangeDetected(path, info.RuleID, info.OriginalHash, newHash, "")
-			}
-			updates = append(updates, hashUpdate{path: path, newHash: newHash})
+			w.reportChange(info, newHash, modified)
 		}
+		updates = append(updates, hashUpdate{path: path, newHash: newHash})
 	}
 
 	// Apply all updates in a single lock acquisition
@@ -190,7 +213,7 @@ func (w *Watcher) pollFiles() {
 		w.filesMu.Lock()
 		for _, u := range updates {
 			if fi, ok := w.files[u.path]; ok {
-				fi.OriginalHash = u.newHash
+				fi.LastSeenHash = u.newHash
 				w.files[u.path] = fi
 			}
 		}
@@ -204,9 +227,6 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 
 	path := event.Name
-	if filepath.Base(path) != "CLAUDE.md" {
-		return
-	}
 
 	w.filesMu.RLock()
 	info, exists := w.files[path]
@@ -217,18 +237,63 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 
 	newHash, err := hashFile(path)
+	if err != nil || newHash == info.LastSeenHash {
+		return
+	}
+
+	modified, err := readSettled(path)
 	if err != nil {
 		return
 	}
+	newHash = hashBytes(modified)
+	if newHash == info.LastSeenHash {
+		return
+	}
+
+	w.filesMu.Lock()
+	if fi, ok := w.files[path]; ok {
+		fi.LastSeenHash = newHash
+		w.files[path] = fi
+	}
+	w.filesMu.Unlock()
 
 	if newHash == info.OriginalHash {
 		return
 	}
 
-	if w.onChangeDetected != nil {
-		// TODO: Generate actual diff
-		w.onChangeDetected(path, info.RuleID, info.OriginalHash, newHash, "")
+	w.reportChange(info, newHash, modified)
+}
+
+// reportChange notifies the change handler with a unified diff of the
+// modified content against the last-known-good snapshot
+func (w *Watcher) reportChange(info FileInfo, newHash string, modified []byte) {
+	if w.onChangeDetected == nil {
+		return
+	}
+
+	diff := UnifiedDiff(info.Path, string(info.Snapshot), string(modified))
+	w.onChangeDetected(info.Path, info.RuleID, info.OriginalHash, newHash, diff)
+}
+
+// readSettled reads a file until two reads settleDelay apart return the same
+// content, so a write in progress is not mistaken for the saved file
+func readSettled(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < settleReads; i++ {
+		time.Sleep(settleDelay)
+		again, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(again, content) {
+			break
+		}
+		content = again
 	}
+	return content, nil
 }
 
 // hashFile computes SHA256 hash using streaming to avoid loading entire file into memory.
@@ -247,16 +312,120 @@ func hashFile(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func (w *Watcher) RevertFile(path string) error {
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Snapshot returns the last-known-good content of a watched file
+func (w *Watcher) Snapshot(path string) ([]byte, bool) {
 	w.filesMu.RLock()
+	defer w.filesMu.RUnlock()
+
 	info, exists := w.files[path]
+	if !exists {
+		return nil, false
+	}
+	return info.Snapshot, true
+}
+
+// Rebaseline makes the current on-disk content of a watched file the new
+// last-known-good snapshot, e.g. after a change was approved
+func (w *Watcher) Rebaseline(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	w.setBaseline(path, content)
+	return nil
+}
+
+// WriteFile atomically writes content to a file. If the file is watched, its
+// baseline is updated first so the write isn't reported as a change, and
+// restored if the write fails.
+func (w *Watcher) WriteFile(path string, content []byte) error {
+	w.filesMu.RLock()
+	previous, watched := w.files[path]
 	w.filesMu.RUnlock()
 
-	if !exists {
-		return nil
+	w.setBaseline(path, content)
+	if err := WriteFileAtomic(path, content); err != nil {
+		if watched {
+			w.restoreBaseline(previous)
+		}
+		return err
 	}
 
-	// This would need the original content stored somewhere
-	_ = info
+	// The rename replaced the watched inode, so re-register the path
+	if watched && w.fsWatcher != nil {
+		_ = w.fsWatcher.Remove(path)
+		return w.fsWatcher.Add(path)
+	}
 	return nil
 }
+
+// RevertFile atomically restores a watched file to the given original content
+// without reporting the restore as a change.
+func (w *Watcher) RevertFile(path string, original []byte) error {
+	return w.WriteFile(path, original)
+}
+
+func (w *Watcher) setBaseline(path string, content []byte) {
+	hash := hashBytes(content)
+
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	info, exists := w.files[path]
+	if !exists {
+		return
+	}
+	info.OriginalHash = hash
+	info.LastSeenHash = hash
+	info.Snapshot = content
+	w.files[path] = info
+}
+
+// restoreBaseline puts back a watched file's baseline from before a failed
+// write, unless the file stopped being watched meanwhile
+func (w *Watcher) restoreBaseline(info FileInfo) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	if _, exists := w.files[info.Path]; exists {
+		w.files[info.Path] = info
+	}
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers never observe a partially written file.
+// The existing file mode is preserved.
+func WriteFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,178 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordedChange struct {
+	path, originalHash, newHash, diff string
+}
+
+func newPollingWatcher(t *testing.T) (*Watcher, string, *[]recordedChange) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"), []byte("# Rules\nkeep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWithPolling(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []recordedChange
+	w.OnChange(func(path, ruleID, originalHash, newHash, diff string) {
+		changes = append(changes, recordedChange{path, originalHash, newHash, diff})
+	})
+
+	path := filepath.Join(dir, "CLAUDE.md")
+	if err := w.WatchFile(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	return w, path, &changes
+}
+
+func TestWatcher_ReportsChangeWithDiffOnce(t *testing.T) {
+	w, path, changes := newPollingWatcher(t)
+
+	if err := os.WriteFile(path, []byte("# Rules\nchanged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+	w.pollFiles()
+
+	if len(*changes) != 1 {
+		t.Fatalf("expected 1 reported change, got %d", len(*changes))
+	}
+	c := (*changes)[0]
+	if !strings.Contains(c.diff, "-keep\n+changed\n") {
+		t.Errorf("expected diff of modification, got:\n%s", c.diff)
+	}
+	if c.originalHash != hashBytes([]byte("# Rules\nkeep\n")) {
+		t.Error("expected original hash of last-known-good content")
+	}
+}
+
+func TestWatcher_WaitsForInPlaceSaveToSettle(t *testing.T) {
+	w, path, changes := newPollingWatcher(t)
+
+	// An editor saving in place truncates the file before writing it
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		time.Sleep(settleDelay / 2)
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err == nil {
+			_, err = f.WriteString("# Rules\nsaved\n")
+			f.Close()
+		}
+		written <- err
+	}()
+	w.pollFiles()
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+
+	if len(*changes) != 1 {
+		t.Fatalf("expected 1 reported change, got %d", len(*changes))
+	}
+	c := (*changes)[0]
+	if c.newHash != hashBytes([]byte("# Rules\nsaved\n")) || !strings.Contains(c.diff, "-keep\n+saved\n") {
+		t.Errorf("expected the saved content to be reported, got %s:\n%s", c.newHash, c.diff)
+	}
+}
+
+func TestWatcher_RevertFileRestoresWithoutReporting(t *testing.T) {
+	w, path, changes := newPollingWatcher(t)
+
+	if err := os.WriteFile(path, []byte("# Rules\nchanged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+
+	original, ok := w.Snapshot(path)
+	if !ok {
+		t.Fatal("expected snapshot for watched file")
+	}
+	if err := w.RevertFile(path, original); err != nil {
+		t.Fatalf("RevertFile failed: %v", err)
+	}
+	w.pollFiles()
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "# Rules\nkeep\n" {
+		t.Errorf("expected original content restored, got %q", content)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode preserved, got %v", info.Mode().Perm())
+	}
+	if len(*changes) != 1 {
+		t.Errorf("expected revert not to be reported, got %d changes", len(*changes))
+	}
+}
+
+func TestWatcher_RebaselineAcceptsCurrentContent(t *testing.T) {
+	w, path, changes := newPollingWatcher(t)
+
+	if err := os.WriteFile(path, []byte("# Rules\napproved\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+	if err := w.Rebaseline(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("# Rules\napproved\nmore\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+
+	if len(*changes) != 2 {
+		t.Fatalf("expected 2 reported changes, got %d", len(*changes))
+	}
+	if diff := (*changes)[1].diff; !strings.Contains(diff, " approved\n+more\n") {
+		t.Errorf("expected diff against approved baseline, got:\n%s", diff)
+	}
+}
+
+func TestWatcher_FailedWriteKeepsBaseline(t *testing.T) {
+	w, path, changes := newPollingWatcher(t)
+
+	dir := filepath.Dir(path)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile(path, []byte("# Rules\nsynced\n")); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if snapshot, _ := w.Snapshot(path); string(snapshot) != "# Rules\nkeep\n" {
+		t.Fatalf("expected the baseline to be restored, got %q", snapshot)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("# Rules\nedited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.pollFiles()
+
+	if len(*changes) != 1 {
+		t.Fatalf("expected 1 reported change, got %d", len(*changes))
+	}
+	if diff := (*changes)[0].diff; !strings.Contains(diff, "-keep\n+edited\n") {
+		t.Errorf("expected diff against the baseline from before the failed write, got:\n%s", diff)
+	}
+}
@@ -16,6 +16,16 @@ import (
 // ErrBufferFull is returned when the send buffer is full and cannot accept more messages
 var ErrBufferFull = errors.New("send buffer full, message dropped")
 
+// ErrNotConnected is returned when sending while there is no open connection
+var ErrNotConnected = errors.New("not connected")
+
+// ErrUnauthorized is returned when the server rejects the access token during the handshake
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden is returned when the server refuses the agent during the
+// handshake, for example because it was revoked
+var ErrForbidden = errors.New("forbidden")
+
 type State int
 
 const (
@@ -29,6 +39,8 @@ type MessageHandler func(Message)
 type Client struct {
 	serverURL      string
 	token          string
+	agentID        string
+	tokenMu        sync.RWMutex
 	conn           *websocket.Conn
 	state          State
 	stateMu        sync.RWMutex
@@ -38,6 +50,8 @@ type Client struct {
 	handlersLock   sync.RWMutex
 	onConnect      func()
 	onDisconnect   func()
+	onUnauthorized func() error
+	onForbidden    func()
 	reconnectDelay time.Duration
 	maxReconnect   time.Duration
 }
@@ -61,8 +75,23 @@ func NewClient(serverURL, token string) *Client {
 	}
 }
 
+// SetToken replaces the access token used for subsequent connections
 func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
 	c.token = token
+	c.tokenMu.Unlock()
+}
+
+// SetAgentID sets the agent ID sent when connecting, which the server checks
+// against its registry
+func (c *Client) SetAgentID(agentID string) {
+	c.agentID = agentID
+}
+
+func (c *Client) getToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.token
 }
 
 func (c *Client) OnConnect(fn func()) {
@@ -73,6 +102,19 @@ func (c *Client) OnDisconnect(fn func()) {
 	c.onDisconnect = fn
 }
 
+// OnUnauthorized registers a callback invoked when the handshake is rejected
+// with 401. It should obtain a new token and call SetToken; if it succeeds
+// the connection is retried immediately.
+func (c *Client) OnUnauthorized(fn func() error) {
+	c.onUnauthorized = fn
+}
+
+// OnForbidden registers a callback invoked when the handshake is rejected
+// with 403. The client stops reconnecting.
+func (c *Client) OnForbidden(fn func()) {
+	c.onForbidden = fn
+}
+
 func (c *Client) OnMessage(msgType MessageType, handler MessageHandler) {
 	c.handlersLock.Lock()
 	c.handlers[msgType] = handler
@@ -85,19 +127,36 @@ func (c *Client) State() State {
 	return c.state
 }
 
+// dialer offers compression; the server only compresses what it sends once
+// the hello advertises it
+var dialer = &websocket.Dialer{
+	Proxy:             http.ProxyFromEnvironment,
+	HandshakeTimeout:  45 * time.Second,
+	EnableCompression: true,
+}
+
 func (c *Client) Connect() error {
 	c.stateMu.Lock()
 	c.state = StateConnecting
 	c.stateMu.Unlock()
 
 	header := http.Header{}
-	header.Set("Authorization", "Bearer "+c.token)
+	header.Set("Authorization", "Bearer "+c.getToken())
+	if c.agentID != "" {
+		header.Set("X-Agent-ID", c.agentID)
+	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(c.serverURL, header)
+	conn, resp, err := dialer.Dial(c.serverURL, header)
 	if err != nil {
 		c.stateMu.Lock()
 		c.state = StateDisconnected
 		c.stateMu.Unlock()
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return ErrUnauthorized
+		}
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return ErrForbidden
+		}
 		return err
 	}
 
@@ -127,6 +186,11 @@ func (c *Client) Connect() error {
 
 func (c *Client) Close() {
 	if c.conn != nil {
+		// A close frame tells the server the agent left on purpose rather
+		// than vanished
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(time.Second))
 		c.conn.Close()
 	}
 	c.stateMu.Lock()
@@ -134,7 +198,12 @@ func (c *Client) Close() {
 	c.stateMu.Unlock()
 }
 
+// Send queues a message for the write pump. Messages are rejected while
+// disconnected so they can't be written to a later connection out of order.
 func (c *Client) Send(msg Message) error {
+	if c.State() != StateConnected {
+		return ErrNotConnected
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -221,6 +290,10 @@ func (c *Client) ConnectWithRetry() {
 // ConnectWithContext attempts to maintain a persistent connection with exponential backoff.
 // The context can be cancelled to gracefully stop the reconnection loop.
 func (c *Client) ConnectWithContext(ctx context.Context) {
+	// Only one immediate retry per rejection, so a token the server keeps
+	// refusing falls back to the normal backoff
+	refreshed := false
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -230,6 +303,22 @@ func (c *Client) ConnectWithContext(ctx context.Context) {
 		}
 
 		if err := c.Connect(); err != nil {
+			if errors.Is(err, ErrForbidden) {
+				log.Println("Connection refused by the server, not reconnecting")
+				if c.onForbidden != nil {
+					c.onForbidden()
+				}
+				return
+			}
+			if errors.Is(err, ErrUnauthorized) && c.onUnauthorized != nil && !refreshed {
+				refreshed = true
+				refreshErr := c.onUnauthorized()
+				if refreshErr == nil {
+					log.Println("Access token refreshed, reconnecting")
+					continue
+				}
+				log.Printf("Token refresh failed: %v", refreshErr)
+			}
 			log.Printf("Connection failed: %v, retrying in %v", err, c.reconnectDelay)
 			select {
 			case <-ctx.Done():
@@ -245,6 +334,7 @@ func (c *Client) ConnectWithContext(ctx context.Context) {
 
 		// Reset delay after successful connection
 		c.reconnectDelay = time.Second
+		refreshed = false
 
 		// Wait for disconnection or context cancellation
 		select {
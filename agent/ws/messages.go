@@ -10,18 +10,35 @@ import (
 
 type MessageType string
 
+// ProtocolVersion is the version of the agent protocol this agent speaks
+const ProtocolVersion = 1
+
+// Capabilities the agent advertises in its hello
+const (
+	CapabilityDelta       = "delta"
+	CapabilityRevert      = "revert"
+	CapabilityTargets     = "targets"
+	CapabilityCompression = "compression"
+)
+
 const (
 	// Server -> Agent
+	TypeWelcome          MessageType = "welcome"
+	TypeUpgradeRequired  MessageType = "upgrade_required"
 	TypeConfigUpdate     MessageType = "config_update"
-	TypeSyncRequest      MessageType = "sync_request"
 	TypeAck              MessageType = "ack"
+	TypeNack             MessageType = "nack"
 	TypeChangeApproved   MessageType = "change_approved"
 	TypeChangeRejected   MessageType = "change_rejected"
 	TypeExceptionGranted MessageType = "exception_granted"
 	TypeExceptionDenied  MessageType = "exception_denied"
+	TypeCommand          MessageType = "command"
+	TypeAgentRevoked     MessageType = "agent_revoked"
 
 	// Agent -> Server
+	TypeHello            MessageType = "hello"
 	TypeHeartbeat        MessageType = "heartbeat"
+	TypeSyncRequest      MessageType = "sync_request"
 	TypeDriftReport      MessageType = "drift_report"
 	TypeContextDetected  MessageType = "context_detected"
 	TypeSyncComplete     MessageType = "sync_complete"
@@ -29,6 +46,7 @@ const (
 	TypeChangeUpdated    MessageType = "change_updated"
 	TypeExceptionRequest MessageType = "exception_request"
 	TypeRevertComplete   MessageType = "revert_complete"
+	TypeCommandResult    MessageType = "command_result"
 )
 
 type Message struct {
@@ -51,6 +69,29 @@ func NewMessage(msgType MessageType, payload interface{}) (Message, error) {
 	}, nil
 }
 
+// HelloPayload is the first message after connecting, telling the server
+// which message shapes the agent understands
+type HelloPayload struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	AgentVersion    string   `json:"agent_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// WelcomePayload answers the hello with the protocol version and the
+// capabilities the server will use
+type WelcomePayload struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// UpgradeRequiredPayload tells the agent it is older than the server
+// accepts; the server closes the connection right after
+type UpgradeRequiredPayload struct {
+	MinVersion   string `json:"min_version"`
+	AgentVersion string `json:"agent_version"`
+	Reason       string `json:"reason"`
+}
+
 type HeartbeatPayload struct {
 	Status         string   `json:"status"`
 	CachedVersion  int      `json:"cached_version"`
@@ -61,27 +102,98 @@ type HeartbeatPayload struct {
 	Version        string   `json:"version,omitempty"`
 	OS             string   `json:"os,omitempty"`
 	ConnectedAt    string   `json:"connected_at,omitempty"`
+	// RuleVersions maps the cached rules to the versions applied
+	RuleVersions map[string]int `json:"rule_versions"`
+}
+
+// SyncRequestPayload asks for the configuration changes since the cached
+// version; 0 requests a full snapshot
+type SyncRequestPayload struct {
+	SinceVersion int `json:"since_version"`
+}
+
+type ContextDetectedPayload struct {
+	ProjectPath     string   `json:"project_path"`
+	DetectedContext []string `json:"detected_context"`
+	DetectedTags    []string `json:"detected_tags"`
+	// Repository is the project's git remote as host/owner/name
+	Repository string `json:"repository,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+}
+
+// DriftFile is the state of one managed file in a drift report
+type DriftFile struct {
+	Path         string   `json:"path"`
+	Level        string   `json:"level"`
+	Status       string   `json:"status"`
+	ExpectedHash string   `json:"expected_hash"`
+	ActualHash   string   `json:"actual_hash"`
+	RuleIDs      []string `json:"rule_ids"`
+}
+
+// DriftReportPayload lists every managed file and whether it matches the
+// rules the agent has cached
+type DriftReportPayload struct {
+	Files         []DriftFile `json:"files"`
+	CachedVersion int         `json:"cached_version"`
 }
 
+// ConfigUpdatePayload moves the cache to a version of the team's
+// configuration. A delta only carries the rules changed since SinceVersion
+// and the IDs of the removed ones; otherwise Rules is the complete rule set.
 type ConfigUpdatePayload struct {
-	Rules   []RulePayload `json:"rules"`
-	Version int           `json:"version"`
+	Rules          []RulePayload `json:"rules"`
+	Version        int           `json:"version"`
+	Delta          bool          `json:"delta,omitempty"`
+	SinceVersion   int           `json:"since_version,omitempty"`
+	RemovedRuleIDs []string      `json:"removed_rule_ids,omitempty"`
+	// Categories order the sections of managed files. Absent when the server
+	// does not send them, leaving the cached categories in place.
+	Categories []CategoryPayload `json:"categories,omitempty"`
+	// Targets lists the output targets enabled for the agent's team. Absent
+	// when the server does not send them, leaving the current targets in place.
+	Targets []string `json:"targets,omitempty"`
 }
 
+// RulePayload is a rule resolved for the agent's team. Effective dates are
+// Unix seconds.
 type RulePayload struct {
 	ID                    string          `json:"id"`
 	Name                  string          `json:"name"`
 	Content               string          `json:"content"`
 	TargetLayer           string          `json:"target_layer"`
 	Triggers              json.RawMessage `json:"triggers"`
+	CategoryID            string          `json:"category_id,omitempty"`
+	CategoryName          string          `json:"category_name,omitempty"`
+	PriorityWeight        int             `json:"priority_weight"`
+	Overridable           bool            `json:"overridable"`
+	EffectiveStart        *int64          `json:"effective_start,omitempty"`
+	EffectiveEnd          *int64          `json:"effective_end,omitempty"`
 	EnforcementMode       string          `json:"enforcement_mode"`
 	TemporaryTimeoutHours int             `json:"temporary_timeout_hours"`
+	// RuleVersion is the rule's approved version on the server
+	RuleVersion int `json:"rule_version,omitempty"`
+}
+
+type CategoryPayload struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	IsSystem     bool   `json:"is_system"`
+	DisplayOrder int    `json:"display_order"`
 }
 
 type AckPayload struct {
 	RefID string `json:"ref_id"`
 }
 
+// NackPayload reports that a message could not be processed. Retry tells
+// whether sending it again may succeed.
+type NackPayload struct {
+	RefID  string `json:"ref_id"`
+	Reason string `json:"reason"`
+	Retry  bool   `json:"retry"`
+}
+
 type ChangeDetectedPayload struct {
 	RuleID          string `json:"rule_id"`
 	FilePath        string `json:"file_path"`
@@ -94,10 +206,37 @@ type ChangeDetectedPayload struct {
 type ChangeApprovedPayload struct {
 	ChangeID string `json:"change_id"`
 	RuleID   string `json:"rule_id"`
+	FilePath string `json:"file_path,omitempty"`
 }
 
 type ChangeRejectedPayload struct {
 	ChangeID     string `json:"change_id"`
 	RuleID       string `json:"rule_id"`
+	FilePath     string `json:"file_path,omitempty"`
 	RevertToHash string `json:"revert_to_hash"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Reasons a change_rejected message is sent
+const (
+	RevertReasonRejected = "rejected"
+	RevertReasonExpired  = "expired"
+)
+
+type RevertCompletePayload struct {
+	ChangeID string `json:"change_id"`
+}
+
+// CommandPayload asks the agent to run a remote command
+type CommandPayload struct {
+	CommandID string `json:"command_id"`
+	Command   string `json:"command"`
+}
+
+// CommandResultPayload answers a command. Error is set when the command
+// failed or is not allowed on this machine.
+type CommandResultPayload struct {
+	CommandID string          `json:"command_id"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
 }
@@ -0,0 +1,160 @@
+// Package diff produces unified diffs of text. The agent uses it for files
+// users modified and the server for rule revisions.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// ContextLines is the number of unchanged lines shown around each change
+	ContextLines = 3
+	// MaxBytes caps the size of a generated diff so large rewrites don't
+	// flood the agent's message queue or the server's tables
+	MaxBytes = 64 * 1024
+)
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns a unified diff between original and modified content with
+// ContextLines lines of context, capped at MaxBytes. The labels name the two
+// sides in the header. Returns an empty string when the contents are
+// identical.
+func Unified(originalLabel, modifiedLabel, original, modified string) string {
+	if original == modified {
+		return ""
+	}
+
+	lines := diffLines(original, modified)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n", originalLabel)
+	fmt.Fprintf(&b, "+++ %s\n", modifiedLabel)
+	writeHunks(&b, lines, ContextLines)
+
+	return capDiff(b.String(), MaxBytes)
+}
+
+// diffLines computes a line-level diff using diffmatchpatch's line mode
+func diffLines(original, modified string) []diffLine {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(original, modified)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	var lines []diffLine
+	for _, d := range diffs {
+		var op byte
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			op = ' '
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range splitLines(d.Text) {
+			lines = append(lines, diffLine{op: op, text: text})
+		}
+	}
+	return lines
+}
+
+// splitLines splits text into lines, dropping the empty element after a trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// writeHunks groups changed lines into hunks, merging changes whose
+// separating run of unchanged lines is short enough to share context
+func writeHunks(b *strings.Builder, lines []diffLine, context int) {
+	// Line numbers (0-based) in the original and modified file before each line
+	oldNo := make([]int, len(lines)+1)
+	newNo := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if l.op != '+' {
+			oldNo[i+1]++
+		}
+		if l.op != '-' {
+			newNo[i+1]++
+		}
+	}
+
+	i := 0
+	for i < len(lines) {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while the next change is within 2*context lines
+		last := i
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j].op != ' ' {
+				last = j
+				continue
+			}
+			if j-last > 2*context {
+				break
+			}
+		}
+
+		end := last + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		oldCount := oldNo[end] - oldNo[start]
+		newCount := newNo[end] - newNo[start]
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldNo[start], oldCount), hunkRange(newNo[start], newCount))
+		for _, l := range lines[start:end] {
+			b.WriteByte(l.op)
+			b.WriteString(l.text)
+			b.WriteByte('\n')
+		}
+
+		i = end
+	}
+}
+
+// hunkRange formats a hunk range; an empty range points at the preceding line
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// capDiff truncates a diff on a line boundary so it fits within maxBytes
+func capDiff(diff string, maxBytes int) string {
+	if len(diff) <= maxBytes {
+		return diff
+	}
+
+	marker := fmt.Sprintf("... diff truncated (%d bytes total)\n", len(diff))
+	cut := maxBytes - len(marker)
+	if cut < 0 {
+		cut = 0
+	}
+	if idx := strings.LastIndexByte(diff[:cut], '\n'); idx >= 0 {
+		cut = idx + 1
+	}
+	return diff[:cut] + marker
+}
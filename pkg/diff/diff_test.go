@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified_IdenticalContent(t *testing.T) {
+	if diff := Unified("a", "b", "a\nb\n", "a\nb\n"); diff != "" {
+		t.Errorf("expected empty diff, got %q", diff)
+	}
+}
+
+func TestUnified_SingleLineChange(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	modified := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\n"
+
+	diff := Unified("v1", "v2", original, modified)
+
+	expected := "--- v1\n" +
+		"+++ v2\n" +
+		"@@ -2,7 +2,7 @@\n" +
+		" two\n" +
+		" three\n" +
+		" four\n" +
+		"-five\n" +
+		"+FIVE\n" +
+		" six\n" +
+		" seven\n" +
+		" eight\n"
+
+	if diff != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", diff, expected)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var orig, mod []string
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1)
+		orig = append(orig, line)
+		mod = append(mod, line)
+	}
+	mod[1] = "changed-early"
+	mod[18] = "changed-late"
+
+	diff := Unified("a", "b", strings.Join(orig, "\n")+"\n", strings.Join(mod, "\n")+"\n")
+
+	if got := strings.Count(diff, "@@ -"); got != 2 {
+		t.Errorf("expected 2 hunks, got %d:\n%s", got, diff)
+	}
+	if !strings.Contains(diff, "@@ -1,5 +1,5 @@") {
+		t.Errorf("expected first hunk header, got:\n%s", diff)
+	}
+	if !strings.Contains(diff, "@@ -16,5 +16,5 @@") {
+		t.Errorf("expected second hunk header, got:\n%s", diff)
+	}
+}
+
+func TestUnified_AppendToEmptyFile(t *testing.T) {
+	diff := Unified("a", "b", "", "new line\n")
+
+	if !strings.Contains(diff, "@@ -0,0 +1 @@\n+new line\n") {
+		t.Errorf("unexpected diff for new content:\n%s", diff)
+	}
+}
+
+func TestUnified_IsCapped(t *testing.T) {
+	var b strings.Builder
+	for b.Len() < MaxBytes*2 {
+		b.WriteString("a long line of added content that keeps growing\n")
+	}
+
+	diff := Unified("a", "b", "", b.String())
+
+	if len(diff) > MaxBytes {
+		t.Errorf("expected diff capped at %d bytes, got %d", MaxBytes, len(diff))
+	}
+	if !strings.Contains(diff, "diff truncated") {
+		t.Error("expected truncation marker")
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/kamilrybacki/edictflow/pkg
 
 go 1.21.5
+
+require (
+	github.com/bmatcuk/doublestar/v4 v4.10.0
+	github.com/sergi/go-diff v1.4.0
+)
@@ -1,8 +1,10 @@
-// Package markdown provides utilities for managing CLAUDE.md files
-// with managed sections that can be rendered from rules.
+// Package markdown provides utilities for managing CLAUDE.md and other AI
+// assistant instruction files with managed sections rendered from rules.
 package markdown
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sort"
 	"strings"
@@ -14,6 +16,59 @@ const (
 	ManagedSectionEnd   = "<!-- END EDICTFLOW -->"
 )
 
+// Format describes how a managed section is written into one kind of file
+type Format struct {
+	// CommentStart and CommentEnd enclose the marker and checksum lines
+	CommentStart string
+	CommentEnd   string
+	// Preamble starts a file created by edictflow, e.g. front matter the
+	// assistant requires before any content
+	Preamble string
+}
+
+// DefaultFormat is used for CLAUDE.md and other plain Markdown files. Its
+// markers are ManagedSectionStart and ManagedSectionEnd.
+var DefaultFormat = Format{CommentStart: "<!--", CommentEnd: "-->"}
+
+// StartMarker returns the line that opens the managed section
+func (f Format) StartMarker() string {
+	return f.comment("MANAGED BY EDICTFLOW - DO NOT EDIT")
+}
+
+// EndMarker returns the line that closes the managed section
+func (f Format) EndMarker() string {
+	return f.comment("END EDICTFLOW")
+}
+
+func (f Format) comment(text string) string {
+	return f.CommentStart + " " + text + " " + f.CommentEnd
+}
+
+// checksumPrefix starts the line recording a hash of the rendered section,
+// so a hand-edited section can be told apart from one rendered from
+// an older set of rules
+func (f Format) checksumPrefix() string {
+	return f.CommentStart + " edictflow:checksum sha256:"
+}
+
+func (f Format) checksumSuffix() string {
+	return " " + f.CommentEnd
+}
+
+// SectionStatus describes how a file's managed section compares to the expected one
+type SectionStatus string
+
+const (
+	// SectionOK means the managed section matches the expected content
+	SectionOK SectionStatus = "ok"
+	// SectionMissing means rules apply but the file has no complete managed section
+	SectionMissing SectionStatus = "missing_markers"
+	// SectionStale means the section is intact but was rendered from different rules
+	SectionStale SectionStatus = "stale"
+	// SectionTampered means the section was edited after it was rendered
+	SectionTampered SectionStatus = "tampered"
+)
+
 // Rule represents a rule to be rendered in the managed section.
 // This is a minimal interface that both server domain.Rule and agent storage.CachedRule
 // can be converted to.
@@ -50,8 +105,14 @@ func (r Rule) IsEffective() bool {
 
 // RenderManagedSection generates the managed CLAUDE.md section from rules.
 // Rules are grouped by category, sorted by DisplayOrder then name.
-// Within each category, rules are sorted by priority weight (descending).
+// Within each category, rules are sorted by priority weight (descending);
+// rules of equal weight keep the order they were passed in.
 func RenderManagedSection(rules []Rule, categories []Category) string {
+	return DefaultFormat.RenderManagedSection(rules, categories)
+}
+
+// RenderManagedSection generates the managed section from rules in this format
+func (f Format) RenderManagedSection(rules []Rule, categories []Category) string {
 	if len(rules) == 0 {
 		return ""
 	}
@@ -96,7 +157,7 @@ func RenderManagedSection(rules []Rule, categories []Category) string {
 	})
 
 	var sections []string
-	sections = append(sections, ManagedSectionStart)
+	sections = append(sections, f.StartMarker())
 
 	for _, catID := range sortedCatIDs {
 		catRules := rulesByCategory[catID]
@@ -108,34 +169,130 @@ func RenderManagedSection(rules []Rule, categories []Category) string {
 		}
 
 		// Sort rules by priority weight within category (descending)
-		sort.Slice(catRules, func(i, j int) bool {
+		sort.SliceStable(catRules, func(i, j int) bool {
 			return catRules[i].PriorityWeight > catRules[j].PriorityWeight
 		})
 
 		sections = append(sections, fmt.Sprintf("\n## %s\n", catName))
 
 		for _, r := range catRules {
-			levelTag := fmt.Sprintf("[%s]", toTitleCase(r.TargetLayer))
-			overridableTag := ""
-			if r.Overridable {
-				overridableTag = " (overridable)"
-			}
+			sections = append(sections, r.header()+"\n"+r.Content)
+		}
+	}
 
-			sections = append(sections, fmt.Sprintf("%s **%s**%s\n%s", levelTag, r.Name, overridableTag, r.Content))
+	content := strings.Join(sections, "\n")
+	return content + "\n\n" + f.checksumLine(content) + "\n" + f.EndMarker()
+}
+
+// RenderManagedSectionWithCategories renders rules that carry their category's
+// name, as agents receive them. Categories are ordered by the display order of
+// the known categories, with categories missing from known listed last. When
+// known is empty, categories are sorted by name. Rules without a category
+// name are listed as Uncategorized.
+func (f Format) RenderManagedSectionWithCategories(rules []Rule, known []Category) string {
+	categoryNames := make(map[string]string)
+	grouped := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.CategoryName == "" {
+			r.CategoryID = ""
+			r.CategoryName = "Uncategorized"
+		}
+		categoryNames[r.CategoryID] = r.CategoryName
+		grouped = append(grouped, r)
+	}
+
+	knownByID := make(map[string]Category)
+	for _, c := range known {
+		knownByID[c.ID] = c
+	}
+	var categories []Category
+	for catID, catName := range categoryNames {
+		category := Category{ID: catID, Name: catName}
+		if c, ok := knownByID[catID]; ok && catID != "" {
+			category.Name = c.Name
+			category.DisplayOrder = c.DisplayOrder
+		} else if len(known) > 0 {
+			category.DisplayOrder = 9999 // Sort last
 		}
+		categories = append(categories, category)
+	}
+
+	return f.RenderManagedSection(grouped, categories)
+}
+
+// header is the line a rule starts with in the managed section
+func (r Rule) header() string {
+	header := fmt.Sprintf("[%s] **%s**", toTitleCase(r.TargetLayer), r.Name)
+	if r.Overridable {
+		header += " (overridable)"
+	}
+	return header
+}
+
+// ChangedRule returns the index of the rule whose part of the expected
+// managed section was edited in fileContent. The first line that differs
+// from the expected section belongs to the rule rendered above it, or to the
+// first rule when the edit comes before any rule. ok is false when the
+// section is unchanged or contains none of the rules.
+func ChangedRule(fileContent, expectedManaged string, rules []Rule) (index int, ok bool) {
+	return DefaultFormat.ChangedRule(fileContent, expectedManaged, rules)
+}
+
+// ChangedRule returns the index of the rule whose part of the expected
+// managed section was edited in fileContent, in this format.
+func (f Format) ChangedRule(fileContent, expectedManaged string, rules []Rule) (index int, ok bool) {
+	actual, _ := f.ExtractManagedSection(fileContent)
+	if actual == expectedManaged {
+		return -1, false
 	}
 
-	sections = append(sections, "\n"+ManagedSectionEnd)
+	headers := make(map[string]int, len(rules))
+	for i := len(rules) - 1; i >= 0; i-- {
+		headers[rules[i].header()] = i
+	}
+
+	expectedLines := strings.Split(expectedManaged, "\n")
+	actualLines := strings.Split(actual, "\n")
+	changed := 0
+	for changed < len(expectedLines) && changed < len(actualLines) && expectedLines[changed] == actualLines[changed] {
+		changed++
+	}
+
+	index = -1
+	for i, line := range expectedLines {
+		ruleIndex, isHeader := headers[line]
+		if !isHeader {
+			continue
+		}
+		if i > changed && index != -1 {
+			break
+		}
+		index = ruleIndex
+	}
+	return index, index != -1
+}
 
-	return strings.Join(sections, "\n")
+func (f Format) checksumLine(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return f.checksumPrefix() + hex.EncodeToString(sum[:]) + f.checksumSuffix()
 }
 
 // MergeWithExisting combines managed section with existing file content.
 // If no managed section exists, it appends at the end.
 // If a managed section exists, it replaces it.
 func MergeWithExisting(existingContent, managedSection string) string {
-	startIdx := strings.Index(existingContent, ManagedSectionStart)
-	endIdx := strings.Index(existingContent, ManagedSectionEnd)
+	return DefaultFormat.MergeWithExisting(existingContent, managedSection)
+}
+
+// MergeWithExisting combines the managed section with existing file content.
+// A new file starts with the format's preamble.
+func (f Format) MergeWithExisting(existingContent, managedSection string) string {
+	if existingContent == "" {
+		return f.Preamble + managedSection
+	}
+
+	startIdx := strings.Index(existingContent, f.StartMarker())
+	endIdx := strings.Index(existingContent, f.EndMarker())
 
 	if startIdx == -1 {
 		// No existing managed section - append at end
@@ -149,7 +306,7 @@ func MergeWithExisting(existingContent, managedSection string) string {
 	before := existingContent[:startIdx]
 	after := ""
 	if endIdx != -1 {
-		after = existingContent[endIdx+len(ManagedSectionEnd):]
+		after = existingContent[endIdx+len(f.EndMarker()):]
 	}
 
 	return before + managedSection + after
@@ -157,8 +314,13 @@ func MergeWithExisting(existingContent, managedSection string) string {
 
 // ExtractManualContent returns content outside the managed section.
 func ExtractManualContent(content string) (before, after string) {
-	startIdx := strings.Index(content, ManagedSectionStart)
-	endIdx := strings.Index(content, ManagedSectionEnd)
+	return DefaultFormat.ExtractManualContent(content)
+}
+
+// ExtractManualContent returns content outside the managed section.
+func (f Format) ExtractManualContent(content string) (before, after string) {
+	startIdx := strings.Index(content, f.StartMarker())
+	endIdx := strings.Index(content, f.EndMarker())
 
 	if startIdx == -1 {
 		return content, ""
@@ -166,7 +328,7 @@ func ExtractManualContent(content string) (before, after string) {
 
 	before = content[:startIdx]
 	if endIdx != -1 {
-		after = content[endIdx+len(ManagedSectionEnd):]
+		after = content[endIdx+len(f.EndMarker()):]
 	}
 
 	return before, after
@@ -174,17 +336,79 @@ func ExtractManualContent(content string) (before, after string) {
 
 // DetectTampering checks if the managed section was modified.
 func DetectTampering(fileContent, expectedManaged string) bool {
-	startIdx := strings.Index(fileContent, ManagedSectionStart)
-	endIdx := strings.Index(fileContent, ManagedSectionEnd)
+	return DefaultFormat.DetectTampering(fileContent, expectedManaged)
+}
+
+// DetectTampering checks if the managed section was modified.
+func (f Format) DetectTampering(fileContent, expectedManaged string) bool {
+	startIdx := strings.Index(fileContent, f.StartMarker())
+	endIdx := strings.Index(fileContent, f.EndMarker())
 
 	if startIdx == -1 || endIdx == -1 {
 		return expectedManaged != ""
 	}
 
-	actual := fileContent[startIdx : endIdx+len(ManagedSectionEnd)]
+	actual := fileContent[startIdx : endIdx+len(f.EndMarker())]
 	return actual != expectedManaged
 }
 
+// ExtractManagedSection returns the managed section of a file including its
+// markers. ok is false when either marker is missing or they are out of order.
+func ExtractManagedSection(fileContent string) (section string, ok bool) {
+	return DefaultFormat.ExtractManagedSection(fileContent)
+}
+
+// ExtractManagedSection returns the managed section of a file including its
+// markers. ok is false when either marker is missing or they are out of order.
+func (f Format) ExtractManagedSection(fileContent string) (section string, ok bool) {
+	startIdx := strings.Index(fileContent, f.StartMarker())
+	endIdx := strings.Index(fileContent, f.EndMarker())
+
+	if startIdx == -1 || endIdx == -1 || endIdx < startIdx {
+		return "", false
+	}
+	return fileContent[startIdx : endIdx+len(f.EndMarker())], true
+}
+
+// CheckManagedSection classifies a file's managed section against the
+// expected one. A differing section is stale when its embedded checksum still
+// matches its content, and tampered otherwise. Sections written before
+// checksums were introduced are reported as stale.
+func CheckManagedSection(fileContent, expectedManaged string) SectionStatus {
+	return DefaultFormat.CheckManagedSection(fileContent, expectedManaged)
+}
+
+// CheckManagedSection classifies a file's managed section against the
+// expected one in this format.
+func (f Format) CheckManagedSection(fileContent, expectedManaged string) SectionStatus {
+	section, ok := f.ExtractManagedSection(fileContent)
+	if !ok {
+		if expectedManaged == "" && !strings.Contains(fileContent, f.StartMarker()) {
+			return SectionOK
+		}
+		return SectionMissing
+	}
+
+	if section == expectedManaged {
+		return SectionOK
+	}
+
+	checksumIdx := strings.LastIndex(section, f.checksumPrefix())
+	if checksumIdx == -1 {
+		return SectionStale
+	}
+
+	content := strings.TrimSuffix(section[:checksumIdx], "\n\n")
+	line := section[checksumIdx:]
+	if end := strings.Index(line, f.checksumSuffix()); end != -1 {
+		line = line[:end+len(f.checksumSuffix())]
+	}
+	if line != f.checksumLine(content) {
+		return SectionTampered
+	}
+	return SectionStale
+}
+
 // toTitleCase capitalizes the first letter of a string.
 func toTitleCase(s string) string {
 	if len(s) == 0 {
@@ -136,6 +136,25 @@ func TestRenderManagedSection_CategorySorting(t *testing.T) {
 	}
 }
 
+func TestRenderManagedSectionWithCategories(t *testing.T) {
+	rules := []Rule{
+		{Name: "Rule A", Content: "A", CategoryID: "cat-unknown", CategoryName: "Archived"},
+		{Name: "Rule B", Content: "B"},
+		{Name: "Rule C", Content: "C", CategoryID: "cat1", CategoryName: "Security"},
+	}
+	known := []Category{{ID: "cat1", Name: "Security", DisplayOrder: 1}}
+
+	result := DefaultFormat.RenderManagedSectionWithCategories(rules, known)
+
+	// Known categories come first, the rest sorted by name
+	securityIdx := strings.Index(result, "## Security")
+	archivedIdx := strings.Index(result, "## Archived")
+	uncategorizedIdx := strings.Index(result, "## Uncategorized")
+	if securityIdx < 0 || securityIdx > archivedIdx || archivedIdx > uncategorizedIdx {
+		t.Errorf("unexpected category order:\n%s", result)
+	}
+}
+
 func TestMergeWithExisting(t *testing.T) {
 	managedSection := ManagedSectionStart + "\nTest content\n" + ManagedSectionEnd
 
@@ -244,3 +263,102 @@ func TestToTitleCase(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckManagedSection(t *testing.T) {
+	oldRules := []Rule{{Name: "Rule A", Content: "Old content", TargetLayer: "project", CategoryID: "cat1"}}
+	newRules := []Rule{{Name: "Rule A", Content: "New content", TargetLayer: "project", CategoryID: "cat1"}}
+	categories := []Category{{ID: "cat1", Name: "General"}}
+
+	expected := RenderManagedSection(newRules, categories)
+	stale := RenderManagedSection(oldRules, categories)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		want     SectionStatus
+	}{
+		{"matching section", "# Notes\n\n" + expected + "\n", expected, SectionOK},
+		{"no rules and no section", "# Notes\n", "", SectionOK},
+		{"missing section", "# Notes\n", expected, SectionMissing},
+		{"missing end marker", ManagedSectionStart + "\nstuff\n", expected, SectionMissing},
+		{"section left after rules removed", expected, "", SectionStale},
+		{"rendered from older rules", stale, expected, SectionStale},
+		{"edited by hand", strings.Replace(expected, "New content", "Edited content", 1), expected, SectionTampered},
+		{"legacy section without checksum", ManagedSectionStart + "\nOld\n\n" + ManagedSectionEnd, expected, SectionStale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckManagedSection(tt.content, tt.expected); got != tt.want {
+				t.Errorf("CheckManagedSection() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedRule(t *testing.T) {
+	rules := []Rule{
+		{Name: "Rule A", Content: "Content A", TargetLayer: "project", CategoryID: "cat1", PriorityWeight: 10},
+		{Name: "Rule B", Content: "Content B\nMore B", TargetLayer: "project", CategoryID: "cat1", Overridable: true},
+		{Name: "Rule C", Content: "Content C", TargetLayer: "user", CategoryID: "cat2"},
+	}
+	categories := []Category{{ID: "cat1", Name: "One", DisplayOrder: 1}, {ID: "cat2", Name: "Two", DisplayOrder: 2}}
+	expected := RenderManagedSection(rules, categories)
+	file := "# Notes\n\n" + expected + "\n"
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantOK  bool
+	}{
+		{"unchanged", file, -1, false},
+		{"edit outside the section", "# Edited notes\n\n" + expected + "\n", -1, false},
+		{"edit in a rule", strings.Replace(file, "More B", "Less B", 1), 1, true},
+		{"edit of a rule header", strings.Replace(file, "**Rule C**", "**Rule X**", 1), 2, true},
+		{"line added after a rule", strings.Replace(file, "Content C\n", "Content C\nExtra\n", 1), 2, true},
+		{"edit before any rule", strings.Replace(file, "## One", "## Renamed", 1), 0, true},
+		{"section removed", "# Notes\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ChangedRule(tt.content, expected, rules)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ChangedRule() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDefaultFormatMarkers(t *testing.T) {
+	if DefaultFormat.StartMarker() != ManagedSectionStart || DefaultFormat.EndMarker() != ManagedSectionEnd {
+		t.Errorf("default format markers changed: %q, %q", DefaultFormat.StartMarker(), DefaultFormat.EndMarker())
+	}
+}
+
+func TestFormat_CustomComments(t *testing.T) {
+	f := Format{CommentStart: "/*", CommentEnd: "*/", Preamble: "// header\n"}
+	rules := []Rule{{Name: "Rule A", Content: "Content", TargetLayer: "project"}}
+
+	section := f.RenderManagedSection(rules, nil)
+	if !strings.HasPrefix(section, "/* MANAGED BY EDICTFLOW - DO NOT EDIT */") || !strings.HasSuffix(section, "/* END EDICTFLOW */") {
+		t.Fatalf("expected format markers, got:\n%s", section)
+	}
+
+	merged := f.MergeWithExisting("", section)
+	if merged != "// header\n"+section {
+		t.Errorf("expected preamble on new file, got:\n%s", merged)
+	}
+	if got := f.CheckManagedSection(merged, section); got != SectionOK {
+		t.Errorf("CheckManagedSection() = %s, want %s", got, SectionOK)
+	}
+	if got := CheckManagedSection(merged, section); got != SectionMissing {
+		t.Errorf("default format should not recognize custom markers, got %s", got)
+	}
+	tampered := strings.Replace(merged, "Content", "Edited", 1)
+	if got := f.CheckManagedSection(tampered, section); got != SectionTampered {
+		t.Errorf("CheckManagedSection() = %s, want %s", got, SectionTampered)
+	}
+}
@@ -0,0 +1,145 @@
+package markdown
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the built-in output targets
+const (
+	TargetClaude  = "claude"
+	TargetAgents  = "agents"
+	TargetGemini  = "gemini"
+	TargetCursor  = "cursor"
+	TargetCopilot = "copilot"
+)
+
+// Target is an AI coding assistant instructions file that rules are rendered into
+type Target struct {
+	// Name identifies the target in team settings
+	Name string
+	// ProjectFile is the file's path relative to a project root, with forward slashes
+	ProjectFile string
+	// UserFile is the file's path relative to the home directory, with forward
+	// slashes. Empty when the assistant has no user-level instructions file.
+	UserFile string
+	// EnterpriseFile is the absolute path of the system-wide file, if any
+	EnterpriseFile string
+	Format         Format
+}
+
+// FoldsLayer reports whether rules of the layer are rendered into the
+// target's project file because the target has no file of its own for it
+func (t Target) FoldsLayer(layer string) bool {
+	switch layer {
+	case "enterprise":
+		return t.EnterpriseFile == ""
+	case "user":
+		return t.UserFile == ""
+	}
+	return false
+}
+
+// ProjectFilePath returns the target's file in the project directory
+func (t Target) ProjectFilePath(projectPath string) string {
+	return filepath.Join(projectPath, filepath.FromSlash(t.ProjectFile))
+}
+
+// ProjectRoot returns the project directory of one of the target's project
+// files. ok is false when path is not such a file.
+func (t Target) ProjectRoot(path string) (root string, ok bool) {
+	suffix := string(filepath.Separator) + filepath.FromSlash(t.ProjectFile)
+	if !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(path, suffix), true
+}
+
+// cursorFormat adds the front matter Cursor needs to apply a rule file to every request
+var cursorFormat = Format{
+	CommentStart: DefaultFormat.CommentStart,
+	CommentEnd:   DefaultFormat.CommentEnd,
+	Preamble:     "---\ndescription: Rules managed by Edictflow\nalwaysApply: true\n---\n\n",
+}
+
+var targets = []Target{
+	{
+		Name:           TargetClaude,
+		ProjectFile:    "CLAUDE.md",
+		UserFile:       ".claude/CLAUDE.md",
+		EnterpriseFile: "/etc/claude-code/CLAUDE.md",
+		Format:         DefaultFormat,
+	},
+	{
+		Name:        TargetAgents,
+		ProjectFile: "AGENTS.md",
+		UserFile:    ".codex/AGENTS.md",
+		Format:      DefaultFormat,
+	},
+	{
+		Name:        TargetGemini,
+		ProjectFile: "GEMINI.md",
+		UserFile:    ".gemini/GEMINI.md",
+		Format:      DefaultFormat,
+	},
+	{
+		Name:        TargetCursor,
+		ProjectFile: ".cursor/rules/edictflow.mdc",
+		Format:      cursorFormat,
+	},
+	{
+		Name:        TargetCopilot,
+		ProjectFile: ".github/copilot-instructions.md",
+		Format:      DefaultFormat,
+	},
+}
+
+// Targets returns all built-in targets
+func Targets() []Target {
+	return append([]Target(nil), targets...)
+}
+
+// LookupTarget returns the built-in target with the given name
+func LookupTarget(name string) (Target, bool) {
+	for _, t := range targets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Target{}, false
+}
+
+// DefaultTargetNames are the targets enabled when a team has not chosen any
+func DefaultTargetNames() []string {
+	return []string{TargetClaude}
+}
+
+// ResolveTargets returns the targets with the given names in the order given,
+// skipping unknown and repeated names. No names resolves to the defaults.
+func ResolveTargets(names []string) []Target {
+	if len(names) == 0 {
+		names = DefaultTargetNames()
+	}
+	var resolved []Target
+	seen := make(map[string]bool)
+	for _, name := range names {
+		t, ok := LookupTarget(name)
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		resolved = append(resolved, t)
+	}
+	return resolved
+}
+
+// ValidateTargetNames returns an error naming the first unknown target
+func ValidateTargetNames(names []string) error {
+	for _, name := range names {
+		if _, ok := LookupTarget(name); !ok {
+			return fmt.Errorf("unknown output target: %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package markdown
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveTargets(t *testing.T) {
+	names := func(targets []Target) []string {
+		var out []string
+		for _, t := range targets {
+			out = append(out, t.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{"defaults", nil, []string{TargetClaude}},
+		{"given order", []string{TargetCursor, TargetClaude}, []string{TargetCursor, TargetClaude}},
+		{"unknown and repeated skipped", []string{"vim", TargetAgents, TargetAgents}, []string{TargetAgents}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(ResolveTargets(tt.input))
+			if len(got) != len(tt.want) {
+				t.Fatalf("ResolveTargets() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("ResolveTargets() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTargetNames(t *testing.T) {
+	if err := ValidateTargetNames([]string{TargetClaude, TargetCopilot}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateTargetNames([]string{"vim"}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+}
+
+func TestTarget_ProjectRoot(t *testing.T) {
+	cursor, _ := LookupTarget(TargetCursor)
+	project := filepath.Join("/work", "api")
+
+	path := cursor.ProjectFilePath(project)
+	if root, ok := cursor.ProjectRoot(path); !ok || root != project {
+		t.Errorf("ProjectRoot(%q) = %q, %v", path, root, ok)
+	}
+	if _, ok := cursor.ProjectRoot(filepath.Join(project, "CLAUDE.md")); ok {
+		t.Error("expected another target's file to be rejected")
+	}
+}
+
+func TestTarget_FoldsLayer(t *testing.T) {
+	claude, _ := LookupTarget(TargetClaude)
+	copilot, _ := LookupTarget(TargetCopilot)
+
+	if claude.FoldsLayer("enterprise") || claude.FoldsLayer("user") {
+		t.Error("claude has its own enterprise and user files")
+	}
+	if !copilot.FoldsLayer("enterprise") || !copilot.FoldsLayer("user") {
+		t.Error("copilot has only a project file")
+	}
+}
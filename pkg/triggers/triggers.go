@@ -0,0 +1,343 @@
+// Package triggers decides which rules apply to a project. It is shared by
+// the server and the agent so both resolve rules the same way.
+package triggers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Type identifies what a trigger matches against
+type Type string
+
+const (
+	// TypePath matches the project path against a pattern
+	TypePath Type = "path"
+	// TypeGlob matches when any file in the project matches a pattern
+	TypeGlob Type = "glob"
+	// TypeRegex matches the project path against a regular expression
+	TypeRegex Type = "regex"
+	// TypeFileExists matches when a file, such as go.mod, exists in the project
+	TypeFileExists Type = "file_exists"
+	// TypeRepository matches the project's git remote and, optionally, its
+	// current branch, wherever the repository is checked out
+	TypeRepository Type = "repository"
+	TypeContext    Type = "context"
+	TypeTag        Type = "tag"
+)
+
+func (t Type) IsValid() bool {
+	switch t {
+	case TypePath, TypeGlob, TypeRegex, TypeFileExists, TypeRepository, TypeContext, TypeTag:
+		return true
+	}
+	return false
+}
+
+// Trigger is a condition under which a rule applies to a project
+type Trigger struct {
+	Type         Type     `json:"type"`
+	Pattern      string   `json:"pattern,omitempty"`
+	ContextTypes []string `json:"context_types,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// Branches optionally restricts a repository trigger to the branches
+	// matching any of these patterns
+	Branches []string `json:"branches,omitempty"`
+	// Negate makes the trigger match the projects the condition does not
+	Negate bool `json:"negate,omitempty"`
+}
+
+// Validate checks that the trigger has what its type matches against
+func (t Trigger) Validate() error {
+	switch t.Type {
+	case TypePath, TypeGlob:
+		if t.Pattern == "" {
+			return fmt.Errorf("%s trigger requires a pattern", t.Type)
+		}
+		if !doublestar.ValidatePattern(t.Pattern) {
+			return fmt.Errorf("invalid %s pattern %q", t.Type, t.Pattern)
+		}
+		if t.Type == TypeGlob && path.IsAbs(t.Pattern) {
+			return fmt.Errorf("glob pattern %q must be relative to the project", t.Pattern)
+		}
+	case TypeFileExists:
+		if t.Pattern == "" {
+			return errors.New("file_exists trigger requires a file path")
+		}
+		if !fs.ValidPath(t.Pattern) {
+			return fmt.Errorf("file_exists path %q must be a clean path relative to the project", t.Pattern)
+		}
+	case TypeRegex:
+		if t.Pattern == "" {
+			return errors.New("regex trigger requires a pattern")
+		}
+		if _, err := regexp.Compile(t.Pattern); err != nil {
+			return fmt.Errorf("invalid regex pattern %q: %w", t.Pattern, err)
+		}
+	case TypeRepository:
+		if t.Pattern == "" {
+			return errors.New("repository trigger requires a pattern")
+		}
+		if !doublestar.ValidatePattern(t.Pattern) {
+			return fmt.Errorf("invalid repository pattern %q", t.Pattern)
+		}
+		for _, b := range t.Branches {
+			if b == "" || !doublestar.ValidatePattern(b) {
+				return fmt.Errorf("invalid branch pattern %q", b)
+			}
+		}
+	case TypeContext:
+		if len(t.ContextTypes) == 0 {
+			return errors.New("context trigger requires context types")
+		}
+	case TypeTag:
+		if len(t.Tags) == 0 {
+			return errors.New("tag trigger requires tags")
+		}
+	default:
+		return fmt.Errorf("invalid trigger type %q", t.Type)
+	}
+	return nil
+}
+
+// Validate checks every trigger
+func Validate(triggers []Trigger) error {
+	for i, t := range triggers {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("trigger %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Specificity ranks trigger types so that rules matched by narrower
+// conditions are listed first. Negated triggers match by absence and rank
+// below every other trigger.
+func (t Trigger) Specificity() int {
+	if t.Negate {
+		return 1
+	}
+	switch t.Type {
+	case TypePath:
+		return 100
+	case TypeRepository:
+		return 95
+	case TypeRegex:
+		return 90
+	case TypeGlob:
+		return 80
+	case TypeFileExists:
+		return 70
+	case TypeContext:
+		return 50
+	case TypeTag:
+		return 10
+	}
+	return 0
+}
+
+// Context describes the project rules are matched against
+type Context struct {
+	ProjectPath      string
+	DetectedContexts []string
+	Tags             []string
+	// Repository is the project's git remote as host/owner/name, see
+	// NormalizeRemote, and Branch its checked out branch
+	Repository string
+	Branch     string
+	// Files is the project's file system, rooted at the project. Glob and
+	// file_exists triggers never match without it, negated or not.
+	Files fs.FS
+}
+
+// Matches reports whether the trigger applies to the project
+func (t Trigger) Matches(ctx Context) bool {
+	if t.needsFiles() && ctx.Files == nil {
+		return false
+	}
+	var matched bool
+	switch t.Type {
+	case TypePath:
+		matched = MatchPath(t.Pattern, ctx.ProjectPath)
+	case TypeGlob:
+		matched = matchGlob(ctx.Files, t.Pattern)
+	case TypeRegex:
+		re, err := regexp.Compile(t.Pattern)
+		matched = err == nil && re.MatchString(filepath.ToSlash(ctx.ProjectPath))
+	case TypeFileExists:
+		matched = fileExists(ctx.Files, t.Pattern)
+	case TypeRepository:
+		matched = t.matchRepository(ctx.Repository, ctx.Branch)
+	case TypeContext:
+		matched = matchAny(t.ContextTypes, ctx.DetectedContexts)
+	case TypeTag:
+		matched = matchAny(t.Tags, ctx.Tags)
+	default:
+		return false
+	}
+	return matched != t.Negate
+}
+
+// NeedsFiles reports whether any of the triggers is decided by the project's
+// files rather than by its path and detected contexts and tags
+func NeedsFiles(triggers []Trigger) bool {
+	for _, t := range triggers {
+		if t.needsFiles() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Trigger) needsFiles() bool {
+	return t.Type == TypeGlob || t.Type == TypeFileExists
+}
+
+// MatchesAny reports whether any of the triggers applies to the project
+func MatchesAny(triggers []Trigger, ctx Context) bool {
+	for _, t := range triggers {
+		if t.Matches(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSpecificity returns the highest specificity among the triggers
+func MaxSpecificity(triggers []Trigger) int {
+	max := 0
+	for _, t := range triggers {
+		if s := t.Specificity(); s > max {
+			max = s
+		}
+	}
+	return max
+}
+
+// Match returns the items with a trigger matching the project, most specific
+// first. Items of equal specificity keep their relative order. Items without
+// triggers never match.
+func Match[T any](items []T, triggersOf func(T) []Trigger, ctx Context) []T {
+	var matched []T
+	var specificity []int
+	for _, item := range items {
+		triggers := triggersOf(item)
+		if MatchesAny(triggers, ctx) {
+			matched = append(matched, item)
+			specificity = append(specificity, MaxSpecificity(triggers))
+		}
+	}
+
+	order := make([]int, len(matched))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return specificity[order[i]] > specificity[order[j]]
+	})
+
+	sorted := make([]T, len(matched))
+	for i, idx := range order {
+		sorted[i] = matched[idx]
+	}
+	return sorted
+}
+
+// MatchPath reports whether the name matches the pattern. Patterns use
+// doublestar syntax: * and ? stay within a path segment and ** spans any
+// number of segments, so **/frontend/** matches a frontend directory and
+// everything below it.
+func MatchPath(pattern, name string) bool {
+	matched, err := doublestar.Match(filepath.ToSlash(pattern), filepath.ToSlash(name))
+	return err == nil && matched
+}
+
+// matchRepository matches the repository case-insensitively, as hosts and
+// the common forges ignore case, and the branch as is
+func (t Trigger) matchRepository(repository, branch string) bool {
+	if repository == "" || !MatchPath(strings.ToLower(t.Pattern), strings.ToLower(repository)) {
+		return false
+	}
+	if len(t.Branches) == 0 {
+		return true
+	}
+	for _, b := range t.Branches {
+		if branch != "" && MatchPath(b, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeRemote turns a git remote URL into host/owner/name, so the SSH
+// and HTTPS remotes of a repository compare equal. For example
+// git@github.com:acme/payments.git and https://github.com/acme/payments
+// both become github.com/acme/payments. It returns "" for remotes without a
+// host, such as local paths.
+func NormalizeRemote(remote string) string {
+	remote = strings.TrimSpace(remote)
+	var host, repoPath string
+	if i := strings.Index(remote, "://"); i >= 0 {
+		// scheme://[user@]host[:port]/path
+		rest := remote[i+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return ""
+		}
+		host, repoPath = rest[:slash], rest[slash+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		if colon := strings.LastIndex(host, ":"); colon >= 0 {
+			host = host[:colon]
+		}
+	} else if colon := strings.Index(remote, ":"); colon > 1 && !strings.Contains(remote[:colon], "/") {
+		// scp-like [user@]host:path
+		host, repoPath = remote[:colon], remote[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		return ""
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || repoPath == "" {
+		return ""
+	}
+	return strings.ToLower(host + "/" + repoPath)
+}
+
+var errFound = errors.New("found")
+
+// matchGlob reports whether any file in the project matches the pattern
+func matchGlob(files fs.FS, pattern string) bool {
+	err := doublestar.GlobWalk(files, pattern, func(string, fs.DirEntry) error {
+		return errFound
+	})
+	return errors.Is(err, errFound)
+}
+
+func fileExists(files fs.FS, name string) bool {
+	_, err := fs.Stat(files, name)
+	return err == nil
+}
+
+func matchAny(wanted, present []string) bool {
+	for _, w := range wanted {
+		for _, p := range present {
+			if strings.EqualFold(w, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package triggers
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+type rule struct {
+	id       string
+	triggers []Trigger
+}
+
+func ruleTriggers(r rule) []Trigger {
+	return r.triggers
+}
+
+func ids(rules []rule) []string {
+	var out []string
+	for _, r := range rules {
+		out = append(out, r.id)
+	}
+	return out
+}
+
+func TestTrigger_Matches(t *testing.T) {
+	ctx := Context{
+		ProjectPath:      "/home/user/myapp/frontend",
+		DetectedContexts: []string{"node", "typescript"},
+		Tags:             []string{"React"},
+	}
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    bool
+	}{
+		{"path segment", Trigger{Type: TypePath, Pattern: "**/frontend/**"}, true},
+		{"other path segment", Trigger{Type: TypePath, Pattern: "**/backend/**"}, false},
+		{"exact glob", Trigger{Type: TypePath, Pattern: "/home/user/*/frontend"}, true},
+		{"context", Trigger{Type: TypeContext, ContextTypes: []string{"go", "node"}}, true},
+		{"missing context", Trigger{Type: TypeContext, ContextTypes: []string{"python"}}, false},
+		{"tag ignores case", Trigger{Type: TypeTag, Tags: []string{"react"}}, true},
+		{"unknown type", Trigger{Type: "unknown", Pattern: "*"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.trigger.Matches(ctx); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch_FiltersAndOrdersBySpecificity(t *testing.T) {
+	rules := []rule{
+		{"tag-a", []Trigger{{Type: TypeTag, Tags: []string{"frontend"}}}},
+		{"untriggered", nil},
+		{"path", []Trigger{{Type: TypePath, Pattern: "**/src/**"}}},
+		{"backend", []Trigger{{Type: TypePath, Pattern: "**/backend/**"}}},
+		{"tag-b", []Trigger{{Type: TypeTag, Tags: []string{"frontend"}}}},
+		{"mixed", []Trigger{{Type: TypeTag, Tags: []string{"none"}}, {Type: TypeContext, ContextTypes: []string{"node"}}}},
+	}
+	ctx := Context{
+		ProjectPath:      "/home/user/myapp/src",
+		DetectedContexts: []string{"node"},
+		Tags:             []string{"frontend"},
+	}
+
+	got := ids(Match(rules, ruleTriggers, ctx))
+
+	want := []string{"path", "mixed", "tag-a", "tag-b"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"src/**/*.ts", "src/app/components/button.ts", true},
+		{"src/**/*.ts", "src/index.ts", true},
+		{"src/**/*.ts", "lib/src/index.ts", false},
+		{"src/**/*.ts", "src/app/button.tsx", false},
+		{"**/frontend/**", "/home/user/frontend", true},
+		{"**/frontend/**", "/home/user/frontend/src/App.tsx", true},
+		{"**/frontend/**", "/home/user/frontend-old", false},
+		{"/home/*/api", "/home/dev/api", true},
+		{"/home/*/api", "/home/dev/team/api", false},
+		{"/srv/{api,web}", "/srv/web", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTrigger_MatchesFilesAndNegation(t *testing.T) {
+	ctx := Context{
+		ProjectPath: "/home/user/services/billing",
+		Tags:        []string{"backend"},
+		Files: fstest.MapFS{
+			"go.mod":                 {},
+			"internal/api/server.go": {},
+			"web/src/app/index.ts":   {},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    bool
+	}{
+		{"glob", Trigger{Type: TypeGlob, Pattern: "web/src/**/*.ts"}, true},
+		{"glob without match", Trigger{Type: TypeGlob, Pattern: "**/*.py"}, false},
+		{"file exists", Trigger{Type: TypeFileExists, Pattern: "go.mod"}, true},
+		{"file missing", Trigger{Type: TypeFileExists, Pattern: "package.json"}, false},
+		{"regex", Trigger{Type: TypeRegex, Pattern: `/services/[a-z]+$`}, true},
+		{"regex without match", Trigger{Type: TypeRegex, Pattern: `^/opt/`}, false},
+		{"negated file", Trigger{Type: TypeFileExists, Pattern: "package.json", Negate: true}, true},
+		{"negated tag", Trigger{Type: TypeTag, Tags: []string{"backend"}, Negate: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.trigger.Matches(ctx); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without the project's files, file triggers are undecided and never match
+	ctx.Files = nil
+	for _, tr := range []Trigger{
+		{Type: TypeFileExists, Pattern: "go.mod"},
+		{Type: TypeFileExists, Pattern: "package.json", Negate: true},
+	} {
+		if tr.Matches(ctx) {
+			t.Errorf("expected %+v not to match without files", tr)
+		}
+	}
+}
+
+func TestTrigger_Validate(t *testing.T) {
+	valid := []Trigger{
+		{Type: TypePath, Pattern: "**/api/**"},
+		{Type: TypeGlob, Pattern: "src/**/*.ts"},
+		{Type: TypeRegex, Pattern: `^/srv/.+`},
+		{Type: TypeFileExists, Pattern: "go.mod", Negate: true},
+		{Type: TypeRepository, Pattern: "github.com/acme/*", Branches: []string{"main"}},
+		{Type: TypeContext, ContextTypes: []string{"go"}},
+		{Type: TypeTag, Tags: []string{"react"}},
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("expected valid triggers, got %v", err)
+	}
+
+	invalid := []Trigger{
+		{Type: "unknown", Pattern: "*"},
+		{Type: TypePath},
+		{Type: TypeGlob, Pattern: "src/[a-"},
+		{Type: TypeGlob, Pattern: "/etc/*.conf"},
+		{Type: TypeRegex, Pattern: "("},
+		{Type: TypeFileExists, Pattern: "../go.mod"},
+		{Type: TypeRepository},
+		{Type: TypeRepository, Pattern: "github.com/acme/*", Branches: []string{""}},
+		{Type: TypeContext},
+		{Type: TypeTag},
+	}
+	for _, tr := range invalid {
+		if err := tr.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", tr)
+		}
+	}
+}
+
+func TestNormalizeRemote(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:acme/payments-api.git":         "github.com/acme/payments-api",
+		"https://github.com/Acme/Payments-API":         "github.com/acme/payments-api",
+		"https://token@github.com/acme/payments-api/":  "github.com/acme/payments-api",
+		"ssh://git@gitlab.example.com:2222/acme/x.git": "gitlab.example.com/acme/x",
+		"git@gitlab.com:acme/platform/billing.git":     "gitlab.com/acme/platform/billing",
+		"/srv/git/payments.git":                        "",
+		"C:\\repos\\payments":                          "",
+		"":                                             "",
+	}
+	for remote, want := range tests {
+		if got := NormalizeRemote(remote); got != want {
+			t.Errorf("NormalizeRemote(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
+
+func TestTrigger_MatchesRepository(t *testing.T) {
+	ctx := Context{ProjectPath: "/home/dev/src/pay", Repository: "github.com/acme/payments-api", Branch: "release/2.1"}
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		want    bool
+	}{
+		{"repository glob", Trigger{Type: TypeRepository, Pattern: "github.com/acme/payments-*"}, true},
+		{"ignores case", Trigger{Type: TypeRepository, Pattern: "GitHub.com/Acme/*"}, true},
+		{"any host", Trigger{Type: TypeRepository, Pattern: "*/acme/payments-api"}, true},
+		{"other org", Trigger{Type: TypeRepository, Pattern: "github.com/other/*"}, false},
+		{"branch glob", Trigger{Type: TypeRepository, Pattern: "github.com/acme/*", Branches: []string{"main", "release/*"}}, true},
+		{"other branch", Trigger{Type: TypeRepository, Pattern: "github.com/acme/*", Branches: []string{"main"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.trigger.Matches(ctx); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (Trigger{Type: TypeRepository, Pattern: "**"}).Matches(Context{ProjectPath: "/tmp/scratch"}) {
+		t.Error("expected projects without a remote not to match")
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+)
+
+type AgentCommandDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewAgentCommandDB(pool *pgxpool.Pool) *AgentCommandDB {
+	return &AgentCommandDB{pool: pool}
+}
+
+const agentCommandColumns = `id, agent_id, type, status, COALESCE(issued_by::text, ''), result, error, created_at, completed_at`
+
+func (db *AgentCommandDB) Create(ctx context.Context, cmd domain.AgentCommand) error {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO agent_commands (id, agent_id, type, status, issued_by, result, error, created_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, cmd.ID, cmd.AgentID, string(cmd.Type), string(cmd.Status), cmd.IssuedBy, nullableJSON(cmd.Result), cmd.Error, cmd.CreatedAt, cmd.CompletedAt)
+	return err
+}
+
+func (db *AgentCommandDB) GetByID(ctx context.Context, id string) (domain.AgentCommand, error) {
+	row := db.pool.QueryRow(ctx, `SELECT `+agentCommandColumns+` FROM agent_commands WHERE id = $1`, id)
+	cmd, err := scanAgentCommand(row)
+	if err == pgx.ErrNoRows {
+		return domain.AgentCommand{}, agentcommands.ErrCommandNotFound
+	}
+	return cmd, err
+}
+
+// ListByAgent returns the agent's most recent commands, newest first
+func (db *AgentCommandDB) ListByAgent(ctx context.Context, agentID string, limit int) ([]domain.AgentCommand, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+agentCommandColumns+`
+		FROM agent_commands
+		WHERE agent_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commands := []domain.AgentCommand{}
+	for rows.Next() {
+		cmd, err := scanAgentCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, rows.Err()
+}
+
+func (db *AgentCommandDB) Update(ctx context.Context, cmd domain.AgentCommand) error {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE agent_commands
+		SET status = $2, result = $3, error = $4, completed_at = $5
+		WHERE id = $1
+	`, cmd.ID, string(cmd.Status), nullableJSON(cmd.Result), cmd.Error, cmd.CompletedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return agentcommands.ErrCommandNotFound
+	}
+	return nil
+}
+
+func scanAgentCommand(row pgx.Row) (domain.AgentCommand, error) {
+	var cmd domain.AgentCommand
+	var commandType, status string
+	var result []byte
+	err := row.Scan(&cmd.ID, &cmd.AgentID, &commandType, &status, &cmd.IssuedBy, &result, &cmd.Error, &cmd.CreatedAt, &cmd.CompletedAt)
+	if err != nil {
+		return domain.AgentCommand{}, err
+	}
+	cmd.Type = domain.AgentCommandType(commandType)
+	cmd.Status = domain.AgentCommandStatus(status)
+	cmd.Result = result
+	return cmd, nil
+}
+
+// nullableJSON stores an empty document as NULL
+func nullableJSON(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type AgentDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewAgentDB(pool *pgxpool.Pool) *AgentDB {
+	return &AgentDB{pool: pool}
+}
+
+const agentColumns = `id, machine_id, user_id, COALESCE(team_id::text, ''), hostname, os, version, status,
+	last_heartbeat, cached_config_version, created_at, revoked_at, revoked_by::text, upgrade_required_reason`
+
+// Register inserts the agent, or refreshes the host details of the agent
+// already registered for the machine and user. Revoked agents are returned
+// unchanged.
+func (db *AgentDB) Register(ctx context.Context, agent domain.Agent) (domain.Agent, error) {
+	row := db.pool.QueryRow(ctx, `
+		INSERT INTO agents (id, machine_id, user_id, team_id, hostname, os, version, status, last_heartbeat, cached_config_version, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (machine_id, user_id) DO UPDATE
+		SET team_id = CASE WHEN agents.status = 'revoked' THEN agents.team_id ELSE EXCLUDED.team_id END,
+			hostname = CASE WHEN agents.status = 'revoked' THEN agents.hostname ELSE EXCLUDED.hostname END,
+			os = CASE WHEN agents.status = 'revoked' THEN agents.os ELSE EXCLUDED.os END,
+			version = CASE WHEN agents.status = 'revoked' THEN agents.version ELSE EXCLUDED.version END
+		RETURNING `+agentColumns,
+		agent.ID, agent.MachineID, agent.UserID, agent.TeamID, agent.Hostname, agent.OS, agent.Version,
+		string(agent.Status), agent.LastHeartbeat, agent.CachedConfigVersion, agent.CreatedAt)
+	return scanAgent(row)
+}
+
+// GetByID returns the agent, or nil when it does not exist
+func (db *AgentDB) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
+	row := db.pool.QueryRow(ctx, `SELECT `+agentColumns+` FROM agents WHERE id = $1`, id)
+	a, err := scanAgent(row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (db *AgentDB) List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.TeamID != "" {
+		args = append(args, filter.TeamID)
+		conditions = append(conditions, fmt.Sprintf("team_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + agentColumns + ` FROM agents`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY last_heartbeat DESC`
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []domain.Agent{}
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func (db *AgentDB) RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat, at time.Time) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE agents
+		SET status = 'online', last_heartbeat = $3, cached_config_version = $4,
+			team_id = NULLIF($5, '')::uuid,
+			hostname = COALESCE(NULLIF($6, ''), hostname),
+			os = COALESCE(NULLIF($7, ''), os),
+			version = COALESCE(NULLIF($8, ''), version),
+			upgrade_required_reason = ''
+		WHERE id = $1 AND user_id = $2 AND status <> 'revoked'
+	`, heartbeat.AgentID, heartbeat.UserID, at, heartbeat.CachedVersion,
+		heartbeat.TeamID, heartbeat.Hostname, heartbeat.OS, heartbeat.Version)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return agents.ErrAgentNotFound
+	}
+
+	if heartbeat.RuleVersions != nil {
+		if err := recordRuleVersions(ctx, tx, heartbeat.AgentID, heartbeat.RuleVersions, at); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// recordRuleVersions replaces the rule versions the agent reported applying
+func recordRuleVersions(ctx context.Context, tx pgx.Tx, agentID string, versions map[string]int, at time.Time) error {
+	ruleIDs := make([]string, 0, len(versions))
+	numbers := make([]int32, 0, len(versions))
+	for ruleID, version := range versions {
+		// Rule IDs come from the agent
+		if _, err := uuid.Parse(ruleID); err != nil {
+			continue
+		}
+		ruleIDs = append(ruleIDs, ruleID)
+		numbers = append(numbers, int32(version))
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM agent_rule_versions
+		WHERE agent_id = $1 AND NOT (rule_id = ANY($2::uuid[]))
+	`, agentID, ruleIDs); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO agent_rule_versions (agent_id, rule_id, version, reported_at)
+		SELECT $1, r.rule_id, r.version, $4
+		FROM unnest($2::uuid[], $3::int[]) AS r(rule_id, version)
+		ON CONFLICT (agent_id, rule_id) DO UPDATE
+		SET version = EXCLUDED.version, reported_at = EXCLUDED.reported_at
+	`, agentID, ruleIDs, numbers, at)
+	return err
+}
+
+// RecordUpgradeRequired stores why the agent was told to upgrade
+func (db *AgentDB) RecordUpgradeRequired(ctx context.Context, id, reason string) error {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE agents SET upgrade_required_reason = $2
+		WHERE id = $1 AND status <> 'revoked'
+	`, id, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return agents.ErrAgentNotFound
+	}
+	return nil
+}
+
+func (db *AgentDB) SetInactiveStatus(ctx context.Context, id string, status domain.AgentStatus, lastHeartbeat time.Time) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE agents SET status = $2
+		WHERE id = $1 AND last_heartbeat = $3 AND status <> 'revoked'
+	`, id, string(status), lastHeartbeat)
+	return err
+}
+
+func (db *AgentDB) Revoke(ctx context.Context, id, revokedBy string, at time.Time) error {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE agents SET status = 'revoked', revoked_at = $2, revoked_by = $3
+		WHERE id = $1
+	`, id, at, revokedBy)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return agents.ErrAgentNotFound
+	}
+	return nil
+}
+
+func scanAgent(row pgx.Row) (domain.Agent, error) {
+	var a domain.Agent
+	var status string
+	err := row.Scan(&a.ID, &a.MachineID, &a.UserID, &a.TeamID, &a.Hostname, &a.OS, &a.Version, &status,
+		&a.LastHeartbeat, &a.CachedConfigVersion, &a.CreatedAt, &a.RevokedAt, &a.RevokedBy, &a.UpgradeRequiredReason)
+	if err != nil {
+		return domain.Agent{}, err
+	}
+	a.Status = domain.AgentStatus(status)
+	return a, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+type AgentProjectDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewAgentProjectDB(pool *pgxpool.Pool) *AgentProjectDB {
+	return &AgentProjectDB{pool: pool}
+}
+
+func (r *AgentProjectDB) UpsertProject(ctx context.Context, project domain.AgentProject) error {
+	query := `
+		INSERT INTO agent_projects (agent_id, project_path, user_id, team_id, detected_contexts, detected_tags, repository, branch, detected_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7, $8, $9)
+		ON CONFLICT (agent_id, project_path) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			team_id = EXCLUDED.team_id,
+			detected_contexts = EXCLUDED.detected_contexts,
+			detected_tags = EXCLUDED.detected_tags,
+			repository = EXCLUDED.repository,
+			branch = EXCLUDED.branch,
+			detected_at = EXCLUDED.detected_at
+	`
+	_, err := r.pool.Exec(ctx, query,
+		project.AgentID, project.ProjectPath, project.UserID, project.TeamID,
+		project.DetectedContexts, project.DetectedTags, project.Repository, project.Branch, project.DetectedAt)
+	return err
+}
+
+func (r *AgentProjectDB) ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error) {
+	query := `
+		SELECT agent_id, project_path, user_id, COALESCE(team_id::text, ''), detected_contexts, detected_tags, repository, branch, detected_at
+		FROM agent_projects
+		WHERE agent_id = $1
+		ORDER BY project_path
+	`
+	rows, err := r.pool.Query(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []domain.AgentProject{}
+	for rows.Next() {
+		var p domain.AgentProject
+		if err := rows.Scan(&p.AgentID, &p.ProjectPath, &p.UserID, &p.TeamID,
+			&p.DetectedContexts, &p.DetectedTags, &p.Repository, &p.Branch, &p.DetectedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// ListByTeam returns the projects reported by the team's agents
+func (r *AgentProjectDB) ListByTeam(ctx context.Context, teamID string) ([]domain.AgentProject, error) {
+	query := `
+		SELECT agent_id, project_path, user_id, COALESCE(team_id::text, ''), detected_contexts, detected_tags, repository, branch, detected_at
+		FROM agent_projects
+		WHERE team_id = $1
+		ORDER BY agent_id, project_path
+	`
+	rows, err := r.pool.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []domain.AgentProject{}
+	for rows.Next() {
+		var p domain.AgentProject
+		if err := rows.Scan(&p.AgentID, &p.ProjectPath, &p.UserID, &p.TeamID,
+			&p.DetectedContexts, &p.DetectedTags, &p.Repository, &p.Branch, &p.DetectedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+type ConfigRevisionDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewConfigRevisionDB(pool *pgxpool.Pool) *ConfigRevisionDB {
+	return &ConfigRevisionDB{pool: pool}
+}
+
+// CommitConfig records state as the team's current configuration. When it
+// differs from the last committed state, the revision is incremented and the
+// rule changes are logged under it. Changes older than retention revisions
+// are pruned. The team's revision row is locked for the duration, so
+// concurrent commits are serialized.
+func (db *ConfigRevisionDB) CommitConfig(ctx context.Context, teamID string, state domain.TeamConfigState, retention int64) (domain.ConfigRevision, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return domain.ConfigRevision{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO team_config_revisions (team_id) VALUES ($1)
+		ON CONFLICT (team_id) DO NOTHING
+	`, teamID); err != nil {
+		return domain.ConfigRevision{}, err
+	}
+
+	result := domain.ConfigRevision{TeamID: teamID}
+	previous := domain.TeamConfigState{Rules: make(map[string]string)}
+	if err := tx.QueryRow(ctx, `
+		SELECT revision, settings_checksum, pruned_through
+		FROM team_config_revisions
+		WHERE team_id = $1
+		FOR UPDATE
+	`, teamID).Scan(&result.Previous, &previous.Settings, &result.PrunedThrough); err != nil {
+		return domain.ConfigRevision{}, err
+	}
+	result.Revision = result.Previous
+
+	rows, err := tx.Query(ctx, `SELECT rule_id::text, checksum FROM team_config_rules WHERE team_id = $1`, teamID)
+	if err != nil {
+		return domain.ConfigRevision{}, err
+	}
+	for rows.Next() {
+		var ruleID, checksum string
+		if err := rows.Scan(&ruleID, &checksum); err != nil {
+			rows.Close()
+			return domain.ConfigRevision{}, err
+		}
+		previous.Rules[ruleID] = checksum
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return domain.ConfigRevision{}, err
+	}
+
+	changes, settingsChanged := state.Diff(previous)
+	if len(changes) == 0 && !settingsChanged {
+		return result, tx.Commit(ctx)
+	}
+	result.Revision++
+
+	for _, c := range changes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO team_config_changes (team_id, revision, rule_id, action)
+			VALUES ($1, $2, $3, $4)
+		`, teamID, result.Revision, c.RuleID, string(c.Action)); err != nil {
+			return domain.ConfigRevision{}, err
+		}
+
+		if c.Action == domain.ConfigChangeRemove {
+			_, err = tx.Exec(ctx, `DELETE FROM team_config_rules WHERE team_id = $1 AND rule_id = $2`, teamID, c.RuleID)
+		} else {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO team_config_rules (team_id, rule_id, checksum) VALUES ($1, $2, $3)
+				ON CONFLICT (team_id, rule_id) DO UPDATE SET checksum = EXCLUDED.checksum
+			`, teamID, c.RuleID, state.Rules[c.RuleID])
+		}
+		if err != nil {
+			return domain.ConfigRevision{}, err
+		}
+	}
+
+	if retention > 0 && result.Revision-retention > result.PrunedThrough {
+		result.PrunedThrough = result.Revision - retention
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM team_config_changes WHERE team_id = $1 AND revision <= $2
+		`, teamID, result.PrunedThrough); err != nil {
+			return domain.ConfigRevision{}, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE team_config_revisions
+		SET revision = $2, settings_checksum = $3, pruned_through = $4, updated_at = NOW()
+		WHERE team_id = $1
+	`, teamID, result.Revision, state.Settings, result.PrunedThrough); err != nil {
+		return domain.ConfigRevision{}, err
+	}
+
+	return result, tx.Commit(ctx)
+}
+
+// ListConfigChanges returns the team's logged changes after revision since,
+// oldest first
+func (db *ConfigRevisionDB) ListConfigChanges(ctx context.Context, teamID string, since int64) ([]domain.ConfigChange, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT team_id::text, revision, rule_id::text, action
+		FROM team_config_changes
+		WHERE team_id = $1 AND revision > $2
+		ORDER BY revision, rule_id
+	`, teamID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []domain.ConfigChange
+	for rows.Next() {
+		var c domain.ConfigChange
+		var action string
+		if err := rows.Scan(&c.TeamID, &c.Revision, &c.RuleID, &action); err != nil {
+			return nil, err
+		}
+		c.Action = domain.ConfigChangeAction(action)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
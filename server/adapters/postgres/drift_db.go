@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
+)
+
+type DriftDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewDriftDB(pool *pgxpool.Pool) *DriftDB {
+	return &DriftDB{pool: pool}
+}
+
+// ReplaceAgentDrift stores a report as the agent's latest state, dropping
+// files that are no longer managed
+func (r *DriftDB) ReplaceAgentDrift(ctx context.Context, report domain.AgentDrift) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM agent_drift WHERE agent_id = $1", report.AgentID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO agent_drift (agent_id, file_path, user_id, team_id, hostname, level, status,
+			expected_hash, actual_hash, rule_ids, reported_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7, $8, $9, $10, $11)
+	`
+	for _, f := range report.Files {
+		ruleIDs := f.RuleIDs
+		if ruleIDs == nil {
+			ruleIDs = []string{}
+		}
+		_, err := tx.Exec(ctx, query,
+			report.AgentID, f.Path, report.UserID, report.TeamID, report.Hostname,
+			f.Level, string(f.Status), f.ExpectedHash, f.ActualHash, ruleIDs, report.ReportedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *DriftDB) GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error) {
+	reports, err := r.query(ctx, "agent_id = $1", agentID)
+	if err != nil {
+		return domain.AgentDrift{}, err
+	}
+	if len(reports) == 0 {
+		return domain.AgentDrift{}, drift.ErrAgentDriftNotFound
+	}
+	return reports[0], nil
+}
+
+func (r *DriftDB) ListTeamDrift(ctx context.Context, teamID string) ([]domain.AgentDrift, error) {
+	return r.query(ctx, "team_id = $1", teamID)
+}
+
+// query loads drift rows matching the condition and groups them by agent
+func (r *DriftDB) query(ctx context.Context, condition string, arg string) ([]domain.AgentDrift, error) {
+	query := `
+		SELECT agent_id, file_path, user_id, COALESCE(team_id::text, ''), hostname, level, status,
+			expected_hash, actual_hash, rule_ids, reported_at
+		FROM agent_drift
+		WHERE ` + condition + `
+		ORDER BY agent_id, file_path
+	`
+	rows, err := r.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []domain.AgentDrift
+	for rows.Next() {
+		var a domain.AgentDrift
+		var f domain.FileDrift
+		var status string
+		if err := rows.Scan(&a.AgentID, &f.Path, &a.UserID, &a.TeamID, &a.Hostname, &f.Level, &status,
+			&f.ExpectedHash, &f.ActualHash, &f.RuleIDs, &a.ReportedAt); err != nil {
+			return nil, err
+		}
+		f.Status = domain.DriftStatus(status)
+
+		if n := len(reports); n > 0 && reports[n-1].AgentID == a.AgentID {
+			reports[n-1].Files = append(reports[n-1].Files, f)
+			continue
+		}
+		a.Files = []domain.FileDrift{f}
+		reports = append(reports, a)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/auth"
+)
+
+type RefreshTokenDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewRefreshTokenDB(pool *pgxpool.Pool) *RefreshTokenDB {
+	return &RefreshTokenDB{pool: pool}
+}
+
+func (r *RefreshTokenDB) Create(ctx context.Context, token domain.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, client_id, agent_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7, $8)
+	`
+	_, err := r.pool.Exec(ctx, query,
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ClientID, token.AgentID, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+func (r *RefreshTokenDB) GetByHash(ctx context.Context, tokenHash string) (domain.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, client_id, COALESCE(agent_id::text, ''), expires_at, created_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`
+	var t domain.RefreshToken
+	err := r.pool.QueryRow(ctx, query, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ClientID, &t.AgentID,
+		&t.ExpiresAt, &t.CreatedAt, &t.UsedAt, &t.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.RefreshToken{}, auth.ErrInvalidRefreshToken
+	}
+	return t, err
+}
+
+// MarkUsed consumes a token. It fails with ErrRefreshTokenReused if the token
+// was already consumed or revoked, which also covers concurrent refreshes.
+func (r *RefreshTokenDB) MarkUsed(ctx context.Context, id string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL
+	`
+	result, err := r.pool.Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return auth.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (r *RefreshTokenDB) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	_, err := r.pool.Exec(ctx, query, time.Now(), familyID)
+	return err
+}
+
+// BindAgent links every token of the family, and the ones it rotates into,
+// to the agent
+func (r *RefreshTokenDB) BindAgent(ctx context.Context, familyID, agentID string) error {
+	query := `UPDATE refresh_tokens SET agent_id = $1 WHERE family_id = $2`
+	_, err := r.pool.Exec(ctx, query, agentID, familyID)
+	return err
+}
+
+func (r *RefreshTokenDB) RevokeAgent(ctx context.Context, agentID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE agent_id = $2 AND revoked_at IS NULL`
+	_, err := r.pool.Exec(ctx, query, time.Now(), agentID)
+	return err
+}
+
+func (r *RefreshTokenDB) DeleteExpired(ctx context.Context) error {
+	_, err := r.pool.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < $1", time.Now())
+	return err
+}
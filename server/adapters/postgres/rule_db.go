@@ -61,7 +61,8 @@ func (db *RuleDB) GetRule(ctx context.Context, id string) (domain.Rule, error) {
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		WHERE id = $1
 	`, id).Scan(
@@ -70,6 +71,7 @@ func (db *RuleDB) GetRule(ctx context.Context, id string) (domain.Rule, error) {
 		&rule.TargetTeams, &rule.TargetUsers, &rule.Tags, &triggersJSON, &rule.TeamID, &rule.Force, &rule.Status,
 		&rule.EnforcementMode, &rule.TemporaryTimeoutHours, &rule.CreatedBy,
 		&rule.SubmittedAt, &rule.ApprovedAt, &rule.CreatedAt, &rule.UpdatedAt,
+		&rule.CurrentVersion, &rule.RestoresVersion,
 	)
 
 	if err != nil {
@@ -93,7 +95,8 @@ func (db *RuleDB) ListRulesByTeam(ctx context.Context, teamID string) ([]domain.
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		WHERE team_id = $1
 		ORDER BY priority_weight DESC, created_at DESC
@@ -119,6 +122,7 @@ func (db *RuleDB) scanRules(rows pgx.Rows) ([]domain.Rule, error) {
 			&rule.TargetTeams, &rule.TargetUsers, &rule.Tags, &triggersJSON, &rule.TeamID, &rule.Force, &rule.Status,
 			&rule.EnforcementMode, &rule.TemporaryTimeoutHours, &rule.CreatedBy,
 			&rule.SubmittedAt, &rule.ApprovedAt, &rule.CreatedAt, &rule.UpdatedAt,
+			&rule.CurrentVersion, &rule.RestoresVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -137,33 +141,47 @@ func (db *RuleDB) scanRules(rows pgx.Rows) ([]domain.Rule, error) {
 	return rulesList, nil
 }
 
-// UpdateRule updates an existing rule
+// UpdateRule updates an existing rule. Changing an approved rule, such as
+// its enforcement, takes effect at once and is recorded as its next version.
 func (db *RuleDB) UpdateRule(ctx context.Context, rule domain.Rule) error {
 	triggersJSON, err := json.Marshal(rule.Triggers)
 	if err != nil {
 		return err
 	}
 
-	result, err := db.pool.Exec(ctx, `
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var status domain.RuleStatus
+	err = tx.QueryRow(ctx, `
 		UPDATE rules
 		SET name = $2, content = $3, description = $4, target_layer = $5, category_id = $6,
 			priority_weight = $7, overridable = $8, effective_start = $9, effective_end = $10,
 			target_teams = $11, target_users = $12, tags = $13, triggers = $14,
-			enforcement_mode = $15, temporary_timeout_hours = $16, updated_at = $17
+			enforcement_mode = $15, temporary_timeout_hours = $16, updated_at = $17,
+			restores_version = $18
 		WHERE id = $1
+		RETURNING status
 	`, rule.ID, rule.Name, rule.Content, rule.Description, rule.TargetLayer, rule.CategoryID,
 		rule.PriorityWeight, rule.Overridable, rule.EffectiveStart, rule.EffectiveEnd,
 		rule.TargetTeams, rule.TargetUsers, rule.Tags, triggersJSON,
-		rule.EnforcementMode, rule.TemporaryTimeoutHours, rule.UpdatedAt)
+		rule.EnforcementMode, rule.TemporaryTimeoutHours, rule.UpdatedAt, rule.RestoresVersion).Scan(&status)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return rules.ErrRuleNotFound
+		}
 		return err
 	}
 
-	if result.RowsAffected() == 0 {
-		return rules.ErrRuleNotFound
+	if status == domain.RuleStatusApproved {
+		if err := recordRuleVersion(ctx, tx, rule.ID); err != nil {
+			return err
+		}
 	}
-
-	return nil
+	return tx.Commit(ctx)
 }
 
 // DeleteRule removes a rule by ID
@@ -183,20 +201,61 @@ func (db *RuleDB) DeleteRule(ctx context.Context, id string) error {
 	return nil
 }
 
-// UpdateStatus updates the status and related timestamps of a rule
+// UpdateStatus updates the status and related timestamps of a rule. Approving
+// a rule records its content, triggers, enforcement and targeting as the
+// rule's next version.
 func (db *RuleDB) UpdateStatus(ctx context.Context, rule domain.Rule) error {
-	result, err := db.pool.Exec(ctx, `
-		UPDATE rules SET status = $2, submitted_at = $3, approved_at = $4, updated_at = $5
-		WHERE id = $1
-	`, rule.ID, rule.Status, rule.SubmittedAt, rule.ApprovedAt, rule.UpdatedAt)
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
+	var previous domain.RuleStatus
+	err = tx.QueryRow(ctx, `SELECT status FROM rules WHERE id = $1 FOR UPDATE`, rule.ID).Scan(&previous)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return rules.ErrRuleNotFound
+		}
 		return err
 	}
-	if result.RowsAffected() == 0 {
-		return rules.ErrRuleNotFound
+
+	if rule.Status == domain.RuleStatusApproved && previous != domain.RuleStatusApproved {
+		if err := recordRuleVersion(ctx, tx, rule.ID); err != nil {
+			return err
+		}
 	}
-	return nil
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE rules SET status = $2, submitted_at = $3, approved_at = $4, updated_at = $5
+		WHERE id = $1
+	`, rule.ID, rule.Status, rule.SubmittedAt, rule.ApprovedAt, rule.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// recordRuleVersion snapshots the rule as its next version
+func recordRuleVersion(ctx context.Context, tx pgx.Tx, ruleID string) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO rule_versions (
+			rule_id, version, name, description, content, target_layer, priority_weight,
+			overridable, effective_start, effective_end, target_teams, target_users, tags,
+			triggers, force, enforcement_mode, temporary_timeout_hours, restored_from, created_at
+		)
+		SELECT id, current_version + 1, name, description, content, target_layer, priority_weight,
+			overridable, effective_start, effective_end, COALESCE(target_teams, '{}'),
+			COALESCE(target_users, '{}'), COALESCE(tags, '{}'), triggers, force,
+			enforcement_mode, temporary_timeout_hours, restores_version, NOW()
+		FROM rules WHERE id = $1
+	`, ruleID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE rules SET current_version = current_version + 1, restores_version = NULL
+		WHERE id = $1
+	`, ruleID)
+	return err
 }
 
 // ListByStatus retrieves all rules for a team with a specific status
@@ -206,7 +265,8 @@ func (db *RuleDB) ListByStatus(ctx context.Context, teamID string, status domain
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules WHERE team_id = $1 AND status = $2
 		ORDER BY created_at DESC
 	`, teamID, status)
@@ -225,7 +285,8 @@ func (db *RuleDB) ListPendingByScope(ctx context.Context, scope domain.TargetLay
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules WHERE target_layer = $1 AND status = 'pending'
 		ORDER BY submitted_at ASC
 	`, scope)
@@ -244,7 +305,8 @@ func (db *RuleDB) GetRulesForMerge(ctx context.Context, targetLayer domain.Targe
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		WHERE status = 'approved'
 		  AND (
@@ -279,7 +341,8 @@ func (db *RuleDB) ListByTargetLayer(ctx context.Context, targetLayer domain.Targ
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		WHERE target_layer = $1 AND status = 'approved'
 		ORDER BY priority_weight DESC, name
@@ -299,7 +362,8 @@ func (db *RuleDB) ListGlobalRules(ctx context.Context) ([]domain.Rule, error) {
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		WHERE team_id IS NULL
 		ORDER BY force DESC, priority_weight DESC, created_at DESC
@@ -319,7 +383,8 @@ func (db *RuleDB) ListAllRules(ctx context.Context) ([]domain.Rule, error) {
 			priority_weight, overridable, effective_start, effective_end,
 			target_teams, target_users, tags, triggers, team_id, force, status,
 			enforcement_mode, temporary_timeout_hours, created_by,
-			submitted_at, approved_at, created_at, updated_at
+			submitted_at, approved_at, created_at, updated_at,
+			current_version, restores_version
 		FROM rules
 		ORDER BY created_at DESC
 	`)
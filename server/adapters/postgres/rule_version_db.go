@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/ruleversions"
+)
+
+// RuleVersionDB reads the versions recorded when rules are approved. Versions
+// are written by RuleDB.UpdateStatus.
+type RuleVersionDB struct {
+	pool *pgxpool.Pool
+}
+
+func NewRuleVersionDB(pool *pgxpool.Pool) *RuleVersionDB {
+	return &RuleVersionDB{pool: pool}
+}
+
+const ruleVersionColumns = `rule_id, version, name, description, content, target_layer, priority_weight,
+	overridable, effective_start, effective_end, target_teams, target_users, tags,
+	triggers, force, enforcement_mode, temporary_timeout_hours, restored_from, created_at`
+
+// ListVersions returns the rule's versions, newest first
+func (db *RuleVersionDB) ListVersions(ctx context.Context, ruleID string) ([]domain.RuleVersion, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT `+ruleVersionColumns+`
+		FROM rule_versions
+		WHERE rule_id = $1
+		ORDER BY version DESC
+	`, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []domain.RuleVersion{}
+	for rows.Next() {
+		v, err := scanRuleVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (db *RuleVersionDB) GetVersion(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error) {
+	row := db.pool.QueryRow(ctx, `
+		SELECT `+ruleVersionColumns+`
+		FROM rule_versions
+		WHERE rule_id = $1 AND version = $2
+	`, ruleID, version)
+	v, err := scanRuleVersion(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.RuleVersion{}, ruleversions.ErrVersionNotFound
+	}
+	return v, err
+}
+
+// CountAgentsByVersion returns how many agents last reported applying each
+// version of the rule
+func (db *RuleVersionDB) CountAgentsByVersion(ctx context.Context, ruleID string) (map[int]int, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT arv.version, COUNT(*)
+		FROM agent_rule_versions arv
+		JOIN agents a ON a.id = arv.agent_id
+		WHERE arv.rule_id = $1 AND a.status <> 'revoked'
+		GROUP BY arv.version
+	`, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var version, count int
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, err
+		}
+		counts[version] = count
+	}
+	return counts, rows.Err()
+}
+
+func scanRuleVersion(row pgx.Row) (domain.RuleVersion, error) {
+	var v domain.RuleVersion
+	var triggersJSON []byte
+	if err := row.Scan(
+		&v.RuleID, &v.Version, &v.Name, &v.Description, &v.Content, &v.TargetLayer, &v.PriorityWeight,
+		&v.Overridable, &v.EffectiveStart, &v.EffectiveEnd, &v.TargetTeams, &v.TargetUsers, &v.Tags,
+		&triggersJSON, &v.Force, &v.EnforcementMode, &v.TemporaryTimeoutHours, &v.RestoredFrom, &v.CreatedAt,
+	); err != nil {
+		return domain.RuleVersion{}, err
+	}
+	if err := json.Unmarshal(triggersJSON, &v.Triggers); err != nil {
+		return domain.RuleVersion{}, err
+	}
+	return v, nil
+}
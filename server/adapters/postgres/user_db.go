@@ -8,9 +8,10 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/auth"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var ErrUserNotFound = auth.ErrUserNotFound
 var ErrEmailExists = errors.New("email already exists")
 
 type UserDB struct {
@@ -2,6 +2,9 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -43,6 +46,79 @@ func (c *Client) Subscribe(ctx context.Context, channels ...string) *redis.PubSu
 	return c.rdb.Subscribe(ctx, channels...)
 }
 
+// streamField is the field holding the message of a stream entry
+const streamField = "data"
+
+// StreamMessage is an entry read from a stream
+type StreamMessage struct {
+	ID   string
+	Data []byte
+}
+
+// StreamAdd appends a message to a stream and returns its ID. Entries older
+// than maxAge are trimmed, approximately, as part of the same call.
+func (c *Client) StreamAdd(ctx context.Context, stream string, message []byte, maxAge time.Duration) (string, error) {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{streamField: message},
+	}
+	if maxAge > 0 {
+		// Entry IDs start with their creation time in milliseconds
+		args.MinID = strconv.FormatInt(time.Now().Add(-maxAge).UnixMilli(), 10)
+		args.Approx = true
+	}
+	return c.rdb.XAdd(ctx, args).Result()
+}
+
+// CreateStreamGroup creates a consumer group that reads entries added to the
+// stream from now on, creating the stream if needed. An existing group keeps
+// its position.
+func (c *Client) CreateStreamGroup(ctx context.Context, stream, group string) error {
+	err := c.rdb.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// ReadStreamGroup reads up to count entries for a consumer of a group. An id
+// of ">" waits up to block for entries never delivered to the group, without
+// waiting when block is zero; any other id returns the consumer's delivered
+// but unacknowledged entries after it.
+func (c *Client) ReadStreamGroup(ctx context.Context, stream, group, consumer, id string, count int64, block time.Duration) ([]StreamMessage, error) {
+	if block <= 0 {
+		// go-redis sends a zero block, which waits forever; negative omits it
+		block = -1
+	}
+	result, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []StreamMessage
+	for _, s := range result {
+		for _, m := range s.Messages {
+			data, _ := m.Values[streamField].(string)
+			messages = append(messages, StreamMessage{ID: m.ID, Data: []byte(data)})
+		}
+	}
+	return messages, nil
+}
+
+// AckStream acknowledges entries processed by a consumer group
+func (c *Client) AckStream(ctx context.Context, stream, group string, ids ...string) error {
+	return c.rdb.XAck(ctx, stream, group, ids...).Err()
+}
+
 // Set stores a value with optional TTL
 func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	return c.rdb.Set(ctx, key, value, ttl).Err()
@@ -58,6 +134,52 @@ func (c *Client) Del(ctx context.Context, keys ...string) error {
 	return c.rdb.Del(ctx, keys...).Err()
 }
 
+// HashSetWithMarkers writes fields of a hash and, in the same transaction,
+// sets a marker key per field that expires after ttl. The markers tell which
+// fields are still being refreshed.
+func (c *Client) HashSetWithMarkers(ctx context.Context, hash string, fields map[string][]byte, markerPrefix string, ttl time.Duration) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for field, value := range fields {
+			pipe.HSet(ctx, hash, field, value)
+			pipe.Set(ctx, markerPrefix+field, 1, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// HashGetAll returns every field of a hash
+func (c *Client) HashGetAll(ctx context.Context, hash string) (map[string]string, error) {
+	return c.rdb.HGetAll(ctx, hash).Result()
+}
+
+// HashDel removes fields from a hash
+func (c *Client) HashDel(ctx context.Context, hash string, fields ...string) error {
+	return c.rdb.HDel(ctx, hash, fields...).Err()
+}
+
+// ExistsEach reports for each key whether it exists
+func (c *Client) ExistsEach(ctx context.Context, keys []string) ([]bool, error) {
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		exists[i] = cmd.Val() > 0
+	}
+	return exists, nil
+}
+
 // Underlying returns the underlying redis.Client for advanced use
 func (c *Client) Underlying() *redis.Client {
 	return c.rdb
@@ -178,3 +178,110 @@ func TestClient_ConnectionPooling(t *testing.T) {
 
 	// Should complete without errors
 }
+
+func TestClient_StreamGroupResumesPending(t *testing.T) {
+	client, err := NewClient("redis://localhost:6379/0")
+	if err != nil {
+		t.Skip("Redis not available:", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skip("Redis not available:", err)
+	}
+
+	stream := "test-stream-" + time.Now().Format(time.RFC3339Nano)
+	defer func() { _ = client.Del(ctx, stream) }()
+
+	if err := client.CreateStreamGroup(ctx, stream, "workers"); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	// Creating it again keeps the group's position
+	if err := client.CreateStreamGroup(ctx, stream, "workers"); err != nil {
+		t.Fatalf("failed to recreate group: %v", err)
+	}
+
+	for _, msg := range []string{"first", "second"} {
+		if _, err := client.StreamAdd(ctx, stream, []byte(msg), time.Hour); err != nil {
+			t.Fatalf("failed to add to stream: %v", err)
+		}
+	}
+
+	delivered, err := client.ReadStreamGroup(ctx, stream, "workers", "worker-1", ">", 10, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if len(delivered) != 2 || string(delivered[0].Data) != "first" {
+		t.Fatalf("expected both entries in order, got %+v", delivered)
+	}
+	if err := client.AckStream(ctx, stream, "workers", delivered[0].ID); err != nil {
+		t.Fatalf("failed to ack: %v", err)
+	}
+
+	// After a restart the consumer first gets what it never acknowledged
+	pending, err := client.ReadStreamGroup(ctx, stream, "workers", "worker-1", "0", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to read pending entries: %v", err)
+	}
+	if len(pending) != 1 || string(pending[0].Data) != "second" {
+		t.Errorf("expected the unacknowledged entry, got %+v", pending)
+	}
+
+	fresh, err := client.ReadStreamGroup(ctx, stream, "workers", "worker-1", ">", 10, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if len(fresh) != 0 {
+		t.Errorf("expected no new entries, got %+v", fresh)
+	}
+}
+
+func TestClient_HashSetWithMarkers(t *testing.T) {
+	client, err := NewClient("redis://localhost:6379/0")
+	if err != nil {
+		t.Skip("Redis not available:", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skip("Redis not available:", err)
+	}
+
+	suffix := time.Now().Format(time.RFC3339Nano)
+	hash := "test-hash-" + suffix
+	prefix := "test-marker-" + suffix + ":"
+	defer func() { _ = client.Del(ctx, hash) }()
+
+	fields := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+	if err := client.HashSetWithMarkers(ctx, hash, fields, prefix, 300*time.Millisecond); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	got, err := client.HashGetAll(ctx, hash)
+	if err != nil || len(got) != 2 || got["b"] != "2" {
+		t.Fatalf("unexpected hash %v (%v)", got, err)
+	}
+
+	exists, err := client.ExistsEach(ctx, []string{prefix + "a", prefix + "missing"})
+	if err != nil || !exists[0] || exists[1] {
+		t.Errorf("expected only the set marker to exist, got %v (%v)", exists, err)
+	}
+
+	// Markers expire while the hash keeps the fields
+	time.Sleep(400 * time.Millisecond)
+	exists, _ = client.ExistsEach(ctx, []string{prefix + "a"})
+	if exists[0] {
+		t.Error("expected marker to expire")
+	}
+	if got, _ := client.HashGetAll(ctx, hash); len(got) != 2 {
+		t.Errorf("expected fields to outlive markers, got %v", got)
+	}
+
+	if err := client.HashDel(ctx, hash, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := client.HashGetAll(ctx, hash); len(got) != 1 {
+		t.Errorf("expected 1 field after delete, got %v", got)
+	}
+}
@@ -14,19 +14,36 @@ import (
 	"github.com/kamilrybacki/edictflow/server/adapters/splunk"
 	"github.com/kamilrybacki/edictflow/server/configurator"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/events"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
 	"github.com/kamilrybacki/edictflow/server/services/approvals"
 	"github.com/kamilrybacki/edictflow/server/services/attachments"
 	"github.com/kamilrybacki/edictflow/server/services/audit"
 	"github.com/kamilrybacki/edictflow/server/services/auth"
+	"github.com/kamilrybacki/edictflow/server/services/changes"
 	"github.com/kamilrybacki/edictflow/server/services/deviceauth"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
+	"github.com/kamilrybacki/edictflow/server/services/exceptions"
+	"github.com/kamilrybacki/edictflow/server/services/impact"
 	"github.com/kamilrybacki/edictflow/server/services/library"
 	"github.com/kamilrybacki/edictflow/server/services/metrics"
 	"github.com/kamilrybacki/edictflow/server/services/notifications"
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+	"github.com/kamilrybacki/edictflow/server/services/preview"
 	"github.com/kamilrybacki/edictflow/server/services/publisher"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+	"github.com/kamilrybacki/edictflow/server/services/ruleversions"
 )
 
 func main() {
 	settings := configurator.LoadSettings()
+	if err := settings.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	ctx := context.Background()
 
 	// Initialize database connection
@@ -60,6 +77,7 @@ func main() {
 	// Initialize Redis (optional - graceful degradation)
 	var pub publisher.Publisher
 	var redisClient *redisAdapter.Client
+	redisConnected := false
 	redisClient, err = redisAdapter.NewClient(settings.RedisURL)
 	if err != nil {
 		log.Printf("Warning: Redis not available, events will not be published: %v", err)
@@ -71,9 +89,17 @@ func main() {
 			pub = &publisher.NoOpPublisher{}
 		} else {
 			log.Println("Connected to Redis")
-			redisPub := publisher.NewRedisPublisher(redisClient)
-			redisPub.SetMetrics(metricsService)
-			pub = redisPub
+			redisConnected = true
+			if settings.EventTransport == configurator.EventTransportStreams {
+				streamPub := publisher.NewStreamPublisher(redisClient, settings.EventStreamMaxAge)
+				streamPub.SetMetrics(metricsService)
+				pub = streamPub
+				log.Printf("Publishing events to stream %s", events.StreamEvents)
+			} else {
+				redisPub := publisher.NewRedisPublisher(redisClient)
+				redisPub.SetMetrics(metricsService)
+				pub = redisPub
+			}
 		}
 	}
 
@@ -131,6 +157,13 @@ func main() {
 	notificationChannelDB := postgres.NewNotificationChannelDB(pool)
 	auditDB := postgres.NewAuditDB(pool)
 	ruleAttachmentDB := postgres.NewRuleAttachmentDB(pool)
+	refreshTokenDB := postgres.NewRefreshTokenDB(pool)
+	driftDB := postgres.NewDriftDB(pool)
+	agentProjectDB := postgres.NewAgentProjectDB(pool)
+	agentDB := postgres.NewAgentDB(pool)
+	agentCommandDB := postgres.NewAgentCommandDB(pool)
+	changeRequestDB := changeRequestRepo{postgres.NewChangeRequestDB(pool)}
+	exceptionRequestDB := exceptionRequestRepo{postgres.NewExceptionRequestDB(pool)}
 
 	// Create services that implement the handler interfaces
 	teamService := &teamServiceImpl{db: teamDB, inviteDB: teamInviteDB, userDB: userDB}
@@ -138,16 +171,65 @@ func main() {
 	categoryService := &categoryServiceImpl{db: categoryDB}
 	userService := &userServiceImpl{db: userDB}
 	usersService := &usersServiceImpl{db: userDB, roleDB: roleDB}
-	authService := auth.NewService(userDB, roleDB, settings.JWTSecret, 24*time.Hour)
+	authService := auth.NewService(userDB, roleDB, settings.JWTSecret, 24*time.Hour).
+		WithRefreshTokens(refreshTokenDB, auth.DefaultRefreshTokenExpiry)
+	go deleteExpiredRefreshTokens(ctx, authService, time.Hour)
 	auditService := audit.NewService(auditDB)
 	approvalsService := approvals.NewService(ruleDB, approvalDB, approvalConfigDB, roleDB).WithAuditLogger(auditService)
-	deviceAuthService := deviceauth.NewService(deviceCodeDB, authService)
+	ruleVersionService := ruleversions.NewService(postgres.NewRuleVersionDB(pool), ruleDB, approvalsService).
+		WithAuditLogger(auditService)
+	deviceAuthService := deviceauth.NewService(deviceCodeDB, authService).WithRefreshTokens(authService)
 	notificationSvc := notifications.NewService(notificationDB, notificationChannelDB)
 	notificationService := &notificationServiceWrapper{svc: notificationSvc}
 
 	// Library and attachments services
 	attachmentsSvc := attachments.NewService(ruleAttachmentDB, ruleDB, teamDB)
 	librarySvc := library.NewService(ruleDB, attachmentsSvc)
+	driftService := drift.NewService(driftDB, teamDB)
+
+	// The preview resolves rule sets like the workers, without committing revisions
+	ruleSetService := rulesets.NewService(ruleDB, ruleAttachmentDB, categoryDB, teamDB, postgres.NewConfigRevisionDB(pool))
+	previewService := preview.NewService(ruleSetService, userDB)
+
+	// Approvers see which teams, agents and projects a pending rule reaches
+	impactService := impact.NewService(ruleDB, teamDB, ruleAttachmentDB, userDB, agentDB, agentProjectDB, ruleSetService)
+
+	// Change and exception decisions and revocations reach the affected agent
+	// through the worker it is connected to
+	agentNotifier := ws.NewRemoteNotifier(pub)
+	agentService := agents.NewService(agentDB, agentProjectDB).
+		WithAuditLogger(auditService).
+		WithWebSocketNotifier(agentNotifier).
+		WithRefreshTokens(authService)
+	go markInactiveAgents(ctx, agentService, settings.AgentStaleAfter, settings.AgentOfflineAfter)
+
+	// Workers publish the agents connected to them in Redis
+	var presenceService handlers.PresenceService
+	if redisConnected {
+		presenceSvc := presence.NewService(presence.NewRedisStore(redisClient, presence.DefaultTTL), ruleSetService).
+			WithNotifier(notificationSvc)
+		go sweepPresence(ctx, presenceSvc, presence.DefaultTTL/3)
+		presenceService = presenceSvc
+	}
+
+	changeSvc := changes.NewService(changeRequestDB, ruleDB, agentDB).
+		WithNotifier(notificationSvc).
+		WithAuditLogger(auditService).
+		WithWebSocketNotifier(agentNotifier)
+	exceptionSvc := exceptions.NewService(exceptionRequestDB, changeRequestDB).
+		WithNotifier(notificationSvc).
+		WithAuditLogger(auditService).
+		WithWebSocketNotifier(agentNotifier)
+
+	agentCommandSvc := agentcommands.NewService(agentCommandDB, agentService, agentNotifier, auditService)
+
+	// Workers forward the agents' change and exception messages and command
+	// results here
+	agentMessageSvc := agentmessages.NewService(changeSvc, exceptionSvc)
+	agentMessageSvc.SetCommands(agentCommandSvc)
+	if settings.InternalAPIToken == "" {
+		log.Println("Warning: INTERNAL_API_TOKEN not set, agent messages from workers will be refused")
+	}
 
 	// Create router (no WebSocket - handled by workers)
 	router := api.NewRouter(api.Config{
@@ -155,8 +237,11 @@ func main() {
 		BaseURL:             settings.BaseURL,
 		TeamService:         teamService,
 		RuleService:         ruleService,
+		RuleVersionService:  ruleVersionService,
+		RuleImpactService:   impactService,
 		CategoryService:     categoryService,
 		AuthService:         authService,
+		RefreshTokenService: authService,
 		UserService:         userService,
 		UsersService:        usersService,
 		ApprovalsService:    approvalsService,
@@ -166,6 +251,15 @@ func main() {
 		AuditService:        auditService,
 		LibraryService:      librarySvc,
 		AttachmentService:   attachmentsSvc,
+		DriftService:        driftService,
+		AgentService:        agentService,
+		ChangeService:       changeServiceWrapper{changeSvc},
+		ExceptionService:    exceptionServiceWrapper{exceptionSvc},
+		AgentMessageService: agentMessageSvc,
+		AgentCommandService: agentCommandSvc,
+		PresenceService:     presenceService,
+		PreviewService:      previewService,
+		InternalToken:       settings.InternalAPIToken,
 		Publisher:           pub,
 		MetricsService:      metricsService,
 	})
@@ -205,3 +299,52 @@ func main() {
 	<-done
 	log.Println("Master stopped")
 }
+
+// markInactiveAgents moves agents that stopped sending heartbeats to stale and
+// then offline. Only the master runs it, so workers do not race each other.
+func markInactiveAgents(ctx context.Context, svc *agents.Service, staleAfter, offlineAfter time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := svc.MarkInactive(ctx, staleAfter, offlineAfter); err != nil {
+				log.Printf("Failed to update inactive agents: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deleteExpiredRefreshTokens keeps expired refresh tokens from piling up
+func deleteExpiredRefreshTokens(ctx context.Context, svc *auth.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := svc.DeleteExpiredRefreshTokens(ctx); err != nil {
+				log.Printf("Failed to delete expired refresh tokens: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweepPresence reports agents whose worker stopped refreshing their presence
+func sweepPresence(ctx context.Context, svc *presence.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := svc.Sweep(ctx); err != nil {
+				log.Printf("Failed to sweep agent presence: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
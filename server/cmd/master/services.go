@@ -7,6 +7,8 @@ import (
 	"github.com/kamilrybacki/edictflow/server/adapters/postgres"
 	"github.com/kamilrybacki/edictflow/server/domain"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/changes"
+	"github.com/kamilrybacki/edictflow/server/services/exceptions"
 	"github.com/kamilrybacki/edictflow/server/services/merge"
 	"github.com/kamilrybacki/edictflow/server/services/notifications"
 )
@@ -129,6 +131,8 @@ func (s *ruleServiceImpl) Create(ctx context.Context, req handlers.CreateRuleReq
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		}
 	}
 
@@ -362,3 +366,87 @@ func (w *notificationServiceWrapper) MarkRead(ctx context.Context, id string) er
 func (w *notificationServiceWrapper) MarkAllRead(ctx context.Context, userID string) error {
 	return w.svc.MarkAllRead(ctx, userID)
 }
+
+// changeRequestRepo adapts postgres.ChangeRequestDB to changes.ChangeRequestRepository
+type changeRequestRepo struct {
+	*postgres.ChangeRequestDB
+}
+
+var _ changes.ChangeRequestRepository = changeRequestRepo{}
+
+func (r changeRequestRepo) ListByTeam(ctx context.Context, teamID string, filter changes.ChangeRequestFilter) ([]domain.ChangeRequest, error) {
+	return r.ChangeRequestDB.ListByTeam(ctx, teamID, postgres.ChangeRequestFilter{
+		Status:          filter.Status,
+		EnforcementMode: filter.EnforcementMode,
+		RuleID:          filter.RuleID,
+		AgentID:         filter.AgentID,
+		UserID:          filter.UserID,
+		Limit:           filter.Limit,
+		Offset:          filter.Offset,
+	})
+}
+
+// exceptionRequestRepo adapts postgres.ExceptionRequestDB to exceptions.ExceptionRequestRepository
+type exceptionRequestRepo struct {
+	*postgres.ExceptionRequestDB
+}
+
+var _ exceptions.ExceptionRequestRepository = exceptionRequestRepo{}
+
+func (r exceptionRequestRepo) ListByTeam(ctx context.Context, teamID string, filter exceptions.ExceptionRequestFilter) ([]domain.ExceptionRequest, error) {
+	return r.ExceptionRequestDB.ListByTeam(ctx, teamID, postgres.ExceptionRequestFilter{
+		Status:          filter.Status,
+		ExceptionType:   filter.ExceptionType,
+		ChangeRequestID: filter.ChangeRequestID,
+		UserID:          filter.UserID,
+		Limit:           filter.Limit,
+		Offset:          filter.Offset,
+	})
+}
+
+// changeServiceWrapper wraps changes.Service to implement handlers.ChangeService
+type changeServiceWrapper struct {
+	*changes.Service
+}
+
+var _ handlers.ChangeService = changeServiceWrapper{}
+
+func (w changeServiceWrapper) ListByTeam(ctx context.Context, teamID string, filter handlers.ChangeRequestFilter) ([]domain.ChangeRequest, error) {
+	return w.Service.ListByTeam(ctx, teamID, changes.ChangeRequestFilter{
+		Status:          filter.Status,
+		EnforcementMode: filter.EnforcementMode,
+		RuleID:          filter.RuleID,
+		AgentID:         filter.AgentID,
+		UserID:          filter.UserID,
+		Limit:           filter.Limit,
+		Offset:          filter.Offset,
+	})
+}
+
+// exceptionServiceWrapper wraps exceptions.Service to implement handlers.ExceptionService
+type exceptionServiceWrapper struct {
+	*exceptions.Service
+}
+
+var _ handlers.ExceptionService = exceptionServiceWrapper{}
+
+func (w exceptionServiceWrapper) ListByTeam(ctx context.Context, teamID string, filter handlers.ExceptionRequestFilter) ([]domain.ExceptionRequest, error) {
+	return w.Service.ListByTeam(ctx, teamID, exceptions.ExceptionRequestFilter{
+		Status:          filter.Status,
+		ExceptionType:   filter.ExceptionType,
+		ChangeRequestID: filter.ChangeRequestID,
+		UserID:          filter.UserID,
+		Limit:           filter.Limit,
+		Offset:          filter.Offset,
+	})
+}
+
+func (w exceptionServiceWrapper) Create(ctx context.Context, req handlers.CreateExceptionServiceRequest) (*domain.ExceptionRequest, error) {
+	return w.Service.Create(ctx, exceptions.CreateExceptionRequest{
+		ChangeRequestID:        req.ChangeRequestID,
+		UserID:                 req.UserID,
+		Justification:          req.Justification,
+		ExceptionType:          req.ExceptionType,
+		RequestedDurationHours: req.RequestedDurationHours,
+	})
+}
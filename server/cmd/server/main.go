@@ -14,6 +14,7 @@ import (
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
 	"github.com/kamilrybacki/edictflow/server/services/approvals"
 	"github.com/kamilrybacki/edictflow/server/services/audit"
 	"github.com/kamilrybacki/edictflow/server/services/auth"
@@ -40,31 +41,42 @@ func main() {
 	approvalDB := postgres.NewRuleApprovalDB(pool)
 	approvalConfigDB := postgres.NewApprovalConfigDB(pool)
 	auditDB := postgres.NewAuditDB(pool)
+	refreshTokenDB := postgres.NewRefreshTokenDB(pool)
+	agentProjectDB := postgres.NewAgentProjectDB(pool)
+	agentDB := postgres.NewAgentDB(pool)
 
 	// Create services that implement the handler interfaces
 	teamService := &teamServiceImpl{db: teamDB, inviteDB: teamInviteDB, userDB: userDB}
 	ruleService := &ruleServiceImpl{db: ruleDB, categoryDB: categoryDB}
 	categoryService := &categoryServiceImpl{db: categoryDB}
 	userService := &userServiceImpl{db: userDB}
-	authService := auth.NewService(userDB, roleDB, settings.JWTSecret, 24*time.Hour)
+	authService := auth.NewService(userDB, roleDB, settings.JWTSecret, 24*time.Hour).
+		WithRefreshTokens(refreshTokenDB, auth.DefaultRefreshTokenExpiry)
+	go deleteExpiredRefreshTokens(ctx, authService, time.Hour)
 	approvalsService := approvals.NewService(ruleDB, approvalDB, approvalConfigDB, roleDB)
 	auditService := audit.NewService(auditDB)
+	agentService := agents.NewService(agentDB, agentProjectDB).
+		WithAuditLogger(auditService).
+		WithRefreshTokens(authService)
 
 	// Initialize WebSocket hub
 	hub := ws.NewHub()
 	go hub.Run()
 
-	// Create router with real services
+	// Create router with real services. Drift endpoints are left out: the
+	// hub below does not record the drift agents report.
 	router := api.NewRouter(api.Config{
-		JWTSecret:        settings.JWTSecret,
-		TeamService:      teamService,
-		RuleService:      ruleService,
-		CategoryService:  categoryService,
-		AuthService:      authService,
-		UserService:      userService,
-		ApprovalsService: approvalsService,
-		InviteService:    teamService,
-		AuditService:     auditService,
+		JWTSecret:           settings.JWTSecret,
+		TeamService:         teamService,
+		RuleService:         ruleService,
+		CategoryService:     categoryService,
+		AuthService:         authService,
+		RefreshTokenService: authService,
+		UserService:         userService,
+		ApprovalsService:    approvalsService,
+		InviteService:       teamService,
+		AuditService:        auditService,
+		AgentService:        agentService,
 	})
 
 	// Add WebSocket endpoint (with auth middleware)
@@ -107,3 +119,19 @@ func main() {
 	<-done
 	log.Println("Server stopped")
 }
+
+// deleteExpiredRefreshTokens keeps expired refresh tokens from piling up
+func deleteExpiredRefreshTokens(ctx context.Context, svc *auth.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := svc.DeleteExpiredRefreshTokens(ctx); err != nil {
+				log.Printf("Failed to delete expired refresh tokens: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
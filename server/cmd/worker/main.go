@@ -13,16 +13,24 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/kamilrybacki/edictflow/server/adapters/postgres"
 	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
 	"github.com/kamilrybacki/edictflow/server/adapters/splunk"
 	"github.com/kamilrybacki/edictflow/server/configurator"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
 	"github.com/kamilrybacki/edictflow/server/services/metrics"
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
 	"github.com/kamilrybacki/edictflow/server/worker"
 )
 
 func main() {
 	settings := configurator.LoadSettings()
+	if err := settings.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	ctx := context.Background()
 
 	// Initialize Redis
@@ -37,10 +45,29 @@ func main() {
 	}
 	log.Println("Connected to Redis")
 
+	// Initialize database connection for persisting agent reports
+	pool, err := postgres.NewPool(ctx, settings.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+	driftService := drift.NewService(postgres.NewDriftDB(pool), postgres.NewTeamDB(pool))
+	agentService := agents.NewService(postgres.NewAgentDB(pool), postgres.NewAgentProjectDB(pool))
+	ruleSetService := rulesets.NewService(
+		postgres.NewRuleDB(pool),
+		postgres.NewRuleAttachmentDB(pool),
+		postgres.NewCategoryDB(pool),
+		postgres.NewTeamDB(pool),
+		postgres.NewConfigRevisionDB(pool),
+	)
+
+	// A stable worker ID lets a restarted worker resume the event stream
+	hostname, _ := os.Hostname()
+	workerID := getEnv("WORKER_ID", hostname)
+
 	// Initialize metrics service
 	var metricsService metrics.Service
 	if settings.SplunkEnabled && settings.SplunkHECURL != "" {
-		hostname, _ := os.Hostname()
 		metricsService = metrics.NewSplunkService(metrics.Config{
 			SplunkConfig: splunk.Config{
 				HECURL:        settings.SplunkHECURL,
@@ -61,11 +88,16 @@ func main() {
 	// Initialize worker hub
 	hub := worker.NewHub(redisClient)
 	hub.SetMetrics(metricsService)
+	hub.SetRuleSets(ruleSetService)
+	hub.SetSlowConsumerTimeout(settings.SlowConsumerTimeout)
+	hub.SetPresence(presence.NewRedisStore(redisClient, presence.DefaultTTL), workerID)
+	if settings.EventTransport == configurator.EventTransportStreams {
+		hub.ConsumeStream(workerID)
+	}
 	go hub.Run()
 
 	// Start hub stats reporter and worker heartbeat if metrics enabled
 	if settings.SplunkEnabled {
-		workerID, _ := os.Hostname()
 		go func() {
 			ticker := time.NewTicker(30 * time.Second)
 			defer ticker.Stop()
@@ -133,6 +165,15 @@ func main() {
 	// WebSocket endpoint with auth
 	auth := middleware.NewAuth(settings.JWTSecret)
 	wsHandler := worker.NewHandler(hub)
+	wsHandler.SetDriftRecorder(driftService)
+	wsHandler.SetContextRecorder(agentService)
+	wsHandler.SetRegistry(agentService)
+	wsHandler.SetMinAgentVersion(settings.MinAgentVersion)
+	if settings.MasterURL != "" && settings.InternalAPIToken != "" {
+		wsHandler.SetForwarder(worker.NewMasterClient(settings.MasterURL, settings.InternalAPIToken))
+	} else {
+		log.Println("Warning: MASTER_URL or INTERNAL_API_TOKEN not set, change and exception messages from agents will be dropped")
+	}
 	router.With(auth.Middleware).Get("/ws", wsHandler.ServeHTTP)
 
 	// Get worker port (different from API port)
@@ -1,12 +1,32 @@
 package configurator
 
 import (
+	"fmt"
 	"os"
+	"time"
+)
+
+// Event transports between the master and workers
+const (
+	// EventTransportPubSub publishes events to Redis channels; events sent
+	// while no worker listens are lost
+	EventTransportPubSub = "pubsub"
+	// EventTransportStreams appends events to a Redis stream that workers
+	// read with consumer groups, resuming after restarts
+	EventTransportStreams = "streams"
 )
 
 type Settings struct {
 	DatabaseURL         string
 	RedisURL            string
+	EventTransport      string
+	EventStreamMaxAge   time.Duration
+	AgentStaleAfter     time.Duration
+	AgentOfflineAfter   time.Duration
+	SlowConsumerTimeout time.Duration
+	MinAgentVersion     string
+	MasterURL           string
+	InternalAPIToken    string
 	ServerPort          string
 	JWTSecret           string
 	BaseURL             string
@@ -24,6 +44,14 @@ func LoadSettings() Settings {
 	return Settings{
 		DatabaseURL:         getEnv("DATABASE_URL", "postgres://localhost:5432/edictflow?sslmode=disable"),
 		RedisURL:            getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		EventTransport:      getEnv("EVENT_TRANSPORT", EventTransportPubSub),
+		EventStreamMaxAge:   getDuration("EVENT_STREAM_MAX_AGE", 24*time.Hour),
+		AgentStaleAfter:     getDuration("AGENT_STALE_AFTER", 3*time.Minute),
+		AgentOfflineAfter:   getDuration("AGENT_OFFLINE_AFTER", 15*time.Minute),
+		SlowConsumerTimeout: getDuration("WS_SLOW_CONSUMER_TIMEOUT", 30*time.Second),
+		MinAgentVersion:     getEnv("MIN_AGENT_VERSION", ""),
+		MasterURL:           getEnv("MASTER_URL", ""),
+		InternalAPIToken:    getEnv("INTERNAL_API_TOKEN", ""),
 		ServerPort:          port,
 		JWTSecret:           getEnv("JWT_SECRET", "dev-secret-change-in-production"),
 		BaseURL:             getEnv("BASE_URL", "http://localhost:"+port),
@@ -37,9 +65,26 @@ func LoadSettings() Settings {
 	}
 }
 
+// Validate reports settings that would make the server misbehave silently
+func (s Settings) Validate() error {
+	switch s.EventTransport {
+	case EventTransportPubSub, EventTransportStreams:
+	default:
+		return fmt.Errorf("unknown EVENT_TRANSPORT %q, expected %q or %q", s.EventTransport, EventTransportPubSub, EventTransportStreams)
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return defaultValue
+}
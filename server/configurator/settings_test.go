@@ -3,6 +3,7 @@ package configurator
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadSettings_RedisURL(t *testing.T) {
@@ -21,3 +22,77 @@ func TestLoadSettings_RedisURL(t *testing.T) {
 		t.Errorf("expected custom redis URL, got %s", settings.RedisURL)
 	}
 }
+
+func TestLoadSettings_EventTransport(t *testing.T) {
+	os.Unsetenv("EVENT_TRANSPORT")
+	os.Unsetenv("EVENT_STREAM_MAX_AGE")
+	settings := LoadSettings()
+	if settings.EventTransport != EventTransportPubSub {
+		t.Errorf("expected pubsub transport by default, got %s", settings.EventTransport)
+	}
+	if settings.EventStreamMaxAge != 24*time.Hour {
+		t.Errorf("expected 24h stream max age by default, got %s", settings.EventStreamMaxAge)
+	}
+
+	os.Setenv("EVENT_TRANSPORT", EventTransportStreams)
+	os.Setenv("EVENT_STREAM_MAX_AGE", "2h")
+	defer os.Unsetenv("EVENT_TRANSPORT")
+	defer os.Unsetenv("EVENT_STREAM_MAX_AGE")
+	settings = LoadSettings()
+	if settings.EventTransport != EventTransportStreams {
+		t.Errorf("expected streams transport, got %s", settings.EventTransport)
+	}
+	if settings.EventStreamMaxAge != 2*time.Hour {
+		t.Errorf("expected 2h stream max age, got %s", settings.EventStreamMaxAge)
+	}
+
+	// Invalid durations fall back to the default
+	os.Setenv("EVENT_STREAM_MAX_AGE", "forever")
+	if settings = LoadSettings(); settings.EventStreamMaxAge != 24*time.Hour {
+		t.Errorf("expected default max age for invalid value, got %s", settings.EventStreamMaxAge)
+	}
+}
+
+func TestLoadSettings_AgentInactivity(t *testing.T) {
+	os.Unsetenv("AGENT_STALE_AFTER")
+	os.Unsetenv("AGENT_OFFLINE_AFTER")
+	settings := LoadSettings()
+	if settings.AgentStaleAfter != 3*time.Minute || settings.AgentOfflineAfter != 15*time.Minute {
+		t.Errorf("expected 3m/15m by default, got %s/%s", settings.AgentStaleAfter, settings.AgentOfflineAfter)
+	}
+
+	os.Setenv("AGENT_STALE_AFTER", "90s")
+	os.Setenv("AGENT_OFFLINE_AFTER", "1h")
+	defer os.Unsetenv("AGENT_STALE_AFTER")
+	defer os.Unsetenv("AGENT_OFFLINE_AFTER")
+	settings = LoadSettings()
+	if settings.AgentStaleAfter != 90*time.Second || settings.AgentOfflineAfter != time.Hour {
+		t.Errorf("expected 90s/1h, got %s/%s", settings.AgentStaleAfter, settings.AgentOfflineAfter)
+	}
+}
+
+func TestLoadSettings_SlowConsumerTimeout(t *testing.T) {
+	os.Unsetenv("WS_SLOW_CONSUMER_TIMEOUT")
+	if settings := LoadSettings(); settings.SlowConsumerTimeout != 30*time.Second {
+		t.Errorf("expected 30s by default, got %s", settings.SlowConsumerTimeout)
+	}
+
+	os.Setenv("WS_SLOW_CONSUMER_TIMEOUT", "2m")
+	defer os.Unsetenv("WS_SLOW_CONSUMER_TIMEOUT")
+	if settings := LoadSettings(); settings.SlowConsumerTimeout != 2*time.Minute {
+		t.Errorf("expected 2m, got %s", settings.SlowConsumerTimeout)
+	}
+}
+
+func TestSettings_Validate(t *testing.T) {
+	for _, transport := range []string{EventTransportPubSub, EventTransportStreams} {
+		if err := (Settings{EventTransport: transport}).Validate(); err != nil {
+			t.Errorf("expected %s to be valid, got %v", transport, err)
+		}
+	}
+	for _, transport := range []string{"stream", "Streams", "redis"} {
+		if err := (Settings{EventTransport: transport}).Validate(); err == nil {
+			t.Errorf("expected %q to be rejected", transport)
+		}
+	}
+}
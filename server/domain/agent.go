@@ -12,16 +12,34 @@ const (
 	AgentStatusOnline  AgentStatus = "online"
 	AgentStatusStale   AgentStatus = "stale"
 	AgentStatusOffline AgentStatus = "offline"
+	AgentStatusRevoked AgentStatus = "revoked"
 )
 
+func (s AgentStatus) IsValid() bool {
+	switch s {
+	case AgentStatusOnline, AgentStatusStale, AgentStatusOffline, AgentStatusRevoked:
+		return true
+	}
+	return false
+}
+
 type Agent struct {
 	ID                  string      `json:"id"`
 	MachineID           string      `json:"machine_id"`
 	UserID              string      `json:"user_id"`
+	TeamID              string      `json:"team_id,omitempty"`
+	Hostname            string      `json:"hostname,omitempty"`
+	OS                  string      `json:"os,omitempty"`
+	Version             string      `json:"version,omitempty"`
 	Status              AgentStatus `json:"status"`
 	LastHeartbeat       time.Time   `json:"last_heartbeat"`
 	CachedConfigVersion int         `json:"cached_config_version"`
 	CreatedAt           time.Time   `json:"created_at"`
+	RevokedAt           *time.Time  `json:"revoked_at,omitempty"`
+	RevokedBy           *string     `json:"revoked_by,omitempty"`
+	// UpgradeRequiredReason is why a worker last told the agent to upgrade,
+	// empty once it connects with a supported version
+	UpgradeRequiredReason string `json:"upgrade_required_reason,omitempty"`
 }
 
 func NewAgent(machineID, userID string) Agent {
@@ -40,3 +58,35 @@ func NewAgent(machineID, userID string) Agent {
 func (a Agent) IsStale(threshold time.Duration) bool {
 	return time.Since(a.LastHeartbeat) > threshold
 }
+
+func (a Agent) IsRevoked() bool {
+	return a.Status == AgentStatusRevoked
+}
+
+// InactiveStatus returns the status an agent should have given how long ago
+// it last sent a heartbeat. Revoked agents keep their status.
+func (a Agent) InactiveStatus(staleAfter, offlineAfter time.Duration) AgentStatus {
+	switch {
+	case a.IsRevoked():
+		return a.Status
+	case a.IsStale(offlineAfter):
+		return AgentStatusOffline
+	case a.IsStale(staleAfter):
+		return AgentStatusStale
+	}
+	return a.Status
+}
+
+// AgentHeartbeat is the state an agent reports in each heartbeat
+type AgentHeartbeat struct {
+	AgentID       string
+	UserID        string
+	TeamID        string
+	Hostname      string
+	OS            string
+	Version       string
+	CachedVersion int
+	// RuleVersions maps the rules the agent applied to their versions. Nil
+	// when the agent does not report them.
+	RuleVersions map[string]int
+}
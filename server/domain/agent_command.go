@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AgentCommandType is an action an admin asks an agent to perform
+type AgentCommandType string
+
+const (
+	AgentCommandResync            AgentCommandType = "resync"
+	AgentCommandTamperCheck       AgentCommandType = "tamper_check"
+	AgentCommandDiagnostics       AgentCommandType = "diagnostics"
+	AgentCommandRotateCredentials AgentCommandType = "rotate_credentials"
+	AgentCommandShutdown          AgentCommandType = "shutdown"
+)
+
+func (t AgentCommandType) IsValid() bool {
+	switch t {
+	case AgentCommandResync, AgentCommandTamperCheck, AgentCommandDiagnostics,
+		AgentCommandRotateCredentials, AgentCommandShutdown:
+		return true
+	}
+	return false
+}
+
+type AgentCommandStatus string
+
+const (
+	// AgentCommandStatusPending commands were issued but not delivered to
+	// any connection of the agent
+	AgentCommandStatusPending   AgentCommandStatus = "pending"
+	AgentCommandStatusSent      AgentCommandStatus = "sent"
+	AgentCommandStatusSucceeded AgentCommandStatus = "succeeded"
+	AgentCommandStatusFailed    AgentCommandStatus = "failed"
+)
+
+// IsFinal reports whether the agent has answered the command
+func (s AgentCommandStatus) IsFinal() bool {
+	return s == AgentCommandStatusSucceeded || s == AgentCommandStatusFailed
+}
+
+// AgentCommand is a command sent to an agent together with the result the
+// agent reported for it
+type AgentCommand struct {
+	ID          string             `json:"id"`
+	AgentID     string             `json:"agent_id"`
+	Type        AgentCommandType   `json:"type"`
+	Status      AgentCommandStatus `json:"status"`
+	IssuedBy    string             `json:"issued_by"`
+	Result      json.RawMessage    `json:"result,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+func NewAgentCommand(agentID string, commandType AgentCommandType, issuedBy string) AgentCommand {
+	return AgentCommand{
+		ID:        uuid.New().String(),
+		AgentID:   agentID,
+		Type:      commandType,
+		Status:    AgentCommandStatusPending,
+		IssuedBy:  issuedBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (c AgentCommand) Validate() error {
+	if c.AgentID == "" {
+		return errors.New("agent ID cannot be empty")
+	}
+	if c.IssuedBy == "" {
+		return errors.New("issuer cannot be empty")
+	}
+	if !c.Type.IsValid() {
+		return fmt.Errorf("unknown command %q", c.Type)
+	}
+	return nil
+}
+
+// Complete records the agent's answer. A non-empty errMsg marks the command
+// failed.
+func (c *AgentCommand) Complete(result json.RawMessage, errMsg string) {
+	now := time.Now()
+	c.Result = result
+	c.Error = errMsg
+	c.CompletedAt = &now
+	c.Status = AgentCommandStatusSucceeded
+	if errMsg != "" {
+		c.Status = AgentCommandStatusFailed
+	}
+}
@@ -0,0 +1,47 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+func TestNewAgentCommandIsPending(t *testing.T) {
+	cmd := domain.NewAgentCommand("agent-1", domain.AgentCommandDiagnostics, "user-1")
+
+	if cmd.ID == "" {
+		t.Error("expected an ID")
+	}
+	if cmd.Status != domain.AgentCommandStatusPending {
+		t.Errorf("expected status 'pending', got '%s'", cmd.Status)
+	}
+	if err := cmd.Validate(); err != nil {
+		t.Errorf("expected valid command, got %v", err)
+	}
+}
+
+func TestAgentCommand_ValidateRejectsUnknownType(t *testing.T) {
+	cmd := domain.NewAgentCommand("agent-1", "format_disk", "user-1")
+
+	if err := cmd.Validate(); err == nil {
+		t.Error("expected error for unknown command type")
+	}
+}
+
+func TestAgentCommand_Complete(t *testing.T) {
+	cmd := domain.NewAgentCommand("agent-1", domain.AgentCommandResync, "user-1")
+	cmd.Complete(json.RawMessage(`{"cached_version":4}`), "")
+
+	if cmd.Status != domain.AgentCommandStatusSucceeded || cmd.CompletedAt == nil {
+		t.Errorf("expected succeeded command with completion time, got %+v", cmd)
+	}
+	if !cmd.Status.IsFinal() {
+		t.Error("expected succeeded to be final")
+	}
+
+	cmd.Complete(nil, "not allowed")
+	if cmd.Status != domain.AgentCommandStatusFailed || cmd.Error != "not allowed" {
+		t.Errorf("expected failed command, got %+v", cmd)
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// AgentProject is a project watched by an agent, with the contexts and tags
+// the agent detected in it
+type AgentProject struct {
+	AgentID          string   `json:"agent_id"`
+	UserID           string   `json:"user_id"`
+	TeamID           string   `json:"team_id,omitempty"`
+	ProjectPath      string   `json:"project_path"`
+	DetectedContexts []string `json:"detected_contexts"`
+	DetectedTags     []string `json:"detected_tags"`
+	// Repository is the git remote the project is checked out from, as
+	// host/owner/name, and Branch its current branch
+	Repository string    `json:"repository,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+func (p AgentProject) Validate() error {
+	if p.AgentID == "" {
+		return errors.New("agent ID cannot be empty")
+	}
+	if p.UserID == "" {
+		return errors.New("user ID cannot be empty")
+	}
+	if p.ProjectPath == "" {
+		return errors.New("project path cannot be empty")
+	}
+	return nil
+}
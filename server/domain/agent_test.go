@@ -37,3 +37,26 @@ func TestAgentIsNotStaleWithinThreshold(t *testing.T) {
 		t.Error("expected agent NOT to be stale within threshold")
 	}
 }
+
+func TestAgentInactiveStatus(t *testing.T) {
+	agent := domain.NewAgent("machine-abc123", "user-456")
+
+	if got := agent.InactiveStatus(time.Minute, time.Hour); got != domain.AgentStatusOnline {
+		t.Errorf("expected recent agent to stay online, got '%s'", got)
+	}
+
+	agent.LastHeartbeat = time.Now().Add(-10 * time.Minute)
+	if got := agent.InactiveStatus(time.Minute, time.Hour); got != domain.AgentStatusStale {
+		t.Errorf("expected 'stale', got '%s'", got)
+	}
+
+	agent.LastHeartbeat = time.Now().Add(-2 * time.Hour)
+	if got := agent.InactiveStatus(time.Minute, time.Hour); got != domain.AgentStatusOffline {
+		t.Errorf("expected 'offline', got '%s'", got)
+	}
+
+	agent.Status = domain.AgentStatusRevoked
+	if got := agent.InactiveStatus(time.Minute, time.Hour); got != domain.AgentStatusRevoked {
+		t.Errorf("expected revoked agent to stay revoked, got '%s'", got)
+	}
+}
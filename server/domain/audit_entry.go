@@ -15,6 +15,8 @@ const (
 	AuditEntityRole           AuditEntityType = "role"
 	AuditEntityTeam           AuditEntityType = "team"
 	AuditEntityApprovalConfig AuditEntityType = "approval_config"
+	AuditEntityAgentCommand   AuditEntityType = "agent_command"
+	AuditEntityAgent          AuditEntityType = "agent"
 )
 
 type AuditAction string
@@ -31,6 +33,9 @@ const (
 	AuditActionRoleRemoved       AuditAction = "role_removed"
 	AuditActionPermissionAdded   AuditAction = "permission_added"
 	AuditActionPermissionRemoved AuditAction = "permission_removed"
+	AuditActionCommandIssued     AuditAction = "command_issued"
+	AuditActionCommandCompleted  AuditAction = "command_completed"
+	AuditActionRevoked           AuditAction = "revoked"
 )
 
 type ChangeValue struct {
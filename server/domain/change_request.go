@@ -2,11 +2,17 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// MaxDiffContentBytes caps the stored unified diff of a change request.
+// Agents cap diffs themselves; this guards against older or misbehaving agents.
+const MaxDiffContentBytes = 64 * 1024
+
 type ChangeRequestStatus string
 
 const (
@@ -60,7 +66,7 @@ func NewChangeRequest(
 		FilePath:        filePath,
 		OriginalHash:    originalHash,
 		ModifiedHash:    modifiedHash,
-		DiffContent:     diffContent,
+		DiffContent:     capDiffContent(diffContent),
 		Status:          ChangeRequestStatusPending,
 		EnforcementMode: enforcementMode,
 		TimeoutAt:       timeoutAt,
@@ -138,5 +144,37 @@ func (cr *ChangeRequest) IsExpired() bool {
 
 func (cr *ChangeRequest) UpdateDiff(newHash, newDiff string) {
 	cr.ModifiedHash = newHash
-	cr.DiffContent = newDiff
+	cr.DiffContent = capDiffContent(newDiff)
+}
+
+// DiffStats counts added and removed lines in the unified diff, ignoring the
+// file headers before the first hunk
+func (cr ChangeRequest) DiffStats() (additions, deletions int) {
+	inHunk := false
+	for _, line := range strings.Split(cr.DiffContent, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+			continue
+		case strings.HasPrefix(line, "+"):
+			additions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return additions, deletions
+}
+
+// capDiffContent truncates a diff on a line boundary to MaxDiffContentBytes
+func capDiffContent(diff string) string {
+	if len(diff) <= MaxDiffContentBytes {
+		return diff
+	}
+	marker := fmt.Sprintf("... diff truncated (%d bytes total)\n", len(diff))
+	cut := MaxDiffContentBytes - len(marker)
+	if idx := strings.LastIndexByte(diff[:cut], '\n'); idx >= 0 {
+		cut = idx + 1
+	}
+	return diff[:cut] + marker
 }
@@ -0,0 +1,72 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+func TestNewChangeRequestKeepsDiff(t *testing.T) {
+	diff := "--- CLAUDE.md (original)\n+++ CLAUDE.md (modified)\n@@ -1 +1 @@\n-a\n+b\n"
+	cr := domain.NewChangeRequest("rule", "agent", "user", "team", "CLAUDE.md", "h1", "h2", diff, domain.EnforcementModeBlock, nil)
+
+	if cr.DiffContent != diff {
+		t.Errorf("expected diff to be stored unchanged, got %q", cr.DiffContent)
+	}
+}
+
+func TestChangeRequestDiffIsCapped(t *testing.T) {
+	huge := strings.Repeat("+added line\n", domain.MaxDiffContentBytes)
+	cr := domain.NewChangeRequest("rule", "agent", "user", "team", "CLAUDE.md", "h1", "h2", huge, domain.EnforcementModeBlock, nil)
+
+	if len(cr.DiffContent) > domain.MaxDiffContentBytes {
+		t.Errorf("expected diff capped at %d bytes, got %d", domain.MaxDiffContentBytes, len(cr.DiffContent))
+	}
+	if !strings.HasSuffix(cr.DiffContent, "bytes total)\n") {
+		t.Error("expected truncation marker at end of diff")
+	}
+
+	cr.UpdateDiff("h3", huge)
+	if len(cr.DiffContent) > domain.MaxDiffContentBytes {
+		t.Errorf("expected updated diff capped, got %d bytes", len(cr.DiffContent))
+	}
+	if cr.ModifiedHash != "h3" {
+		t.Errorf("expected modified hash h3, got %s", cr.ModifiedHash)
+	}
+}
+
+func TestChangeRequestDiffStats(t *testing.T) {
+	diff := "--- CLAUDE.md (original)\n" +
+		"+++ CLAUDE.md (modified)\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" keep\n" +
+		"-old one\n" +
+		"-old two\n" +
+		"+new one\n"
+	cr := domain.ChangeRequest{DiffContent: diff}
+
+	additions, deletions := cr.DiffStats()
+	if additions != 1 {
+		t.Errorf("expected 1 addition, got %d", additions)
+	}
+	if deletions != 2 {
+		t.Errorf("expected 2 deletions, got %d", deletions)
+	}
+}
+
+func TestChangeRequestDiffStats_HeaderLikeLinesInHunk(t *testing.T) {
+	// Removing "-- note" and adding "++ x" look like file headers
+	diff := "--- CLAUDE.md (original)\n" +
+		"+++ CLAUDE.md (modified)\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" keep\n" +
+		"--- note\n" +
+		"+++ x\n"
+	cr := domain.ChangeRequest{DiffContent: diff}
+
+	additions, deletions := cr.DiffStats()
+	if additions != 1 || deletions != 1 {
+		t.Errorf("expected 1 addition and 1 deletion, got %d and %d", additions, deletions)
+	}
+}
@@ -0,0 +1,84 @@
+package domain
+
+import "sort"
+
+// ConfigChangeAction is what happened to a rule in a team's configuration
+type ConfigChangeAction string
+
+const (
+	ConfigChangeUpsert ConfigChangeAction = "upsert"
+	ConfigChangeRemove ConfigChangeAction = "remove"
+)
+
+// ConfigChange records a rule added to, updated in or removed from a team's
+// configuration at a revision
+type ConfigChange struct {
+	TeamID   string             `json:"team_id"`
+	Revision int64              `json:"revision"`
+	RuleID   string             `json:"rule_id"`
+	Action   ConfigChangeAction `json:"action"`
+}
+
+// TeamConfigState summarizes a team's resolved configuration: a checksum per
+// rule and one for the settings that apply to all rules
+type TeamConfigState struct {
+	Rules    map[string]string
+	Settings string
+}
+
+// Diff returns the rule changes from previous to s, ordered by rule ID, and
+// whether the settings changed
+func (s TeamConfigState) Diff(previous TeamConfigState) (changes []ConfigChange, settingsChanged bool) {
+	for ruleID, checksum := range s.Rules {
+		if previous.Rules[ruleID] != checksum {
+			changes = append(changes, ConfigChange{RuleID: ruleID, Action: ConfigChangeUpsert})
+		}
+	}
+	for ruleID := range previous.Rules {
+		if _, ok := s.Rules[ruleID]; !ok {
+			changes = append(changes, ConfigChange{RuleID: ruleID, Action: ConfigChangeRemove})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].RuleID < changes[j].RuleID })
+	return changes, s.Settings != previous.Settings
+}
+
+// ConfigRevision is the result of committing a team's configuration
+type ConfigRevision struct {
+	TeamID string
+	// Revision is the team's current revision; it increases by one with
+	// every change and never decreases
+	Revision int64
+	// Previous is the revision before the commit, equal to Revision when
+	// nothing changed
+	Previous int64
+	// PrunedThrough is the last revision no longer in the change log. Deltas
+	// can only be computed from later revisions.
+	PrunedThrough int64
+}
+
+// Changed reports whether the commit created a new revision
+func (r ConfigRevision) Changed() bool {
+	return r.Revision != r.Previous
+}
+
+// CollapseChanges reduces a change log to the final action per rule,
+// returning the rules to upsert and to remove, each ordered by rule ID
+func CollapseChanges(changes []ConfigChange) (upserted, removed []string) {
+	final := make(map[string]ConfigChange)
+	for _, c := range changes {
+		if last, ok := final[c.RuleID]; !ok || c.Revision >= last.Revision {
+			final[c.RuleID] = c
+		}
+	}
+	for ruleID, c := range final {
+		if c.Action == ConfigChangeRemove {
+			removed = append(removed, ruleID)
+		} else {
+			upserted = append(upserted, ruleID)
+		}
+	}
+	sort.Strings(upserted)
+	sort.Strings(removed)
+	return upserted, removed
+}
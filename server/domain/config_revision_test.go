@@ -0,0 +1,76 @@
+package domain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+func TestTeamConfigState_Diff(t *testing.T) {
+	previous := domain.TeamConfigState{
+		Rules:    map[string]string{"a": "1", "b": "2", "c": "3"},
+		Settings: "s1",
+	}
+	current := domain.TeamConfigState{
+		Rules:    map[string]string{"a": "1", "b": "changed", "d": "4"},
+		Settings: "s1",
+	}
+
+	changes, settingsChanged := current.Diff(previous)
+
+	want := []domain.ConfigChange{
+		{RuleID: "b", Action: domain.ConfigChangeUpsert},
+		{RuleID: "c", Action: domain.ConfigChangeRemove},
+		{RuleID: "d", Action: domain.ConfigChangeUpsert},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Diff() = %+v, want %+v", changes, want)
+	}
+	if settingsChanged {
+		t.Error("expected settings to be unchanged")
+	}
+
+	current.Settings = "s2"
+	if _, settingsChanged := current.Diff(previous); !settingsChanged {
+		t.Error("expected settings change to be detected")
+	}
+}
+
+func TestTeamConfigState_DiffFromEmpty(t *testing.T) {
+	current := domain.TeamConfigState{Rules: map[string]string{"a": "1"}}
+
+	changes, _ := current.Diff(domain.TeamConfigState{})
+
+	if len(changes) != 1 || changes[0].Action != domain.ConfigChangeUpsert {
+		t.Errorf("expected a single upsert, got %+v", changes)
+	}
+}
+
+func TestCollapseChanges(t *testing.T) {
+	changes := []domain.ConfigChange{
+		{Revision: 2, RuleID: "a", Action: domain.ConfigChangeUpsert},
+		{Revision: 3, RuleID: "a", Action: domain.ConfigChangeRemove},
+		{Revision: 3, RuleID: "b", Action: domain.ConfigChangeRemove},
+		{Revision: 4, RuleID: "b", Action: domain.ConfigChangeUpsert},
+		{Revision: 4, RuleID: "c", Action: domain.ConfigChangeUpsert},
+	}
+
+	upserted, removed := domain.CollapseChanges(changes)
+
+	if !reflect.DeepEqual(upserted, []string{"b", "c"}) {
+		t.Errorf("upserted = %v, want [b c]", upserted)
+	}
+	if !reflect.DeepEqual(removed, []string{"a"}) {
+		t.Errorf("removed = %v, want [a]", removed)
+	}
+}
+
+func TestConfigRevision_Changed(t *testing.T) {
+	if (domain.ConfigRevision{Revision: 3, Previous: 3}).Changed() {
+		t.Error("expected unchanged revision")
+	}
+	if !(domain.ConfigRevision{Revision: 4, Previous: 3}).Changed() {
+		t.Error("expected changed revision")
+	}
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// DriftStatus is how a managed file on an agent compares to the rules it should contain
+type DriftStatus string
+
+const (
+	DriftStatusOK             DriftStatus = "ok"
+	DriftStatusMissingFile    DriftStatus = "missing_file"
+	DriftStatusMissingMarkers DriftStatus = "missing_markers"
+	DriftStatusStale          DriftStatus = "stale"
+	DriftStatusTampered       DriftStatus = "tampered"
+	DriftStatusError          DriftStatus = "error"
+)
+
+func (s DriftStatus) IsValid() bool {
+	switch s {
+	case DriftStatusOK, DriftStatusMissingFile, DriftStatusMissingMarkers,
+		DriftStatusStale, DriftStatusTampered, DriftStatusError:
+		return true
+	}
+	return false
+}
+
+// FileDrift is the reported state of one managed file
+type FileDrift struct {
+	Path         string      `json:"path"`
+	Level        string      `json:"level"`
+	Status       DriftStatus `json:"status"`
+	ExpectedHash string      `json:"expected_hash"`
+	ActualHash   string      `json:"actual_hash"`
+	RuleIDs      []string    `json:"rule_ids"`
+}
+
+func (f FileDrift) Drifted() bool {
+	return f.Status != DriftStatusOK
+}
+
+// AgentDrift is the latest drift report received from an agent
+type AgentDrift struct {
+	AgentID    string      `json:"agent_id"`
+	UserID     string      `json:"user_id"`
+	TeamID     string      `json:"team_id,omitempty"`
+	Hostname   string      `json:"hostname,omitempty"`
+	ReportedAt time.Time   `json:"reported_at"`
+	Files      []FileDrift `json:"files"`
+}
+
+func (a AgentDrift) Validate() error {
+	if a.AgentID == "" {
+		return errors.New("agent ID cannot be empty")
+	}
+	if a.UserID == "" {
+		return errors.New("user ID cannot be empty")
+	}
+	for _, f := range a.Files {
+		if f.Path == "" {
+			return errors.New("file path cannot be empty")
+		}
+		if !f.Status.IsValid() {
+			return errors.New("invalid drift status: " + string(f.Status))
+		}
+	}
+	return nil
+}
+
+// DriftedFiles returns the number of files that do not match their rules
+func (a AgentDrift) DriftedFiles() int {
+	count := 0
+	for _, f := range a.Files {
+		if f.Drifted() {
+			count++
+		}
+	}
+	return count
+}
+
+// IsOutdated reports whether the last report is older than the threshold,
+// meaning the agent's current state is unknown
+func (a AgentDrift) IsOutdated(threshold time.Duration, now time.Time) bool {
+	return threshold > 0 && now.Sub(a.ReportedAt) > threshold
+}
+
+// AgentDriftSummary condenses one agent's report for a team overview
+type AgentDriftSummary struct {
+	AgentID      string    `json:"agent_id"`
+	UserID       string    `json:"user_id"`
+	Hostname     string    `json:"hostname,omitempty"`
+	ReportedAt   time.Time `json:"reported_at"`
+	Files        int       `json:"files"`
+	DriftedFiles int       `json:"drifted_files"`
+	Outdated     bool      `json:"outdated"`
+}
+
+// TeamDriftSummary aggregates the latest drift reports of a team's agents
+type TeamDriftSummary struct {
+	TeamID           string              `json:"team_id"`
+	ThresholdMinutes int                 `json:"threshold_minutes"`
+	Agents           int                 `json:"agents"`
+	CompliantAgents  int                 `json:"compliant_agents"`
+	DriftedAgents    int                 `json:"drifted_agents"`
+	OutdatedAgents   int                 `json:"outdated_agents"`
+	StatusCounts     map[DriftStatus]int `json:"status_counts"`
+	AgentSummaries   []AgentDriftSummary `json:"agent_summaries"`
+}
+
+// SummarizeTeamDrift builds a team summary. Agents whose last report is older
+// than thresholdMinutes are counted as outdated rather than compliant.
+func SummarizeTeamDrift(teamID string, thresholdMinutes int, reports []AgentDrift, now time.Time) TeamDriftSummary {
+	summary := TeamDriftSummary{
+		TeamID:           teamID,
+		ThresholdMinutes: thresholdMinutes,
+		Agents:           len(reports),
+		StatusCounts:     make(map[DriftStatus]int),
+		AgentSummaries:   make([]AgentDriftSummary, 0, len(reports)),
+	}
+	threshold := time.Duration(thresholdMinutes) * time.Minute
+
+	for _, report := range reports {
+		agent := AgentDriftSummary{
+			AgentID:      report.AgentID,
+			UserID:       report.UserID,
+			Hostname:     report.Hostname,
+			ReportedAt:   report.ReportedAt,
+			Files:        len(report.Files),
+			DriftedFiles: report.DriftedFiles(),
+			Outdated:     report.IsOutdated(threshold, now),
+		}
+		for _, f := range report.Files {
+			summary.StatusCounts[f.Status]++
+		}
+
+		switch {
+		case agent.DriftedFiles > 0:
+			summary.DriftedAgents++
+		case agent.Outdated:
+			summary.OutdatedAgents++
+		default:
+			summary.CompliantAgents++
+		}
+		summary.AgentSummaries = append(summary.AgentSummaries, agent)
+	}
+	return summary
+}
@@ -17,6 +17,7 @@ const (
 	NotificationTypeChangeAutoReverted NotificationType = "change_auto_reverted"
 	NotificationTypeExceptionGranted   NotificationType = "exception_granted"
 	NotificationTypeExceptionDenied    NotificationType = "exception_denied"
+	NotificationTypeAgentOffline       NotificationType = "agent_offline"
 )
 
 func (t NotificationType) IsValid() bool {
@@ -24,7 +25,7 @@ func (t NotificationType) IsValid() bool {
 	case NotificationTypeChangeDetected, NotificationTypeApprovalRequired,
 		NotificationTypeChangeApproved, NotificationTypeChangeRejected,
 		NotificationTypeChangeAutoReverted, NotificationTypeExceptionGranted,
-		NotificationTypeExceptionDenied:
+		NotificationTypeExceptionDenied, NotificationTypeAgentOffline:
 		return true
 	}
 	return false
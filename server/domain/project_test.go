@@ -27,3 +27,14 @@ func TestProjectMatchesPathPattern(t *testing.T) {
 		t.Error("expected path NOT to match pattern")
 	}
 }
+
+func TestProjectMatchesPathDoublestar(t *testing.T) {
+	project := domain.NewProject("src/**/*.ts", []string{}, "team-123")
+
+	if !project.MatchesPath("src/app/components/button.ts") {
+		t.Error("expected nested file to match pattern")
+	}
+	if project.MatchesPath("lib/src/index.ts") || project.MatchesPath("src/app/button.tsx") {
+		t.Error("expected paths outside src or with other extensions NOT to match")
+	}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a single-use credential for obtaining a new access token.
+// Only the SHA-256 hash of the token is stored. Tokens issued by rotating an
+// earlier token share its FamilyID, so reuse of a consumed token can revoke
+// every token descended from the same login. AgentID is set once the agent
+// that logged in registers, and is kept through rotation.
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ClientID  string     `json:"client_id"`
+	AgentID   string     `json:"agent_id,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewRefreshToken creates a refresh token and returns it with the plaintext
+// token to hand to the client. An empty familyID starts a new family.
+func NewRefreshToken(userID, familyID, clientID string, ttl time.Duration) (RefreshToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return RefreshToken{}, "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	now := time.Now()
+	return RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashRefreshToken(plaintext),
+		ClientID:  clientID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, plaintext, nil
+}
+
+// HashRefreshToken returns the stored form of a plaintext refresh token
+func HashRefreshToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
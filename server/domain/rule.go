@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kamilrybacki/edictflow/pkg/triggers"
 )
 
 type TargetLayer string
@@ -41,12 +42,18 @@ func (s RuleStatus) IsValid() bool {
 	return false
 }
 
-type TriggerType string
+// Triggers are defined in the shared pkg module so the agent resolves rules
+// for a project exactly like the server does
+type TriggerType = triggers.Type
 
 const (
-	TriggerTypePath    TriggerType = "path"
-	TriggerTypeContext TriggerType = "context"
-	TriggerTypeTag     TriggerType = "tag"
+	TriggerTypePath       = triggers.TypePath
+	TriggerTypeGlob       = triggers.TypeGlob
+	TriggerTypeRegex      = triggers.TypeRegex
+	TriggerTypeFileExists = triggers.TypeFileExists
+	TriggerTypeRepository = triggers.TypeRepository
+	TriggerTypeContext    = triggers.TypeContext
+	TriggerTypeTag        = triggers.TypeTag
 )
 
 type EnforcementMode string
@@ -65,24 +72,7 @@ func (e EnforcementMode) IsValid() bool {
 	return false
 }
 
-type Trigger struct {
-	Type         TriggerType `json:"type"`
-	Pattern      string      `json:"pattern,omitempty"`
-	ContextTypes []string    `json:"context_types,omitempty"`
-	Tags         []string    `json:"tags,omitempty"`
-}
-
-func (t Trigger) Specificity() int {
-	switch t.Type {
-	case TriggerTypePath:
-		return 100
-	case TriggerTypeContext:
-		return 50
-	case TriggerTypeTag:
-		return 10
-	}
-	return 0
-}
+type Trigger = triggers.Trigger
 
 type Rule struct {
 	ID                    string          `json:"id"`
@@ -110,6 +100,12 @@ type Rule struct {
 	ApprovedAt            *time.Time      `json:"approved_at,omitempty"`
 	CreatedAt             time.Time       `json:"created_at"`
 	UpdatedAt             time.Time       `json:"updated_at"`
+
+	// CurrentVersion is the rule's latest approved version, 0 before its
+	// first approval
+	CurrentVersion int `json:"current_version"`
+	// RestoresVersion is set while a rollback to that version awaits approval
+	RestoresVersion *int `json:"restores_version,omitempty"`
 }
 
 func NewRule(name string, targetLayer TargetLayer, content string, triggers []Trigger, teamID string) Rule {
@@ -201,7 +197,7 @@ func (r Rule) Validate() error {
 			return errors.New("force flag is only valid for global rules")
 		}
 	}
-	return nil
+	return triggers.Validate(r.Triggers)
 }
 
 func (tl TargetLayer) IsValid() bool {
@@ -251,13 +247,7 @@ func (r *Rule) TargetLayerPriority() int {
 }
 
 func (r Rule) MaxSpecificity() int {
-	maxSpecificity := 0
-	for _, trigger := range r.Triggers {
-		if s := trigger.Specificity(); s > maxSpecificity {
-			maxSpecificity = s
-		}
-	}
-	return maxSpecificity
+	return triggers.MaxSpecificity(r.Triggers)
 }
 
 func (r *Rule) CanSubmit() bool {
@@ -289,3 +279,16 @@ func (r *Rule) ResetToDraft() {
 	r.ApprovedAt = nil
 	r.UpdatedAt = time.Now()
 }
+
+// AgentLayer returns the agent file level the layer's rules are written to:
+// "enterprise", "user" or "project"
+func (tl TargetLayer) AgentLayer() string {
+	switch tl {
+	case TargetLayerOrganization, TargetLayerEnterprise:
+		return "enterprise"
+	case TargetLayerTeam, TargetLayerUser, TargetLayerGlobal:
+		return "user"
+	default:
+		return "project"
+	}
+}
@@ -53,6 +53,21 @@ func TestRuleValidateRejectsEmptyContent(t *testing.T) {
 	}
 }
 
+func TestRuleValidateChecksTriggers(t *testing.T) {
+	rule := domain.NewRule("Test Rule", domain.TargetLayerProject, "content", []domain.Trigger{
+		{Type: domain.TriggerTypeGlob, Pattern: "src/**/*.ts"},
+		{Type: domain.TriggerTypeFileExists, Pattern: "go.mod", Negate: true},
+	}, "team-123")
+	if err := rule.Validate(); err != nil {
+		t.Errorf("expected valid triggers, got %v", err)
+	}
+
+	rule.Triggers = append(rule.Triggers, domain.Trigger{Type: domain.TriggerTypeRegex, Pattern: "(unclosed"})
+	if err := rule.Validate(); err == nil {
+		t.Error("expected validation error for an invalid regex trigger")
+	}
+}
+
 func TestRuleStatus_Transitions(t *testing.T) {
 	rule := domain.NewRule("Test", domain.TargetLayerTeam, "content", nil, "team-1")
 
@@ -419,3 +434,26 @@ func TestRule_IsEnterprise(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetLayer_AgentLayer(t *testing.T) {
+	tests := []struct {
+		layer domain.TargetLayer
+		want  string
+	}{
+		{domain.TargetLayerOrganization, "enterprise"},
+		{domain.TargetLayerEnterprise, "enterprise"},
+		{domain.TargetLayerTeam, "user"},
+		{domain.TargetLayerUser, "user"},
+		{domain.TargetLayerGlobal, "user"},
+		{domain.TargetLayerProject, "project"},
+		{domain.TargetLayerLocal, "project"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.layer), func(t *testing.T) {
+			if got := tt.layer.AgentLayer(); got != tt.want {
+				t.Errorf("AgentLayer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
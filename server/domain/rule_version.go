@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"reflect"
+	"time"
+)
+
+// RuleVersion is an approved revision of a rule's content, triggers,
+// enforcement and targeting. Versions are numbered from 1 per rule and are
+// never changed once recorded.
+type RuleVersion struct {
+	RuleID                string          `json:"rule_id"`
+	Version               int             `json:"version"`
+	Name                  string          `json:"name"`
+	Content               string          `json:"content"`
+	Description           *string         `json:"description,omitempty"`
+	TargetLayer           TargetLayer     `json:"target_layer"`
+	PriorityWeight        int             `json:"priority_weight"`
+	Overridable           bool            `json:"overridable"`
+	EffectiveStart        *time.Time      `json:"effective_start,omitempty"`
+	EffectiveEnd          *time.Time      `json:"effective_end,omitempty"`
+	TargetTeams           []string        `json:"target_teams,omitempty"`
+	TargetUsers           []string        `json:"target_users,omitempty"`
+	Tags                  []string        `json:"tags,omitempty"`
+	Triggers              []Trigger       `json:"triggers"`
+	Force                 bool            `json:"force"`
+	EnforcementMode       EnforcementMode `json:"enforcement_mode"`
+	TemporaryTimeoutHours int             `json:"temporary_timeout_hours"`
+	// RestoredFrom is the version a rollback brought back
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Restore puts the version's content, triggers, enforcement and targeting
+// back on the rule and marks the rule as restoring it
+func (v RuleVersion) Restore(rule *Rule) {
+	rule.Name = v.Name
+	rule.Content = v.Content
+	rule.Description = v.Description
+	rule.TargetLayer = v.TargetLayer
+	rule.PriorityWeight = v.PriorityWeight
+	rule.Overridable = v.Overridable
+	rule.EffectiveStart = v.EffectiveStart
+	rule.EffectiveEnd = v.EffectiveEnd
+	rule.TargetTeams = v.TargetTeams
+	rule.TargetUsers = v.TargetUsers
+	rule.Tags = v.Tags
+	rule.Triggers = v.Triggers
+	rule.Force = v.Force
+	rule.EnforcementMode = v.EnforcementMode
+	rule.TemporaryTimeoutHours = v.TemporaryTimeoutHours
+	version := v.Version
+	rule.RestoresVersion = &version
+	rule.UpdatedAt = time.Now()
+}
+
+// Changes returns the fields that differ from v to other, except the
+// content, which is compared as a diff
+func (v RuleVersion) Changes(other RuleVersion) map[string]*ChangeValue {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"name", v.Name, other.Name},
+		{"description", v.Description, other.Description},
+		{"target_layer", v.TargetLayer, other.TargetLayer},
+		{"priority_weight", v.PriorityWeight, other.PriorityWeight},
+		{"overridable", v.Overridable, other.Overridable},
+		{"effective_start", v.EffectiveStart, other.EffectiveStart},
+		{"effective_end", v.EffectiveEnd, other.EffectiveEnd},
+		{"target_teams", v.TargetTeams, other.TargetTeams},
+		{"target_users", v.TargetUsers, other.TargetUsers},
+		{"tags", v.Tags, other.Tags},
+		{"triggers", v.Triggers, other.Triggers},
+		{"force", v.Force, other.Force},
+		{"enforcement_mode", v.EnforcementMode, other.EnforcementMode},
+		{"temporary_timeout_hours", v.TemporaryTimeoutHours, other.TemporaryTimeoutHours},
+	}
+
+	changes := make(map[string]*ChangeValue)
+	for _, f := range fields {
+		if !equalValues(f.old, f.new) {
+			changes[f.name] = &ChangeValue{Old: f.old, New: f.new}
+		}
+	}
+	return changes
+}
+
+// equalValues compares field values, treating nil and empty slices as equal
+// and pointers by what they point to
+func equalValues(a, b interface{}) bool {
+	if ta, ok := a.(*time.Time); ok {
+		tb := b.(*time.Time)
+		if ta == nil || tb == nil {
+			return ta == nil && tb == nil
+		}
+		return ta.Equal(*tb)
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Slice:
+		if va.Len() == 0 && vb.Len() == 0 {
+			return true
+		}
+	case reflect.Ptr:
+		if va.IsNil() || vb.IsNil() {
+			return va.IsNil() == vb.IsNil()
+		}
+		return reflect.DeepEqual(va.Elem().Interface(), vb.Elem().Interface())
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,54 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+func TestRuleVersion_Changes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sameStart := start.In(time.FixedZone("CET", 3600))
+	a := domain.RuleVersion{
+		Name:            "Secrets",
+		Tags:            nil,
+		EffectiveStart:  &start,
+		EnforcementMode: domain.EnforcementModeWarning,
+		Triggers:        []domain.Trigger{{Type: domain.TriggerTypePath, Pattern: "**/*.go"}},
+	}
+	b := a
+	b.Tags = []string{}
+	b.EffectiveStart = &sameStart
+
+	if changes := a.Changes(b); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	b.EnforcementMode = domain.EnforcementModeBlock
+	b.Triggers = []domain.Trigger{{Type: domain.TriggerTypePath, Pattern: "**/*.ts"}}
+	changes := a.Changes(b)
+	if len(changes) != 2 || changes["enforcement_mode"] == nil || changes["triggers"] == nil {
+		t.Errorf("expected enforcement and trigger changes, got %v", changes)
+	}
+}
+
+func TestRuleVersion_Restore(t *testing.T) {
+	rule := domain.NewRule("Secrets", domain.TargetLayerTeam, "new", nil, "team-1")
+	v := domain.RuleVersion{
+		Version:         3,
+		Name:            "Old Secrets",
+		Content:         "old",
+		TargetLayer:     domain.TargetLayerTeam,
+		EnforcementMode: domain.EnforcementModeWarning,
+	}
+
+	v.Restore(&rule)
+
+	if rule.Name != "Old Secrets" || rule.Content != "old" || rule.EnforcementMode != domain.EnforcementModeWarning {
+		t.Errorf("expected version 3 to be restored, got %+v", rule)
+	}
+	if rule.RestoresVersion == nil || *rule.RestoresVersion != 3 {
+		t.Errorf("expected the rule to restore version 3, got %v", rule.RestoresVersion)
+	}
+}
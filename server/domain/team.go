@@ -5,11 +5,23 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
 )
 
 type TeamSettings struct {
 	DriftThresholdMinutes int  `json:"drift_threshold_minutes"`
 	InheritGlobalRules    bool `json:"inherit_global_rules"`
+	// OutputTargets names the AI assistant files agents render rules into
+	OutputTargets []string `json:"output_targets,omitempty"`
+}
+
+// EnabledTargets returns the team's output targets, or the defaults when
+// none were chosen
+func (s TeamSettings) EnabledTargets() []string {
+	if len(s.OutputTargets) == 0 {
+		return markdown.DefaultTargetNames()
+	}
+	return s.OutputTargets
 }
 
 type Team struct {
@@ -35,5 +47,5 @@ func (t Team) Validate() error {
 	if t.Name == "" {
 		return errors.New("team name cannot be empty")
 	}
-	return nil
+	return markdown.ValidateTargetNames(t.Settings.OutputTargets)
 }
@@ -41,3 +41,20 @@ func TestNewTeam_DefaultsInheritGlobalRules(t *testing.T) {
 		t.Error("expected InheritGlobalRules to default to true")
 	}
 }
+
+func TestTeamSettings_EnabledTargets(t *testing.T) {
+	team := domain.NewTeam("Engineering")
+	if got := team.Settings.EnabledTargets(); len(got) != 1 || got[0] != "claude" {
+		t.Errorf("expected default claude target, got %v", got)
+	}
+
+	team.Settings.OutputTargets = []string{"agents", "copilot"}
+	if err := team.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	team.Settings.OutputTargets = []string{"vim"}
+	if err := team.Validate(); err == nil {
+		t.Error("expected validation error for unknown output target")
+	}
+}
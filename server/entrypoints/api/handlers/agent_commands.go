@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type AgentCommandService interface {
+	Dispatch(ctx context.Context, agentID string, commandType domain.AgentCommandType, issuedBy string) (domain.AgentCommand, error)
+	Get(ctx context.Context, agentID, id string) (domain.AgentCommand, error)
+	List(ctx context.Context, agentID string) ([]domain.AgentCommand, error)
+}
+
+type AgentCommandsHandler struct {
+	service AgentCommandService
+}
+
+func NewAgentCommandsHandler(service AgentCommandService) *AgentCommandsHandler {
+	return &AgentCommandsHandler{service: service}
+}
+
+type DispatchCommandRequest struct {
+	Command string `json:"command"`
+}
+
+// Dispatch sends a command to an agent. The result arrives asynchronously
+// and is read back with Get.
+func (h *AgentCommandsHandler) Dispatch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DispatchCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cmd, err := h.service.Dispatch(r.Context(), chi.URLParam(r, "id"), domain.AgentCommandType(req.Command), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, agentcommands.ErrInvalidCommand):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, agents.ErrAgentNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, agents.ErrAgentRevoked):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(cmd)
+}
+
+// List returns the agent's most recent commands with their results
+func (h *AgentCommandsHandler) List(w http.ResponseWriter, r *http.Request) {
+	commands, err := h.service.List(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(commands)
+}
+
+// Get returns a command with its result, once the agent has reported it
+func (h *AgentCommandsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cmd, err := h.service.Get(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "commandId"))
+	if err != nil {
+		if errors.Is(err, agentcommands.ErrCommandNotFound) {
+			http.Error(w, "command not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cmd)
+}
+
+func (h *AgentCommandsHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/{id}/commands", h.Dispatch)
+	r.Get("/{id}/commands", h.List)
+	r.Get("/{id}/commands/{commandId}", h.Get)
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type mockAgentCommandService struct {
+	commands []domain.AgentCommand
+}
+
+func (m *mockAgentCommandService) Dispatch(ctx context.Context, agentID string, commandType domain.AgentCommandType, issuedBy string) (domain.AgentCommand, error) {
+	cmd := domain.NewAgentCommand(agentID, commandType, issuedBy)
+	if err := cmd.Validate(); err != nil {
+		return domain.AgentCommand{}, fmt.Errorf("%w: %v", agentcommands.ErrInvalidCommand, err)
+	}
+	switch agentID {
+	case "unknown-agent":
+		return domain.AgentCommand{}, agents.ErrAgentNotFound
+	case "revoked-agent":
+		return domain.AgentCommand{}, agents.ErrAgentRevoked
+	}
+	cmd.Status = domain.AgentCommandStatusSent
+	m.commands = append(m.commands, cmd)
+	return cmd, nil
+}
+
+func (m *mockAgentCommandService) Get(ctx context.Context, agentID, id string) (domain.AgentCommand, error) {
+	for _, cmd := range m.commands {
+		if cmd.ID == id && cmd.AgentID == agentID {
+			return cmd, nil
+		}
+	}
+	return domain.AgentCommand{}, agentcommands.ErrCommandNotFound
+}
+
+func (m *mockAgentCommandService) List(ctx context.Context, agentID string) ([]domain.AgentCommand, error) {
+	return m.commands, nil
+}
+
+func newAgentCommandsRouter(svc handlers.AgentCommandService) *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/agents", handlers.NewAgentCommandsHandler(svc).RegisterRoutes)
+	return r
+}
+
+func TestAgentCommandsHandler_Dispatch(t *testing.T) {
+	svc := &mockAgentCommandService{}
+	r := newAgentCommandsRouter(svc)
+
+	body, _ := json.Marshal(handlers.DispatchCommandRequest{Command: "diagnostics"})
+	req := withUserContext(httptest.NewRequest("POST", "/agents/agent-1/commands", bytes.NewReader(body)), "admin-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp domain.AgentCommand
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.AgentID != "agent-1" || resp.IssuedBy != "admin-1" || resp.Type != domain.AgentCommandDiagnostics {
+		t.Errorf("unexpected command: %+v", resp)
+	}
+
+	req = httptest.NewRequest("GET", "/agents/agent-1/commands/"+resp.ID, nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for stored command, got %d", rec.Code)
+	}
+}
+
+func TestAgentCommandsHandler_DispatchUnknownCommand(t *testing.T) {
+	r := newAgentCommandsRouter(&mockAgentCommandService{})
+
+	req := withUserContext(httptest.NewRequest("POST", "/agents/agent-1/commands", bytes.NewBufferString(`{"command":"rm"}`)), "admin-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAgentCommandsHandler_DispatchToUnavailableAgent(t *testing.T) {
+	r := newAgentCommandsRouter(&mockAgentCommandService{})
+
+	for agentID, code := range map[string]int{
+		"unknown-agent": http.StatusNotFound,
+		"revoked-agent": http.StatusForbidden,
+	} {
+		body, _ := json.Marshal(handlers.DispatchCommandRequest{Command: "diagnostics"})
+		req := withUserContext(httptest.NewRequest("POST", "/agents/"+agentID+"/commands", bytes.NewReader(body)), "admin-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", agentID, code, rec.Code)
+		}
+	}
+}
+
+func TestAgentCommandsHandler_GetOtherAgentsCommand(t *testing.T) {
+	svc := &mockAgentCommandService{}
+	cmd, _ := svc.Dispatch(context.Background(), "agent-1", domain.AgentCommandResync, "admin-1")
+	r := newAgentCommandsRouter(svc)
+
+	req := httptest.NewRequest("GET", "/agents/agent-2/commands/"+cmd.ID, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+)
+
+type AgentMessageService interface {
+	Process(ctx context.Context, msg agentmessages.Message) error
+}
+
+// AgentMessagesHandler receives agent messages that workers forward to the
+// master
+type AgentMessagesHandler struct {
+	service AgentMessageService
+}
+
+func NewAgentMessagesHandler(service AgentMessageService) *AgentMessagesHandler {
+	return &AgentMessagesHandler{service: service}
+}
+
+// Forward processes one forwarded message. Rejected messages get 422, so
+// the worker tells the agent not to retry them.
+func (h *AgentMessagesHandler) Forward(w http.ResponseWriter, r *http.Request) {
+	var msg agentmessages.Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg.AgentID == "" || msg.UserID == "" {
+		http.Error(w, "agent identity is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Process(r.Context(), msg); err != nil {
+		if errors.Is(err, agentmessages.ErrRejected) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AgentMessagesHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/", h.Forward)
+}
@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+)
+
+type mockAgentMessageService struct {
+	err      error
+	received []agentmessages.Message
+}
+
+func (m *mockAgentMessageService) Process(ctx context.Context, msg agentmessages.Message) error {
+	m.received = append(m.received, msg)
+	return m.err
+}
+
+func TestAgentMessagesHandler_Forward(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{
+			name: "processed",
+			body: `{"id":"msg-1","type":"change_detected","agent_id":"agent-1","user_id":"user-1","payload":{}}`,
+			want: http.StatusNoContent,
+		},
+		{
+			name: "rejected",
+			body: `{"id":"msg-1","type":"change_detected","agent_id":"agent-1","user_id":"user-1","payload":{}}`,
+			err:  fmt.Errorf("%w: rule not found", agentmessages.ErrRejected),
+			want: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "failed",
+			body: `{"id":"msg-1","type":"change_detected","agent_id":"agent-1","user_id":"user-1","payload":{}}`,
+			err:  errors.New("database unavailable"),
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "missing identity",
+			body: `{"id":"msg-1","type":"change_detected","payload":{}}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "invalid body",
+			body: `not json`,
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &mockAgentMessageService{err: tt.err}
+			r := chi.NewRouter()
+			r.Route("/internal/agent-messages", handlers.NewAgentMessagesHandler(svc).RegisterRoutes)
+
+			req := httptest.NewRequest("POST", "/internal/agent-messages", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
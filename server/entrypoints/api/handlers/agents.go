@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+	"github.com/kamilrybacki/edictflow/server/services/auth"
+)
+
+type AgentService interface {
+	Register(ctx context.Context, reg agents.Registration) (domain.Agent, error)
+	Get(ctx context.Context, id string) (domain.Agent, error)
+	List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error)
+	Revoke(ctx context.Context, id, revokedBy string) (domain.Agent, error)
+	ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error)
+}
+
+type AgentsHandler struct {
+	service AgentService
+}
+
+func NewAgentsHandler(service AgentService) *AgentsHandler {
+	return &AgentsHandler{service: service}
+}
+
+type RegisterAgentRequest struct {
+	MachineID string `json:"machine_id"`
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Version   string `json:"version"`
+	// RefreshToken links the caller's login to the agent, so revoking the
+	// agent ends it
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// Register returns the caller's agent ID for a machine, creating the agent on
+// the first login from it
+func (h *AgentsHandler) Register(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RegisterAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	agent, err := h.service.Register(r.Context(), agents.Registration{
+		MachineID:    req.MachineID,
+		UserID:       userID,
+		TeamID:       middleware.GetTeamID(r.Context()),
+		Hostname:     req.Hostname,
+		OS:           req.OS,
+		Version:      req.Version,
+		RefreshToken: req.RefreshToken,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, agents.ErrMachineIDEmpty), errors.Is(err, auth.ErrInvalidRefreshToken):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, agents.ErrAgentRevoked):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(agent)
+}
+
+// List returns the fleet, optionally filtered by status, team or user
+func (h *AgentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := agents.Filter{
+		UserID: query.Get("user_id"),
+		TeamID: query.Get("team_id"),
+		Status: domain.AgentStatus(query.Get("status")),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *AgentsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	agent, err := h.service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, agents.ErrAgentNotFound) {
+			http.Error(w, "agent not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(agent)
+}
+
+// Revoke disconnects the agent and refuses its future connections
+func (h *AgentsHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	agent, err := h.service.Revoke(r.Context(), chi.URLParam(r, "id"), userID)
+	if err != nil {
+		if errors.Is(err, agents.ErrAgentNotFound) {
+			http.Error(w, "agent not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(agent)
+}
+
+// ListProjects returns the projects an agent watches with their detected contexts and tags
+func (h *AgentsHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	agentID := chi.URLParam(r, "id")
+
+	projects, err := h.service.ListProjects(r.Context(), agentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(projects)
+}
+
+// RegisterRoutes registers the routes every authenticated user can call
+func (h *AgentsHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/register", h.Register)
+	r.Get("/{id}/projects", h.ListProjects)
+}
+
+// RegisterFleetRoutes registers the fleet management routes, which need the
+// manage_agents permission
+func (h *AgentsHandler) RegisterFleetRoutes(r chi.Router) {
+	r.Get("/", h.List)
+	r.Get("/{id}", h.Get)
+	r.Post("/{id}/revoke", h.Revoke)
+}
@@ -0,0 +1,175 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type mockAgentService struct {
+	projects []domain.AgentProject
+	agents   map[string]domain.Agent
+	lastReg  agents.Registration
+}
+
+func (m *mockAgentService) Register(ctx context.Context, reg agents.Registration) (domain.Agent, error) {
+	m.lastReg = reg
+	if reg.MachineID == "" {
+		return domain.Agent{}, agents.ErrMachineIDEmpty
+	}
+	for _, a := range m.agents {
+		if a.MachineID == reg.MachineID && a.UserID == reg.UserID {
+			if a.IsRevoked() {
+				return domain.Agent{}, agents.ErrAgentRevoked
+			}
+			return a, nil
+		}
+	}
+	return domain.NewAgent(reg.MachineID, reg.UserID), nil
+}
+
+func (m *mockAgentService) Get(ctx context.Context, id string) (domain.Agent, error) {
+	a, ok := m.agents[id]
+	if !ok {
+		return domain.Agent{}, agents.ErrAgentNotFound
+	}
+	return a, nil
+}
+
+func (m *mockAgentService) List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error) {
+	result := []domain.Agent{}
+	for _, a := range m.agents {
+		if filter.Status == "" || a.Status == filter.Status {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAgentService) Revoke(ctx context.Context, id, revokedBy string) (domain.Agent, error) {
+	a, ok := m.agents[id]
+	if !ok {
+		return domain.Agent{}, agents.ErrAgentNotFound
+	}
+	a.Status = domain.AgentStatusRevoked
+	a.RevokedBy = &revokedBy
+	m.agents[id] = a
+	return a, nil
+}
+
+func (m *mockAgentService) ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error) {
+	result := []domain.AgentProject{}
+	for _, p := range m.projects {
+		if p.AgentID == agentID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func TestAgentsHandler_ListProjects(t *testing.T) {
+	svc := &mockAgentService{projects: []domain.AgentProject{
+		{AgentID: "agent-1", ProjectPath: "/repo", DetectedContexts: []string{"go"}, DetectedTags: []string{"cli"}},
+		{AgentID: "agent-2", ProjectPath: "/other"},
+	}}
+	r := chi.NewRouter()
+	r.Route("/agents", handlers.NewAgentsHandler(svc).RegisterRoutes)
+
+	req := httptest.NewRequest("GET", "/agents/agent-1/projects", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp []domain.AgentProject
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 1 || resp[0].ProjectPath != "/repo" || resp[0].DetectedContexts[0] != "go" {
+		t.Errorf("unexpected projects: %+v", resp)
+	}
+}
+
+func TestAgentsHandler_Register(t *testing.T) {
+	revoked := domain.NewAgent("machine-revoked", "user-1")
+	revoked.Status = domain.AgentStatusRevoked
+	svc := &mockAgentService{agents: map[string]domain.Agent{revoked.ID: revoked}}
+	r := chi.NewRouter()
+	r.Route("/agents", handlers.NewAgentsHandler(svc).RegisterRoutes)
+
+	register := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/agents/register", bytes.NewBufferString(body))
+		req = withUserContext(req, "user-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := register(`{"machine_id": "machine-1", "hostname": "laptop", "os": "linux/amd64", "version": "1.2.0"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if svc.lastReg.UserID != "user-1" || svc.lastReg.Hostname != "laptop" {
+		t.Errorf("unexpected registration: %+v", svc.lastReg)
+	}
+	var agent domain.Agent
+	if err := json.NewDecoder(rec.Body).Decode(&agent); err != nil || agent.ID == "" {
+		t.Errorf("expected agent ID in response, got %s (%v)", rec.Body.String(), err)
+	}
+
+	if rec := register(`{"hostname": "laptop"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without machine ID, got %d", rec.Code)
+	}
+	if rec := register(`{"machine_id": "machine-revoked"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for revoked agent, got %d", rec.Code)
+	}
+}
+
+func TestAgentsHandler_Revoke(t *testing.T) {
+	agent := domain.NewAgent("machine-1", "user-1")
+	svc := &mockAgentService{agents: map[string]domain.Agent{agent.ID: agent}}
+	r := chi.NewRouter()
+	r.Route("/agents", handlers.NewAgentsHandler(svc).RegisterFleetRoutes)
+
+	req := withUserContext(httptest.NewRequest("POST", "/agents/"+agent.ID+"/revoke", nil), "admin-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !svc.agents[agent.ID].IsRevoked() {
+		t.Error("expected agent to be revoked")
+	}
+
+	req = withUserContext(httptest.NewRequest("POST", "/agents/missing/revoke", nil), "admin-1")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown agent, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/agents/?status=revoked", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var list []domain.Agent
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list) != 1 {
+		t.Errorf("expected 1 revoked agent, got %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/agents/?status=sleeping", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown status, got %d", rec.Code)
+	}
+}
@@ -25,12 +25,19 @@ type ApprovalsService interface {
 
 type ApprovalsHandler struct {
 	service ApprovalsService
+	impact  RuleImpactService
 }
 
 func NewApprovalsHandler(service ApprovalsService) *ApprovalsHandler {
 	return &ApprovalsHandler{service: service}
 }
 
+// WithImpact embeds the rule's impact report in pending approval statuses
+func (h *ApprovalsHandler) WithImpact(svc RuleImpactService) *ApprovalsHandler {
+	h.impact = svc
+	return h
+}
+
 type ApprovalDecisionRequest struct {
 	Comment string `json:"comment,omitempty"`
 }
@@ -41,6 +48,7 @@ type ApprovalStatusResponse struct {
 	RequiredCount int                      `json:"required_count"`
 	CurrentCount  int                      `json:"current_count"`
 	Approvals     []ApprovalRecordResponse `json:"approvals"`
+	Impact        *RuleImpactResponse      `json:"impact,omitempty"`
 }
 
 type ApprovalRecordResponse struct {
@@ -191,6 +199,17 @@ func (h *ApprovalsHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// Approvers see who the rule reaches before deciding
+	if h.impact != nil && status.Status == domain.RuleStatusPending {
+		report, err := h.impact.Analyze(r.Context(), ruleID)
+		if err != nil {
+			response.InternalError(w, "internal server error")
+			return
+		}
+		impact := impactToResponse(report)
+		resp.Impact = &impact
+	}
+
 	response.WriteSuccess(w, resp)
 }
 
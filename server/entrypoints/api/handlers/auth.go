@@ -3,7 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/kamilrybacki/edictflow/server/domain"
@@ -22,9 +24,17 @@ type UserService interface {
 	UpdatePassword(ctx context.Context, userID, oldPassword, newPassword string) error
 }
 
+// RefreshTokenService issues and rotates refresh tokens for long-lived clients
+type RefreshTokenService interface {
+	IssueRefreshToken(ctx context.Context, userID, clientID string) (string, error)
+	Refresh(ctx context.Context, refreshToken string) (auth.TokenPair, error)
+	AccessTokenExpiry() time.Duration
+}
+
 type AuthHandler struct {
-	authService AuthService
-	userService UserService
+	authService   AuthService
+	userService   UserService
+	refreshTokens RefreshTokenService
 }
 
 func NewAuthHandler(authService AuthService, userService UserService) *AuthHandler {
@@ -34,6 +44,12 @@ func NewAuthHandler(authService AuthService, userService UserService) *AuthHandl
 	}
 }
 
+// WithRefreshTokens enables the refresh grant and refresh tokens on login
+func (h *AuthHandler) WithRefreshTokens(svc RefreshTokenService) *AuthHandler {
+	h.refreshTokens = svc
+	return h
+}
+
 type RegisterUserRequest struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
@@ -44,11 +60,20 @@ type RegisterUserRequest struct {
 type LoginUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// ClientID identifies non-browser clients such as the agent CLI.
+	// A refresh token is only issued when it is set.
+	ClientID string `json:"client_id,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type AuthResponse struct {
-	Token string           `json:"token"`
-	User  AuthUserResponse `json:"user"`
+	Token        string           `json:"token"`
+	RefreshToken string           `json:"refresh_token,omitempty"`
+	ExpiresIn    int              `json:"expires_in,omitempty"`
+	User         AuthUserResponse `json:"user"`
 }
 
 // AuthUserResponse matches frontend User interface with camelCase
@@ -130,11 +155,55 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(AuthResponse{
+	resp := AuthResponse{
 		Token: token,
 		User:  authUserToResponse(user),
-	})
+	}
+	if h.refreshTokens != nil && req.ClientID != "" {
+		refreshToken, err := h.refreshTokens.IssueRefreshToken(r.Context(), user.ID, req.ClientID)
+		if err != nil {
+			http.Error(w, "failed to issue refresh token", http.StatusInternalServerError)
+			return
+		}
+		resp.RefreshToken = refreshToken
+		resp.ExpiresIn = int(h.refreshTokens.AccessTokenExpiry().Seconds())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated
+// refresh token. Errors use OAuth-style bodies like the device token endpoint.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.refreshTokens == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	pair, err := h.refreshTokens.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "server_error"})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(pair)
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -225,6 +294,7 @@ func (h *AuthHandler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/register", h.Register)
 	r.Post("/login", h.Login)
+	r.Post("/refresh", h.Refresh)
 	r.Post("/logout", h.Logout)
 	r.Get("/me", h.GetProfile)
 	r.Put("/me", h.UpdateProfile)
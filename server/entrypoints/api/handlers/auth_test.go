@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/kamilrybacki/edictflow/server/domain"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
@@ -33,6 +34,24 @@ func (m *mockAuthService) Login(ctx context.Context, req auth.LoginRequest) (str
 	return "test-token", domain.User{ID: "test-user", Email: req.Email}, nil
 }
 
+type mockRefreshTokenService struct {
+	issued    []string
+	refreshFn func(ctx context.Context, refreshToken string) (auth.TokenPair, error)
+}
+
+func (m *mockRefreshTokenService) IssueRefreshToken(ctx context.Context, userID, clientID string) (string, error) {
+	m.issued = append(m.issued, userID+"/"+clientID)
+	return "refresh-1", nil
+}
+
+func (m *mockRefreshTokenService) Refresh(ctx context.Context, refreshToken string) (auth.TokenPair, error) {
+	return m.refreshFn(ctx, refreshToken)
+}
+
+func (m *mockRefreshTokenService) AccessTokenExpiry() time.Duration {
+	return time.Hour
+}
+
 type mockUserServiceForAuth struct {
 	users map[string]domain.User
 }
@@ -172,3 +191,106 @@ func TestAuthHandler_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_LoginIssuesRefreshTokenForClients(t *testing.T) {
+	refreshSvc := &mockRefreshTokenService{}
+	userSvc := &mockUserServiceForAuth{users: make(map[string]domain.User)}
+	h := handlers.NewAuthHandler(&mockAuthService{}, userSvc).WithRefreshTokens(refreshSvc)
+
+	// Browser logins don't identify a client and get no refresh token
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"a@example.com","password":"Password123"}`))
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+
+	var resp handlers.AuthResponse
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.RefreshToken != "" {
+		t.Error("expected no refresh token without client_id")
+	}
+
+	req = httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"a@example.com","password":"Password123","client_id":"edictflow-cli"}`))
+	rec = httptest.NewRecorder()
+	h.Login(rec, req)
+
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.RefreshToken != "refresh-1" {
+		t.Errorf("expected refresh token, got %q", resp.RefreshToken)
+	}
+	if resp.ExpiresIn != 3600 {
+		t.Errorf("expected expires_in 3600, got %d", resp.ExpiresIn)
+	}
+	if len(refreshSvc.issued) != 1 || refreshSvc.issued[0] != "test-user/edictflow-cli" {
+		t.Errorf("unexpected refresh token issuance: %v", refreshSvc.issued)
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		refreshErr     error
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "successful refresh",
+			body:           `{"refresh_token":"refresh-1"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing token",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_request",
+		},
+		{
+			name:           "invalid token",
+			body:           `{"refresh_token":"unknown"}`,
+			refreshErr:     auth.ErrInvalidRefreshToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_grant",
+		},
+		{
+			name:           "reused token",
+			body:           `{"refresh_token":"refresh-0"}`,
+			refreshErr:     auth.ErrRefreshTokenReused,
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_grant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshSvc := &mockRefreshTokenService{
+				refreshFn: func(ctx context.Context, refreshToken string) (auth.TokenPair, error) {
+					if tt.refreshErr != nil {
+						return auth.TokenPair{}, tt.refreshErr
+					}
+					return auth.TokenPair{AccessToken: "access-2", RefreshToken: "refresh-2", TokenType: "Bearer", ExpiresIn: 3600}, nil
+				},
+			}
+			userSvc := &mockUserServiceForAuth{users: make(map[string]domain.User)}
+			h := handlers.NewAuthHandler(&mockAuthService{}, userSvc).WithRefreshTokens(refreshSvc)
+
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			h.Refresh(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+
+			var resp map[string]interface{}
+			_ = json.NewDecoder(rec.Body).Decode(&resp)
+			if tt.expectedError != "" {
+				if resp["error"] != tt.expectedError {
+					t.Errorf("expected error %q, got %v", tt.expectedError, resp["error"])
+				}
+				return
+			}
+			if resp["access_token"] != "access-2" || resp["refresh_token"] != "refresh-2" {
+				t.Errorf("expected rotated tokens, got %v", resp)
+			}
+		})
+	}
+}
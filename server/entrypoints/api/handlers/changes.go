@@ -82,6 +82,18 @@ func changeRequestToResponse(cr domain.ChangeRequest) ChangeRequestResponse {
 	return resp
 }
 
+type ChangeDiffResponse struct {
+	ChangeID string          `json:"change_id"`
+	FilePath string          `json:"file_path"`
+	Diff     string          `json:"diff"`
+	Stats    ChangeDiffStats `json:"stats"`
+}
+
+type ChangeDiffStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
 func (h *ChangesHandler) List(w http.ResponseWriter, r *http.Request) {
 	teamID := r.URL.Query().Get("team_id")
 	if teamID == "" {
@@ -129,6 +141,33 @@ func (h *ChangesHandler) Get(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(changeRequestToResponse(*cr))
 }
 
+func (h *ChangesHandler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	cr, err := h.service.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if cr == nil {
+		http.Error(w, "change request not found", http.StatusNotFound)
+		return
+	}
+
+	additions, deletions := cr.DiffStats()
+	resp := ChangeDiffResponse{
+		ChangeID: cr.ID,
+		FilePath: cr.FilePath,
+		Diff:     cr.DiffContent,
+		Stats: ChangeDiffStats{
+			Additions: additions,
+			Deletions: deletions,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 func (h *ChangesHandler) Approve(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	userID := middleware.GetUserID(r.Context())
@@ -156,6 +195,7 @@ func (h *ChangesHandler) Reject(w http.ResponseWriter, r *http.Request) {
 func (h *ChangesHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/", h.List)
 	r.Get("/{id}", h.Get)
+	r.Get("/{id}/diff", h.GetDiff)
 	r.Post("/{id}/approve", h.Approve)
 	r.Post("/{id}/reject", h.Reject)
 }
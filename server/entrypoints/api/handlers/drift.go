@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type DriftService interface {
+	GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error)
+	TeamSummary(ctx context.Context, teamID string) (domain.TeamDriftSummary, error)
+}
+
+type DriftHandler struct {
+	service DriftService
+}
+
+func NewDriftHandler(service DriftService) *DriftHandler {
+	return &DriftHandler{service: service}
+}
+
+// GetAgentDrift returns the latest drift report of an agent
+func (h *DriftHandler) GetAgentDrift(w http.ResponseWriter, r *http.Request) {
+	agentID := chi.URLParam(r, "id")
+
+	report, err := h.service.GetAgentDrift(r.Context(), agentID)
+	if err != nil {
+		if errors.Is(err, drift.ErrAgentDriftNotFound) {
+			http.Error(w, "no drift report for agent", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// GetTeamSummary returns the drift summary across a team's agents
+func (h *DriftHandler) GetTeamSummary(w http.ResponseWriter, r *http.Request) {
+	teamID := chi.URLParam(r, "teamId")
+
+	summary, err := h.service.TeamSummary(r.Context(), teamID)
+	if err != nil {
+		if errors.Is(err, teams.ErrTeamNotFound) {
+			http.Error(w, "team not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+func (h *DriftHandler) RegisterAgentRoutes(r chi.Router) {
+	r.Get("/{id}/drift", h.GetAgentDrift)
+}
+
+func (h *DriftHandler) RegisterTeamRoutes(r chi.Router) {
+	r.Get("/", h.GetTeamSummary)
+}
@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type mockDriftService struct {
+	reports map[string]domain.AgentDrift
+}
+
+func (m *mockDriftService) GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error) {
+	if report, ok := m.reports[agentID]; ok {
+		return report, nil
+	}
+	return domain.AgentDrift{}, drift.ErrAgentDriftNotFound
+}
+
+func (m *mockDriftService) TeamSummary(ctx context.Context, teamID string) (domain.TeamDriftSummary, error) {
+	if teamID != "team-1" {
+		return domain.TeamDriftSummary{}, teams.ErrTeamNotFound
+	}
+	var reports []domain.AgentDrift
+	for _, r := range m.reports {
+		reports = append(reports, r)
+	}
+	return domain.SummarizeTeamDrift(teamID, 60, reports, time.Now()), nil
+}
+
+func newDriftRouter() *chi.Mux {
+	svc := &mockDriftService{reports: map[string]domain.AgentDrift{
+		"agent-1": {
+			AgentID:    "agent-1",
+			UserID:     "user-1",
+			TeamID:     "team-1",
+			ReportedAt: time.Now(),
+			Files: []domain.FileDrift{{
+				Path:         "/repo/CLAUDE.md",
+				Level:        "project",
+				Status:       domain.DriftStatusTampered,
+				ExpectedHash: "abc",
+				ActualHash:   "def",
+				RuleIDs:      []string{"rule-1"},
+			}},
+		},
+	}}
+	h := handlers.NewDriftHandler(svc)
+
+	r := chi.NewRouter()
+	r.Route("/agents", h.RegisterAgentRoutes)
+	r.Route("/teams/{teamId}/drift", h.RegisterTeamRoutes)
+	return r
+}
+
+func TestDriftHandler_GetAgentDrift(t *testing.T) {
+	r := newDriftRouter()
+
+	req := httptest.NewRequest("GET", "/agents/agent-1/drift", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp domain.AgentDrift
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].ActualHash != "def" || resp.Files[0].RuleIDs[0] != "rule-1" {
+		t.Errorf("unexpected files in response: %+v", resp.Files)
+	}
+}
+
+func TestDriftHandler_GetAgentDriftNotFound(t *testing.T) {
+	r := newDriftRouter()
+
+	req := httptest.NewRequest("GET", "/agents/unknown/drift", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestDriftHandler_GetTeamSummary(t *testing.T) {
+	r := newDriftRouter()
+
+	req := httptest.NewRequest("GET", "/teams/team-1/drift", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp domain.TeamDriftSummary
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Agents != 1 || resp.DriftedAgents != 1 {
+		t.Errorf("expected 1 drifted agent, got %+v", resp)
+	}
+
+	req = httptest.NewRequest("GET", "/teams/missing/drift", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown team, got %d", rec.Code)
+	}
+}
@@ -59,6 +59,8 @@ func (h *LibraryHandler) Create(w http.ResponseWriter, r *http.Request) {
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		})
 	}
 
@@ -155,6 +157,8 @@ func (h *LibraryHandler) Update(w http.ResponseWriter, r *http.Request) {
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		})
 	}
 
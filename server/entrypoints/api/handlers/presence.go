@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+)
+
+type PresenceService interface {
+	Online(ctx context.Context, teamID string) ([]presence.Entry, error)
+}
+
+type PresenceHandler struct {
+	service PresenceService
+}
+
+func NewPresenceHandler(service PresenceService) *PresenceHandler {
+	return &PresenceHandler{service: service}
+}
+
+// List returns the agents connected to any worker and the worker holding
+// each connection, optionally for one team
+func (h *PresenceHandler) List(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.Online(r.Context(), r.URL.Query().Get("team_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// RegisterRoutes registers the presence routes, which need the manage_agents
+// permission
+func (h *PresenceHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/presence", h.List)
+}
@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+)
+
+type mockPresenceService struct {
+	entries []presence.Entry
+}
+
+func (m *mockPresenceService) Online(ctx context.Context, teamID string) ([]presence.Entry, error) {
+	result := []presence.Entry{}
+	for _, e := range m.entries {
+		if teamID == "" || e.TeamID == teamID {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func TestPresenceHandler_List(t *testing.T) {
+	svc := &mockPresenceService{entries: []presence.Entry{
+		{ConnectionID: "conn-1", AgentID: "agent-1", WorkerID: "worker-1", TeamID: "team-1", Hostname: "laptop"},
+		{ConnectionID: "conn-2", AgentID: "agent-2", WorkerID: "worker-2", TeamID: "team-2", Hostname: "desktop"},
+	}}
+	r := chi.NewRouter()
+	handlers.NewPresenceHandler(svc).RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/presence?team_id=team-1", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var entries []presence.Entry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].WorkerID != "worker-1" {
+		t.Errorf("expected the team-1 agent on worker-1, got %+v", entries)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/services/preview"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type PreviewService interface {
+	Render(ctx context.Context, req preview.Request) (preview.Preview, error)
+}
+
+type PreviewHandler struct {
+	service PreviewService
+}
+
+func NewPreviewHandler(service PreviewService) *PreviewHandler {
+	return &PreviewHandler{service: service}
+}
+
+type RenderPreviewRequest struct {
+	TeamID      string   `json:"team_id"`
+	UserID      string   `json:"user_id"`
+	ProjectPath string   `json:"project_path"`
+	Contexts    []string `json:"contexts"`
+	Tags        []string `json:"tags"`
+	Repository  string   `json:"repository"`
+	Branch      string   `json:"branch"`
+	Target      string   `json:"target"`
+}
+
+type PreviewFileResponse struct {
+	Level          string   `json:"level"`
+	Path           string   `json:"path"`
+	ManagedSection string   `json:"managed_section"`
+	Hash           string   `json:"hash"`
+	RuleIDs        []string `json:"rule_ids"`
+}
+
+type PreviewRuleResponse struct {
+	RuleID      string `json:"rule_id"`
+	Name        string `json:"name"`
+	TargetLayer string `json:"target_layer"`
+	Included    bool   `json:"included"`
+	Level       string `json:"level"`
+	Reason      string `json:"reason"`
+}
+
+type RenderPreviewResponse struct {
+	TeamID      string                `json:"team_id"`
+	Target      string                `json:"target"`
+	ProjectPath string                `json:"project_path"`
+	Files       []PreviewFileResponse `json:"files"`
+	Rules       []PreviewRuleResponse `json:"rules"`
+}
+
+// Render returns the managed sections an agent writes for the team, user and
+// project in the request, and why each rule is or is not included
+func (h *PreviewHandler) Render(w http.ResponseWriter, r *http.Request) {
+	var req RenderPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TeamID == "" && req.UserID == "" {
+		http.Error(w, "team_id or user_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.ProjectPath == "" {
+		http.Error(w, "project_path is required", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.service.Render(r.Context(), preview.Request{
+		TeamID:      req.TeamID,
+		UserID:      req.UserID,
+		ProjectPath: req.ProjectPath,
+		Contexts:    req.Contexts,
+		Tags:        req.Tags,
+		Repository:  req.Repository,
+		Branch:      req.Branch,
+		Target:      req.Target,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, preview.ErrUserNotFound), errors.Is(err, teams.ErrTeamNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, preview.ErrNoTeam), errors.Is(err, preview.ErrUnknownTarget), errors.Is(err, preview.ErrTargetDisabled):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := RenderPreviewResponse{
+		TeamID:      p.TeamID,
+		Target:      p.Target,
+		ProjectPath: p.ProjectPath,
+		Files:       make([]PreviewFileResponse, len(p.Files)),
+		Rules:       make([]PreviewRuleResponse, len(p.Rules)),
+	}
+	for i, f := range p.Files {
+		response.Files[i] = PreviewFileResponse{
+			Level:          f.Level,
+			Path:           f.Path,
+			ManagedSection: f.Section,
+			Hash:           f.Hash,
+			RuleIDs:        f.RuleIDs,
+		}
+	}
+	for i, d := range p.Rules {
+		response.Rules[i] = PreviewRuleResponse{
+			RuleID:      d.RuleID,
+			Name:        d.Name,
+			TargetLayer: string(d.TargetLayer),
+			Included:    d.Included,
+			Level:       d.Level,
+			Reason:      d.Reason,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (h *PreviewHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/preview", h.Render)
+}
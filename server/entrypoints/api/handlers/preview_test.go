@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/preview"
+)
+
+type mockPreviewService struct {
+	req preview.Request
+}
+
+func (m *mockPreviewService) Render(ctx context.Context, req preview.Request) (preview.Preview, error) {
+	m.req = req
+	if req.Target == "vim" {
+		return preview.Preview{}, preview.ErrUnknownTarget
+	}
+	return preview.Preview{
+		TeamID:      "team-1",
+		Target:      "claude",
+		ProjectPath: req.ProjectPath,
+		Files:       []preview.File{{Level: "project", Path: req.ProjectPath + "/CLAUDE.md", Section: "section", Hash: "abc", RuleIDs: []string{"rule-1"}}},
+		Rules:       []preview.RuleDecision{{RuleID: "rule-1", Name: "Tests", Included: true, Level: "project", Reason: "team rule"}},
+	}, nil
+}
+
+func postPreview(r http.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/render/preview", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestPreviewHandler_Render(t *testing.T) {
+	svc := &mockPreviewService{}
+	r := chi.NewRouter()
+	r.Route("/render", handlers.NewPreviewHandler(svc).RegisterRoutes)
+
+	rec := postPreview(r, `{"user_id":"user-1","project_path":"/home/dev/api","tags":["backend"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp handlers.RenderPreviewResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].ManagedSection != "section" || resp.Files[0].Hash != "abc" {
+		t.Errorf("unexpected files: %+v", resp.Files)
+	}
+	if len(resp.Rules) != 1 || !resp.Rules[0].Included || resp.Rules[0].Reason != "team rule" {
+		t.Errorf("unexpected rules: %+v", resp.Rules)
+	}
+	if svc.req.UserID != "user-1" || len(svc.req.Tags) != 1 {
+		t.Errorf("expected the request to be passed on, got %+v", svc.req)
+	}
+
+	for body, code := range map[string]int{
+		`{"project_path":"/home/dev/api"}`:                        http.StatusBadRequest,
+		`{"team_id":"team-1"}`:                                    http.StatusBadRequest,
+		`{"team_id":"team-1","project_path":"/p","target":"vim"}`: http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		if rec := postPreview(r, body); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", body, code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/impact"
+)
+
+type RuleImpactService interface {
+	Analyze(ctx context.Context, ruleID string) (impact.Report, error)
+}
+
+type RuleImpactHandler struct {
+	service RuleImpactService
+}
+
+func NewRuleImpactHandler(service RuleImpactService) *RuleImpactHandler {
+	return &RuleImpactHandler{service: service}
+}
+
+type ImpactTeamResponse struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type ImpactUserResponse struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Email  string  `json:"email"`
+	TeamID *string `json:"team_id,omitempty"`
+}
+
+type ImpactFileResponse struct {
+	Level string `json:"level"`
+	Path  string `json:"path"`
+	Diff  string `json:"diff"`
+}
+
+type ImpactSampleResponse struct {
+	TeamID      string               `json:"team_id"`
+	AgentID     string               `json:"agent_id"`
+	ProjectPath string               `json:"project_path"`
+	Target      string               `json:"target"`
+	Files       []ImpactFileResponse `json:"files"`
+}
+
+type RuleImpactResponse struct {
+	RuleID          string                 `json:"rule_id"`
+	Teams           []ImpactTeamResponse   `json:"teams"`
+	Users           []ImpactUserResponse   `json:"users"`
+	Agents          []domain.Agent         `json:"agents"`
+	ConnectedAgents int                    `json:"connected_agents"`
+	Projects        []domain.AgentProject  `json:"projects"`
+	Samples         []ImpactSampleResponse `json:"samples"`
+}
+
+// Get returns the teams, users, agents and projects the rule reaches once
+// approved, with before and after diffs for a sample of the projects
+func (h *RuleImpactHandler) Get(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.Analyze(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, impact.ErrRuleNotFound) {
+			http.Error(w, "rule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(impactToResponse(report))
+}
+
+func (h *RuleImpactHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/{id}/impact", h.Get)
+}
+
+func impactToResponse(report impact.Report) RuleImpactResponse {
+	resp := RuleImpactResponse{
+		RuleID:          report.RuleID,
+		Teams:           make([]ImpactTeamResponse, len(report.Teams)),
+		Users:           make([]ImpactUserResponse, len(report.Users)),
+		Agents:          report.Agents,
+		ConnectedAgents: report.ConnectedAgents,
+		Projects:        report.Projects,
+		Samples:         make([]ImpactSampleResponse, len(report.Samples)),
+	}
+	for i, t := range report.Teams {
+		resp.Teams[i] = ImpactTeamResponse{ID: t.ID, Name: t.Name, Reason: t.Reason}
+	}
+	for i, u := range report.Users {
+		resp.Users[i] = ImpactUserResponse{ID: u.ID, Name: u.Name, Email: u.Email, TeamID: u.TeamID}
+	}
+	for i, s := range report.Samples {
+		files := make([]ImpactFileResponse, len(s.Files))
+		for j, f := range s.Files {
+			files[j] = ImpactFileResponse{Level: f.Level, Path: f.Path, Diff: f.Diff}
+		}
+		resp.Samples[i] = ImpactSampleResponse{
+			TeamID:      s.TeamID,
+			AgentID:     s.AgentID,
+			ProjectPath: s.ProjectPath,
+			Target:      s.Target,
+			Files:       files,
+		}
+	}
+	return resp
+}
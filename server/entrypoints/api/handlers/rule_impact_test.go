@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/impact"
+)
+
+type mockRuleImpactService struct{}
+
+func (m *mockRuleImpactService) Analyze(ctx context.Context, ruleID string) (impact.Report, error) {
+	if ruleID != "rule-1" {
+		return impact.Report{}, impact.ErrRuleNotFound
+	}
+	return impact.Report{
+		RuleID:          ruleID,
+		Teams:           []impact.Team{{ID: "team-1", Name: "Backend", Reason: "team rule"}},
+		Users:           []domain.User{{ID: "user-1", Name: "Dev", Email: "dev@example.com"}},
+		Agents:          []domain.Agent{{ID: "agent-1", UserID: "user-1", Status: domain.AgentStatusOnline}},
+		ConnectedAgents: 1,
+		Projects:        []domain.AgentProject{{AgentID: "agent-1", TeamID: "team-1", ProjectPath: "/home/dev/api"}},
+		Samples: []impact.Sample{{
+			TeamID:      "team-1",
+			AgentID:     "agent-1",
+			ProjectPath: "/home/dev/api",
+			Target:      "claude",
+			Files:       []impact.FileDiff{{Level: "project", Path: "/home/dev/api/CLAUDE.md", Diff: "+- **API Style**"}},
+		}},
+	}, nil
+}
+
+func TestRuleImpactHandler_Get(t *testing.T) {
+	r := chi.NewRouter()
+	r.Route("/rules", handlers.NewRuleImpactHandler(&mockRuleImpactService{}).RegisterRoutes)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/rule-1/impact", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp handlers.RuleImpactResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Teams) != 1 || resp.Teams[0].Reason != "team rule" || resp.ConnectedAgents != 1 {
+		t.Errorf("unexpected impact: %+v", resp)
+	}
+	if len(resp.Samples) != 1 || len(resp.Samples[0].Files) != 1 || resp.Samples[0].Files[0].Diff == "" {
+		t.Errorf("expected the sample diff, got %+v", resp.Samples)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/missing/impact", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestApprovalsHandler_GetStatusWithImpact(t *testing.T) {
+	svc := newMockApprovalsService()
+	pending := domain.NewRule("Pending", domain.TargetLayerProject, "content", nil, "team-1")
+	pending.ID = "rule-1"
+	pending.Status = domain.RuleStatusPending
+	svc.rules[pending.ID] = pending
+	approved := domain.NewRule("Approved", domain.TargetLayerProject, "content", nil, "team-1")
+	approved.Status = domain.RuleStatusApproved
+	svc.rules[approved.ID] = approved
+
+	r := chi.NewRouter()
+	r.Get("/approvals/rules/{ruleId}", handlers.NewApprovalsHandler(svc).WithImpact(&mockRuleImpactService{}).GetStatus)
+
+	for id, wantImpact := range map[string]bool{pending.ID: true, approved.ID: false} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/approvals/rules/"+id, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Data handlers.ApprovalStatusResponse `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if (body.Data.Impact != nil) != wantImpact {
+			t.Errorf("%s: expected impact=%v, got %+v", id, wantImpact, body.Data.Impact)
+		}
+	}
+}
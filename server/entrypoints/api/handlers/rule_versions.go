@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/events"
+	"github.com/kamilrybacki/edictflow/server/services/publisher"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/ruleversions"
+)
+
+type RuleVersionService interface {
+	List(ctx context.Context, ruleID string) ([]ruleversions.Version, error)
+	Get(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error)
+	Diff(ctx context.Context, ruleID string, from, to int) (ruleversions.Diff, error)
+	Rollback(ctx context.Context, ruleID string, version int, actorID string) (domain.Rule, error)
+}
+
+type RuleVersionsHandler struct {
+	service   RuleVersionService
+	publisher publisher.Publisher
+}
+
+func NewRuleVersionsHandler(service RuleVersionService, pub publisher.Publisher) *RuleVersionsHandler {
+	return &RuleVersionsHandler{service: service, publisher: pub}
+}
+
+type RuleVersionResponse struct {
+	domain.RuleVersion
+	// Agents is how many agents last reported applying the version
+	Agents int `json:"agents"`
+}
+
+type RuleVersionDiffResponse struct {
+	RuleID  string                         `json:"rule_id"`
+	From    int                            `json:"from"`
+	To      int                            `json:"to"`
+	Changes map[string]*domain.ChangeValue `json:"changes"`
+	Content string                         `json:"content_diff"`
+}
+
+// List returns the rule's approved versions, newest first
+func (h *RuleVersionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.service.List(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeRuleVersionError(w, err)
+		return
+	}
+
+	response := make([]RuleVersionResponse, len(versions))
+	for i, v := range versions {
+		response[i] = RuleVersionResponse{RuleVersion: v.RuleVersion, Agents: v.Agents}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (h *RuleVersionsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	v, err := h.service.Get(r.Context(), chi.URLParam(r, "id"), version)
+	if err != nil {
+		writeRuleVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Diff compares the versions given by the from and to query parameters
+func (h *RuleVersionsHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	from, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	to, errTo := strconv.Atoi(r.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil {
+		http.Error(w, "from and to query parameters must be version numbers", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.service.Diff(r.Context(), chi.URLParam(r, "id"), from, to)
+	if err != nil {
+		writeRuleVersionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RuleVersionDiffResponse{
+		RuleID:  d.RuleID,
+		From:    d.From,
+		To:      d.To,
+		Changes: d.Changes,
+		Content: d.Content,
+	})
+}
+
+// Rollback restores a version and submits the rule for approval
+func (h *RuleVersionsHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.service.Rollback(r.Context(), chi.URLParam(r, "id"), version, userID)
+	if err != nil {
+		writeRuleVersionError(w, err)
+		return
+	}
+
+	// The rule awaits approval, so agents stop receiving the current version
+	if h.publisher != nil {
+		go func() {
+			_ = h.publisher.PublishRuleEvent(context.Background(), events.EventRuleUpdated, rule.ID, derefTeamID(rule.TeamID))
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ruleToResponse(rule))
+}
+
+func writeRuleVersionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, rules.ErrRuleNotFound):
+		http.Error(w, "rule not found", http.StatusNotFound)
+	case errors.Is(err, ruleversions.ErrVersionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ruleversions.ErrRulePending), errors.Is(err, ruleversions.ErrAlreadyCurrent):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *RuleVersionsHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/{id}/versions", h.List)
+	r.Get("/{id}/versions/diff", h.Diff)
+	r.Get("/{id}/versions/{version}", h.Get)
+	r.Post("/{id}/versions/{version}/rollback", h.Rollback)
+}
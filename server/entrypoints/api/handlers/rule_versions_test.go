@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/ruleversions"
+)
+
+type mockRuleVersionService struct {
+	rollbackErr error
+	rolledBack  int
+}
+
+func (m *mockRuleVersionService) List(ctx context.Context, ruleID string) ([]ruleversions.Version, error) {
+	if ruleID != "rule-1" {
+		return nil, rules.ErrRuleNotFound
+	}
+	return []ruleversions.Version{
+		{RuleVersion: domain.RuleVersion{RuleID: ruleID, Version: 2}, Agents: 4},
+		{RuleVersion: domain.RuleVersion{RuleID: ruleID, Version: 1}},
+	}, nil
+}
+
+func (m *mockRuleVersionService) Get(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error) {
+	return domain.RuleVersion{}, ruleversions.ErrVersionNotFound
+}
+
+func (m *mockRuleVersionService) Diff(ctx context.Context, ruleID string, from, to int) (ruleversions.Diff, error) {
+	return ruleversions.Diff{RuleID: ruleID, From: from, To: to, Content: "--- version 1\n"}, nil
+}
+
+func (m *mockRuleVersionService) Rollback(ctx context.Context, ruleID string, version int, actorID string) (domain.Rule, error) {
+	if m.rollbackErr != nil {
+		return domain.Rule{}, m.rollbackErr
+	}
+	m.rolledBack = version
+	rule := domain.NewRule("Secrets", domain.TargetLayerTeam, "content", nil, "team-1")
+	rule.Submit()
+	return rule, nil
+}
+
+func newRuleVersionsRouter(svc handlers.RuleVersionService) *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/rules", handlers.NewRuleVersionsHandler(svc, nil).RegisterRoutes)
+	return r
+}
+
+func TestRuleVersionsHandler_List(t *testing.T) {
+	r := newRuleVersionsRouter(&mockRuleVersionService{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/rule-1/versions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var versions []handlers.RuleVersionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].Agents != 4 {
+		t.Errorf("unexpected versions: %+v", versions)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/missing/versions", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown rule, got %d", rec.Code)
+	}
+}
+
+func TestRuleVersionsHandler_Diff(t *testing.T) {
+	r := newRuleVersionsRouter(&mockRuleVersionService{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/rule-1/versions/diff?from=1&to=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var diff handlers.RuleVersionDiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.From != 1 || diff.To != 2 || diff.Content == "" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/rules/rule-1/versions/diff?from=1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without to, got %d", rec.Code)
+	}
+}
+
+func TestRuleVersionsHandler_Rollback(t *testing.T) {
+	svc := &mockRuleVersionService{}
+	r := newRuleVersionsRouter(svc)
+
+	req := withUserContext(httptest.NewRequest("POST", "/rules/rule-1/versions/1/rollback", nil), "user-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rule handlers.RuleResponse
+	if err := json.NewDecoder(rec.Body).Decode(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if svc.rolledBack != 1 || rule.Status != string(domain.RuleStatusPending) {
+		t.Errorf("expected a pending rollback to version 1, got version %d and status %s", svc.rolledBack, rule.Status)
+	}
+
+	svc.rollbackErr = ruleversions.ErrRulePending
+	req = withUserContext(httptest.NewRequest("POST", "/rules/rule-1/versions/1/rollback", nil), "user-1")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the rule is pending, got %d", rec.Code)
+	}
+}
@@ -70,6 +70,8 @@ type TriggerRequest struct {
 	Pattern      string   `json:"pattern,omitempty"`
 	ContextTypes []string `json:"context_types,omitempty"`
 	Tags         []string `json:"tags,omitempty"`
+	Branches     []string `json:"branches,omitempty"`
+	Negate       bool     `json:"negate,omitempty"`
 }
 
 type CreateRuleRequest struct {
@@ -114,6 +116,8 @@ type RuleResponse struct {
 	ApprovedAt            string            `json:"approvedAt,omitempty"`
 	CreatedAt             string            `json:"createdAt"`
 	UpdatedAt             string            `json:"updatedAt"`
+	CurrentVersion        int               `json:"currentVersion"`
+	RestoresVersion       *int              `json:"restoresVersion,omitempty"`
 }
 
 type TriggerResponse struct {
@@ -121,6 +125,8 @@ type TriggerResponse struct {
 	Pattern      string   `json:"pattern,omitempty"`
 	ContextTypes []string `json:"context_types,omitempty"`
 	Tags         []string `json:"tags,omitempty"`
+	Branches     []string `json:"branches,omitempty"`
+	Negate       bool     `json:"negate,omitempty"`
 }
 
 // derefTeamID safely dereferences a *string, returning empty string if nil
@@ -175,6 +181,8 @@ func ruleToResponseWithUserName(rule domain.Rule, createdByName string) RuleResp
 		CreatedByName:         createdByName,
 		CreatedAt:             rule.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:             rule.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		CurrentVersion:        rule.CurrentVersion,
+		RestoresVersion:       rule.RestoresVersion,
 	}
 
 	if rule.EffectiveStart != nil {
@@ -198,6 +206,8 @@ func ruleToResponseWithUserName(rule domain.Rule, createdByName string) RuleResp
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		})
 	}
 
@@ -386,6 +396,8 @@ func (h *RulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	rule.Content = req.Content
 	rule.TargetLayer = domain.TargetLayer(req.TargetLayer)
 	rule.Tags = req.Tags
+	// An edited rule is a new revision rather than a rollback
+	rule.RestoresVersion = nil
 
 	// Convert triggers
 	rule.Triggers = nil
@@ -395,6 +407,8 @@ func (h *RulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		})
 	}
 
@@ -38,7 +38,8 @@ type CreateTeamRequest struct {
 }
 
 type UpdateTeamSettingsRequest struct {
-	DriftThresholdMinutes *int `json:"drift_threshold_minutes,omitempty"`
+	DriftThresholdMinutes *int      `json:"drift_threshold_minutes,omitempty"`
+	OutputTargets         *[]string `json:"output_targets,omitempty"`
 	// InheritGlobalRules is no longer configurable - teams always inherit global rules
 }
 
@@ -144,8 +145,16 @@ func (h *TeamsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
 	if req.DriftThresholdMinutes != nil {
 		team.Settings.DriftThresholdMinutes = *req.DriftThresholdMinutes
 	}
+	if req.OutputTargets != nil {
+		team.Settings.OutputTargets = *req.OutputTargets
+	}
 	// InheritGlobalRules is always true - not configurable
 
+	if err := team.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := h.service.Update(r.Context(), team); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
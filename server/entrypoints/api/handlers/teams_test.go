@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/kamilrybacki/edictflow/server/domain"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/handlers"
 )
@@ -110,3 +111,38 @@ func TestListTeamsHandler(t *testing.T) {
 		t.Errorf("expected 2 teams, got %d", len(resp))
 	}
 }
+
+func TestUpdateTeamSettingsHandler_OutputTargets(t *testing.T) {
+	svc := newMockTeamService()
+	team, _ := svc.Create(context.Background(), "Engineering")
+
+	r := chi.NewRouter()
+	handlers.NewTeamsHandler(svc).RegisterRoutes(r)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     []string
+	}{
+		{"enable targets", `{"output_targets": ["claude", "cursor"]}`, http.StatusOK, []string{"claude", "cursor"}},
+		{"unknown target", `{"output_targets": ["vim"]}`, http.StatusBadRequest, []string{"claude", "cursor"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/"+team.ID+"/settings", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d", tt.wantCode, rec.Code)
+			}
+			got := svc.teams[team.ID].Settings.EnabledTargets()
+			if len(got) != len(tt.want) || got[0] != tt.want[0] || got[1] != tt.want[1] {
+				t.Errorf("expected targets %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// InternalTokenHeader carries the shared secret of internal requests
+const InternalTokenHeader = "X-Internal-Token"
+
+// InternalAuth admits requests from other server processes, which present
+// the shared internal token instead of a user's JWT
+type InternalAuth struct {
+	token []byte
+}
+
+func NewInternalAuth(token string) *InternalAuth {
+	return &InternalAuth{token: []byte(token)}
+}
+
+func (a *InternalAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := []byte(r.Header.Get(InternalTokenHeader))
+		if len(a.token) == 0 || subtle.ConstantTimeCompare(token, a.token) != 1 {
+			http.Error(w, "invalid internal token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,42 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+)
+
+func TestInternalAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		presented  string
+		want       int
+	}{
+		{"matching token", "s3cret", "s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "guess", http.StatusUnauthorized},
+		{"missing token", "s3cret", "", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.NewInternalAuth(tt.configured).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/internal/agent-messages", nil)
+			if tt.presented != "" {
+				req.Header.Set(middleware.InternalTokenHeader, tt.presented)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -34,6 +34,8 @@ func (m *mockMetricsService) RecordAgentConnection(agentID, teamID, action strin
 func (m *mockMetricsService) RecordWebSocketMessage(direction, messageType, agentID string, sizeBytes int) {
 }
 func (m *mockMetricsService) RecordBroadcast(teamID, eventType string, recipientCount int) {}
+func (m *mockMetricsService) RecordDroppedMessage(agentID, teamID, messageType string, droppedTotal int64) {
+}
 func (m *mockMetricsService) RecordDBQuery(operation string, table string, duration time.Duration, success bool) {
 }
 func (m *mockMetricsService) RecordDBPoolStats(totalConns, acquiredConns, idleConns, maxConns int32) {}
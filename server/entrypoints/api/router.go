@@ -25,6 +25,8 @@ type Config struct {
 	BaseURL                    string
 	TeamService                handlers.TeamService
 	RuleService                handlers.RuleService
+	RuleVersionService         handlers.RuleVersionService
+	RuleImpactService          handlers.RuleImpactService
 	CategoryService            handlers.CategoryService
 	ChangeService              handlers.ChangeService
 	ExceptionService           handlers.ExceptionService
@@ -32,6 +34,7 @@ type Config struct {
 	NotificationChannelService handlers.NotificationChannelService
 	DeviceAuthService          handlers.DeviceAuthService
 	AuthService                handlers.AuthService
+	RefreshTokenService        handlers.RefreshTokenService
 	UserService                handlers.UserService
 	UsersService               handlers.UsersService
 	ApprovalsService           handlers.ApprovalsService
@@ -39,6 +42,13 @@ type Config struct {
 	AuditService               FullAuditService
 	LibraryService             handlers.LibraryService
 	AttachmentService          handlers.AttachmentService
+	DriftService               handlers.DriftService
+	AgentService               handlers.AgentService
+	AgentMessageService        handlers.AgentMessageService
+	AgentCommandService        handlers.AgentCommandService
+	PresenceService            handlers.PresenceService
+	PreviewService             handlers.PreviewService
+	InternalToken              string
 	PermissionProvider         middleware.PermissionProvider
 	Publisher                  publisher.Publisher
 	MetricsService             metrics.Service
@@ -104,6 +114,15 @@ func NewRouter(cfg Config) *chi.Mux {
 		_, _ = w.Write([]byte("OK"))
 	})
 
+	// Internal routes for worker processes, authenticated with the shared token
+	if cfg.AgentMessageService != nil && cfg.InternalToken != "" {
+		internalAuth := middleware.NewInternalAuth(cfg.InternalToken)
+		r.Route("/internal", func(r chi.Router) {
+			r.Use(internalAuth.Middleware)
+			r.Route("/agent-messages", handlers.NewAgentMessagesHandler(cfg.AgentMessageService).RegisterRoutes)
+		})
+	}
+
 	// Auth routes (mix of public and protected)
 	r.Route("/api/v1/auth", func(r chi.Router) {
 		// Apply rate limiting to auth routes (stricter limits)
@@ -114,8 +133,12 @@ func NewRouter(cfg Config) *chi.Mux {
 		// Public endpoints - login and register don't require auth
 		if cfg.AuthService != nil && cfg.UserService != nil {
 			authHandler := handlers.NewAuthHandler(cfg.AuthService, cfg.UserService)
+			if cfg.RefreshTokenService != nil {
+				authHandler.WithRefreshTokens(cfg.RefreshTokenService)
+			}
 			r.Post("/login", authHandler.Login)
 			r.Post("/register", authHandler.Register)
+			r.Post("/refresh", authHandler.Refresh)
 
 			// Protected auth endpoints
 			r.Group(func(r chi.Router) {
@@ -181,6 +204,12 @@ func NewRouter(cfg Config) *chi.Mux {
 				h = h.WithUserLookup(cfg.UsersService)
 			}
 			h.RegisterRoutes(r)
+			if cfg.RuleVersionService != nil {
+				handlers.NewRuleVersionsHandler(cfg.RuleVersionService, cfg.Publisher).RegisterRoutes(r)
+			}
+			if cfg.RuleImpactService != nil {
+				handlers.NewRuleImpactHandler(cfg.RuleImpactService).RegisterRoutes(r)
+			}
 		})
 
 		if cfg.CategoryService != nil {
@@ -195,6 +224,7 @@ func NewRouter(cfg Config) *chi.Mux {
 			h := handlers.NewChangesHandler(cfg.ChangeService)
 			r.Get("/", h.List)
 			r.Get("/{id}", h.Get)
+			r.Get("/{id}/diff", h.GetDiff)
 			r.Group(func(r chi.Router) {
 				r.Use(perm.RequirePermission("changes.approve"))
 				r.Post("/{id}/approve", h.Approve)
@@ -217,6 +247,9 @@ func NewRouter(cfg Config) *chi.Mux {
 		if cfg.ApprovalsService != nil {
 			r.Route("/approvals", func(r chi.Router) {
 				h := handlers.NewApprovalsHandler(cfg.ApprovalsService)
+				if cfg.RuleImpactService != nil {
+					h = h.WithImpact(cfg.RuleImpactService)
+				}
 				h.RegisterRoutes(r)
 			})
 		}
@@ -278,6 +311,57 @@ func NewRouter(cfg Config) *chi.Mux {
 				h.RegisterAttachmentRoutes(r)
 			})
 		}
+
+		// Agent routes (fleet registry, presence, state reported by agents and commands sent to them)
+		if cfg.AgentService != nil || cfg.DriftService != nil || cfg.AgentCommandService != nil || cfg.PresenceService != nil {
+			r.Route("/agents", func(r chi.Router) {
+				if cfg.AgentService != nil {
+					h := handlers.NewAgentsHandler(cfg.AgentService)
+					h.RegisterRoutes(r)
+					r.Group(func(r chi.Router) {
+						r.Use(perm.RequirePermission("manage_agents"))
+						h.RegisterFleetRoutes(r)
+					})
+				}
+				if cfg.DriftService != nil {
+					r.Group(func(r chi.Router) {
+						r.Use(perm.RequirePermission("manage_agents"))
+						h := handlers.NewDriftHandler(cfg.DriftService)
+						h.RegisterAgentRoutes(r)
+					})
+				}
+				if cfg.AgentCommandService != nil {
+					r.Group(func(r chi.Router) {
+						r.Use(perm.RequirePermission("manage_agents"))
+						h := handlers.NewAgentCommandsHandler(cfg.AgentCommandService)
+						h.RegisterRoutes(r)
+					})
+				}
+				if cfg.PresenceService != nil {
+					r.Group(func(r chi.Router) {
+						r.Use(perm.RequirePermission("manage_agents"))
+						handlers.NewPresenceHandler(cfg.PresenceService).RegisterRoutes(r)
+					})
+				}
+			})
+		}
+
+		// Managed file preview for a team, user and project
+		if cfg.PreviewService != nil {
+			r.Route("/render", func(r chi.Router) {
+				r.Use(perm.RequirePermission("manage_agents"))
+				handlers.NewPreviewHandler(cfg.PreviewService).RegisterRoutes(r)
+			})
+		}
+
+		// Team drift summary
+		if cfg.DriftService != nil {
+			r.Route("/teams/{teamId}/drift", func(r chi.Router) {
+				r.Use(perm.RequirePermission("manage_agents"))
+				h := handlers.NewDriftHandler(cfg.DriftService)
+				h.RegisterTeamRoutes(r)
+			})
+		}
 	})
 
 	return r
@@ -9,18 +9,44 @@ import (
 
 type MessageType string
 
+// ProtocolVersion is the version of the agent protocol the server speaks.
+// Agents that connect without a hello speak version 0.
+const ProtocolVersion = 1
+
+// Capabilities an agent can advertise in its hello. The server only uses the
+// message shapes an agent advertised.
+const (
+	// CapabilityDelta agents apply config updates that only carry changes
+	CapabilityDelta = "delta"
+	// CapabilityRevert agents revert files when their change is rejected
+	CapabilityRevert = "revert"
+	// CapabilityTargets agents write rules to their team's output targets
+	CapabilityTargets = "targets"
+	// CapabilityCompression agents read compressed WebSocket messages
+	CapabilityCompression = "compression"
+)
+
+// Capabilities lists every capability the server can use
+var Capabilities = []string{CapabilityDelta, CapabilityRevert, CapabilityTargets, CapabilityCompression}
+
 const (
 	// Server -> Agent
+	TypeWelcome          MessageType = "welcome"
+	TypeUpgradeRequired  MessageType = "upgrade_required"
 	TypeConfigUpdate     MessageType = "config_update"
-	TypeSyncRequest      MessageType = "sync_request"
 	TypeAck              MessageType = "ack"
+	TypeNack             MessageType = "nack"
 	TypeChangeApproved   MessageType = "change_approved"
 	TypeChangeRejected   MessageType = "change_rejected"
 	TypeExceptionGranted MessageType = "exception_granted"
 	TypeExceptionDenied  MessageType = "exception_denied"
+	TypeCommand          MessageType = "command"
+	TypeAgentRevoked     MessageType = "agent_revoked"
 
 	// Agent -> Server
+	TypeHello            MessageType = "hello"
 	TypeHeartbeat        MessageType = "heartbeat"
+	TypeSyncRequest      MessageType = "sync_request"
 	TypeDriftReport      MessageType = "drift_report"
 	TypeContextDetected  MessageType = "context_detected"
 	TypeSyncComplete     MessageType = "sync_complete"
@@ -28,6 +54,7 @@ const (
 	TypeChangeUpdated    MessageType = "change_updated"
 	TypeExceptionRequest MessageType = "exception_request"
 	TypeRevertComplete   MessageType = "revert_complete"
+	TypeCommandResult    MessageType = "command_result"
 )
 
 type Message struct {
@@ -52,45 +79,121 @@ func NewMessage(msgType MessageType, payload interface{}) (Message, error) {
 }
 
 // Server -> Agent payloads
+
+// WelcomePayload answers a hello with the protocol version both sides speak
+// and the agent's capabilities the server will use
+type WelcomePayload struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// UpgradeRequiredPayload tells an agent it is older than the server accepts;
+// the connection is closed right after
+type UpgradeRequiredPayload struct {
+	MinVersion   string `json:"min_version"`
+	AgentVersion string `json:"agent_version"`
+	Reason       string `json:"reason"`
+}
+
+// ConfigUpdatePayload moves an agent to a revision of its team's
+// configuration. Version is the revision. A delta only carries the rules
+// changed since SinceVersion and the IDs of the removed ones; otherwise Rules
+// is the complete rule set.
 type ConfigUpdatePayload struct {
-	Rules   []RulePayload `json:"rules"`
-	Version int           `json:"version"`
+	Rules          []RulePayload `json:"rules"`
+	Version        int           `json:"version"`
+	Delta          bool          `json:"delta,omitempty"`
+	SinceVersion   int           `json:"since_version,omitempty"`
+	RemovedRuleIDs []string      `json:"removed_rule_ids,omitempty"`
+	// Categories are the categories referenced by Rules, for section ordering
+	Categories []CategoryPayload `json:"categories,omitempty"`
+	// Targets lists the output targets enabled for the agent's team
+	Targets []string `json:"targets,omitempty"`
 }
 
+// RulePayload is a rule as cached by agents. TargetLayer is the agent file
+// level: "enterprise", "user" or "project". Effective dates are Unix seconds.
 type RulePayload struct {
-	ID          string          `json:"id"`
-	Name        string          `json:"name"`
-	Content     string          `json:"content"`
-	TargetLayer string          `json:"target_layer"`
-	Triggers    json.RawMessage `json:"triggers"`
+	ID                    string          `json:"id"`
+	Name                  string          `json:"name"`
+	Content               string          `json:"content"`
+	TargetLayer           string          `json:"target_layer"`
+	Triggers              json.RawMessage `json:"triggers"`
+	CategoryID            string          `json:"category_id,omitempty"`
+	CategoryName          string          `json:"category_name,omitempty"`
+	PriorityWeight        int             `json:"priority_weight"`
+	Overridable           bool            `json:"overridable"`
+	EffectiveStart        *int64          `json:"effective_start,omitempty"`
+	EffectiveEnd          *int64          `json:"effective_end,omitempty"`
+	EnforcementMode       string          `json:"enforcement_mode,omitempty"`
+	TemporaryTimeoutHours int             `json:"temporary_timeout_hours,omitempty"`
+	// RuleVersion is the rule's approved version, reported back by agents
+	RuleVersion int `json:"rule_version,omitempty"`
 }
 
-type SyncRequestPayload struct {
-	ProjectPaths []string `json:"project_paths"`
+type CategoryPayload struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	IsSystem     bool   `json:"is_system"`
+	DisplayOrder int    `json:"display_order"`
 }
 
 type AckPayload struct {
 	RefID string `json:"ref_id"`
 }
 
+// NackPayload reports that a message could not be processed. Retry tells
+// whether sending it again may succeed.
+type NackPayload struct {
+	RefID  string `json:"ref_id"`
+	Reason string `json:"reason"`
+	Retry  bool   `json:"retry"`
+}
+
 // Agent -> Server payloads
+
+// HelloPayload is the first message of an agent, describing what it
+// understands
+type HelloPayload struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	AgentVersion    string   `json:"agent_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
 type HeartbeatPayload struct {
 	Status         string   `json:"status"`
 	CachedVersion  int      `json:"cached_version"`
 	ActiveProjects []string `json:"active_projects"`
+	// RuleVersions maps the rules the agent applied to their versions
+	RuleVersions map[string]int `json:"rule_versions"`
+}
+
+// SyncRequestPayload asks for the changes since the revision the agent has
+// cached; 0 requests a full snapshot
+type SyncRequestPayload struct {
+	SinceVersion int `json:"since_version"`
 }
 
 type DriftReportPayload struct {
-	ProjectPath  string `json:"project_path"`
-	ExpectedHash string `json:"expected_hash"`
-	ActualHash   string `json:"actual_hash"`
-	Diff         string `json:"diff"`
+	Files         []DriftFilePayload `json:"files"`
+	CachedVersion int                `json:"cached_version"`
+}
+
+type DriftFilePayload struct {
+	Path         string   `json:"path"`
+	Level        string   `json:"level"`
+	Status       string   `json:"status"`
+	ExpectedHash string   `json:"expected_hash"`
+	ActualHash   string   `json:"actual_hash"`
+	RuleIDs      []string `json:"rule_ids"`
 }
 
 type ContextDetectedPayload struct {
 	ProjectPath     string   `json:"project_path"`
 	DetectedContext []string `json:"detected_context"`
 	DetectedTags    []string `json:"detected_tags"`
+	Repository      string   `json:"repository,omitempty"`
+	Branch          string   `json:"branch,omitempty"`
 }
 
 type SyncCompletePayload struct {
@@ -103,12 +206,15 @@ type SyncCompletePayload struct {
 type ChangeApprovedPayload struct {
 	ChangeID string `json:"change_id"`
 	RuleID   string `json:"rule_id"`
+	FilePath string `json:"file_path,omitempty"`
 }
 
 type ChangeRejectedPayload struct {
 	ChangeID     string `json:"change_id"`
 	RuleID       string `json:"rule_id"`
+	FilePath     string `json:"file_path,omitempty"`
 	RevertToHash string `json:"revert_to_hash"`
+	Reason       string `json:"reason,omitempty"`
 }
 
 type ExceptionGrantedPayload struct {
@@ -149,3 +255,25 @@ type ExceptionRequestPayload struct {
 type RevertCompletePayload struct {
 	ChangeID string `json:"change_id"`
 }
+
+// Remote commands
+
+// CommandPayload asks the agent to run one of its allowed commands
+type CommandPayload struct {
+	CommandID string `json:"command_id"`
+	Command   string `json:"command"`
+}
+
+// CommandResultPayload answers a command. Error is set when the command
+// failed or the agent does not allow it.
+type CommandResultPayload struct {
+	CommandID string          `json:"command_id"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// AgentRevokedPayload tells an agent it was revoked; the connection is closed
+// right after
+type AgentRevokedPayload struct {
+	AgentID string `json:"agent_id"`
+}
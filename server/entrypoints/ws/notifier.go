@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AgentPublisher publishes a message to an agent's direct channel
+type AgentPublisher interface {
+	PublishToAgent(ctx context.Context, agentID string, data []byte) error
+}
+
+// RemoteNotifier delivers messages to agents connected to worker processes.
+// Messages are published to the agent's direct channel, which the worker
+// holding the agent's connection subscribes to.
+type RemoteNotifier struct {
+	publisher AgentPublisher
+	timeout   time.Duration
+}
+
+// NewRemoteNotifier creates a notifier publishing through publisher
+func NewRemoteNotifier(publisher AgentPublisher) *RemoteNotifier {
+	return &RemoteNotifier{publisher: publisher, timeout: 5 * time.Second}
+}
+
+// BroadcastToAgent sends a message to every connection of an agent. Agents
+// that are not connected miss the message.
+func (n *RemoteNotifier) BroadcastToAgent(agentID string, msgType string, payload interface{}) error {
+	msg, err := NewMessage(MessageType(msgType), payload)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	return n.publisher.PublishToAgent(ctx, agentID, data)
+}
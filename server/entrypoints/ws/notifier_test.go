@@ -0,0 +1,44 @@
+package ws_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+)
+
+type recordingPublisher struct {
+	agentID string
+	data    []byte
+}
+
+func (p *recordingPublisher) PublishToAgent(ctx context.Context, agentID string, data []byte) error {
+	p.agentID = agentID
+	p.data = data
+	return nil
+}
+
+func TestRemoteNotifier_BroadcastToAgent(t *testing.T) {
+	pub := &recordingPublisher{}
+	notifier := ws.NewRemoteNotifier(pub)
+
+	err := notifier.BroadcastToAgent("agent-1", "change_approved", map[string]string{"change_id": "cr-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if pub.agentID != "agent-1" {
+		t.Errorf("expected message for agent-1, got %s", pub.agentID)
+	}
+	var msg ws.Message
+	if err := json.Unmarshal(pub.data, &msg); err != nil {
+		t.Fatalf("failed to unmarshal message: %v", err)
+	}
+	if msg.Type != "change_approved" || msg.ID == "" {
+		t.Errorf("unexpected message: %s", pub.data)
+	}
+	if string(msg.Payload) != `{"change_id":"cr-1"}` {
+		t.Errorf("unexpected payload: %s", msg.Payload)
+	}
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -65,3 +66,16 @@ const ChannelBroadcast = "broadcast:all"
 func ChannelForAgent(agentID string) string {
 	return "agent:" + agentID + ":direct"
 }
+
+// AgentFromChannel returns the agent ID of a direct message channel
+func AgentFromChannel(channel string) (string, bool) {
+	if !strings.HasPrefix(channel, "agent:") || !strings.HasSuffix(channel, ":direct") {
+		return "", false
+	}
+	agentID := strings.TrimSuffix(strings.TrimPrefix(channel, "agent:"), ":direct")
+	return agentID, agentID != ""
+}
+
+// StreamEvents is the Redis stream carrying team and broadcast events when
+// workers consume events from a stream instead of channels
+const StreamEvents = "stream:events"
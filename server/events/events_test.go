@@ -43,3 +43,14 @@ func TestChannelNaming(t *testing.T) {
 		t.Errorf("got %s, want agent:xyz:direct", got)
 	}
 }
+
+func TestAgentFromChannel(t *testing.T) {
+	if got, ok := AgentFromChannel(ChannelForAgent("xyz")); !ok || got != "xyz" {
+		t.Errorf("got %q, %v, want xyz", got, ok)
+	}
+	for _, channel := range []string{ChannelForTeam("abc"), ChannelBroadcast, "agent::direct"} {
+		if _, ok := AgentFromChannel(channel); ok {
+			t.Errorf("expected %s not to be a direct channel", channel)
+		}
+	}
+}
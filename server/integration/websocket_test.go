@@ -222,10 +222,15 @@ func TestWebSocket_SendDriftReport(t *testing.T) {
 	defer conn.Close()
 
 	driftReport := ws.DriftReportPayload{
-		ProjectPath:  "/path/to/project",
-		ExpectedHash: "abc123",
-		ActualHash:   "def456",
-		Diff:         "some diff content",
+		Files: []ws.DriftFilePayload{{
+			Path:         "/path/to/project/CLAUDE.md",
+			Level:        "project",
+			Status:       "tampered",
+			ExpectedHash: "abc123",
+			ActualHash:   "def456",
+			RuleIDs:      []string{"rule-1"},
+		}},
+		CachedVersion: 3,
 	}
 
 	msg, err := ws.NewMessage(ws.TypeDriftReport, driftReport)
@@ -253,8 +258,8 @@ func TestWebSocket_SendDriftReport(t *testing.T) {
 	if len(messageHandler.driftReports) != 1 {
 		t.Errorf("Expected 1 drift report, got %d", len(messageHandler.driftReports))
 	}
-	if messageHandler.driftReports[0].ProjectPath != "/path/to/project" {
-		t.Errorf("Expected project path '/path/to/project', got %s", messageHandler.driftReports[0].ProjectPath)
+	if files := messageHandler.driftReports[0].Files; len(files) != 1 || files[0].Path != "/path/to/project/CLAUDE.md" {
+		t.Errorf("Expected drift for '/path/to/project/CLAUDE.md', got %+v", files)
 	}
 }
 
@@ -0,0 +1,2 @@
+-- 000011_refresh_tokens.down.sql
+DROP TABLE IF EXISTS refresh_tokens;
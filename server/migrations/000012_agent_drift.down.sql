@@ -0,0 +1,2 @@
+-- 000012_agent_drift.down.sql
+DROP TABLE IF EXISTS agent_drift;
@@ -0,0 +1,21 @@
+-- 000012_agent_drift.up.sql
+-- Latest drift state reported by each agent, one row per managed file.
+-- Every report replaces the agent's previous rows.
+
+CREATE TABLE agent_drift (
+    agent_id VARCHAR(255) NOT NULL,
+    file_path TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    hostname VARCHAR(255) NOT NULL DEFAULT '',
+    level VARCHAR(50) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    expected_hash VARCHAR(64) NOT NULL DEFAULT '',
+    actual_hash VARCHAR(64) NOT NULL DEFAULT '',
+    rule_ids TEXT[] NOT NULL DEFAULT '{}',
+    reported_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (agent_id, file_path)
+);
+
+CREATE INDEX idx_agent_drift_team_id ON agent_drift(team_id);
+CREATE INDEX idx_agent_drift_status ON agent_drift(status);
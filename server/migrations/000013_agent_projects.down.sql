@@ -0,0 +1,2 @@
+-- 000013_agent_projects.down.sql
+DROP TABLE IF EXISTS agent_projects;
@@ -0,0 +1,18 @@
+-- 000013_agent_projects.up.sql
+-- Projects watched by each agent with the contexts and tags detected in them.
+-- Each context_detected message replaces the row for that project.
+
+CREATE TABLE agent_projects (
+    agent_id VARCHAR(255) NOT NULL,
+    project_path TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    detected_contexts TEXT[] NOT NULL DEFAULT '{}',
+    detected_tags TEXT[] NOT NULL DEFAULT '{}',
+    detected_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (agent_id, project_path)
+);
+
+CREATE INDEX idx_agent_projects_team_id ON agent_projects(team_id);
+CREATE INDEX idx_agent_projects_contexts ON agent_projects USING GIN(detected_contexts);
+CREATE INDEX idx_agent_projects_tags ON agent_projects USING GIN(detected_tags);
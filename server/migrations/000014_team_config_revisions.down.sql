@@ -0,0 +1,4 @@
+-- 000014_team_config_revisions.down.sql
+DROP TABLE IF EXISTS team_config_changes;
+DROP TABLE IF EXISTS team_config_rules;
+DROP TABLE IF EXISTS team_config_revisions;
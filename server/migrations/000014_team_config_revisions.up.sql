@@ -0,0 +1,28 @@
+-- 000014_team_config_revisions.up.sql
+-- Monotonic configuration revision per team and the log of rule changes
+-- between revisions, so agents can catch up with deltas.
+
+CREATE TABLE team_config_revisions (
+    team_id UUID PRIMARY KEY REFERENCES teams(id) ON DELETE CASCADE,
+    revision BIGINT NOT NULL DEFAULT 0,
+    settings_checksum VARCHAR(64) NOT NULL DEFAULT '',
+    pruned_through BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Checksum of each rule in the team's configuration as of its current revision
+CREATE TABLE team_config_rules (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    rule_id UUID NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    PRIMARY KEY (team_id, rule_id)
+);
+
+CREATE TABLE team_config_changes (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    revision BIGINT NOT NULL,
+    rule_id UUID NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('upsert', 'remove')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (team_id, revision, rule_id)
+);
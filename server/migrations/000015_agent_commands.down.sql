@@ -0,0 +1,3 @@
+-- 000015_agent_commands.down.sql
+DELETE FROM permissions WHERE code = 'manage_agents';
+DROP TABLE IF EXISTS agent_commands;
@@ -0,0 +1,22 @@
+-- 000015_agent_commands.up.sql
+-- Commands admins send to agents, with the result each agent reported back.
+
+CREATE TABLE agent_commands (
+    id UUID PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'sent', 'succeeded', 'failed')),
+    issued_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_agent_commands_agent_id ON agent_commands(agent_id, created_at DESC);
+
+INSERT INTO permissions (id, code, description, category) VALUES
+    ('a0000001-0000-0000-0000-00000000000d', 'manage_agents', 'Send commands to agents', 'admin');
+
+INSERT INTO role_permissions (role_id, permission_id) VALUES
+    ('b0000001-0000-0000-0000-000000000002', 'a0000001-0000-0000-0000-00000000000d');
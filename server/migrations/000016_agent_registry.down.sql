@@ -0,0 +1,10 @@
+-- 000016_agent_registry.down.sql
+DROP INDEX IF EXISTS idx_agents_team_id;
+UPDATE agents SET status = 'offline' WHERE status = 'revoked';
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS revoked_by,
+    DROP COLUMN IF EXISTS revoked_at,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS hostname,
+    DROP COLUMN IF EXISTS team_id;
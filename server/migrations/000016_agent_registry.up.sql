@@ -0,0 +1,13 @@
+-- 000016_agent_registry.up.sql
+-- Host details reported in heartbeats and revocation of agents. Agents are
+-- registered at login, one row per machine and user.
+
+ALTER TABLE agents
+    ADD COLUMN team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    ADD COLUMN hostname VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN os VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN version VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN revoked_at TIMESTAMPTZ,
+    ADD COLUMN revoked_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_agents_team_id ON agents(team_id);
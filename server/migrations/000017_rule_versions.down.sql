@@ -0,0 +1,7 @@
+-- 000017_rule_versions.down.sql
+DROP TABLE IF EXISTS agent_rule_versions;
+DROP TABLE IF EXISTS rule_versions;
+DROP FUNCTION IF EXISTS reject_rule_version_update();
+ALTER TABLE rules
+    DROP COLUMN IF EXISTS restores_version,
+    DROP COLUMN IF EXISTS current_version;
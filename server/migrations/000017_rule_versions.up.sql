@@ -0,0 +1,65 @@
+-- 000017_rule_versions.up.sql
+-- Immutable history of the approved revisions of each rule, and the rule
+-- versions each agent reported applying.
+
+ALTER TABLE rules
+    ADD COLUMN current_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN restores_version INTEGER;
+
+CREATE TABLE rule_versions (
+    rule_id UUID NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    content TEXT NOT NULL,
+    target_layer VARCHAR(50) NOT NULL,
+    priority_weight INTEGER NOT NULL,
+    overridable BOOLEAN NOT NULL,
+    effective_start TIMESTAMP WITH TIME ZONE,
+    effective_end TIMESTAMP WITH TIME ZONE,
+    target_teams UUID[] NOT NULL DEFAULT '{}',
+    target_users UUID[] NOT NULL DEFAULT '{}',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    triggers JSONB NOT NULL DEFAULT '[]',
+    force BOOLEAN NOT NULL,
+    enforcement_mode TEXT NOT NULL,
+    temporary_timeout_hours INTEGER NOT NULL,
+    restored_from INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (rule_id, version)
+);
+
+-- Versions are only ever added; they go away with their rule
+CREATE FUNCTION reject_rule_version_update() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'rule versions cannot be modified';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER rule_versions_immutable
+    BEFORE UPDATE ON rule_versions
+    FOR EACH ROW EXECUTE FUNCTION reject_rule_version_update();
+
+-- Rules approved before versions were recorded start at version 1
+INSERT INTO rule_versions (
+    rule_id, version, name, description, content, target_layer, priority_weight,
+    overridable, effective_start, effective_end, target_teams, target_users, tags,
+    triggers, force, enforcement_mode, temporary_timeout_hours, created_at
+)
+SELECT id, 1, name, description, content, target_layer, priority_weight,
+    overridable, effective_start, effective_end, COALESCE(target_teams, '{}'),
+    COALESCE(target_users, '{}'), COALESCE(tags, '{}'), triggers, force,
+    enforcement_mode, temporary_timeout_hours, COALESCE(approved_at, updated_at)
+FROM rules WHERE status = 'approved';
+
+UPDATE rules SET current_version = 1 WHERE status = 'approved';
+
+CREATE TABLE agent_rule_versions (
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    rule_id UUID NOT NULL,
+    version INTEGER NOT NULL,
+    reported_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (agent_id, rule_id)
+);
+
+CREATE INDEX idx_agent_rule_versions_rule_id ON agent_rule_versions(rule_id, version);
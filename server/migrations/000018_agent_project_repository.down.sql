@@ -0,0 +1,5 @@
+-- 000018_agent_project_repository.down.sql
+DROP INDEX IF EXISTS idx_agent_projects_repository;
+ALTER TABLE agent_projects
+    DROP COLUMN IF EXISTS branch,
+    DROP COLUMN IF EXISTS repository;
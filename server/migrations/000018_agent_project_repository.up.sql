@@ -0,0 +1,9 @@
+-- 000018_agent_project_repository.up.sql
+-- The git repository (as host/owner/name) and branch each watched project is
+-- checked out from, as reported with the project's context.
+
+ALTER TABLE agent_projects
+    ADD COLUMN repository TEXT NOT NULL DEFAULT '',
+    ADD COLUMN branch TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_agent_projects_repository ON agent_projects(repository);
@@ -0,0 +1,2 @@
+-- 000019_agent_upgrade_required.down.sql
+ALTER TABLE agents DROP COLUMN IF EXISTS upgrade_required_reason;
@@ -0,0 +1,6 @@
+-- 000019_agent_upgrade_required.up.sql
+-- Why the agent was last told to upgrade, cleared by its next accepted
+-- heartbeat.
+
+ALTER TABLE agents
+    ADD COLUMN upgrade_required_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- 000020_refresh_token_agent.down.sql
+DROP INDEX IF EXISTS idx_refresh_tokens_agent_id;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS agent_id;
@@ -0,0 +1,8 @@
+-- 000020_refresh_token_agent.up.sql
+-- The agent a refresh token family was issued to, set when the agent
+-- registers, so revoking the agent also revokes its logins.
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN agent_id UUID REFERENCES agents(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_refresh_tokens_agent_id ON refresh_tokens(agent_id);
@@ -0,0 +1,151 @@
+package agentcommands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	ErrInvalidCommand  = errors.New("invalid command")
+)
+
+// MessageTypeCommand is the WebSocket message type commands are sent to
+// agents with
+const MessageTypeCommand = "command"
+
+// defaultListLimit caps how many of an agent's commands are listed
+const defaultListLimit = 50
+
+type CommandDB interface {
+	Create(ctx context.Context, cmd domain.AgentCommand) error
+	GetByID(ctx context.Context, id string) (domain.AgentCommand, error)
+	ListByAgent(ctx context.Context, agentID string, limit int) ([]domain.AgentCommand, error)
+	Update(ctx context.Context, cmd domain.AgentCommand) error
+}
+
+// AgentRegistry looks up the agents commands are sent to
+type AgentRegistry interface {
+	Get(ctx context.Context, id string) (domain.Agent, error)
+}
+
+type WebSocketNotifier interface {
+	BroadcastToAgent(agentID string, msgType string, payload interface{}) error
+}
+
+type AuditLogger interface {
+	Log(ctx context.Context, action domain.AuditAction, actorID *string, resourceType, resourceID string, metadata map[string]interface{}) error
+}
+
+// Result is an agent's answer to a command
+type Result struct {
+	CommandID string
+	Output    json.RawMessage
+	Error     string
+}
+
+// Service sends commands to agents and records their results
+type Service struct {
+	db       CommandDB
+	registry AgentRegistry
+	notifier WebSocketNotifier
+	auditLog AuditLogger
+}
+
+func NewService(db CommandDB, registry AgentRegistry, notifier WebSocketNotifier, auditLog AuditLogger) *Service {
+	return &Service{db: db, registry: registry, notifier: notifier, auditLog: auditLog}
+}
+
+// Dispatch records a command and sends it to every connection of the agent.
+// A command that cannot be published is stored as failed. Unknown agents
+// fail with agents.ErrAgentNotFound and revoked ones with
+// agents.ErrAgentRevoked.
+func (s *Service) Dispatch(ctx context.Context, agentID string, commandType domain.AgentCommandType, issuedBy string) (domain.AgentCommand, error) {
+	cmd := domain.NewAgentCommand(agentID, commandType, issuedBy)
+	if err := cmd.Validate(); err != nil {
+		return domain.AgentCommand{}, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+	agent, err := s.registry.Get(ctx, agentID)
+	if err != nil {
+		return domain.AgentCommand{}, err
+	}
+	if agent.IsRevoked() {
+		return domain.AgentCommand{}, agents.ErrAgentRevoked
+	}
+	if err := s.db.Create(ctx, cmd); err != nil {
+		return domain.AgentCommand{}, err
+	}
+	if err := s.auditLog.Log(ctx, domain.AuditActionCommandIssued, &issuedBy, string(domain.AuditEntityAgentCommand), cmd.ID, map[string]interface{}{
+		"agent_id": agentID,
+		"command":  string(commandType),
+	}); err != nil {
+		log.Printf("Failed to audit command %s: %v", cmd.ID, err)
+	}
+
+	err = s.notifier.BroadcastToAgent(agentID, MessageTypeCommand, map[string]interface{}{
+		"command_id": cmd.ID,
+		"command":    string(commandType),
+	})
+	if err != nil {
+		cmd.Complete(nil, "delivery failed: "+err.Error())
+	} else {
+		cmd.Status = domain.AgentCommandStatusSent
+	}
+	if err := s.db.Update(ctx, cmd); err != nil {
+		return domain.AgentCommand{}, err
+	}
+	return cmd, nil
+}
+
+// Complete stores the result an agent reported for one of its commands.
+// Results for commands already completed are ignored, so agents may resend
+// them.
+func (s *Service) Complete(ctx context.Context, agentID string, result Result) error {
+	cmd, err := s.db.GetByID(ctx, result.CommandID)
+	if err != nil {
+		return err
+	}
+	if cmd.AgentID != agentID {
+		// Agents can only answer their own commands
+		return ErrCommandNotFound
+	}
+	if cmd.Status.IsFinal() {
+		return nil
+	}
+
+	cmd.Complete(result.Output, result.Error)
+	if err := s.db.Update(ctx, cmd); err != nil {
+		return err
+	}
+	if err := s.auditLog.Log(ctx, domain.AuditActionCommandCompleted, nil, string(domain.AuditEntityAgentCommand), cmd.ID, map[string]interface{}{
+		"agent_id": agentID,
+		"command":  string(cmd.Type),
+		"status":   string(cmd.Status),
+	}); err != nil {
+		log.Printf("Failed to audit result of command %s: %v", cmd.ID, err)
+	}
+	return nil
+}
+
+// Get returns one of the agent's commands
+func (s *Service) Get(ctx context.Context, agentID, id string) (domain.AgentCommand, error) {
+	cmd, err := s.db.GetByID(ctx, id)
+	if err != nil {
+		return domain.AgentCommand{}, err
+	}
+	if cmd.AgentID != agentID {
+		return domain.AgentCommand{}, ErrCommandNotFound
+	}
+	return cmd, nil
+}
+
+// List returns the agent's most recent commands, newest first
+func (s *Service) List(ctx context.Context, agentID string) ([]domain.AgentCommand, error) {
+	return s.db.ListByAgent(ctx, agentID, defaultListLimit)
+}
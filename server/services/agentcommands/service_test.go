@@ -0,0 +1,203 @@
+package agentcommands_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type mockCommandDB struct {
+	commands map[string]domain.AgentCommand
+}
+
+func newMockCommandDB() *mockCommandDB {
+	return &mockCommandDB{commands: make(map[string]domain.AgentCommand)}
+}
+
+func (m *mockCommandDB) Create(ctx context.Context, cmd domain.AgentCommand) error {
+	m.commands[cmd.ID] = cmd
+	return nil
+}
+
+func (m *mockCommandDB) GetByID(ctx context.Context, id string) (domain.AgentCommand, error) {
+	cmd, ok := m.commands[id]
+	if !ok {
+		return domain.AgentCommand{}, agentcommands.ErrCommandNotFound
+	}
+	return cmd, nil
+}
+
+func (m *mockCommandDB) ListByAgent(ctx context.Context, agentID string, limit int) ([]domain.AgentCommand, error) {
+	var result []domain.AgentCommand
+	for _, cmd := range m.commands {
+		if cmd.AgentID == agentID {
+			result = append(result, cmd)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockCommandDB) Update(ctx context.Context, cmd domain.AgentCommand) error {
+	m.commands[cmd.ID] = cmd
+	return nil
+}
+
+// mockAgents knows agent-1 and agent-2, and the revoked agent-3
+type mockAgents struct{}
+
+func (mockAgents) Get(ctx context.Context, id string) (domain.Agent, error) {
+	switch id {
+	case "agent-1", "agent-2":
+		return domain.Agent{ID: id, Status: domain.AgentStatusOnline}, nil
+	case "agent-3":
+		return domain.Agent{ID: id, Status: domain.AgentStatusRevoked}, nil
+	}
+	return domain.Agent{}, agents.ErrAgentNotFound
+}
+
+type sentMessage struct {
+	agentID string
+	msgType string
+	payload map[string]interface{}
+}
+
+type mockNotifier struct {
+	sent []sentMessage
+	err  error
+}
+
+func (m *mockNotifier) BroadcastToAgent(agentID string, msgType string, payload interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMessage{agentID, msgType, payload.(map[string]interface{})})
+	return nil
+}
+
+type mockAuditLogger struct {
+	actions []domain.AuditAction
+}
+
+func (m *mockAuditLogger) Log(ctx context.Context, action domain.AuditAction, actorID *string, resourceType, resourceID string, metadata map[string]interface{}) error {
+	m.actions = append(m.actions, action)
+	return nil
+}
+
+func TestService_DispatchSendsAndAudits(t *testing.T) {
+	db, notifier, audit := newMockCommandDB(), &mockNotifier{}, &mockAuditLogger{}
+	svc := agentcommands.NewService(db, mockAgents{}, notifier, audit)
+
+	cmd, err := svc.Dispatch(context.Background(), "agent-1", domain.AgentCommandDiagnostics, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Status != domain.AgentCommandStatusSent {
+		t.Errorf("expected status 'sent', got '%s'", cmd.Status)
+	}
+	if db.commands[cmd.ID].Status != domain.AgentCommandStatusSent {
+		t.Error("expected stored command to be marked sent")
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].agentID != "agent-1" || notifier.sent[0].msgType != agentcommands.MessageTypeCommand {
+		t.Fatalf("unexpected messages: %+v", notifier.sent)
+	}
+	if notifier.sent[0].payload["command_id"] != cmd.ID {
+		t.Errorf("expected command ID in payload, got %v", notifier.sent[0].payload)
+	}
+	if len(audit.actions) != 1 || audit.actions[0] != domain.AuditActionCommandIssued {
+		t.Errorf("expected command to be audited, got %v", audit.actions)
+	}
+}
+
+func TestService_DispatchRejectsUnknownCommand(t *testing.T) {
+	db, notifier, audit := newMockCommandDB(), &mockNotifier{}, &mockAuditLogger{}
+	svc := agentcommands.NewService(db, mockAgents{}, notifier, audit)
+
+	_, err := svc.Dispatch(context.Background(), "agent-1", "format_disk", "admin-1")
+	if !errors.Is(err, agentcommands.ErrInvalidCommand) {
+		t.Fatalf("expected ErrInvalidCommand, got %v", err)
+	}
+	if len(db.commands) != 0 || len(notifier.sent) != 0 {
+		t.Error("expected nothing stored or sent")
+	}
+}
+
+func TestService_DispatchRefusesUnknownAndRevokedAgents(t *testing.T) {
+	db, notifier, audit := newMockCommandDB(), &mockNotifier{}, &mockAuditLogger{}
+	svc := agentcommands.NewService(db, mockAgents{}, notifier, audit)
+
+	for agentID, want := range map[string]error{
+		"agent-9": agents.ErrAgentNotFound,
+		"agent-3": agents.ErrAgentRevoked,
+	} {
+		if _, err := svc.Dispatch(context.Background(), agentID, domain.AgentCommandDiagnostics, "admin-1"); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", agentID, want, err)
+		}
+	}
+	if len(db.commands) != 0 || len(notifier.sent) != 0 || len(audit.actions) != 0 {
+		t.Error("expected nothing stored, sent or audited")
+	}
+}
+
+func TestService_DispatchRecordsDeliveryFailure(t *testing.T) {
+	db, audit := newMockCommandDB(), &mockAuditLogger{}
+	svc := agentcommands.NewService(db, mockAgents{}, &mockNotifier{err: errors.New("redis down")}, audit)
+
+	cmd, err := svc.Dispatch(context.Background(), "agent-1", domain.AgentCommandResync, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Status != domain.AgentCommandStatusFailed || cmd.Error == "" {
+		t.Errorf("expected failed command with error, got %+v", cmd)
+	}
+}
+
+func TestService_CompleteStoresResult(t *testing.T) {
+	db, audit := newMockCommandDB(), &mockAuditLogger{}
+	svc := agentcommands.NewService(db, mockAgents{}, &mockNotifier{}, audit)
+	ctx := context.Background()
+
+	cmd, _ := svc.Dispatch(ctx, "agent-1", domain.AgentCommandDiagnostics, "admin-1")
+	err := svc.Complete(ctx, "agent-1", agentcommands.Result{CommandID: cmd.ID, Output: json.RawMessage(`{"queue_depth":2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := svc.Get(ctx, "agent-1", cmd.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != domain.AgentCommandStatusSucceeded || string(stored.Result) != `{"queue_depth":2}` {
+		t.Errorf("expected stored result, got %+v", stored)
+	}
+	if audit.actions[len(audit.actions)-1] != domain.AuditActionCommandCompleted {
+		t.Errorf("expected completion to be audited, got %v", audit.actions)
+	}
+
+	// A resent result does not overwrite the stored one
+	if err := svc.Complete(ctx, "agent-1", agentcommands.Result{CommandID: cmd.ID, Error: "late"}); err != nil {
+		t.Fatal(err)
+	}
+	if db.commands[cmd.ID].Status != domain.AgentCommandStatusSucceeded {
+		t.Error("expected resent result to be ignored")
+	}
+}
+
+func TestService_CompleteRejectsOtherAgents(t *testing.T) {
+	svc := agentcommands.NewService(newMockCommandDB(), mockAgents{}, &mockNotifier{}, &mockAuditLogger{})
+	ctx := context.Background()
+
+	cmd, _ := svc.Dispatch(ctx, "agent-1", domain.AgentCommandShutdown, "admin-1")
+	err := svc.Complete(ctx, "agent-2", agentcommands.Result{CommandID: cmd.ID})
+	if !errors.Is(err, agentcommands.ErrCommandNotFound) {
+		t.Fatalf("expected ErrCommandNotFound, got %v", err)
+	}
+	if _, err := svc.Get(ctx, "agent-2", cmd.ID); !errors.Is(err, agentcommands.ErrCommandNotFound) {
+		t.Errorf("expected ErrCommandNotFound for another agent, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package agentmessages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/changes"
+	"github.com/kamilrybacki/edictflow/server/services/exceptions"
+)
+
+// ErrRejected marks messages that can never be processed, so retrying them
+// is pointless
+var ErrRejected = errors.New("message rejected")
+
+// Message types that workers forward to the master
+const (
+	TypeChangeDetected   = "change_detected"
+	TypeChangeUpdated    = "change_updated"
+	TypeExceptionRequest = "exception_request"
+	TypeSyncComplete     = "sync_complete"
+	TypeRevertComplete   = "revert_complete"
+	TypeCommandResult    = "command_result"
+)
+
+// Forwarded reports whether workers hand messages of the type to the master
+func Forwarded(msgType string) bool {
+	switch msgType {
+	case TypeChangeDetected, TypeChangeUpdated, TypeExceptionRequest, TypeSyncComplete, TypeRevertComplete,
+		TypeCommandResult:
+		return true
+	}
+	return false
+}
+
+// Message is a message an agent sent to a worker, forwarded to the master
+// with the identity the worker authenticated the agent with
+type Message struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	AgentID  string          `json:"agent_id"`
+	UserID   string          `json:"user_id"`
+	TeamID   string          `json:"team_id"`
+	Hostname string          `json:"hostname,omitempty"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type ChangeService interface {
+	CreateFromAgent(ctx context.Context, payload changes.AgentChangePayload) (*domain.ChangeRequest, error)
+	UpdateFromAgent(ctx context.Context, id, agentID, userID, newHash, newDiff string) error
+}
+
+type ExceptionService interface {
+	Create(ctx context.Context, req exceptions.CreateExceptionRequest) (*domain.ExceptionRequest, error)
+}
+
+type CommandService interface {
+	Complete(ctx context.Context, agentID string, result agentcommands.Result) error
+}
+
+// Service processes agent messages forwarded by workers
+type Service struct {
+	changes    ChangeService
+	exceptions ExceptionService
+	commands   CommandService
+}
+
+func NewService(changes ChangeService, exceptions ExceptionService) *Service {
+	return &Service{changes: changes, exceptions: exceptions}
+}
+
+// SetCommands sets where command results are stored. Results are rejected
+// when no command service is set.
+func (s *Service) SetCommands(commands CommandService) {
+	s.commands = commands
+}
+
+// Process handles a forwarded message. Errors wrapping ErrRejected mean the
+// message is invalid; any other error is worth retrying.
+func (s *Service) Process(ctx context.Context, msg Message) error {
+	switch msg.Type {
+	case TypeChangeDetected:
+		// The enforcement mode agents send is ignored in favour of the rule's
+		var payload struct {
+			RuleID       string `json:"rule_id"`
+			FilePath     string `json:"file_path"`
+			OriginalHash string `json:"original_hash"`
+			ModifiedHash string `json:"modified_hash"`
+			Diff         string `json:"diff"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return reject(err)
+		}
+		cr, err := s.changes.CreateFromAgent(ctx, changes.AgentChangePayload{
+			RuleID:       payload.RuleID,
+			AgentID:      msg.AgentID,
+			UserID:       msg.UserID,
+			TeamID:       msg.TeamID,
+			FilePath:     payload.FilePath,
+			OriginalHash: payload.OriginalHash,
+			ModifiedHash: payload.ModifiedHash,
+			Diff:         payload.Diff,
+		})
+		if errors.Is(err, changes.ErrRuleNotFound) || errors.Is(err, changes.ErrAgentNotFound) {
+			return reject(err)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Change request %s recorded for %s from agent %s", cr.ID, cr.FilePath, msg.AgentID)
+
+	case TypeChangeUpdated:
+		var payload struct {
+			ChangeID     string `json:"change_id"`
+			ModifiedHash string `json:"modified_hash"`
+			Diff         string `json:"diff"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return reject(err)
+		}
+		err := s.changes.UpdateFromAgent(ctx, payload.ChangeID, msg.AgentID, msg.UserID, payload.ModifiedHash, payload.Diff)
+		if errors.Is(err, changes.ErrChangeRequestNotFound) || errors.Is(err, changes.ErrChangeNotPending) ||
+			errors.Is(err, changes.ErrNotChangeOwner) {
+			return reject(err)
+		}
+		return err
+
+	case TypeExceptionRequest:
+		var payload struct {
+			ChangeID               string `json:"change_id"`
+			Justification          string `json:"justification"`
+			ExceptionType          string `json:"exception_type"`
+			RequestedDurationHours *int   `json:"requested_duration_hours"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return reject(err)
+		}
+		_, err := s.exceptions.Create(ctx, exceptions.CreateExceptionRequest{
+			ChangeRequestID:        payload.ChangeID,
+			UserID:                 msg.UserID,
+			AgentID:                msg.AgentID,
+			Justification:          payload.Justification,
+			ExceptionType:          domain.ExceptionType(payload.ExceptionType),
+			RequestedDurationHours: payload.RequestedDurationHours,
+		})
+		if errors.Is(err, exceptions.ErrChangeRequestNotFound) || errors.Is(err, exceptions.ErrChangeNotRejected) ||
+			errors.Is(err, exceptions.ErrNotChangeOwner) {
+			return reject(err)
+		}
+		return err
+
+	case TypeCommandResult:
+		if s.commands == nil {
+			return reject(errors.New("commands are not supported"))
+		}
+		var payload struct {
+			CommandID string          `json:"command_id"`
+			Output    json.RawMessage `json:"output"`
+			Error     string          `json:"error"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return reject(err)
+		}
+		err := s.commands.Complete(ctx, msg.AgentID, agentcommands.Result{
+			CommandID: payload.CommandID,
+			Output:    payload.Output,
+			Error:     payload.Error,
+		})
+		if errors.Is(err, agentcommands.ErrCommandNotFound) {
+			return reject(err)
+		}
+		return err
+
+	case TypeSyncComplete, TypeRevertComplete:
+		// Nothing is recorded for these yet
+		log.Printf("Agent %s reported %s: %s", msg.AgentID, msg.Type, msg.Payload)
+
+	default:
+		return reject(fmt.Errorf("unsupported message type %q", msg.Type))
+	}
+	return nil
+}
+
+func reject(err error) error {
+	return fmt.Errorf("%w: %v", ErrRejected, err)
+}
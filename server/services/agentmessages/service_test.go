@@ -0,0 +1,396 @@
+package agentmessages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agentcommands"
+	"github.com/kamilrybacki/edictflow/server/services/changes"
+	"github.com/kamilrybacki/edictflow/server/services/exceptions"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+)
+
+type fakeChanges struct {
+	created   []changes.AgentChangePayload
+	updated   []string
+	createErr error
+	updateErr error
+}
+
+func (f *fakeChanges) CreateFromAgent(ctx context.Context, payload changes.AgentChangePayload) (*domain.ChangeRequest, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	f.created = append(f.created, payload)
+	return &domain.ChangeRequest{ID: "cr-1", FilePath: payload.FilePath}, nil
+}
+
+func (f *fakeChanges) UpdateFromAgent(ctx context.Context, id, agentID, userID, newHash, newDiff string) error {
+	f.updated = append(f.updated, id)
+	return f.updateErr
+}
+
+type fakeExceptions struct {
+	requests []exceptions.CreateExceptionRequest
+	err      error
+}
+
+func (f *fakeExceptions) Create(ctx context.Context, req exceptions.CreateExceptionRequest) (*domain.ExceptionRequest, error) {
+	f.requests = append(f.requests, req)
+	return &domain.ExceptionRequest{}, f.err
+}
+
+type fakeCommands struct {
+	results []agentcommands.Result
+	err     error
+}
+
+func (f *fakeCommands) Complete(ctx context.Context, agentID string, result agentcommands.Result) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.results = append(f.results, result)
+	return nil
+}
+
+func message(msgType string, payload interface{}) Message {
+	data, _ := json.Marshal(payload)
+	return Message{ID: "msg-1", Type: msgType, AgentID: "agent-1", UserID: "user-1", TeamID: "team-1", Payload: data}
+}
+
+func TestProcess_ChangeDetected(t *testing.T) {
+	changeSvc := &fakeChanges{}
+	svc := NewService(changeSvc, &fakeExceptions{})
+
+	err := svc.Process(context.Background(), message(TypeChangeDetected, map[string]string{
+		"rule_id":       "rule-1",
+		"file_path":     "/repo/CLAUDE.md",
+		"original_hash": "a",
+		"modified_hash": "b",
+		"diff":          "-a\n+b",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(changeSvc.created) != 1 {
+		t.Fatalf("expected 1 change request, got %d", len(changeSvc.created))
+	}
+	got := changeSvc.created[0]
+	if got.AgentID != "agent-1" || got.UserID != "user-1" || got.TeamID != "team-1" {
+		t.Errorf("expected the forwarded identity, got %+v", got)
+	}
+	if got.RuleID != "rule-1" || got.FilePath != "/repo/CLAUDE.md" || got.ModifiedHash != "b" {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
+
+type memoryChangeRepo struct {
+	created []domain.ChangeRequest
+}
+
+func (r *memoryChangeRepo) Create(ctx context.Context, cr domain.ChangeRequest) error {
+	r.created = append(r.created, cr)
+	return nil
+}
+
+func (r *memoryChangeRepo) GetByID(ctx context.Context, id string) (*domain.ChangeRequest, error) {
+	for _, cr := range r.created {
+		if cr.ID == id {
+			return &cr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryChangeRepo) ListByTeam(ctx context.Context, teamID string, filter changes.ChangeRequestFilter) ([]domain.ChangeRequest, error) {
+	return nil, nil
+}
+
+func (r *memoryChangeRepo) Update(ctx context.Context, cr domain.ChangeRequest) error {
+	for i := range r.created {
+		if r.created[i].ID == cr.ID {
+			r.created[i] = cr
+		}
+	}
+	return nil
+}
+
+func (r *memoryChangeRepo) FindExpiredTemporary(ctx context.Context, before time.Time) ([]domain.ChangeRequest, error) {
+	return nil, nil
+}
+
+func (r *memoryChangeRepo) FindByAgentAndFile(ctx context.Context, agentID, filePath string) (*domain.ChangeRequest, error) {
+	return nil, nil
+}
+
+type memoryExceptionRepo struct {
+	created []domain.ExceptionRequest
+}
+
+func (r *memoryExceptionRepo) Create(ctx context.Context, er domain.ExceptionRequest) error {
+	r.created = append(r.created, er)
+	return nil
+}
+
+func (r *memoryExceptionRepo) GetByID(ctx context.Context, id string) (*domain.ExceptionRequest, error) {
+	return nil, nil
+}
+
+func (r *memoryExceptionRepo) ListByTeam(ctx context.Context, teamID string, filter exceptions.ExceptionRequestFilter) ([]domain.ExceptionRequest, error) {
+	return nil, nil
+}
+
+func (r *memoryExceptionRepo) Update(ctx context.Context, er domain.ExceptionRequest) error {
+	return nil
+}
+
+func (r *memoryExceptionRepo) FindActiveByUserRuleFile(ctx context.Context, userID, ruleID, filePath string) (*domain.ExceptionRequest, error) {
+	return nil, nil
+}
+
+type memoryRuleRepo map[string]domain.Rule
+
+func (r memoryRuleRepo) GetRule(ctx context.Context, id string) (domain.Rule, error) {
+	if rule, ok := r[id]; ok {
+		return rule, nil
+	}
+	return domain.Rule{}, rules.ErrRuleNotFound
+}
+
+type memoryAgentRepo map[string]domain.Agent
+
+func (r memoryAgentRepo) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
+	if agent, ok := r[id]; ok {
+		return &agent, nil
+	}
+	return nil, nil
+}
+
+type failingRuleRepo struct{}
+
+func (failingRuleRepo) GetRule(ctx context.Context, id string) (domain.Rule, error) {
+	return domain.Rule{}, errors.New("connection refused")
+}
+
+// TestProcess_ChangeDetectedFromDaemon runs a change_detected payload as the
+// daemon sends it through the change service
+func TestProcess_ChangeDetectedFromDaemon(t *testing.T) {
+	rule := domain.NewRule("Tests", domain.TargetLayerProject, "Write table tests", nil, "team-1")
+	rule.EnforcementMode = domain.EnforcementModeTemporary
+	rule.TemporaryTimeoutHours = 2
+	agentID := uuid.New().String()
+	repo := &memoryChangeRepo{}
+	changeSvc := changes.NewService(repo, memoryRuleRepo{rule.ID: rule}, memoryAgentRepo{agentID: {ID: agentID}})
+	svc := NewService(changeSvc, &fakeExceptions{})
+
+	payload := map[string]string{
+		"rule_id":          rule.ID,
+		"file_path":        "/repo/CLAUDE.md",
+		"original_hash":    "a",
+		"modified_hash":    "b",
+		"diff":             "-Write table tests\n+Tests are optional",
+		"enforcement_mode": "warning",
+	}
+	msg := message(TypeChangeDetected, payload)
+	msg.AgentID = agentID
+	if err := svc.Process(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repo.created) != 1 {
+		t.Fatalf("expected 1 change request, got %d", len(repo.created))
+	}
+	cr := repo.created[0]
+	if cr.RuleID != rule.ID || cr.AgentID != agentID || cr.FilePath != "/repo/CLAUDE.md" {
+		t.Errorf("unexpected change request: %+v", cr)
+	}
+	if cr.EnforcementMode != domain.EnforcementModeTemporary || cr.TimeoutAt == nil {
+		t.Errorf("expected the rule's temporary mode, got %s (timeout %v)", cr.EnforcementMode, cr.TimeoutAt)
+	}
+
+	for _, unknown := range []string{uuid.New().String(), "agent-2"} {
+		msg.AgentID = unknown
+		if err := svc.Process(context.Background(), msg); !errors.Is(err, ErrRejected) {
+			t.Errorf("expected a change from unknown agent %s to be rejected, got %v", unknown, err)
+		}
+	}
+
+	// A failing rule lookup is retried rather than losing the change
+	msg.AgentID = agentID
+	failing := NewService(changes.NewService(repo, failingRuleRepo{}, memoryAgentRepo{agentID: {ID: agentID}}), &fakeExceptions{})
+	if err := failing.Process(context.Background(), msg); err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
+
+// TestProcess_ChangeOfAnotherAgentIsRejected runs change updates and exception
+// requests through the services for a change another agent reported
+func TestProcess_ChangeOfAnotherAgentIsRejected(t *testing.T) {
+	cr := domain.NewChangeRequest("rule-1", "agent-1", "user-1", "team-1", "/repo/CLAUDE.md", "a", "b", "-a\n+b",
+		domain.EnforcementModeBlock, nil)
+	cr.Status = domain.ChangeRequestStatusPending
+	changeRepo := &memoryChangeRepo{created: []domain.ChangeRequest{cr}}
+	exceptionRepo := &memoryExceptionRepo{}
+	svc := NewService(changes.NewService(changeRepo, memoryRuleRepo{}, memoryAgentRepo{}),
+		exceptions.NewService(exceptionRepo, changeRepo))
+
+	update := map[string]string{"change_id": cr.ID, "modified_hash": "c", "diff": "-a\n+c"}
+	request := map[string]string{"change_id": cr.ID, "justification": "hotfix", "exception_type": "time_limited"}
+	for _, sender := range []struct{ agentID, userID string }{
+		{"agent-2", "user-1"},
+		{"agent-1", "user-2"},
+	} {
+		msg := message(TypeChangeUpdated, update)
+		msg.AgentID, msg.UserID = sender.agentID, sender.userID
+		if err := svc.Process(context.Background(), msg); !errors.Is(err, ErrRejected) {
+			t.Errorf("expected the update from %s of %s to be rejected, got %v", sender.agentID, sender.userID, err)
+		}
+	}
+	if changeRepo.created[0].ModifiedHash != "b" {
+		t.Errorf("expected the change to be left alone, got hash %s", changeRepo.created[0].ModifiedHash)
+	}
+	if err := svc.Process(context.Background(), message(TypeChangeUpdated, update)); err != nil {
+		t.Errorf("expected the reporting agent's update to be applied, got %v", err)
+	}
+
+	changeRepo.created[0].Status = domain.ChangeRequestStatusRejected
+	msg := message(TypeExceptionRequest, request)
+	msg.AgentID = "agent-2"
+	if err := svc.Process(context.Background(), msg); !errors.Is(err, ErrRejected) {
+		t.Errorf("expected the exception request of another agent to be rejected, got %v", err)
+	}
+	if len(exceptionRepo.created) != 0 {
+		t.Errorf("expected no exception request, got %+v", exceptionRepo.created)
+	}
+	if err := svc.Process(context.Background(), message(TypeExceptionRequest, request)); err != nil {
+		t.Errorf("expected the reporting agent's exception request to be created, got %v", err)
+	}
+}
+
+func TestProcess_ExceptionRequestUsesForwardedUser(t *testing.T) {
+	exceptionSvc := &fakeExceptions{}
+	svc := NewService(&fakeChanges{}, exceptionSvc)
+
+	err := svc.Process(context.Background(), message(TypeExceptionRequest, map[string]string{
+		"change_id":      "cr-1",
+		"justification":  "hotfix",
+		"exception_type": "time_limited",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(exceptionSvc.requests) != 1 || exceptionSvc.requests[0].UserID != "user-1" || exceptionSvc.requests[0].AgentID != "agent-1" ||
+		exceptionSvc.requests[0].ChangeRequestID != "cr-1" {
+		t.Errorf("unexpected exception requests: %+v", exceptionSvc.requests)
+	}
+}
+
+func TestProcess_Errors(t *testing.T) {
+	transient := errors.New("connection refused")
+
+	tests := []struct {
+		name     string
+		changes  *fakeChanges
+		except   *fakeExceptions
+		msg      Message
+		rejected bool
+	}{
+		{
+			name:     "unknown rule",
+			changes:  &fakeChanges{createErr: changes.ErrRuleNotFound},
+			msg:      message(TypeChangeDetected, map[string]string{"rule_id": "gone"}),
+			rejected: true,
+		},
+		{
+			name:     "unknown agent",
+			changes:  &fakeChanges{createErr: changes.ErrAgentNotFound},
+			msg:      message(TypeChangeDetected, map[string]string{"rule_id": "rule-1"}),
+			rejected: true,
+		},
+		{
+			name:    "database failure",
+			changes: &fakeChanges{createErr: transient},
+			msg:     message(TypeChangeDetected, map[string]string{"rule_id": "rule-1"}),
+		},
+		{
+			name:     "change no longer pending",
+			changes:  &fakeChanges{updateErr: changes.ErrChangeNotPending},
+			msg:      message(TypeChangeUpdated, map[string]string{"change_id": "cr-1"}),
+			rejected: true,
+		},
+		{
+			name:     "exception for a change that was not rejected",
+			except:   &fakeExceptions{err: exceptions.ErrChangeNotRejected},
+			msg:      message(TypeExceptionRequest, map[string]string{"change_id": "cr-1"}),
+			rejected: true,
+		},
+		{
+			name:     "malformed payload",
+			msg:      Message{Type: TypeChangeDetected, Payload: json.RawMessage(`"nope"`)},
+			rejected: true,
+		},
+		{
+			name:     "unsupported type",
+			msg:      message("heartbeat", nil),
+			rejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.changes == nil {
+				tt.changes = &fakeChanges{}
+			}
+			if tt.except == nil {
+				tt.except = &fakeExceptions{}
+			}
+			err := NewService(tt.changes, tt.except).Process(context.Background(), tt.msg)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrRejected) != tt.rejected {
+				t.Errorf("rejected = %v, want %v (%v)", errors.Is(err, ErrRejected), tt.rejected, err)
+			}
+		})
+	}
+}
+
+func TestForwarded(t *testing.T) {
+	for _, msgType := range []string{TypeChangeDetected, TypeChangeUpdated, TypeExceptionRequest, TypeSyncComplete, TypeRevertComplete} {
+		if !Forwarded(msgType) {
+			t.Errorf("expected %s to be forwarded", msgType)
+		}
+	}
+	if Forwarded("heartbeat") {
+		t.Error("expected heartbeats to stay on the worker")
+	}
+}
+
+func TestProcess_CommandResult(t *testing.T) {
+	commands := &fakeCommands{}
+	svc := NewService(&fakeChanges{}, &fakeExceptions{})
+	svc.SetCommands(commands)
+
+	err := svc.Process(context.Background(), message(TypeCommandResult, map[string]interface{}{
+		"command_id": "cmd-1",
+		"output":     map[string]int{"queue_depth": 3},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands.results) != 1 || commands.results[0].CommandID != "cmd-1" || string(commands.results[0].Output) != `{"queue_depth":3}` {
+		t.Errorf("unexpected results: %+v", commands.results)
+	}
+
+	commands.err = agentcommands.ErrCommandNotFound
+	err = svc.Process(context.Background(), message(TypeCommandResult, map[string]string{"command_id": "other"}))
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("expected result for an unknown command to be rejected, got %v", err)
+	}
+}
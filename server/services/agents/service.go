@@ -0,0 +1,279 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+var (
+	ErrAgentNotFound  = errors.New("agent not found")
+	ErrAgentRevoked   = errors.New("agent revoked")
+	ErrMachineIDEmpty = errors.New("machine ID cannot be empty")
+)
+
+// MessageTypeAgentRevoked is the WebSocket message that tells a revoked
+// agent's connections to close
+const MessageTypeAgentRevoked = "agent_revoked"
+
+type AgentDB interface {
+	// Register creates the agent of a machine and user, or returns the
+	// existing one with its host details updated
+	Register(ctx context.Context, agent domain.Agent) (domain.Agent, error)
+	GetByID(ctx context.Context, id string) (*domain.Agent, error)
+	List(ctx context.Context, filter Filter) ([]domain.Agent, error)
+	RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat, at time.Time) error
+	RecordUpgradeRequired(ctx context.Context, id, reason string) error
+	// SetInactiveStatus changes an agent's status unless it sent a heartbeat
+	// after lastHeartbeat or was revoked
+	SetInactiveStatus(ctx context.Context, id string, status domain.AgentStatus, lastHeartbeat time.Time) error
+	Revoke(ctx context.Context, id, revokedBy string, at time.Time) error
+}
+
+type ProjectDB interface {
+	UpsertProject(ctx context.Context, project domain.AgentProject) error
+	ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error)
+}
+
+type AuditLogger interface {
+	Log(ctx context.Context, action domain.AuditAction, actorID *string, resourceType, resourceID string, metadata map[string]interface{}) error
+}
+
+type WebSocketNotifier interface {
+	BroadcastToAgent(agentID string, msgType string, payload interface{}) error
+}
+
+// RefreshTokens links logins to the agent that made them, so revoking the
+// agent also ends its logins
+type RefreshTokens interface {
+	BindRefreshToken(ctx context.Context, refreshToken, userID, agentID string) error
+	RevokeAgentRefreshTokens(ctx context.Context, agentID string) error
+}
+
+// Filter narrows the listed agents; empty fields match every agent
+type Filter struct {
+	UserID string
+	TeamID string
+	Status domain.AgentStatus
+}
+
+// Registration is what an agent reports about its machine at login
+type Registration struct {
+	MachineID string
+	UserID    string
+	TeamID    string
+	Hostname  string
+	OS        string
+	Version   string
+	// RefreshToken is the refresh token of the login, linked to the agent
+	RefreshToken string
+}
+
+type Service struct {
+	agentDB       AgentDB
+	projectDB     ProjectDB
+	auditLog      AuditLogger
+	wsNotifier    WebSocketNotifier
+	refreshTokens RefreshTokens
+}
+
+func NewService(agentDB AgentDB, projectDB ProjectDB) *Service {
+	return &Service{agentDB: agentDB, projectDB: projectDB}
+}
+
+func (s *Service) WithAuditLogger(logger AuditLogger) *Service {
+	s.auditLog = logger
+	return s
+}
+
+// WithWebSocketNotifier lets revocations disconnect the agent
+func (s *Service) WithWebSocketNotifier(wsNotifier WebSocketNotifier) *Service {
+	s.wsNotifier = wsNotifier
+	return s
+}
+
+// WithRefreshTokens lets revocations end the agent's logins
+func (s *Service) WithRefreshTokens(tokens RefreshTokens) *Service {
+	s.refreshTokens = tokens
+	return s
+}
+
+// Register returns the agent ID of a machine, creating the agent on its
+// first login. Logging in again on the same machine keeps the ID.
+func (s *Service) Register(ctx context.Context, reg Registration) (domain.Agent, error) {
+	if reg.MachineID == "" {
+		return domain.Agent{}, ErrMachineIDEmpty
+	}
+
+	agent := domain.NewAgent(reg.MachineID, reg.UserID)
+	agent.Status = domain.AgentStatusOffline
+	agent.TeamID = reg.TeamID
+	agent.Hostname = reg.Hostname
+	agent.OS = reg.OS
+	agent.Version = reg.Version
+
+	registered, err := s.agentDB.Register(ctx, agent)
+	if err != nil {
+		return domain.Agent{}, err
+	}
+	if registered.IsRevoked() {
+		return domain.Agent{}, ErrAgentRevoked
+	}
+	if s.refreshTokens != nil && reg.RefreshToken != "" {
+		if err := s.refreshTokens.BindRefreshToken(ctx, reg.RefreshToken, reg.UserID, registered.ID); err != nil {
+			return domain.Agent{}, fmt.Errorf("failed to link login to agent: %w", err)
+		}
+	}
+	return registered, nil
+}
+
+// Authorize checks that the agent belongs to the user and was not revoked
+func (s *Service) Authorize(ctx context.Context, agentID, userID string) error {
+	_, err := s.lookup(ctx, agentID, userID)
+	return err
+}
+
+// RecordHeartbeat marks the agent online and stores the state it reported
+func (s *Service) RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat) error {
+	if _, err := s.lookup(ctx, heartbeat.AgentID, heartbeat.UserID); err != nil {
+		return err
+	}
+	return s.agentDB.RecordHeartbeat(ctx, heartbeat, time.Now())
+}
+
+// RecordUpgradeRequired stores why the agent was told to upgrade on its
+// registry entry
+func (s *Service) RecordUpgradeRequired(ctx context.Context, agentID, userID, reason string) error {
+	if _, err := s.lookup(ctx, agentID, userID); err != nil {
+		return err
+	}
+	return s.agentDB.RecordUpgradeRequired(ctx, agentID, reason)
+}
+
+func (s *Service) lookup(ctx context.Context, agentID, userID string) (*domain.Agent, error) {
+	if _, err := uuid.Parse(agentID); err != nil {
+		return nil, ErrAgentNotFound
+	}
+	agent, err := s.agentDB.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil || agent.UserID != userID {
+		return nil, ErrAgentNotFound
+	}
+	if agent.IsRevoked() {
+		return nil, ErrAgentRevoked
+	}
+	return agent, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (domain.Agent, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.Agent{}, ErrAgentNotFound
+	}
+	agent, err := s.agentDB.GetByID(ctx, id)
+	if err != nil {
+		return domain.Agent{}, err
+	}
+	if agent == nil {
+		return domain.Agent{}, ErrAgentNotFound
+	}
+	return *agent, nil
+}
+
+func (s *Service) List(ctx context.Context, filter Filter) ([]domain.Agent, error) {
+	return s.agentDB.List(ctx, filter)
+}
+
+// MarkInactive moves agents that stopped sending heartbeats to stale and
+// then offline, returning how many changed status
+func (s *Service) MarkInactive(ctx context.Context, staleAfter, offlineAfter time.Duration) (int, error) {
+	changed := 0
+	for _, status := range []domain.AgentStatus{domain.AgentStatusOnline, domain.AgentStatusStale} {
+		agents, err := s.agentDB.List(ctx, Filter{Status: status})
+		if err != nil {
+			return changed, err
+		}
+		for _, a := range agents {
+			next := a.InactiveStatus(staleAfter, offlineAfter)
+			if next == a.Status {
+				continue
+			}
+			if err := s.agentDB.SetInactiveStatus(ctx, a.ID, next, a.LastHeartbeat); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// Revoke stops the agent from connecting, ends its logins and disconnects
+// it. Its user has to be given a new agent by logging in on another machine.
+func (s *Service) Revoke(ctx context.Context, id, revokedBy string) (domain.Agent, error) {
+	agent, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Agent{}, err
+	}
+	if agent.IsRevoked() {
+		return agent, nil
+	}
+
+	// Logins go first, so a failure leaves the agent to be revoked again
+	if s.refreshTokens != nil {
+		if err := s.refreshTokens.RevokeAgentRefreshTokens(ctx, id); err != nil {
+			return domain.Agent{}, err
+		}
+	}
+
+	now := time.Now()
+	if err := s.agentDB.Revoke(ctx, id, revokedBy, now); err != nil {
+		return domain.Agent{}, err
+	}
+	agent.Status = domain.AgentStatusRevoked
+	agent.RevokedAt = &now
+	agent.RevokedBy = &revokedBy
+
+	if s.auditLog != nil {
+		_ = s.auditLog.Log(ctx, domain.AuditActionRevoked, &revokedBy, string(domain.AuditEntityAgent), id, map[string]interface{}{
+			"user_id":  agent.UserID,
+			"hostname": agent.Hostname,
+		})
+	}
+
+	if s.wsNotifier != nil {
+		if err := s.wsNotifier.BroadcastToAgent(id, MessageTypeAgentRevoked, map[string]interface{}{
+			"agent_id": id,
+		}); err != nil {
+			log.Printf("Failed to disconnect revoked agent %s: %v", id, err)
+		}
+	}
+	return agent, nil
+}
+
+// RecordProjectContext stores the contexts and tags an agent detected in one
+// of its projects, replacing what was detected before
+func (s *Service) RecordProjectContext(ctx context.Context, project domain.AgentProject) error {
+	if err := project.Validate(); err != nil {
+		return err
+	}
+	if project.DetectedContexts == nil {
+		project.DetectedContexts = []string{}
+	}
+	if project.DetectedTags == nil {
+		project.DetectedTags = []string{}
+	}
+	if project.DetectedAt.IsZero() {
+		project.DetectedAt = time.Now()
+	}
+	return s.projectDB.UpsertProject(ctx, project)
+}
+
+func (s *Service) ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error) {
+	return s.projectDB.ListProjects(ctx, agentID)
+}
@@ -0,0 +1,331 @@
+package agents_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+)
+
+type mockAgentDB struct {
+	agents map[string]domain.Agent
+}
+
+func newMockAgentDB() *mockAgentDB {
+	return &mockAgentDB{agents: make(map[string]domain.Agent)}
+}
+
+func (m *mockAgentDB) Register(ctx context.Context, agent domain.Agent) (domain.Agent, error) {
+	for id, a := range m.agents {
+		if a.MachineID == agent.MachineID && a.UserID == agent.UserID {
+			if !a.IsRevoked() {
+				a.Hostname = agent.Hostname
+				m.agents[id] = a
+			}
+			return a, nil
+		}
+	}
+	m.agents[agent.ID] = agent
+	return agent, nil
+}
+
+func (m *mockAgentDB) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
+	a, ok := m.agents[id]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (m *mockAgentDB) List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error) {
+	result := []domain.Agent{}
+	for _, a := range m.agents {
+		if filter.Status != "" && a.Status != filter.Status {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+func (m *mockAgentDB) RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat, at time.Time) error {
+	a := m.agents[heartbeat.AgentID]
+	a.Status = domain.AgentStatusOnline
+	a.LastHeartbeat = at
+	a.CachedConfigVersion = heartbeat.CachedVersion
+	a.UpgradeRequiredReason = ""
+	m.agents[a.ID] = a
+	return nil
+}
+
+func (m *mockAgentDB) RecordUpgradeRequired(ctx context.Context, id, reason string) error {
+	a := m.agents[id]
+	a.UpgradeRequiredReason = reason
+	m.agents[id] = a
+	return nil
+}
+
+func (m *mockAgentDB) SetInactiveStatus(ctx context.Context, id string, status domain.AgentStatus, lastHeartbeat time.Time) error {
+	a := m.agents[id]
+	if a.LastHeartbeat.Equal(lastHeartbeat) {
+		a.Status = status
+		m.agents[id] = a
+	}
+	return nil
+}
+
+func (m *mockAgentDB) Revoke(ctx context.Context, id, revokedBy string, at time.Time) error {
+	a := m.agents[id]
+	a.Status = domain.AgentStatusRevoked
+	a.RevokedAt = &at
+	a.RevokedBy = &revokedBy
+	m.agents[id] = a
+	return nil
+}
+
+type mockNotifier struct {
+	sent []string
+}
+
+func (m *mockNotifier) BroadcastToAgent(agentID string, msgType string, payload interface{}) error {
+	m.sent = append(m.sent, agentID+":"+msgType)
+	return nil
+}
+
+type mockRefreshTokens struct {
+	bound   map[string]string // refresh token to agent ID
+	revoked []string
+}
+
+func (m *mockRefreshTokens) BindRefreshToken(ctx context.Context, refreshToken, userID, agentID string) error {
+	m.bound[refreshToken] = agentID
+	return nil
+}
+
+func (m *mockRefreshTokens) RevokeAgentRefreshTokens(ctx context.Context, agentID string) error {
+	m.revoked = append(m.revoked, agentID)
+	return nil
+}
+
+type mockProjectDB struct {
+	projects map[string]domain.AgentProject
+}
+
+func newMockProjectDB() *mockProjectDB {
+	return &mockProjectDB{projects: make(map[string]domain.AgentProject)}
+}
+
+func (m *mockProjectDB) UpsertProject(ctx context.Context, project domain.AgentProject) error {
+	m.projects[project.AgentID+":"+project.ProjectPath] = project
+	return nil
+}
+
+func (m *mockProjectDB) ListProjects(ctx context.Context, agentID string) ([]domain.AgentProject, error) {
+	var result []domain.AgentProject
+	for _, p := range m.projects {
+		if p.AgentID == agentID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func TestService_RecordProjectContextReplacesDetection(t *testing.T) {
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB())
+	ctx := context.Background()
+
+	first := domain.AgentProject{AgentID: "agent-1", UserID: "user-1", ProjectPath: "/repo", DetectedContexts: []string{"go"}}
+	if err := svc.RecordProjectContext(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second := domain.AgentProject{AgentID: "agent-1", UserID: "user-1", ProjectPath: "/repo", DetectedContexts: []string{"go", "docker"}}
+	if err := svc.RecordProjectContext(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	projects, err := svc.ListProjects(ctx, "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 {
+		t.Fatalf("expected 1 project, got %d", len(projects))
+	}
+	if len(projects[0].DetectedContexts) != 2 {
+		t.Errorf("expected latest contexts, got %v", projects[0].DetectedContexts)
+	}
+	if projects[0].DetectedTags == nil {
+		t.Error("expected missing tags stored as an empty list")
+	}
+	if projects[0].DetectedAt.IsZero() {
+		t.Error("expected detection time to be set")
+	}
+}
+
+func TestService_RecordProjectContextRequiresPath(t *testing.T) {
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB())
+
+	err := svc.RecordProjectContext(context.Background(), domain.AgentProject{AgentID: "agent-1", UserID: "user-1"})
+	if err == nil {
+		t.Error("expected error for missing project path")
+	}
+}
+
+func TestService_RegisterKeepsAgentIDPerMachine(t *testing.T) {
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB())
+	ctx := context.Background()
+
+	first, err := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1", Hostname: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1", Hostname: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID {
+		t.Errorf("expected the same agent ID on re-login, got %s and %s", first.ID, second.ID)
+	}
+	if second.Hostname != "new" {
+		t.Errorf("expected host details to be refreshed, got %q", second.Hostname)
+	}
+
+	if _, err := svc.Register(ctx, agents.Registration{UserID: "user-1"}); err != agents.ErrMachineIDEmpty {
+		t.Errorf("expected ErrMachineIDEmpty, got %v", err)
+	}
+}
+
+func TestService_RecordUpgradeRequired(t *testing.T) {
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB())
+	ctx := context.Background()
+
+	agent, _ := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1"})
+
+	reason := "agent version 0.1.0 is older than the minimum 0.2.0"
+	if err := svc.RecordUpgradeRequired(ctx, agent.ID, "user-2", reason); err != agents.ErrAgentNotFound {
+		t.Errorf("expected ErrAgentNotFound for another user's agent, got %v", err)
+	}
+	if err := svc.RecordUpgradeRequired(ctx, agent.ID, "user-1", reason); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := svc.Get(ctx, agent.ID)
+	if got.UpgradeRequiredReason != reason {
+		t.Errorf("expected the reason on the agent, got %q", got.UpgradeRequiredReason)
+	}
+
+	if err := svc.RecordHeartbeat(ctx, domain.AgentHeartbeat{AgentID: agent.ID, UserID: "user-1"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = svc.Get(ctx, agent.ID)
+	if got.UpgradeRequiredReason != "" {
+		t.Errorf("expected an accepted heartbeat to clear the reason, got %q", got.UpgradeRequiredReason)
+	}
+}
+
+func TestService_RecordHeartbeatChecksOwner(t *testing.T) {
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB())
+	ctx := context.Background()
+
+	agent, _ := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1"})
+
+	err := svc.RecordHeartbeat(ctx, domain.AgentHeartbeat{AgentID: agent.ID, UserID: "user-2"})
+	if err != agents.ErrAgentNotFound {
+		t.Errorf("expected ErrAgentNotFound for another user's agent, got %v", err)
+	}
+	if err := svc.Authorize(ctx, "not-a-uuid", "user-1"); err != agents.ErrAgentNotFound {
+		t.Errorf("expected ErrAgentNotFound for a malformed ID, got %v", err)
+	}
+
+	if err := svc.RecordHeartbeat(ctx, domain.AgentHeartbeat{AgentID: agent.ID, UserID: "user-1", CachedVersion: 4}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := svc.Get(ctx, agent.ID)
+	if got.Status != domain.AgentStatusOnline || got.CachedConfigVersion != 4 {
+		t.Errorf("expected online agent at version 4, got %s at %d", got.Status, got.CachedConfigVersion)
+	}
+}
+
+func TestService_RevokeRefusesAgent(t *testing.T) {
+	notifier := &mockNotifier{}
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB()).WithWebSocketNotifier(notifier)
+	ctx := context.Background()
+
+	agent, _ := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1"})
+
+	revoked, err := svc.Revoke(ctx, agent.ID, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked.IsRevoked() || revoked.RevokedBy == nil || *revoked.RevokedBy != "admin-1" {
+		t.Errorf("expected agent revoked by admin-1, got %+v", revoked)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0] != agent.ID+":"+agents.MessageTypeAgentRevoked {
+		t.Errorf("expected revoked agent to be disconnected, got %v", notifier.sent)
+	}
+
+	if err := svc.Authorize(ctx, agent.ID, "user-1"); err != agents.ErrAgentRevoked {
+		t.Errorf("expected ErrAgentRevoked on reconnect, got %v", err)
+	}
+	if _, err := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1"}); err != agents.ErrAgentRevoked {
+		t.Errorf("expected ErrAgentRevoked on re-login, got %v", err)
+	}
+	if _, err := svc.Revoke(ctx, "00000000-0000-0000-0000-000000000000", "admin-1"); !errors.Is(err, agents.ErrAgentNotFound) {
+		t.Errorf("expected ErrAgentNotFound, got %v", err)
+	}
+}
+
+func TestService_RevokeEndsAgentLogins(t *testing.T) {
+	tokens := &mockRefreshTokens{bound: make(map[string]string)}
+	svc := agents.NewService(newMockAgentDB(), newMockProjectDB()).WithRefreshTokens(tokens)
+	ctx := context.Background()
+
+	agent, err := svc.Register(ctx, agents.Registration{MachineID: "machine-1", UserID: "user-1", RefreshToken: "refresh-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.bound["refresh-1"] != agent.ID {
+		t.Errorf("expected the login to be linked to agent %s, got %v", agent.ID, tokens.bound)
+	}
+
+	if _, err := svc.Revoke(ctx, agent.ID, "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens.revoked) != 1 || tokens.revoked[0] != agent.ID {
+		t.Errorf("expected the agent's refresh tokens to be revoked, got %v", tokens.revoked)
+	}
+}
+
+func TestService_MarkInactive(t *testing.T) {
+	db := newMockAgentDB()
+	svc := agents.NewService(db, newMockProjectDB())
+	ctx := context.Background()
+
+	fresh := domain.NewAgent("machine-1", "user-1")
+	stale := domain.NewAgent("machine-2", "user-1")
+	stale.LastHeartbeat = time.Now().Add(-5 * time.Minute)
+	gone := domain.NewAgent("machine-3", "user-1")
+	gone.Status = domain.AgentStatusStale
+	gone.LastHeartbeat = time.Now().Add(-time.Hour)
+	for _, a := range []domain.Agent{fresh, stale, gone} {
+		db.agents[a.ID] = a
+	}
+
+	changed, err := svc.MarkInactive(ctx, 3*time.Minute, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 2 {
+		t.Errorf("expected 2 agents to change status, got %d", changed)
+	}
+	for id, want := range map[string]domain.AgentStatus{
+		fresh.ID: domain.AgentStatusOnline,
+		stale.ID: domain.AgentStatusStale,
+		gone.ID:  domain.AgentStatusOffline,
+	} {
+		if got := db.agents[id].Status; got != want {
+			t.Errorf("agent %s: expected %s, got %s", db.agents[id].MachineID, want, got)
+		}
+	}
+}
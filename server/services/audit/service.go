@@ -68,6 +68,15 @@ func (s *Service) LogApprovalAction(ctx context.Context, ruleID string, action d
 	return s.db.Create(ctx, entry)
 }
 
+// Log records an action on any kind of resource
+func (s *Service) Log(ctx context.Context, action domain.AuditAction, actorID *string, resourceType, resourceID string, metadata map[string]interface{}) error {
+	entry := domain.NewAuditEntry(domain.AuditEntityType(resourceType), resourceID, action, actorID)
+	if metadata != nil {
+		entry.Metadata = metadata
+	}
+	return s.db.Create(ctx, entry)
+}
+
 func (s *Service) List(ctx context.Context, params ListParams) ([]domain.AuditEntry, int, error) {
 	dbParams := postgres.AuditListParams{
 		EntityType: params.EntityType,
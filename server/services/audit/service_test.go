@@ -164,6 +164,28 @@ func TestService_LogApprovalAction(t *testing.T) {
 	}
 }
 
+func TestService_Log(t *testing.T) {
+	svc, db := newTestService()
+	actorID := "approver-1"
+
+	err := svc.Log(context.Background(), domain.AuditActionApproved, &actorID, "change_request", "cr-1", map[string]interface{}{"file_path": "CLAUDE.md"})
+	if err != nil {
+		t.Fatalf("Log() error = %v", err)
+	}
+
+	if len(db.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(db.entries))
+	}
+
+	entry := db.entries[0]
+	if entry.EntityType != "change_request" || entry.EntityID != "cr-1" {
+		t.Errorf("Expected change_request cr-1, got %s %s", entry.EntityType, entry.EntityID)
+	}
+	if entry.Metadata["file_path"] != "CLAUDE.md" {
+		t.Errorf("Expected metadata file_path 'CLAUDE.md', got '%v'", entry.Metadata["file_path"])
+	}
+}
+
 func TestService_List(t *testing.T) {
 	svc, db := newTestService()
 
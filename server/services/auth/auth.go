@@ -12,10 +12,13 @@ import (
 var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrEmailExists        = errors.New("email already registered")
+	ErrUserNotFound       = errors.New("user not found")
 )
 
 type UserDB interface {
 	Create(ctx context.Context, user domain.User) error
+	// GetByID fails with ErrUserNotFound when the user does not exist
+	GetByID(ctx context.Context, id string) (domain.User, error)
 	GetByEmail(ctx context.Context, email string) (domain.User, error)
 	UpdateLastLogin(ctx context.Context, userID string) error
 }
@@ -26,10 +29,12 @@ type RoleDB interface {
 }
 
 type Service struct {
-	userDB      UserDB
-	roleDB      RoleDB
-	jwtSecret   string
-	tokenExpiry time.Duration
+	userDB        UserDB
+	roleDB        RoleDB
+	refreshDB     RefreshTokenDB
+	jwtSecret     string
+	tokenExpiry   time.Duration
+	refreshExpiry time.Duration
 }
 
 func NewService(userDB UserDB, roleDB RoleDB, jwtSecret string, tokenExpiry time.Duration) *Service {
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 
 type mockUserDB struct {
 	users map[string]domain.User
+	// err fails every GetByID when set
+	err error
 }
 
 func (m *mockUserDB) Create(ctx context.Context, user domain.User) error {
@@ -17,6 +20,18 @@ func (m *mockUserDB) Create(ctx context.Context, user domain.User) error {
 	return nil
 }
 
+func (m *mockUserDB) GetByID(ctx context.Context, id string) (domain.User, error) {
+	if m.err != nil {
+		return domain.User{}, m.err
+	}
+	for _, user := range m.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return domain.User{}, ErrUserNotFound
+}
+
 func (m *mockUserDB) GetByEmail(ctx context.Context, email string) (domain.User, error) {
 	if user, ok := m.users[email]; ok {
 		return user, nil
@@ -140,3 +155,270 @@ func TestService_ValidateToken(t *testing.T) {
 		t.Errorf("Expected permissions ['create_rules'], got %v", claims.Permissions)
 	}
 }
+
+type mockRefreshTokenDB struct {
+	tokens map[string]domain.RefreshToken // by hash
+}
+
+func (m *mockRefreshTokenDB) Create(ctx context.Context, token domain.RefreshToken) error {
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *mockRefreshTokenDB) GetByHash(ctx context.Context, tokenHash string) (domain.RefreshToken, error) {
+	if t, ok := m.tokens[tokenHash]; ok {
+		return t, nil
+	}
+	return domain.RefreshToken{}, ErrInvalidRefreshToken
+}
+
+func (m *mockRefreshTokenDB) MarkUsed(ctx context.Context, id string) error {
+	for hash, t := range m.tokens {
+		if t.ID == id {
+			if t.UsedAt != nil || t.RevokedAt != nil {
+				return ErrRefreshTokenReused
+			}
+			now := time.Now()
+			t.UsedAt = &now
+			m.tokens[hash] = t
+			return nil
+		}
+	}
+	return ErrInvalidRefreshToken
+}
+
+func (m *mockRefreshTokenDB) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	for hash, t := range m.tokens {
+		if t.FamilyID == familyID {
+			t.RevokedAt = &now
+			m.tokens[hash] = t
+		}
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenDB) BindAgent(ctx context.Context, familyID, agentID string) error {
+	for hash, t := range m.tokens {
+		if t.FamilyID == familyID {
+			t.AgentID = agentID
+			m.tokens[hash] = t
+		}
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenDB) RevokeAgent(ctx context.Context, agentID string) error {
+	now := time.Now()
+	for hash, t := range m.tokens {
+		if t.AgentID == agentID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			m.tokens[hash] = t
+		}
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenDB) DeleteExpired(ctx context.Context) error {
+	for hash, t := range m.tokens {
+		if t.IsExpired() {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// newRefreshService returns a service knowing the active user user-1
+func newRefreshService() (*Service, *mockUserDB) {
+	teamID := "team-1"
+	users := &mockUserDB{users: map[string]domain.User{
+		"dev@example.com": {ID: "user-1", Email: "dev@example.com", TeamID: &teamID, IsActive: true},
+	}}
+	svc := NewService(users, &mockRoleDB{}, "test-secret", time.Hour).
+		WithRefreshTokens(&mockRefreshTokenDB{tokens: make(map[string]domain.RefreshToken)}, DefaultRefreshTokenExpiry)
+	return svc, users
+}
+
+func TestService_RefreshRotatesToken(t *testing.T) {
+	svc, _ := newRefreshService()
+
+	first, err := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+
+	pair, err := svc.Refresh(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if pair.AccessToken == "" || pair.RefreshToken == "" || pair.RefreshToken == first {
+		t.Errorf("expected new access token and rotated refresh token, got %+v", pair)
+	}
+	if pair.ExpiresIn != 3600 {
+		t.Errorf("expected expires_in 3600, got %d", pair.ExpiresIn)
+	}
+
+	claims, err := svc.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("expected subject user-1, got %s", claims.Subject)
+	}
+
+	if _, err := svc.Refresh(context.Background(), pair.RefreshToken); err != nil {
+		t.Errorf("expected rotated token to be usable, got %v", err)
+	}
+}
+
+func TestService_RefreshReuseRevokesFamily(t *testing.T) {
+	svc, _ := newRefreshService()
+
+	first, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	pair, err := svc.Refresh(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err := svc.Refresh(context.Background(), first); err != ErrRefreshTokenReused {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// The token issued from the reused one must no longer work either
+	if _, err := svc.Refresh(context.Background(), pair.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("expected rotated token revoked, got %v", err)
+	}
+}
+
+func TestService_RefreshUnknownToken(t *testing.T) {
+	svc, _ := newRefreshService()
+
+	if _, err := svc.Refresh(context.Background(), "not-a-token"); err != ErrInvalidRefreshToken {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestService_RefreshClaimsMatchLogin(t *testing.T) {
+	svc, _ := newRefreshService()
+
+	token, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	pair, err := svc.Refresh(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	claims, err := svc.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if claims.Email != "dev@example.com" || claims.TeamID == nil || *claims.TeamID != "team-1" {
+		t.Errorf("expected the login claims, got email %q team %v", claims.Email, claims.TeamID)
+	}
+	if len(claims.Permissions) != 1 || claims.Permissions[0] != "create_rules" {
+		t.Errorf("expected permissions ['create_rules'], got %v", claims.Permissions)
+	}
+}
+
+func TestService_RefreshInactiveUserRevokesFamily(t *testing.T) {
+	svc, users := newRefreshService()
+
+	first, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	pair, err := svc.Refresh(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	user := users.users["dev@example.com"]
+	user.IsActive = false
+	users.users[user.Email] = user
+
+	if _, err := svc.Refresh(context.Background(), pair.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Fatalf("expected ErrInvalidRefreshToken for a deactivated user, got %v", err)
+	}
+
+	// Reactivating the user does not bring the revoked family back
+	user.IsActive = true
+	users.users[user.Email] = user
+	if _, err := svc.Refresh(context.Background(), pair.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("expected the family to stay revoked, got %v", err)
+	}
+
+	delete(users.users, user.Email)
+	deleted, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	if _, err := svc.Refresh(context.Background(), deleted); err != ErrInvalidRefreshToken {
+		t.Errorf("expected ErrInvalidRefreshToken for a deleted user, got %v", err)
+	}
+}
+
+func TestService_RefreshUserLookupFailureKeepsFamily(t *testing.T) {
+	svc, users := newRefreshService()
+
+	first, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	lookupErr := errors.New("connection refused")
+	users.err = lookupErr
+	if _, err := svc.Refresh(context.Background(), first); !errors.Is(err, lookupErr) {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+
+	users.err = nil
+	if _, err := svc.Refresh(context.Background(), first); err != nil {
+		t.Errorf("expected the token to stay usable after a failed lookup, got %v", err)
+	}
+}
+
+func TestService_DeleteExpiredRefreshTokens(t *testing.T) {
+	svc, _ := newRefreshService()
+	db := svc.refreshDB.(*mockRefreshTokenDB)
+
+	expired, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	current, _ := svc.IssueRefreshToken(context.Background(), "user-1", "edictflow-cli")
+	token := db.tokens[domain.HashRefreshToken(expired)]
+	token.ExpiresAt = time.Now().Add(-time.Minute)
+	db.tokens[token.TokenHash] = token
+
+	if err := svc.DeleteExpiredRefreshTokens(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.tokens[domain.HashRefreshToken(expired)]; ok {
+		t.Error("expected the expired token to be deleted")
+	}
+	if _, ok := db.tokens[domain.HashRefreshToken(current)]; !ok {
+		t.Error("expected the current token to be kept")
+	}
+}
+
+func TestService_RevokeAgentRefreshTokens(t *testing.T) {
+	svc, _ := newRefreshService()
+	ctx := context.Background()
+
+	agentLogin, _ := svc.IssueRefreshToken(ctx, "user-1", "edictflow-cli")
+	otherLogin, _ := svc.IssueRefreshToken(ctx, "user-1", "edictflow-cli")
+	if err := svc.BindRefreshToken(ctx, agentLogin, "user-1", "agent-1"); err != nil {
+		t.Fatalf("BindRefreshToken() error = %v", err)
+	}
+	// Rotated tokens stay linked to the agent
+	pair, err := svc.Refresh(ctx, agentLogin)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if err := svc.RevokeAgentRefreshTokens(ctx, "agent-1"); err != nil {
+		t.Fatalf("RevokeAgentRefreshTokens() error = %v", err)
+	}
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the agent's rotated token to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, otherLogin); err != nil {
+		t.Errorf("expected the user's other login to be kept, got %v", err)
+	}
+}
+
+func TestService_BindRefreshTokenOfAnotherUser(t *testing.T) {
+	svc, _ := newRefreshService()
+	ctx := context.Background()
+
+	token, _ := svc.IssueRefreshToken(ctx, "user-1", "edictflow-cli")
+	if err := svc.BindRefreshToken(ctx, token, "user-2", "agent-2"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
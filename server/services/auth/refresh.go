@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrRefreshDisabled     = errors.New("refresh tokens not configured")
+)
+
+// DefaultRefreshTokenExpiry is how long an unused refresh token stays valid
+const DefaultRefreshTokenExpiry = 30 * 24 * time.Hour
+
+type RefreshTokenDB interface {
+	Create(ctx context.Context, token domain.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (domain.RefreshToken, error)
+	MarkUsed(ctx context.Context, id string) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	// BindAgent links the tokens of a family to the agent that logged in
+	BindAgent(ctx context.Context, familyID, agentID string) error
+	// RevokeAgent revokes the tokens linked to the agent
+	RevokeAgent(ctx context.Context, agentID string) error
+	// DeleteExpired removes the tokens that expired
+	DeleteExpired(ctx context.Context) error
+}
+
+// TokenPair is the result of a refresh grant
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// WithRefreshTokens enables refresh token issuance and rotation
+func (s *Service) WithRefreshTokens(db RefreshTokenDB, expiry time.Duration) *Service {
+	s.refreshDB = db
+	s.refreshExpiry = expiry
+	return s
+}
+
+// AccessTokenExpiry returns the lifetime of issued access tokens
+func (s *Service) AccessTokenExpiry() time.Duration {
+	return s.tokenExpiry
+}
+
+// IssueRefreshToken starts a new refresh token family for a user
+func (s *Service) IssueRefreshToken(ctx context.Context, userID, clientID string) (string, error) {
+	if s.refreshDB == nil {
+		return "", ErrRefreshDisabled
+	}
+	return s.createRefreshToken(ctx, userID, "", clientID, "")
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated
+// refresh token. Presenting a token that was already exchanged is treated as
+// theft: the whole family is revoked and the holder must log in again. The
+// family is also revoked once its user is deactivated or deleted.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	if s.refreshDB == nil {
+		return TokenPair{}, ErrRefreshDisabled
+	}
+
+	stored, err := s.refreshDB.GetByHash(ctx, domain.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			return TokenPair{}, ErrInvalidRefreshToken
+		}
+		return TokenPair{}, err
+	}
+
+	if stored.IsRevoked() || stored.IsExpired() {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if stored.IsUsed() {
+		return TokenPair{}, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+
+	user, err := s.userDB.GetByID(ctx, stored.UserID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return TokenPair{}, err
+	}
+	if err != nil || !user.IsActive {
+		if err := s.refreshDB.RevokeFamily(ctx, stored.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	if err := s.refreshDB.MarkUsed(ctx, stored.ID); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			return TokenPair{}, s.revokeReusedFamily(ctx, stored.FamilyID)
+		}
+		return TokenPair{}, err
+	}
+
+	accessToken, err := s.generateToken(ctx, user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	rotated, err := s.createRefreshToken(ctx, stored.UserID, stored.FamilyID, stored.ClientID, stored.AgentID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rotated,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokenExpiry.Seconds()),
+	}, nil
+}
+
+// BindRefreshToken links the login the refresh token belongs to with the
+// agent the user registered, so revoking the agent ends the login
+func (s *Service) BindRefreshToken(ctx context.Context, refreshToken, userID, agentID string) error {
+	if s.refreshDB == nil {
+		return nil
+	}
+	stored, err := s.refreshDB.GetByHash(ctx, domain.HashRefreshToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if stored.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+	return s.refreshDB.BindAgent(ctx, stored.FamilyID, agentID)
+}
+
+// RevokeAgentRefreshTokens revokes the refresh tokens of the logins linked to
+// the agent
+func (s *Service) RevokeAgentRefreshTokens(ctx context.Context, agentID string) error {
+	if s.refreshDB == nil {
+		return nil
+	}
+	return s.refreshDB.RevokeAgent(ctx, agentID)
+}
+
+// DeleteExpiredRefreshTokens removes the refresh tokens that expired
+func (s *Service) DeleteExpiredRefreshTokens(ctx context.Context) error {
+	if s.refreshDB == nil {
+		return nil
+	}
+	return s.refreshDB.DeleteExpired(ctx)
+}
+
+func (s *Service) createRefreshToken(ctx context.Context, userID, familyID, clientID, agentID string) (string, error) {
+	token, plaintext, err := domain.NewRefreshToken(userID, familyID, clientID, s.refreshExpiry)
+	if err != nil {
+		return "", err
+	}
+	token.AgentID = agentID
+	if err := s.refreshDB.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func (s *Service) revokeReusedFamily(ctx context.Context, familyID string) error {
+	if err := s.refreshDB.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
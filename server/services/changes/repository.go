@@ -27,10 +27,12 @@ type ChangeRequestFilter struct {
 }
 
 type RuleRepository interface {
+	// GetRule fails with rules.ErrRuleNotFound when the rule does not exist
 	GetRule(ctx context.Context, id string) (domain.Rule, error)
 }
 
 type AgentRepository interface {
+	// GetByID returns nil when the agent does not exist
 	GetByID(ctx context.Context, id string) (*domain.Agent, error)
 }
 
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
 )
 
 var (
@@ -14,6 +16,7 @@ var (
 	ErrChangeNotPending      = errors.New("change request is not pending")
 	ErrRuleNotFound          = errors.New("rule not found")
 	ErrAgentNotFound         = errors.New("agent not found")
+	ErrNotChangeOwner        = errors.New("change request belongs to another agent")
 )
 
 type Service struct {
@@ -56,28 +59,42 @@ func (s *Service) WithWebSocketNotifier(wsNotifier WebSocketNotifier) *Service {
 	return s
 }
 
+// AgentChangePayload is a change an agent detected in the part of a managed
+// file rendered from RuleID. The change is enforced with the rule's mode.
 type AgentChangePayload struct {
-	RuleID          string
-	AgentID         string
-	UserID          string
-	TeamID          string
-	FilePath        string
-	OriginalHash    string
-	ModifiedHash    string
-	Diff            string
-	EnforcementMode domain.EnforcementMode
+	RuleID       string
+	AgentID      string
+	UserID       string
+	TeamID       string
+	FilePath     string
+	OriginalHash string
+	ModifiedHash string
+	Diff         string
 }
 
 func (s *Service) CreateFromAgent(ctx context.Context, payload AgentChangePayload) (*domain.ChangeRequest, error) {
-	// Validate rule exists
+	// Validate rule exists. IDs come from the agent, and lookup failures
+	// other than a missing row are worth retrying.
+	if _, err := uuid.Parse(payload.RuleID); err != nil {
+		return nil, ErrRuleNotFound
+	}
 	rule, err := s.ruleRepo.GetRule(ctx, payload.RuleID)
-	if err != nil {
+	if errors.Is(err, rules.ErrRuleNotFound) {
 		return nil, ErrRuleNotFound
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule %s: %w", payload.RuleID, err)
+	}
 
 	// Validate agent exists
+	if _, err := uuid.Parse(payload.AgentID); err != nil {
+		return nil, ErrAgentNotFound
+	}
 	agent, err := s.agentRepo.GetByID(ctx, payload.AgentID)
-	if err != nil || agent == nil {
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agent %s: %w", payload.AgentID, err)
+	}
+	if agent == nil {
 		return nil, ErrAgentNotFound
 	}
 
@@ -97,7 +114,7 @@ func (s *Service) CreateFromAgent(ctx context.Context, payload AgentChangePayloa
 
 	// Calculate timeout for temporary mode
 	var timeoutAt *time.Time
-	if payload.EnforcementMode == domain.EnforcementModeTemporary {
+	if rule.EnforcementMode == domain.EnforcementModeTemporary {
 		t := time.Now().Add(time.Duration(rule.TemporaryTimeoutHours) * time.Hour)
 		timeoutAt = &t
 	}
@@ -111,7 +128,7 @@ func (s *Service) CreateFromAgent(ctx context.Context, payload AgentChangePayloa
 		payload.OriginalHash,
 		payload.ModifiedHash,
 		payload.Diff,
-		payload.EnforcementMode,
+		rule.EnforcementMode,
 		timeoutAt,
 	)
 
@@ -124,7 +141,7 @@ func (s *Service) CreateFromAgent(ctx context.Context, payload AgentChangePayloa
 		_ = s.auditLog.Log(ctx, domain.AuditActionCreated, &payload.UserID, "change_request", cr.ID, map[string]interface{}{
 			"rule_id":          payload.RuleID,
 			"file_path":        payload.FilePath,
-			"enforcement_mode": string(payload.EnforcementMode),
+			"enforcement_mode": string(rule.EnforcementMode),
 		})
 	}
 
@@ -177,6 +194,7 @@ func (s *Service) Approve(ctx context.Context, id, approverUserID string) error
 		_ = s.wsNotifier.BroadcastToAgent(cr.AgentID, "change_approved", map[string]interface{}{
 			"change_id": cr.ID,
 			"rule_id":   cr.RuleID,
+			"file_path": cr.FilePath,
 		})
 	}
 
@@ -228,7 +246,9 @@ func (s *Service) Reject(ctx context.Context, id, approverUserID string) error {
 		_ = s.wsNotifier.BroadcastToAgent(cr.AgentID, "change_rejected", map[string]interface{}{
 			"change_id":      cr.ID,
 			"rule_id":        cr.RuleID,
+			"file_path":      cr.FilePath,
 			"revert_to_hash": cr.OriginalHash,
+			"reason":         "rejected",
 		})
 	}
 
@@ -269,7 +289,9 @@ func (s *Service) HandleExpiredTemporary(ctx context.Context) ([]domain.ChangeRe
 			_ = s.wsNotifier.BroadcastToAgent(cr.AgentID, "change_rejected", map[string]interface{}{
 				"change_id":      cr.ID,
 				"rule_id":        cr.RuleID,
+				"file_path":      cr.FilePath,
 				"revert_to_hash": cr.OriginalHash,
+				"reason":         "expired",
 			})
 		}
 
@@ -292,7 +314,9 @@ func (s *Service) HandleExpiredTemporary(ctx context.Context) ([]domain.ChangeRe
 	return processed, nil
 }
 
-func (s *Service) UpdateFromAgent(ctx context.Context, id, newHash, newDiff string) error {
+// UpdateFromAgent replaces the diff of a pending change request with a newer
+// one from the agent and user that reported the change
+func (s *Service) UpdateFromAgent(ctx context.Context, id, agentID, userID, newHash, newDiff string) error {
 	cr, err := s.changeRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
@@ -300,6 +324,9 @@ func (s *Service) UpdateFromAgent(ctx context.Context, id, newHash, newDiff stri
 	if cr == nil {
 		return ErrChangeRequestNotFound
 	}
+	if cr.AgentID != agentID || cr.UserID != userID {
+		return ErrNotChangeOwner
+	}
 
 	if !cr.IsPending() {
 		return ErrChangeNotPending
@@ -23,11 +23,19 @@ type TokenGenerator interface {
 	GenerateToken(userID string) (string, error)
 }
 
+// RefreshTokenIssuer issues a refresh token alongside the access token so
+// devices can stay signed in after the access token expires
+type RefreshTokenIssuer interface {
+	IssueRefreshToken(ctx context.Context, userID, clientID string) (string, error)
+}
+
 type Service struct {
-	repo         Repository
-	tokenGen     TokenGenerator
-	expiresIn    time.Duration
-	pollInterval time.Duration
+	repo          Repository
+	tokenGen      TokenGenerator
+	refreshIssuer RefreshTokenIssuer
+	expiresIn     time.Duration
+	pollInterval  time.Duration
+	tokenExpiry   time.Duration
 }
 
 func NewService(repo Repository, tokenGen TokenGenerator) *Service {
@@ -36,9 +44,16 @@ func NewService(repo Repository, tokenGen TokenGenerator) *Service {
 		tokenGen:     tokenGen,
 		expiresIn:    15 * time.Minute,
 		pollInterval: 5 * time.Second,
+		tokenExpiry:  24 * time.Hour,
 	}
 }
 
+// WithRefreshTokens includes a refresh token in successful token responses
+func (s *Service) WithRefreshTokens(issuer RefreshTokenIssuer) *Service {
+	s.refreshIssuer = issuer
+	return s
+}
+
 type DeviceAuthResponse struct {
 	DeviceCode      string `json:"device_code"`
 	UserCode        string `json:"user_code"`
@@ -48,9 +63,10 @@ type DeviceAuthResponse struct {
 }
 
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 func (s *Service) InitiateDeviceAuth(ctx context.Context, clientID, baseURL string) (DeviceAuthResponse, error) {
@@ -98,12 +114,21 @@ func (s *Service) PollForToken(ctx context.Context, deviceCode string) (TokenRes
 		return TokenResponse{}, err
 	}
 
+	var refreshToken string
+	if s.refreshIssuer != nil {
+		refreshToken, err = s.refreshIssuer.IssueRefreshToken(ctx, *dc.UserID, dc.ClientID)
+		if err != nil {
+			return TokenResponse{}, err
+		}
+	}
+
 	_ = s.repo.Delete(ctx, deviceCode)
 
 	return TokenResponse{
-		AccessToken: token,
-		TokenType:   "Bearer",
-		ExpiresIn:   86400,
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokenExpiry.Seconds()),
 	}, nil
 }
 
@@ -0,0 +1,62 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+var ErrAgentDriftNotFound = errors.New("no drift report for agent")
+
+type DB interface {
+	ReplaceAgentDrift(ctx context.Context, report domain.AgentDrift) error
+	GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error)
+	ListTeamDrift(ctx context.Context, teamID string) ([]domain.AgentDrift, error)
+}
+
+// TeamDB provides the team settings that define when a report is outdated
+type TeamDB interface {
+	GetTeam(ctx context.Context, id string) (domain.Team, error)
+}
+
+type Service struct {
+	db     DB
+	teamDB TeamDB
+}
+
+func NewService(db DB, teamDB TeamDB) *Service {
+	return &Service{db: db, teamDB: teamDB}
+}
+
+// Record stores a report as the latest drift state of its agent
+func (s *Service) Record(ctx context.Context, report domain.AgentDrift) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	if report.ReportedAt.IsZero() {
+		report.ReportedAt = time.Now()
+	}
+	return s.db.ReplaceAgentDrift(ctx, report)
+}
+
+func (s *Service) GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error) {
+	return s.db.GetAgentDrift(ctx, agentID)
+}
+
+// TeamSummary aggregates the latest reports of a team's agents, flagging
+// agents that have not reported within the team's drift threshold
+func (s *Service) TeamSummary(ctx context.Context, teamID string) (domain.TeamDriftSummary, error) {
+	team, err := s.teamDB.GetTeam(ctx, teamID)
+	if err != nil {
+		return domain.TeamDriftSummary{}, err
+	}
+
+	reports, err := s.db.ListTeamDrift(ctx, teamID)
+	if err != nil {
+		return domain.TeamDriftSummary{}, err
+	}
+
+	return domain.SummarizeTeamDrift(teamID, team.Settings.DriftThresholdMinutes, reports, time.Now()), nil
+}
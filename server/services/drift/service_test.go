@@ -0,0 +1,143 @@
+package drift_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/drift"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type mockDriftDB struct {
+	reports map[string]domain.AgentDrift
+}
+
+func newMockDriftDB() *mockDriftDB {
+	return &mockDriftDB{reports: make(map[string]domain.AgentDrift)}
+}
+
+func (m *mockDriftDB) ReplaceAgentDrift(ctx context.Context, report domain.AgentDrift) error {
+	m.reports[report.AgentID] = report
+	return nil
+}
+
+func (m *mockDriftDB) GetAgentDrift(ctx context.Context, agentID string) (domain.AgentDrift, error) {
+	if report, ok := m.reports[agentID]; ok {
+		return report, nil
+	}
+	return domain.AgentDrift{}, drift.ErrAgentDriftNotFound
+}
+
+func (m *mockDriftDB) ListTeamDrift(ctx context.Context, teamID string) ([]domain.AgentDrift, error) {
+	var result []domain.AgentDrift
+	for _, report := range m.reports {
+		if report.TeamID == teamID {
+			result = append(result, report)
+		}
+	}
+	return result, nil
+}
+
+type mockTeamDB struct {
+	teams map[string]domain.Team
+}
+
+func (m *mockTeamDB) GetTeam(ctx context.Context, id string) (domain.Team, error) {
+	if team, ok := m.teams[id]; ok {
+		return team, nil
+	}
+	return domain.Team{}, teams.ErrTeamNotFound
+}
+
+func newTestService() *drift.Service {
+	db := newMockDriftDB()
+	teamDB := &mockTeamDB{teams: map[string]domain.Team{
+		"team-1": {ID: "team-1", Settings: domain.TeamSettings{DriftThresholdMinutes: 60}},
+	}}
+	return drift.NewService(db, teamDB)
+}
+
+func report(agentID string, reportedAt time.Time, statuses ...domain.DriftStatus) domain.AgentDrift {
+	r := domain.AgentDrift{
+		AgentID:    agentID,
+		UserID:     "user-" + agentID,
+		TeamID:     "team-1",
+		ReportedAt: reportedAt,
+	}
+	for i, s := range statuses {
+		r.Files = append(r.Files, domain.FileDrift{
+			Path:   "/file-" + string(rune('a'+i)),
+			Level:  "project",
+			Status: s,
+		})
+	}
+	return r
+}
+
+func TestService_RecordReplacesPreviousReport(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	if err := svc.Record(ctx, report("agent-1", time.Time{}, domain.DriftStatusTampered)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Record(ctx, report("agent-1", time.Time{}, domain.DriftStatusOK, domain.DriftStatusOK)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.GetAgentDrift(ctx, "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 2 || got.DriftedFiles() != 0 {
+		t.Errorf("expected latest report with 2 clean files, got %+v", got.Files)
+	}
+	if got.ReportedAt.IsZero() {
+		t.Error("expected report time to be set")
+	}
+}
+
+func TestService_RecordRejectsInvalidStatus(t *testing.T) {
+	svc := newTestService()
+
+	err := svc.Record(context.Background(), report("agent-1", time.Now(), domain.DriftStatus("bogus")))
+	if err == nil {
+		t.Error("expected error for unknown drift status")
+	}
+}
+
+func TestService_TeamSummary(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	now := time.Now()
+
+	_ = svc.Record(ctx, report("clean", now, domain.DriftStatusOK, domain.DriftStatusOK))
+	_ = svc.Record(ctx, report("drifted", now, domain.DriftStatusOK, domain.DriftStatusTampered))
+	_ = svc.Record(ctx, report("silent", now.Add(-2*time.Hour), domain.DriftStatusOK))
+
+	summary, err := svc.TeamSummary(ctx, "team-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Agents != 3 {
+		t.Errorf("expected 3 agents, got %d", summary.Agents)
+	}
+	if summary.CompliantAgents != 1 || summary.DriftedAgents != 1 || summary.OutdatedAgents != 1 {
+		t.Errorf("expected 1 compliant, 1 drifted, 1 outdated; got %d/%d/%d",
+			summary.CompliantAgents, summary.DriftedAgents, summary.OutdatedAgents)
+	}
+	if summary.StatusCounts[domain.DriftStatusTampered] != 1 || summary.StatusCounts[domain.DriftStatusOK] != 4 {
+		t.Errorf("unexpected status counts: %v", summary.StatusCounts)
+	}
+}
+
+func TestService_TeamSummaryUnknownTeam(t *testing.T) {
+	svc := newTestService()
+
+	if _, err := svc.TeamSummary(context.Background(), "missing"); err != teams.ErrTeamNotFound {
+		t.Errorf("expected ErrTeamNotFound, got %v", err)
+	}
+}
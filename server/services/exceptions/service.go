@@ -14,6 +14,7 @@ var (
 	ErrExceptionNotPending      = errors.New("exception request is not pending")
 	ErrChangeRequestNotFound    = errors.New("change request not found")
 	ErrChangeNotRejected        = errors.New("change request must be rejected to create exception")
+	ErrNotChangeOwner           = errors.New("change request belongs to another agent")
 )
 
 type Service struct {
@@ -52,6 +53,9 @@ func (s *Service) WithWebSocketNotifier(wsNotifier WebSocketNotifier) *Service {
 type CreateExceptionRequest struct {
 	ChangeRequestID       string
 	UserID                string
+	// AgentID is set when an agent asks for the exception. The change request
+	// must then have been reported by that agent and user.
+	AgentID               string
 	Justification         string
 	ExceptionType         domain.ExceptionType
 	RequestedDurationHours *int
@@ -66,6 +70,9 @@ func (s *Service) Create(ctx context.Context, req CreateExceptionRequest) (*doma
 	if cr == nil {
 		return nil, ErrChangeRequestNotFound
 	}
+	if req.AgentID != "" && (cr.AgentID != req.AgentID || cr.UserID != req.UserID) {
+		return nil, ErrNotChangeOwner
+	}
 
 	if cr.Status != domain.ChangeRequestStatusRejected && cr.Status != domain.ChangeRequestStatusAutoReverted {
 		return nil, ErrChangeNotRejected
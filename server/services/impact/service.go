@@ -0,0 +1,342 @@
+package impact
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/kamilrybacki/edictflow/pkg/diff"
+	"github.com/kamilrybacki/edictflow/pkg/triggers"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+	"github.com/kamilrybacki/edictflow/server/services/preview"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+// SampleSize is how many of the affected projects get a rendered diff
+const SampleSize = 5
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+type RuleDB interface {
+	GetRule(ctx context.Context, id string) (domain.Rule, error)
+}
+
+type TeamDB interface {
+	ListTeams(ctx context.Context) ([]domain.Team, error)
+}
+
+type AttachmentDB interface {
+	ListByRule(ctx context.Context, ruleID string) ([]domain.RuleAttachment, error)
+}
+
+type UserDB interface {
+	List(ctx context.Context, teamID *string, activeOnly bool) ([]domain.User, error)
+	GetByIDs(ctx context.Context, ids []string) (map[string]domain.User, error)
+}
+
+type AgentDB interface {
+	List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error)
+}
+
+type ProjectDB interface {
+	ListByTeam(ctx context.Context, teamID string) ([]domain.AgentProject, error)
+}
+
+// Resolver resolves a team's rule set as it is delivered now and as it will
+// be once a rule is approved
+type Resolver interface {
+	Explain(ctx context.Context, teamID string) (rulesets.RuleSet, []rulesets.Decision, error)
+	ExplainApproved(ctx context.Context, teamID, ruleID string) (rulesets.RuleSet, []rulesets.Decision, error)
+}
+
+// Team is a team the rule reaches, with how it reaches it
+type Team struct {
+	ID     string
+	Name   string
+	Reason string
+}
+
+// Sample is the rendered change to the managed files of one affected project
+type Sample struct {
+	TeamID      string
+	AgentID     string
+	ProjectPath string
+	Target      string
+	Files       []FileDiff
+}
+
+// FileDiff is a unified diff of a managed section before and after approval
+type FileDiff struct {
+	Level string
+	Path  string
+	Diff  string
+}
+
+// Report is who and what a rule affects once approved
+type Report struct {
+	RuleID   string
+	Teams    []Team
+	Users    []domain.User
+	Agents   []domain.Agent
+	Projects []domain.AgentProject
+	// ConnectedAgents counts the agents that are online
+	ConnectedAgents int
+	Samples         []Sample
+}
+
+type Service struct {
+	ruleDB       RuleDB
+	teamDB       TeamDB
+	attachmentDB AttachmentDB
+	userDB       UserDB
+	agentDB      AgentDB
+	projectDB    ProjectDB
+	resolver     Resolver
+}
+
+func NewService(ruleDB RuleDB, teamDB TeamDB, attachmentDB AttachmentDB, userDB UserDB, agentDB AgentDB, projectDB ProjectDB, resolver Resolver) *Service {
+	return &Service{
+		ruleDB:       ruleDB,
+		teamDB:       teamDB,
+		attachmentDB: attachmentDB,
+		userDB:       userDB,
+		agentDB:      agentDB,
+		projectDB:    projectDB,
+		resolver:     resolver,
+	}
+}
+
+// Analyze reports the teams, users, agents and projects the rule reaches
+// once approved in its current state, and diffs the managed files of a
+// sample of the projects against what their agents have now
+func (s *Service) Analyze(ctx context.Context, ruleID string) (Report, error) {
+	rule, err := s.ruleDB.GetRule(ctx, ruleID)
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		return Report{}, ErrRuleNotFound
+	}
+	if err != nil {
+		return Report{}, err
+	}
+
+	teams, err := s.affectedTeams(ctx, rule)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{RuleID: ruleID, Teams: teams, Users: []domain.User{}, Agents: []domain.Agent{}, Projects: []domain.AgentProject{}}
+
+	seenUsers := make(map[string]bool)
+	seenAgents := make(map[string]bool)
+	for _, team := range teams {
+		teamID := team.ID
+		users, err := s.userDB.List(ctx, &teamID, true)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, u := range users {
+			if !seenUsers[u.ID] {
+				seenUsers[u.ID] = true
+				report.Users = append(report.Users, u)
+			}
+		}
+
+		teamAgents, err := s.agentDB.List(ctx, agents.Filter{TeamID: teamID})
+		if err != nil {
+			return Report{}, err
+		}
+		report.addAgents(teamAgents, seenAgents)
+
+		projects, err := s.projectDB.ListByTeam(ctx, teamID)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, p := range projects {
+			if appliesToProject(rule, p) {
+				report.Projects = append(report.Projects, p)
+			}
+		}
+	}
+
+	// Targeted users are affected wherever their team is
+	var targeted []string
+	for _, id := range rule.TargetUsers {
+		if !seenUsers[id] {
+			targeted = append(targeted, id)
+		}
+	}
+	if len(targeted) > 0 {
+		users, err := s.userDB.GetByIDs(ctx, targeted)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, id := range targeted {
+			u, ok := users[id]
+			if !ok {
+				continue
+			}
+			report.Users = append(report.Users, u)
+			userAgents, err := s.agentDB.List(ctx, agents.Filter{UserID: id})
+			if err != nil {
+				return Report{}, err
+			}
+			report.addAgents(userAgents, seenAgents)
+		}
+	}
+
+	report.Samples, err = s.samples(ctx, ruleID, report.Projects)
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// addAgents adds the agents that are not revoked and not yet in the report
+func (r *Report) addAgents(list []domain.Agent, seen map[string]bool) {
+	for _, a := range list {
+		if a.IsRevoked() || seen[a.ID] {
+			continue
+		}
+		seen[a.ID] = true
+		r.Agents = append(r.Agents, a)
+		if a.Status == domain.AgentStatusOnline {
+			r.ConnectedAgents++
+		}
+	}
+}
+
+// affectedTeams returns the teams whose rule set includes the rule once it
+// is approved: every team for a forced organization rule, the teams that
+// inherit organization rules otherwise, the rule's own team, the teams it is
+// attached to and the teams it targets
+func (s *Service) affectedTeams(ctx context.Context, rule domain.Rule) ([]Team, error) {
+	all, err := s.teamDB.ListTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.attachmentDB.ListByRule(ctx, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+	attached := make(map[string]domain.AttachmentStatus)
+	for _, a := range attachments {
+		if a.Status != domain.AttachmentStatusRejected {
+			attached[a.TeamID] = a.Status
+		}
+	}
+	targeted := make(map[string]bool)
+	for _, id := range rule.TargetTeams {
+		targeted[id] = true
+	}
+
+	var teams []Team
+	for _, t := range all {
+		reason := ""
+		switch {
+		case rule.TeamID != nil && *rule.TeamID == t.ID:
+			reason = "team rule"
+		case rule.IsEnterprise() && rule.Force:
+			reason = "organization rule forced on every team"
+		case rule.IsEnterprise() && t.Settings.InheritGlobalRules:
+			reason = "team inherits organization rules"
+		case attached[t.ID] == domain.AttachmentStatusApproved:
+			reason = "attached to the team"
+		case attached[t.ID] == domain.AttachmentStatusPending:
+			reason = "attachment to the team is pending"
+		case targeted[t.ID]:
+			reason = "listed in the rule's target teams"
+		default:
+			continue
+		}
+		teams = append(teams, Team{ID: t.ID, Name: t.Name, Reason: reason})
+	}
+	return teams, nil
+}
+
+// appliesToProject reports whether the rule reaches the project's files.
+// Project rules with triggers only reach the projects they match. Glob and
+// file_exists triggers depend on files only the agent sees, so projects are
+// kept when the rule has any.
+func appliesToProject(rule domain.Rule, p domain.AgentProject) bool {
+	if rule.TargetLayer.AgentLayer() != "project" || len(rule.Triggers) == 0 || triggers.NeedsFiles(rule.Triggers) {
+		return true
+	}
+	return triggers.MatchesAny(rule.Triggers, triggers.Context{
+		ProjectPath:      p.ProjectPath,
+		DetectedContexts: p.DetectedContexts,
+		Tags:             p.DetectedTags,
+		Repository:       p.Repository,
+		Branch:           p.Branch,
+	})
+}
+
+// samples renders the managed files of the first projects, ordered by team,
+// agent and path, with the rule set agents have now and after approval
+func (s *Service) samples(ctx context.Context, ruleID string, projects []domain.AgentProject) ([]Sample, error) {
+	sorted := append([]domain.AgentProject(nil), projects...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].TeamID != sorted[j].TeamID {
+			return sorted[i].TeamID < sorted[j].TeamID
+		}
+		if sorted[i].AgentID != sorted[j].AgentID {
+			return sorted[i].AgentID < sorted[j].AgentID
+		}
+		return sorted[i].ProjectPath < sorted[j].ProjectPath
+	})
+	if len(sorted) > SampleSize {
+		sorted = sorted[:SampleSize]
+	}
+
+	type resolved struct {
+		set       rulesets.RuleSet
+		decisions []rulesets.Decision
+	}
+	before := make(map[string]resolved)
+	after := make(map[string]resolved)
+	samples := []Sample{}
+	for _, p := range sorted {
+		if _, ok := before[p.TeamID]; !ok {
+			set, decisions, err := s.resolver.Explain(ctx, p.TeamID)
+			if err != nil {
+				return nil, err
+			}
+			before[p.TeamID] = resolved{set, decisions}
+			set, decisions, err = s.resolver.ExplainApproved(ctx, p.TeamID, ruleID)
+			if err != nil {
+				return nil, err
+			}
+			after[p.TeamID] = resolved{set, decisions}
+		}
+
+		req := preview.Request{
+			TeamID:      p.TeamID,
+			ProjectPath: p.ProjectPath,
+			Contexts:    p.DetectedContexts,
+			Tags:        p.DetectedTags,
+			Repository:  p.Repository,
+			Branch:      p.Branch,
+		}
+		old, err := preview.RenderRuleSet(before[p.TeamID].set, before[p.TeamID].decisions, req)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := preview.RenderRuleSet(after[p.TeamID].set, after[p.TeamID].decisions, req)
+		if err != nil {
+			return nil, err
+		}
+
+		sample := Sample{TeamID: p.TeamID, AgentID: p.AgentID, ProjectPath: p.ProjectPath, Target: updated.Target, Files: []FileDiff{}}
+		for i, f := range updated.Files {
+			if f.Section == old.Files[i].Section {
+				continue
+			}
+			sample.Files = append(sample.Files, FileDiff{
+				Level: f.Level,
+				Path:  f.Path,
+				Diff:  diff.Unified(f.Path+" (before)", f.Path+" (after)", old.Files[i].Section, f.Section),
+			})
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
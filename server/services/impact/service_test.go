@@ -0,0 +1,286 @@
+package impact_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+	"github.com/kamilrybacki/edictflow/server/services/impact"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+type mockRuleDB struct {
+	rules map[string]domain.Rule
+	err   error
+}
+
+func (m *mockRuleDB) GetRule(ctx context.Context, id string) (domain.Rule, error) {
+	if m.err != nil {
+		return domain.Rule{}, m.err
+	}
+	if r, ok := m.rules[id]; ok {
+		return r, nil
+	}
+	return domain.Rule{}, rules.ErrRuleNotFound
+}
+
+type mockTeamDB struct {
+	teams []domain.Team
+}
+
+func (m *mockTeamDB) ListTeams(ctx context.Context) ([]domain.Team, error) {
+	return m.teams, nil
+}
+
+type mockAttachmentDB struct {
+	attachments []domain.RuleAttachment
+}
+
+func (m *mockAttachmentDB) ListByRule(ctx context.Context, ruleID string) ([]domain.RuleAttachment, error) {
+	var result []domain.RuleAttachment
+	for _, a := range m.attachments {
+		if a.RuleID == ruleID {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+type mockUserDB struct {
+	users []domain.User
+}
+
+func (m *mockUserDB) List(ctx context.Context, teamID *string, activeOnly bool) ([]domain.User, error) {
+	var result []domain.User
+	for _, u := range m.users {
+		if u.TeamID != nil && *u.TeamID == *teamID && (!activeOnly || u.IsActive) {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockUserDB) GetByIDs(ctx context.Context, ids []string) (map[string]domain.User, error) {
+	result := make(map[string]domain.User)
+	for _, u := range m.users {
+		for _, id := range ids {
+			if u.ID == id {
+				result[id] = u
+			}
+		}
+	}
+	return result, nil
+}
+
+type mockAgentDB struct {
+	agents []domain.Agent
+}
+
+func (m *mockAgentDB) List(ctx context.Context, filter agents.Filter) ([]domain.Agent, error) {
+	var result []domain.Agent
+	for _, a := range m.agents {
+		if (filter.TeamID == "" || a.TeamID == filter.TeamID) && (filter.UserID == "" || a.UserID == filter.UserID) {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+type mockProjectDB struct {
+	projects []domain.AgentProject
+}
+
+func (m *mockProjectDB) ListByTeam(ctx context.Context, teamID string) ([]domain.AgentProject, error) {
+	var result []domain.AgentProject
+	for _, p := range m.projects {
+		if p.TeamID == teamID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+type mockResolver struct {
+	current []domain.Rule
+	pending domain.Rule
+}
+
+func (m *mockResolver) Explain(ctx context.Context, teamID string) (rulesets.RuleSet, []rulesets.Decision, error) {
+	return m.set(teamID, m.current)
+}
+
+func (m *mockResolver) ExplainApproved(ctx context.Context, teamID, ruleID string) (rulesets.RuleSet, []rulesets.Decision, error) {
+	return m.set(teamID, append(append([]domain.Rule(nil), m.current...), m.pending))
+}
+
+func (m *mockResolver) set(teamID string, included []domain.Rule) (rulesets.RuleSet, []rulesets.Decision, error) {
+	var decisions []rulesets.Decision
+	for _, r := range included {
+		decisions = append(decisions, rulesets.Decision{Rule: r, Included: true, Reason: "team rule"})
+	}
+	return rulesets.RuleSet{TeamID: teamID, Rules: included}, decisions, nil
+}
+
+func TestService_Analyze(t *testing.T) {
+	team1 := domain.Team{ID: "team-1", Name: "Backend"}
+	team2 := domain.Team{ID: "team-2", Name: "Frontend"}
+	team3 := domain.Team{ID: "team-3", Name: "Data"}
+	team4 := domain.Team{ID: "team-4", Name: "Ops"}
+
+	rule := domain.NewRule("API Style", domain.TargetLayerProject, "Version every endpoint", []domain.Trigger{
+		{Type: domain.TriggerTypePath, Pattern: "**/api/**"},
+	}, "team-1")
+	rule.TargetUsers = []string{"user-4"}
+	existing := domain.NewRule("Tests", domain.TargetLayerProject, "Write tests", nil, "team-1")
+
+	attached := domain.NewRuleAttachment(rule.ID, "team-2", domain.EnforcementModeBlock, "admin")
+	rejected := domain.NewRuleAttachment(rule.ID, "team-3", domain.EnforcementModeBlock, "admin")
+	rejected.Status = domain.AttachmentStatusRejected
+
+	t1, t2, t4 := "team-1", "team-2", "team-4"
+	users := &mockUserDB{users: []domain.User{
+		{ID: "user-1", TeamID: &t1, IsActive: true},
+		{ID: "user-2", TeamID: &t2, IsActive: true},
+		{ID: "user-3", TeamID: &t1},
+		{ID: "user-4", TeamID: &t4, IsActive: true},
+	}}
+	agentDB := &mockAgentDB{agents: []domain.Agent{
+		{ID: "agent-1", UserID: "user-1", TeamID: "team-1", Status: domain.AgentStatusOnline},
+		{ID: "agent-2", UserID: "user-2", TeamID: "team-2", Status: domain.AgentStatusOffline},
+		{ID: "agent-3", UserID: "user-2", TeamID: "team-2", Status: domain.AgentStatusRevoked},
+		{ID: "agent-4", UserID: "user-4", TeamID: "team-4", Status: domain.AgentStatusOnline},
+	}}
+	projects := &mockProjectDB{projects: []domain.AgentProject{
+		{AgentID: "agent-1", TeamID: "team-1", ProjectPath: "/home/dev/api"},
+		{AgentID: "agent-1", TeamID: "team-1", ProjectPath: "/home/dev/web"},
+		{AgentID: "agent-2", TeamID: "team-2", ProjectPath: "/srv/api/payments"},
+		{AgentID: "agent-5", TeamID: "team-3", ProjectPath: "/srv/api/etl"},
+	}}
+
+	svc := impact.NewService(
+		&mockRuleDB{rules: map[string]domain.Rule{rule.ID: rule}},
+		&mockTeamDB{teams: []domain.Team{team1, team2, team3, team4}},
+		&mockAttachmentDB{attachments: []domain.RuleAttachment{attached, rejected}},
+		users, agentDB, projects,
+		&mockResolver{current: []domain.Rule{existing}, pending: rule},
+	)
+
+	report, err := svc.Analyze(context.Background(), rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Teams) != 2 || report.Teams[0].Reason != "team rule" || report.Teams[1].Reason != "attachment to the team is pending" {
+		t.Errorf("expected the owning and attached teams, got %+v", report.Teams)
+	}
+	if len(report.Users) != 3 {
+		t.Errorf("expected active team members and the targeted user, got %+v", report.Users)
+	}
+	if len(report.Agents) != 3 || report.ConnectedAgents != 2 {
+		t.Errorf("expected three non-revoked agents with two connected, got %d/%d", len(report.Agents), report.ConnectedAgents)
+	}
+	if len(report.Projects) != 2 {
+		t.Errorf("expected only the projects matching the trigger, got %+v", report.Projects)
+	}
+
+	if len(report.Samples) != 2 {
+		t.Fatalf("expected a sample per project, got %d", len(report.Samples))
+	}
+	sample := report.Samples[0]
+	if sample.ProjectPath != "/home/dev/api" || len(sample.Files) != 1 {
+		t.Fatalf("expected one changed file for /home/dev/api, got %+v", sample)
+	}
+	d := sample.Files[0].Diff
+	if !strings.Contains(d, "CLAUDE.md (before)") || !strings.Contains(d, "+") || !strings.Contains(d, "API Style") {
+		t.Errorf("expected a diff adding the rule, got:\n%s", d)
+	}
+}
+
+func TestService_AnalyzeOrganizationRule(t *testing.T) {
+	inherits := domain.NewTeam("Backend")
+	isolated := domain.NewTeam("Contractors")
+	isolated.Settings.InheritGlobalRules = false
+
+	rule := domain.NewGlobalRule("No Secrets", "Never commit secrets", false)
+	forced := domain.NewGlobalRule("Licenses", "Check licenses", true)
+
+	svc := impact.NewService(
+		&mockRuleDB{rules: map[string]domain.Rule{rule.ID: rule, forced.ID: forced}},
+		&mockTeamDB{teams: []domain.Team{inherits, isolated}},
+		&mockAttachmentDB{},
+		&mockUserDB{}, &mockAgentDB{}, &mockProjectDB{},
+		&mockResolver{},
+	)
+
+	report, err := svc.Analyze(context.Background(), rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Teams) != 1 || report.Teams[0].ID != inherits.ID {
+		t.Errorf("expected only the inheriting team, got %+v", report.Teams)
+	}
+
+	report, err = svc.Analyze(context.Background(), forced.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Teams) != 2 {
+		t.Errorf("expected every team for a forced rule, got %+v", report.Teams)
+	}
+
+	if _, err := svc.Analyze(context.Background(), "missing"); !errors.Is(err, impact.ErrRuleNotFound) {
+		t.Errorf("expected ErrRuleNotFound, got %v", err)
+	}
+}
+
+func TestService_AnalyzeCountsAgentsOnce(t *testing.T) {
+	team := domain.Team{ID: "team-1", Name: "Backend"}
+	rule := domain.NewRule("API Style", domain.TargetLayerProject, "Version every endpoint", nil, team.ID)
+	// An inactive member is only reached as a targeted user
+	rule.TargetUsers = []string{"user-2"}
+
+	teamID := team.ID
+	svc := impact.NewService(
+		&mockRuleDB{rules: map[string]domain.Rule{rule.ID: rule}},
+		&mockTeamDB{teams: []domain.Team{team}},
+		&mockAttachmentDB{},
+		&mockUserDB{users: []domain.User{
+			{ID: "user-1", TeamID: &teamID, IsActive: true},
+			{ID: "user-2", TeamID: &teamID},
+		}},
+		&mockAgentDB{agents: []domain.Agent{
+			{ID: "agent-1", UserID: "user-1", TeamID: teamID, Status: domain.AgentStatusOnline},
+			{ID: "agent-2", UserID: "user-2", TeamID: teamID, Status: domain.AgentStatusOnline},
+		}},
+		&mockProjectDB{},
+		&mockResolver{},
+	)
+
+	report, err := svc.Analyze(context.Background(), rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Users) != 2 {
+		t.Errorf("expected the active member and the targeted user, got %+v", report.Users)
+	}
+	if len(report.Agents) != 2 || report.ConnectedAgents != 2 {
+		t.Errorf("expected each agent once, got %d agents with %d connected", len(report.Agents), report.ConnectedAgents)
+	}
+}
+
+func TestService_AnalyzeDatabaseError(t *testing.T) {
+	failure := errors.New("connection refused")
+	svc := impact.NewService(
+		&mockRuleDB{err: failure},
+		&mockTeamDB{}, &mockAttachmentDB{}, &mockUserDB{}, &mockAgentDB{}, &mockProjectDB{}, &mockResolver{},
+	)
+
+	_, err := svc.Analyze(context.Background(), "rule-1")
+	if !errors.Is(err, failure) || errors.Is(err, impact.ErrRuleNotFound) {
+		t.Errorf("expected the database error, got %v", err)
+	}
+}
@@ -25,6 +25,7 @@ type Service interface {
 	RecordAgentConnection(agentID, teamID string, action string)
 	RecordWebSocketMessage(direction string, messageType string, agentID string, sizeBytes int)
 	RecordBroadcast(teamID string, eventType string, recipientCount int)
+	RecordDroppedMessage(agentID, teamID string, messageType string, droppedTotal int64)
 
 	// Database metrics
 	RecordDBQuery(operation string, table string, duration time.Duration, success bool)
@@ -247,6 +248,22 @@ func (s *SplunkService) RecordBroadcast(teamID string, eventType string, recipie
 	s.addEvent(event)
 }
 
+// RecordDroppedMessage records a message an agent's full send buffer could
+// not take, with the agent's running total of dropped messages
+func (s *SplunkService) RecordDroppedMessage(agentID, teamID string, messageType string, droppedTotal int64) {
+	event := splunk.Event{
+		Host: s.hostname,
+		Event: map[string]interface{}{
+			"type":          "dropped_message",
+			"agent_id":      agentID,
+			"team_id":       teamID,
+			"message_type":  messageType,
+			"dropped_total": droppedTotal,
+		},
+	}
+	s.addEvent(event)
+}
+
 // RecordDBPoolStats records database connection pool statistics
 func (s *SplunkService) RecordDBPoolStats(totalConns, acquiredConns, idleConns, maxConns int32) {
 	event := splunk.Event{
@@ -345,6 +362,7 @@ func (n *NoOpService) RecordHubStats(agents, teams, subscriptions int)
 func (n *NoOpService) RecordAgentConnection(agentID, teamID string, action string)                    {}
 func (n *NoOpService) RecordWebSocketMessage(direction, messageType, agentID string, sizeBytes int)   {}
 func (n *NoOpService) RecordBroadcast(teamID string, eventType string, recipientCount int)            {}
+func (n *NoOpService) RecordDroppedMessage(agentID, teamID, messageType string, droppedTotal int64)   {}
 func (n *NoOpService) RecordDBQuery(operation, table string, duration time.Duration, success bool)    {}
 func (n *NoOpService) RecordDBPoolStats(totalConns, acquiredConns, idleConns, maxConns int32)         {}
 func (n *NoOpService) RecordHealthCheck(component string, status string, latencyMs int64)             {}
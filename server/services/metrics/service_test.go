@@ -242,6 +242,7 @@ func TestNoOpService(t *testing.T) {
 	service.RecordAgentConnection("agent-1", "team-1", "connected")
 	service.RecordWebSocketMessage("inbound", "heartbeat", "agent-1", 64)
 	service.RecordBroadcast("team-1", "rule_updated", 5)
+	service.RecordDroppedMessage("agent-1", "team-1", "config_update", 3)
 	service.RecordDBQuery("SELECT", "rules", 5*time.Millisecond, true)
 	service.RecordDBPoolStats(25, 5, 20, 50)
 	service.RecordHealthCheck("postgres", "healthy", 2)
@@ -310,6 +311,7 @@ func TestNewMetricsMethods(t *testing.T) {
 	service.RecordAgentConnection("agent-abc", "team-123", "connected")
 	service.RecordWebSocketMessage("inbound", "heartbeat", "agent-abc", 64)
 	service.RecordBroadcast("team-123", "rule_updated", 5)
+	service.RecordDroppedMessage("agent-abc", "team-123", "config_update", 3)
 	service.RecordDBPoolStats(25, 5, 20, 50)
 	service.RecordHealthCheck("postgres", "healthy", 2)
 	service.RecordWorkerHeartbeat("worker-1", 10, 5)
@@ -332,6 +334,7 @@ func TestNewMetricsMethods(t *testing.T) {
 		"agent_connection",
 		"websocket_message",
 		"broadcast",
+		"dropped_message",
 		"db_pool_stats",
 		"health_check",
 		"worker_heartbeat",
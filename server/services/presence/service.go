@@ -0,0 +1,173 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type Store interface {
+	List(ctx context.Context) ([]Entry, error)
+	Remove(ctx context.Context, connIDs ...string) error
+}
+
+// Resolver returns the rule set a team's agents enforce
+type Resolver interface {
+	Resolve(ctx context.Context, teamID string) (rulesets.RuleSet, error)
+}
+
+type Notifier interface {
+	Create(ctx context.Context, n domain.Notification) error
+}
+
+// Service answers which agents are connected across all workers and reports
+// the ones that vanished
+type Service struct {
+	store    Store
+	rules    Resolver
+	notifier Notifier
+}
+
+func NewService(store Store, rules Resolver) *Service {
+	return &Service{store: store, rules: rules}
+}
+
+func (s *Service) WithNotifier(notifier Notifier) *Service {
+	s.notifier = notifier
+	return s
+}
+
+// Online returns the connected agents, optionally only those of a team,
+// ordered by worker and hostname
+func (s *Service) Online(ctx context.Context, teamID string) ([]Entry, error) {
+	entries, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	online := []Entry{}
+	for _, e := range entries {
+		if e.Online && (teamID == "" || e.TeamID == teamID) {
+			online = append(online, e)
+		}
+	}
+	sort.Slice(online, func(i, j int) bool {
+		if online[i].WorkerID != online[j].WorkerID {
+			return online[i].WorkerID < online[j].WorkerID
+		}
+		return online[i].Hostname < online[j].Hostname
+	})
+	return online, nil
+}
+
+// Sweep removes the connections whose worker stopped refreshing them and
+// notifies the users of agents that vanished while block-mode rules apply to
+// them. An agent that reconnected in the meantime is not reported. Entries
+// of agents whose notification failed are kept for the next sweep. It
+// returns how many agents were reported.
+func (s *Service) Sweep(ctx context.Context) (int, error) {
+	entries, err := s.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	connected := make(map[string]bool)
+	for _, e := range entries {
+		if e.Online {
+			connected[e.Identity()] = true
+		}
+	}
+
+	reported := 0
+	blocking := make(map[string]bool)
+	notified := make(map[string]bool)
+	// Agents whose notification failed keep all their entries
+	failed := make(map[string]bool)
+	for _, e := range entries {
+		if e.Online || failed[e.Identity()] {
+			continue
+		}
+		if !connected[e.Identity()] && !notified[e.Identity()] {
+			hasBlocking, ok := blocking[e.TeamID]
+			if !ok {
+				hasBlocking, err = s.hasBlockingRules(ctx, e.TeamID)
+				if err != nil {
+					return reported, err
+				}
+				blocking[e.TeamID] = hasBlocking
+			}
+			if hasBlocking {
+				if err := s.notifyOffline(ctx, e); err != nil {
+					log.Printf("Failed to notify about offline agent %s: %v", e.Identity(), err)
+					failed[e.Identity()] = true
+					continue
+				}
+				notified[e.Identity()] = true
+				reported++
+			}
+		}
+		if err := s.store.Remove(ctx, e.ConnectionID); err != nil {
+			return reported, err
+		}
+	}
+	return reported, nil
+}
+
+// hasBlockingRules reports whether the team's rule set has a block-mode rule
+// in effect. Agents without a team, or whose team was deleted, receive no
+// rules.
+func (s *Service) hasBlockingRules(ctx context.Context, teamID string) (bool, error) {
+	if teamID == "" {
+		return false, nil
+	}
+	set, err := s.rules.Resolve(ctx, teamID)
+	if errors.Is(err, teams.ErrTeamNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range set.Rules {
+		if rule.EnforcementMode == domain.EnforcementModeBlock && rule.IsEffective() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Service) notifyOffline(ctx context.Context, e Entry) error {
+	log.Printf("Agent %s on %s disappeared from worker %s", e.Identity(), e.Hostname, e.WorkerID)
+	if s.notifier == nil {
+		return nil
+	}
+
+	var teamID *string
+	if e.TeamID != "" {
+		teamID = &e.TeamID
+	}
+	hostname := e.Hostname
+	if hostname == "" {
+		hostname = "an unknown host"
+	}
+	n := domain.NewNotification(
+		e.UserID,
+		teamID,
+		domain.NotificationTypeAgentOffline,
+		"Agent went offline",
+		fmt.Sprintf("The agent on %s stopped responding; block-mode rules are not enforced until it reconnects", hostname),
+		map[string]interface{}{
+			"agent_id":  e.Identity(),
+			"hostname":  e.Hostname,
+			"worker_id": e.WorkerID,
+			"last_seen": e.LastSeen,
+		},
+	)
+	return s.notifier.Create(ctx, n)
+}
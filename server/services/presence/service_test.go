@@ -0,0 +1,160 @@
+package presence_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+	"github.com/kamilrybacki/edictflow/server/services/teams"
+)
+
+type mockStore struct {
+	entries map[string]presence.Entry
+}
+
+func newMockStore(entries ...presence.Entry) *mockStore {
+	m := &mockStore{entries: make(map[string]presence.Entry)}
+	for _, e := range entries {
+		m.entries[e.ConnectionID] = e
+	}
+	return m
+}
+
+func (m *mockStore) List(ctx context.Context) ([]presence.Entry, error) {
+	result := []presence.Entry{}
+	for _, e := range m.entries {
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (m *mockStore) Remove(ctx context.Context, connIDs ...string) error {
+	for _, id := range connIDs {
+		delete(m.entries, id)
+	}
+	return nil
+}
+
+type mockRules struct {
+	byTeam map[string][]domain.Rule
+}
+
+func (m *mockRules) Resolve(ctx context.Context, teamID string) (rulesets.RuleSet, error) {
+	rules, ok := m.byTeam[teamID]
+	if !ok {
+		return rulesets.RuleSet{}, teams.ErrTeamNotFound
+	}
+	return rulesets.RuleSet{TeamID: teamID, Rules: rules}, nil
+}
+
+type mockNotifier struct {
+	notifications []domain.Notification
+	err           error
+}
+
+func (m *mockNotifier) Create(ctx context.Context, n domain.Notification) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.notifications = append(m.notifications, n)
+	return nil
+}
+
+// resolvedRule is a rule as it appears in a resolved rule set
+func resolvedRule(mode domain.EnforcementMode) domain.Rule {
+	return domain.Rule{Status: domain.RuleStatusApproved, EnforcementMode: mode}
+}
+
+func TestService_OnlineFiltersByTeam(t *testing.T) {
+	store := newMockStore(
+		presence.Entry{ConnectionID: "c1", AgentID: "a1", TeamID: "team-1", WorkerID: "w2", Online: true},
+		presence.Entry{ConnectionID: "c2", AgentID: "a2", TeamID: "team-1", WorkerID: "w1", Online: true},
+		presence.Entry{ConnectionID: "c3", AgentID: "a3", TeamID: "team-2", WorkerID: "w1", Online: true},
+		presence.Entry{ConnectionID: "c4", AgentID: "a4", TeamID: "team-1", WorkerID: "w1"},
+	)
+	svc := presence.NewService(store, &mockRules{})
+
+	online, err := svc.Online(context.Background(), "team-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(online) != 2 || online[0].WorkerID != "w1" || online[1].WorkerID != "w2" {
+		t.Errorf("expected the 2 online agents of team-1 ordered by worker, got %+v", online)
+	}
+}
+
+func TestService_SweepNotifiesVanishedBlockingAgents(t *testing.T) {
+	store := newMockStore(
+		// Vanished, team has block rules
+		presence.Entry{ConnectionID: "c1", AgentID: "a1", UserID: "u1", TeamID: "blocking", Hostname: "laptop"},
+		// Vanished, but reconnected through another connection
+		presence.Entry{ConnectionID: "c2", AgentID: "a2", UserID: "u2", TeamID: "blocking"},
+		presence.Entry{ConnectionID: "c3", AgentID: "a2", UserID: "u2", TeamID: "blocking", Online: true},
+		// Vanished, team only warns
+		presence.Entry{ConnectionID: "c4", AgentID: "a4", UserID: "u4", TeamID: "warning"},
+	)
+	upcoming := resolvedRule(domain.EnforcementModeBlock)
+	start := time.Now().Add(time.Hour)
+	upcoming.EffectiveStart = &start
+	rules := &mockRules{byTeam: map[string][]domain.Rule{
+		"blocking": {resolvedRule(domain.EnforcementModeWarning), resolvedRule(domain.EnforcementModeBlock)},
+		"warning":  {resolvedRule(domain.EnforcementModeWarning), upcoming},
+	}}
+	notifier := &mockNotifier{}
+	svc := presence.NewService(store, rules).WithNotifier(notifier)
+
+	reported, err := svc.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reported != 1 || len(notifier.notifications) != 1 {
+		t.Fatalf("expected 1 agent reported, got %d", reported)
+	}
+	n := notifier.notifications[0]
+	if n.Type != domain.NotificationTypeAgentOffline || n.UserID != "u1" || n.TeamID == nil || *n.TeamID != "blocking" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+	if len(store.entries) != 1 {
+		t.Errorf("expected only the online connection to remain, got %d entries", len(store.entries))
+	}
+
+	// Removed entries are not reported twice
+	if reported, _ := svc.Sweep(context.Background()); reported != 0 {
+		t.Errorf("expected nothing to report on the second sweep, got %d", reported)
+	}
+}
+
+func TestService_SweepKeepsEntriesWhoseNotificationFailed(t *testing.T) {
+	store := newMockStore(
+		presence.Entry{ConnectionID: "c1", AgentID: "a1", UserID: "u1", TeamID: "blocking"},
+		// The team was deleted, so no rules apply
+		presence.Entry{ConnectionID: "c2", AgentID: "a2", UserID: "u2", TeamID: "deleted"},
+	)
+	rules := &mockRules{byTeam: map[string][]domain.Rule{
+		"blocking": {resolvedRule(domain.EnforcementModeBlock)},
+	}}
+	notifier := &mockNotifier{err: errors.New("database unavailable")}
+	svc := presence.NewService(store, rules).WithNotifier(notifier)
+
+	if reported, err := svc.Sweep(context.Background()); err != nil || reported != 0 {
+		t.Fatalf("expected nothing reported, got %d, %v", reported, err)
+	}
+	if _, ok := store.entries["c1"]; !ok {
+		t.Error("expected the entry to be kept for the next sweep")
+	}
+	if _, ok := store.entries["c2"]; ok {
+		t.Error("expected the entry of the deleted team to be removed")
+	}
+
+	notifier.err = nil
+	if reported, err := svc.Sweep(context.Background()); err != nil || reported != 1 {
+		t.Fatalf("expected the agent to be reported on the next sweep, got %d, %v", reported, err)
+	}
+	if len(store.entries) != 0 {
+		t.Errorf("expected the entry to be removed once reported, got %d entries", len(store.entries))
+	}
+}
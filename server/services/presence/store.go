@@ -0,0 +1,137 @@
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
+)
+
+const (
+	// KeyAgents is the Redis hash holding the last known presence of every
+	// connection, keyed by connection ID
+	KeyAgents = "presence:agents"
+	// markerPrefix prefixes the per-connection keys that expire when the
+	// worker holding the connection stops refreshing them
+	markerPrefix = "presence:conn:"
+
+	// DefaultTTL is how long a connection counts as present without a
+	// refresh. Workers refresh every third of it.
+	DefaultTTL = 90 * time.Second
+)
+
+// Entry describes one agent connection on a worker
+type Entry struct {
+	ConnectionID  string    `json:"connection_id"`
+	AgentID       string    `json:"agent_id"`
+	UserID        string    `json:"user_id"`
+	WorkerID      string    `json:"worker_id"`
+	TeamID        string    `json:"team_id,omitempty"`
+	Hostname      string    `json:"hostname,omitempty"`
+	Version       string    `json:"version,omitempty"`
+	OS            string    `json:"os,omitempty"`
+	ConfigVersion int       `json:"config_version"`
+	ConnectedAt   string    `json:"connected_at,omitempty"`
+	LastSeen      time.Time `json:"last_seen"`
+
+	// Online is false once the worker stopped refreshing the entry
+	Online bool `json:"-"`
+}
+
+// Identity returns the agent ID, or the user ID for agents that have not
+// reported one
+func (e Entry) Identity() string {
+	if e.AgentID != "" {
+		return e.AgentID
+	}
+	return e.UserID
+}
+
+// RedisStore keeps presence entries in Redis. Entries stay in a hash after
+// their marker expires, so the master can tell which agents vanished.
+type RedisStore struct {
+	client *redisAdapter.Client
+	ttl    time.Duration
+}
+
+func NewRedisStore(client *redisAdapter.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+// TTL returns how long entries count as present without a refresh
+func (s *RedisStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Put writes the entries and refreshes their markers
+func (s *RedisStore) Put(ctx context.Context, entries []Entry) error {
+	fields := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		fields[e.ConnectionID] = data
+	}
+	return s.client.HashSetWithMarkers(ctx, KeyAgents, fields, markerPrefix, s.ttl)
+}
+
+// Remove forgets connections that closed cleanly
+func (s *RedisStore) Remove(ctx context.Context, connIDs ...string) error {
+	if len(connIDs) == 0 {
+		return nil
+	}
+	if err := s.client.HashDel(ctx, KeyAgents, connIDs...); err != nil {
+		return err
+	}
+	return s.client.Del(ctx, markers(connIDs)...)
+}
+
+// MarkLost expires connections that dropped without closing, leaving their
+// entries for the master to report
+func (s *RedisStore) MarkLost(ctx context.Context, connIDs ...string) error {
+	if len(connIDs) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, markers(connIDs)...)
+}
+
+// List returns every entry, with Online set for those still refreshed
+func (s *RedisStore) List(ctx context.Context) ([]Entry, error) {
+	fields, err := s.client.HashGetAll(ctx, KeyAgents)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(fields))
+	ids := make([]string, 0, len(fields))
+	for connID, data := range fields {
+		var e Entry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			log.Printf("Invalid presence entry %s: %v", connID, err)
+			continue
+		}
+		e.ConnectionID = connID
+		entries = append(entries, e)
+		ids = append(ids, connID)
+	}
+
+	online, err := s.client.ExistsEach(ctx, markers(ids))
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Online = online[i]
+	}
+	return entries, nil
+}
+
+func markers(connIDs []string) []string {
+	keys := make([]string, len(connIDs))
+	for i, id := range connIDs {
+		keys[i] = markerPrefix + id
+	}
+	return keys
+}
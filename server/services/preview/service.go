@@ -0,0 +1,301 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/pkg/markdown"
+	"github.com/kamilrybacki/edictflow/pkg/triggers"
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrNoTeam         = errors.New("user is not in a team")
+	ErrUnknownTarget  = errors.New("unknown target")
+	ErrTargetDisabled = errors.New("target is not enabled for the team")
+)
+
+// Resolver resolves a team's rule set the way the workers deliver it to agents
+type Resolver interface {
+	Explain(ctx context.Context, teamID string) (rulesets.RuleSet, []rulesets.Decision, error)
+}
+
+type UserDB interface {
+	GetByID(ctx context.Context, id string) (domain.User, error)
+}
+
+// Request describes the developer and project to render the managed files for
+type Request struct {
+	// TeamID is the team whose rules apply. When empty, the team of UserID is used.
+	TeamID string
+	UserID string
+	// ProjectPath is the project directory as the agent watches it
+	ProjectPath string
+	// Contexts and Tags stand in for what the agent detects in the project
+	Contexts []string
+	Tags     []string
+	// Repository is the project's git remote, as a URL or host/owner/name,
+	// and Branch its checked out branch
+	Repository string
+	Branch     string
+	// Target is the output target to render. When empty, the team's first
+	// enabled target is used.
+	Target string
+}
+
+// File is the managed section an agent writes into one file
+type File struct {
+	// Level is "enterprise", "user" or "project"
+	Level string
+	// Path is the file's location. User files are relative to the home directory.
+	Path    string
+	Section string
+	// Hash is the hex SHA-256 of Section, as agents report it in drift
+	// reports, or empty when no rules apply
+	Hash    string
+	RuleIDs []string
+}
+
+// RuleDecision explains whether a rule is rendered and why
+type RuleDecision struct {
+	RuleID      string
+	Name        string
+	TargetLayer domain.TargetLayer
+	Included    bool
+	// Level is the file level the rule is rendered into, or would be if it
+	// were included
+	Level  string
+	Reason string
+}
+
+// Preview is what an agent writes for the request
+type Preview struct {
+	TeamID      string
+	Target      string
+	ProjectPath string
+	Files       []File
+	Rules       []RuleDecision
+}
+
+type Service struct {
+	resolver Resolver
+	userDB   UserDB
+}
+
+func NewService(resolver Resolver, userDB UserDB) *Service {
+	return &Service{resolver: resolver, userDB: userDB}
+}
+
+// Render resolves the team's rules, matches them to the project and renders
+// the target's managed files exactly like an agent with a fresh cache
+func (s *Service) Render(ctx context.Context, req Request) (Preview, error) {
+	teamID := req.TeamID
+	if teamID == "" {
+		user, err := s.userDB.GetByID(ctx, req.UserID)
+		if err != nil {
+			return Preview{}, ErrUserNotFound
+		}
+		if user.TeamID == nil {
+			return Preview{}, ErrNoTeam
+		}
+		teamID = *user.TeamID
+	}
+
+	set, decisions, err := s.resolver.Explain(ctx, teamID)
+	if err != nil {
+		return Preview{}, err
+	}
+	return RenderRuleSet(set, decisions, req)
+}
+
+// RenderRuleSet renders the target's managed files for the project from a
+// team's resolved rule set and its decisions
+func RenderRuleSet(set rulesets.RuleSet, decisions []rulesets.Decision, req Request) (Preview, error) {
+	target, err := resolveTarget(req.Target, set.Targets)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	// Project rules with triggers only apply when one of them matches the
+	// project; rules without triggers apply to every project. Rules that are
+	// not effective yet apply to none.
+	matchCtx := rules.MatchContext{
+		ProjectPath:      req.ProjectPath,
+		DetectedContexts: req.Contexts,
+		Tags:             req.Tags,
+		Repository:       triggers.NormalizeRemote(req.Repository),
+		Branch:           req.Branch,
+	}
+	var scoped []domain.Rule
+	for _, d := range decisions {
+		if d.Included && !notYetEffective(d.Rule) && d.Rule.TargetLayer.AgentLayer() == "project" && len(d.Rule.Triggers) > 0 {
+			scoped = append(scoped, d.Rule)
+		}
+	}
+	matched := rules.NewMatcher(scoped).Match(matchCtx)
+	matchedIDs := make(map[string]bool, len(matched))
+	for _, r := range matched {
+		matchedIDs[r.ID] = true
+	}
+
+	preview := Preview{TeamID: set.TeamID, Target: target.Name, ProjectPath: req.ProjectPath}
+	byLayer := make(map[string][]domain.Rule)
+	var unscoped []domain.Rule
+	for _, d := range decisions {
+		rule := d.Rule
+		decision := RuleDecision{
+			RuleID:      rule.ID,
+			Name:        rule.Name,
+			TargetLayer: rule.TargetLayer,
+			Level:       fileLevel(target, rule.TargetLayer.AgentLayer()),
+			Included:    d.Included,
+			Reason:      d.Reason,
+		}
+		if d.Included {
+			switch {
+			case notYetEffective(rule):
+				decision.Included = false
+				decision.Reason = fmt.Sprintf("%s, but not effective until %s", d.Reason, rule.EffectiveStart.UTC().Format("2006-01-02T15:04:05Z"))
+			case rule.TargetLayer.AgentLayer() != "project":
+				byLayer[rule.TargetLayer.AgentLayer()] = append(byLayer[rule.TargetLayer.AgentLayer()], rule)
+			case len(rule.Triggers) == 0:
+				decision.Reason = d.Reason + ", without triggers so it applies to every project"
+				unscoped = append(unscoped, rule)
+			case matchedIDs[rule.ID]:
+				decision.Reason = d.Reason + ", matched by " + describeTriggers(rule.Triggers, matchCtx)
+			case triggers.NeedsFiles(rule.Triggers):
+				decision.Included = false
+				decision.Reason = d.Reason + ", but no trigger matches the project and glob and file_exists triggers can only be checked by the agent"
+			default:
+				decision.Included = false
+				decision.Reason = d.Reason + ", but no trigger matches the project"
+			}
+		}
+		preview.Rules = append(preview.Rules, decision)
+	}
+	// Enterprise and user rules without a file of their own precede the
+	// project's rules, which are listed most specific first
+	var projectRules []domain.Rule
+	for _, layer := range []string{"enterprise", "user"} {
+		if target.FoldsLayer(layer) {
+			projectRules = append(projectRules, byLayer[layer]...)
+		}
+	}
+	projectRules = append(append(projectRules, matched...), unscoped...)
+
+	categoryNames := make(map[string]string)
+	categories := make([]markdown.Category, 0, len(set.Categories))
+	for _, c := range set.Categories {
+		categoryNames[c.ID] = c.Name
+		categories = append(categories, markdown.Category{ID: c.ID, Name: c.Name, DisplayOrder: c.DisplayOrder})
+	}
+
+	if target.EnterpriseFile != "" {
+		preview.Files = append(preview.Files, renderFile(target, "enterprise", target.EnterpriseFile, byLayer["enterprise"], categoryNames, categories))
+	}
+	if target.UserFile != "" {
+		preview.Files = append(preview.Files, renderFile(target, "user", path.Join("~", target.UserFile), byLayer["user"], categoryNames, categories))
+	}
+	preview.Files = append(preview.Files, renderFile(target, "project", target.ProjectFilePath(req.ProjectPath), projectRules, categoryNames, categories))
+	return preview, nil
+}
+
+// notYetEffective reports whether the rule's effective window has not started
+func notYetEffective(rule domain.Rule) bool {
+	return rule.EffectiveStart != nil && time.Now().Before(*rule.EffectiveStart)
+}
+
+// resolveTarget returns the named target, which the team must have enabled,
+// or the team's first enabled target
+func resolveTarget(name string, enabled []string) (markdown.Target, error) {
+	if name == "" {
+		if targets := markdown.ResolveTargets(enabled); len(targets) > 0 {
+			return targets[0], nil
+		}
+		return markdown.Target{}, ErrTargetDisabled
+	}
+	target, ok := markdown.LookupTarget(name)
+	if !ok {
+		return markdown.Target{}, fmt.Errorf("%w: %s", ErrUnknownTarget, name)
+	}
+	for _, n := range enabled {
+		if n == name {
+			return target, nil
+		}
+	}
+	return markdown.Target{}, fmt.Errorf("%w: %s", ErrTargetDisabled, name)
+}
+
+// fileLevel returns the level of the target's file the agent writes rules of
+// the layer into
+func fileLevel(target markdown.Target, layer string) string {
+	if target.FoldsLayer(layer) {
+		return "project"
+	}
+	return layer
+}
+
+func renderFile(target markdown.Target, level, filePath string, included []domain.Rule, categoryNames map[string]string, categories []markdown.Category) File {
+	file := File{Level: level, Path: filePath, RuleIDs: []string{}}
+	mdRules := make([]markdown.Rule, 0, len(included))
+	for _, r := range included {
+		rule := markdown.Rule{
+			Name:           r.Name,
+			Content:        r.Content,
+			TargetLayer:    r.TargetLayer.AgentLayer(),
+			Overridable:    r.Overridable,
+			PriorityWeight: r.PriorityWeight,
+		}
+		if r.CategoryID != nil {
+			rule.CategoryID = *r.CategoryID
+			rule.CategoryName = categoryNames[*r.CategoryID]
+		}
+		mdRules = append(mdRules, rule)
+		file.RuleIDs = append(file.RuleIDs, r.ID)
+	}
+
+	file.Section = target.Format.RenderManagedSectionWithCategories(mdRules, categories)
+	if file.Section != "" {
+		sum := sha256.Sum256([]byte(file.Section))
+		file.Hash = hex.EncodeToString(sum[:])
+	}
+	return file
+}
+
+// describeTriggers lists the triggers that match the project
+func describeTriggers(ruleTriggers []domain.Trigger, ctx rules.MatchContext) string {
+	description := ""
+	for _, t := range ruleTriggers {
+		if !t.Matches(ctx) {
+			continue
+		}
+		if description != "" {
+			description += " and "
+		}
+		if t.Negate {
+			description += "negated "
+		}
+		switch t.Type {
+		case domain.TriggerTypeContext:
+			description += fmt.Sprintf("context trigger %v", t.ContextTypes)
+		case domain.TriggerTypeTag:
+			description += fmt.Sprintf("tag trigger %v", t.Tags)
+		case domain.TriggerTypeRepository:
+			description += fmt.Sprintf("repository trigger %q", t.Pattern)
+			if len(t.Branches) > 0 {
+				description += fmt.Sprintf(" on branches %v", t.Branches)
+			}
+		default:
+			description += fmt.Sprintf("%s trigger %q", t.Type, t.Pattern)
+		}
+	}
+	return description
+}
@@ -0,0 +1,207 @@
+package preview_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/preview"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+type mockResolver struct {
+	set       rulesets.RuleSet
+	decisions []rulesets.Decision
+}
+
+func (m *mockResolver) Explain(ctx context.Context, teamID string) (rulesets.RuleSet, []rulesets.Decision, error) {
+	if teamID != m.set.TeamID {
+		return rulesets.RuleSet{}, nil, errors.New("team not found")
+	}
+	return m.set, m.decisions, nil
+}
+
+type mockUserDB struct {
+	users map[string]domain.User
+}
+
+func (m *mockUserDB) GetByID(ctx context.Context, id string) (domain.User, error) {
+	if user, ok := m.users[id]; ok {
+		return user, nil
+	}
+	return domain.User{}, errors.New("user not found")
+}
+
+func newTestService() *preview.Service {
+	future := time.Now().Add(time.Hour)
+
+	forced := domain.NewGlobalRule("No Secrets", "Never commit secrets", true)
+	teamRule := domain.NewRule("Reviews", domain.TargetLayerTeam, "Request a review", nil, "team-1")
+	api := domain.NewRule("API Style", domain.TargetLayerProject, "Version every endpoint", []domain.Trigger{
+		{Type: domain.TriggerTypePath, Pattern: "**/api/**"},
+	}, "team-1")
+	everywhere := domain.NewRule("Tests", domain.TargetLayerProject, "Write tests", nil, "team-1")
+	frontend := domain.NewRule("Frontend", domain.TargetLayerProject, "Use hooks", []domain.Trigger{
+		{Type: domain.TriggerTypeTag, Tags: []string{"frontend"}},
+	}, "team-1")
+	upcoming := domain.NewRule("Upcoming", domain.TargetLayerProject, "Soon", nil, "team-1")
+	upcoming.EffectiveStart = &future
+	draft := domain.NewRule("Draft", domain.TargetLayerProject, "Not yet", nil, "team-1")
+
+	included := []domain.Rule{forced, teamRule, everywhere, api, frontend, upcoming}
+	resolver := &mockResolver{set: rulesets.RuleSet{TeamID: "team-1", Rules: included, Targets: []string{"claude", "cursor"}}}
+	for _, r := range included {
+		resolver.decisions = append(resolver.decisions, rulesets.Decision{Rule: r, Included: true, Reason: "team rule"})
+	}
+	resolver.decisions = append(resolver.decisions, rulesets.Decision{Rule: draft, Reason: "rule is draft"})
+
+	teamID := "team-1"
+	users := &mockUserDB{users: map[string]domain.User{
+		"user-1": {ID: "user-1", TeamID: &teamID},
+		"user-2": {ID: "user-2"},
+	}}
+	return preview.NewService(resolver, users)
+}
+
+func TestService_Render(t *testing.T) {
+	svc := newTestService()
+
+	p, err := svc.Render(context.Background(), preview.Request{UserID: "user-1", ProjectPath: "/home/dev/api/server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TeamID != "team-1" || p.Target != "claude" {
+		t.Errorf("expected the user's team and first target, got %s/%s", p.TeamID, p.Target)
+	}
+	if len(p.Files) != 3 {
+		t.Fatalf("expected enterprise, user and project files, got %d", len(p.Files))
+	}
+
+	project := p.Files[2]
+	if project.Path != "/home/dev/api/server/CLAUDE.md" {
+		t.Errorf("unexpected project file path %s", project.Path)
+	}
+	api := strings.Index(project.Section, "**API Style**")
+	tests := strings.Index(project.Section, "**Tests**")
+	if api < 0 || tests < 0 || api > tests {
+		t.Errorf("expected the matched rule before the rule without triggers:\n%s", project.Section)
+	}
+	if strings.Contains(project.Section, "Frontend") || strings.Contains(project.Section, "Upcoming") {
+		t.Errorf("expected unmatched and upcoming rules to be left out:\n%s", project.Section)
+	}
+	sum := sha256.Sum256([]byte(project.Section))
+	if project.Hash != hex.EncodeToString(sum[:]) {
+		t.Error("expected the hash of the managed section")
+	}
+	if !strings.Contains(p.Files[0].Section, "No Secrets") || !strings.Contains(p.Files[1].Section, "Reviews") {
+		t.Error("expected organization and team rules in their own files")
+	}
+
+	reasons := make(map[string]preview.RuleDecision)
+	for _, d := range p.Rules {
+		reasons[d.Name] = d
+	}
+	for name, included := range map[string]bool{"API Style": true, "Tests": true, "Frontend": false, "Upcoming": false, "Draft": false} {
+		if reasons[name].Included != included {
+			t.Errorf("expected %s included=%v, got %+v", name, included, reasons[name])
+		}
+	}
+	if !strings.Contains(reasons["API Style"].Reason, `path trigger "**/api/**"`) {
+		t.Errorf("expected the matching trigger in the reason, got %q", reasons["API Style"].Reason)
+	}
+}
+
+func TestService_RenderFoldsLayers(t *testing.T) {
+	svc := newTestService()
+
+	p, err := svc.Render(context.Background(), preview.Request{TeamID: "team-1", ProjectPath: "/home/dev/web", Tags: []string{"frontend"}, Target: "cursor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Files) != 1 {
+		t.Fatalf("expected only the project file, got %d", len(p.Files))
+	}
+	section := p.Files[0].Section
+	for _, name := range []string{"No Secrets", "Reviews", "Frontend", "Tests"} {
+		if !strings.Contains(section, name) {
+			t.Errorf("expected %s in the project file:\n%s", name, section)
+		}
+	}
+}
+
+func TestService_RenderErrors(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	if _, err := svc.Render(ctx, preview.Request{UserID: "missing"}); !errors.Is(err, preview.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := svc.Render(ctx, preview.Request{UserID: "user-2"}); !errors.Is(err, preview.ErrNoTeam) {
+		t.Errorf("expected ErrNoTeam, got %v", err)
+	}
+	if _, err := svc.Render(ctx, preview.Request{TeamID: "team-1", Target: "vim"}); !errors.Is(err, preview.ErrUnknownTarget) {
+		t.Errorf("expected ErrUnknownTarget, got %v", err)
+	}
+	if _, err := svc.Render(ctx, preview.Request{TeamID: "team-1", Target: "gemini"}); !errors.Is(err, preview.ErrTargetDisabled) {
+		t.Errorf("expected ErrTargetDisabled, got %v", err)
+	}
+}
+
+func TestService_RenderMatchesRepository(t *testing.T) {
+	payments := domain.NewRule("Payments", domain.TargetLayerProject, "Use the ledger client", []domain.Trigger{
+		{Type: domain.TriggerTypeRepository, Pattern: "github.com/acme/payments-*", Branches: []string{"main"}},
+	}, "team-1")
+	resolver := &mockResolver{
+		set:       rulesets.RuleSet{TeamID: "team-1", Rules: []domain.Rule{payments}},
+		decisions: []rulesets.Decision{{Rule: payments, Included: true, Reason: "team rule"}},
+	}
+	svc := preview.NewService(resolver, &mockUserDB{})
+
+	for branch, included := range map[string]bool{"main": true, "feature/x": false} {
+		p, err := svc.Render(context.Background(), preview.Request{
+			TeamID:      "team-1",
+			ProjectPath: "/anywhere/on/disk",
+			Repository:  "git@github.com:acme/payments-api.git",
+			Branch:      branch,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Rules[0].Included != included {
+			t.Errorf("branch %s: expected included=%v, got %+v", branch, included, p.Rules[0])
+		}
+		if included && !strings.Contains(p.Rules[0].Reason, `repository trigger "github.com/acme/payments-*" on branches [main]`) {
+			t.Errorf("expected the repository trigger in the reason, got %q", p.Rules[0].Reason)
+		}
+	}
+}
+
+func TestService_RenderLeavesOutUpcomingTriggeredRules(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	upcoming := domain.NewRule("Upcoming API", domain.TargetLayerProject, "Deprecate v1", []domain.Trigger{
+		{Type: domain.TriggerTypePath, Pattern: "**/api/**"},
+	}, "team-1")
+	upcoming.EffectiveStart = &future
+	resolver := &mockResolver{
+		set:       rulesets.RuleSet{TeamID: "team-1", Rules: []domain.Rule{upcoming}},
+		decisions: []rulesets.Decision{{Rule: upcoming, Included: true, Reason: "team rule"}},
+	}
+	svc := preview.NewService(resolver, &mockUserDB{})
+
+	p, err := svc.Render(context.Background(), preview.Request{TeamID: "team-1", ProjectPath: "/home/dev/api/server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := p.Files[len(p.Files)-1]
+	if strings.Contains(project.Section, "Upcoming API") || len(project.RuleIDs) != 0 {
+		t.Errorf("expected the upcoming rule to be left out:\n%s", project.Section)
+	}
+	if p.Rules[0].Included {
+		t.Errorf("expected the upcoming rule not to be included, got %+v", p.Rules[0])
+	}
+}
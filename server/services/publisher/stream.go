@@ -0,0 +1,78 @@
+package publisher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
+	"github.com/kamilrybacki/edictflow/server/events"
+	"github.com/kamilrybacki/edictflow/server/services/metrics"
+)
+
+// StreamPublisher implements Publisher using a Redis stream, so events
+// published while a worker is down are delivered once it is back
+type StreamPublisher struct {
+	client  *redisAdapter.Client
+	maxAge  time.Duration
+	metrics metrics.Service
+}
+
+// NewStreamPublisher creates a publisher that keeps events in the stream for
+// maxAge
+func NewStreamPublisher(client *redisAdapter.Client, maxAge time.Duration) *StreamPublisher {
+	return &StreamPublisher{
+		client:  client,
+		maxAge:  maxAge,
+		metrics: &metrics.NoOpService{},
+	}
+}
+
+// SetMetrics sets the metrics service for observability
+func (p *StreamPublisher) SetMetrics(m metrics.Service) {
+	p.metrics = m
+}
+
+// PublishRuleEvent publishes a rule change event
+func (p *StreamPublisher) PublishRuleEvent(ctx context.Context, eventType events.EventType, ruleID, teamID string) error {
+	log.Printf("Publishing %s to %s: team=%s rule=%s", eventType, events.StreamEvents, teamID, ruleID)
+	return p.add(ctx, events.NewEvent(eventType, ruleID, teamID))
+}
+
+// PublishCategoryEvent publishes a category change event
+func (p *StreamPublisher) PublishCategoryEvent(ctx context.Context, eventType events.EventType, categoryID, teamID string) error {
+	log.Printf("Publishing %s to %s: team=%s category=%s", eventType, events.StreamEvents, teamID, categoryID)
+	return p.add(ctx, events.NewEvent(eventType, categoryID, teamID))
+}
+
+// PublishBroadcast publishes to all workers
+func (p *StreamPublisher) PublishBroadcast(ctx context.Context, eventType events.EventType, message string) error {
+	return p.add(ctx, events.NewEvent(eventType, message, ""))
+}
+
+// PublishToAgent publishes directly to an agent. Direct messages only matter
+// to a connected agent, so they stay on the agent's channel.
+func (p *StreamPublisher) PublishToAgent(ctx context.Context, agentID string, data []byte) error {
+	channel := events.ChannelForAgent(agentID)
+
+	start := time.Now()
+	err := p.client.Publish(ctx, channel, data)
+	latency := time.Since(start).Milliseconds()
+	p.metrics.RecordRedisPublish(channel, "direct_message", err == nil, latency)
+
+	return err
+}
+
+func (p *StreamPublisher) add(ctx context.Context, event events.Event) error {
+	data, err := event.Marshal()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	_, err = p.client.StreamAdd(ctx, events.StreamEvents, data, p.maxAge)
+	latency := time.Since(start).Milliseconds()
+	p.metrics.RecordRedisPublish(events.StreamEvents, string(event.Type), err == nil, latency)
+
+	return err
+}
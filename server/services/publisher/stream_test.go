@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
+	"github.com/kamilrybacki/edictflow/server/events"
+)
+
+func TestStreamPublisher_PublishRuleEvent(t *testing.T) {
+	client, err := redisAdapter.NewClient("redis://localhost:6379/0")
+	if err != nil {
+		t.Skip("Redis not available:", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skip("Redis not available:", err)
+	}
+
+	// The group only sees entries added after it was created
+	group := "test-group-" + time.Now().Format(time.RFC3339Nano)
+	if err := client.CreateStreamGroup(ctx, events.StreamEvents, group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	defer func() { _ = client.Underlying().XGroupDestroy(ctx, events.StreamEvents, group).Err() }()
+
+	// Nobody is listening when the event is published
+	publisher := NewStreamPublisher(client, time.Hour)
+	if err := publisher.PublishRuleEvent(ctx, events.EventRuleUpdated, "rule-123", "test-team"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	messages, err := client.ReadStreamGroup(ctx, events.StreamEvents, group, "worker", ">", 10, time.Second)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(messages))
+	}
+
+	event, err := events.UnmarshalEvent(messages[0].Data)
+	if err != nil {
+		t.Fatalf("failed to unmarshal event: %v", err)
+	}
+	if event.Type != events.EventRuleUpdated || event.EntityID != "rule-123" || event.TeamID != "test-team" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
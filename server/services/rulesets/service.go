@@ -0,0 +1,367 @@
+package rulesets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+type RuleDB interface {
+	GetRule(ctx context.Context, id string) (domain.Rule, error)
+	ListRulesByTeam(ctx context.Context, teamID string) ([]domain.Rule, error)
+	ListGlobalRules(ctx context.Context) ([]domain.Rule, error)
+}
+
+type AttachmentDB interface {
+	ListByTeam(ctx context.Context, teamID string) ([]domain.RuleAttachment, error)
+}
+
+type CategoryDB interface {
+	ListAll(ctx context.Context) ([]domain.Category, error)
+}
+
+type TeamDB interface {
+	GetTeam(ctx context.Context, id string) (domain.Team, error)
+}
+
+// RevisionDB stores each team's configuration revision and change log
+type RevisionDB interface {
+	CommitConfig(ctx context.Context, teamID string, state domain.TeamConfigState, retention int64) (domain.ConfigRevision, error)
+	ListConfigChanges(ctx context.Context, teamID string, since int64) ([]domain.ConfigChange, error)
+}
+
+const (
+	// ChangeLogRetention is how many revisions of changes are kept per team
+	ChangeLogRetention = 1000
+	// MaxDeltaRevisions is the largest revision gap served as a delta;
+	// agents further behind get a full snapshot
+	MaxDeltaRevisions = 500
+)
+
+// RuleSet is everything a team's agents need to render their managed files
+type RuleSet struct {
+	TeamID string
+	// Rules are the approved rules in effect for the team, with the
+	// enforcement settings of the team's attachment applied
+	Rules []domain.Rule
+	// Categories are the categories referenced by Rules
+	Categories []domain.Category
+	// Targets are the team's enabled output targets
+	Targets []string
+}
+
+// Update moves an agent's cache to a revision of its team's configuration
+type Update struct {
+	TeamID   string
+	Revision int64
+	// Full is set when Rules is the complete rule set replacing the cache.
+	// Otherwise Rules and RemovedRuleIDs are the changes since Since.
+	Full           bool
+	Since          int64
+	Rules          []domain.Rule
+	RemovedRuleIDs []string
+	Categories     []domain.Category
+	Targets        []string
+}
+
+type Service struct {
+	ruleDB       RuleDB
+	attachmentDB AttachmentDB
+	categoryDB   CategoryDB
+	teamDB       TeamDB
+	revisionDB   RevisionDB
+}
+
+func NewService(ruleDB RuleDB, attachmentDB AttachmentDB, categoryDB CategoryDB, teamDB TeamDB, revisionDB RevisionDB) *Service {
+	return &Service{
+		ruleDB:       ruleDB,
+		attachmentDB: attachmentDB,
+		categoryDB:   categoryDB,
+		teamDB:       teamDB,
+		revisionDB:   revisionDB,
+	}
+}
+
+// Decision records whether a candidate rule is part of a team's rule set and why
+type Decision struct {
+	// Rule is the rule as delivered to agents, with the enforcement settings
+	// of the team's attachment applied when included
+	Rule     domain.Rule
+	Included bool
+	Reason   string
+}
+
+// Resolve computes the team's effective rule set: forced organization rules
+// (and the other organization rules when the team inherits them), the team's
+// own rules and rules attached to the team. Only approved rules and
+// attachments count. Rules whose effective end has passed are dropped; rules
+// that start in the future are kept so agents activate them on time.
+func (s *Service) Resolve(ctx context.Context, teamID string) (RuleSet, error) {
+	set, _, err := s.resolve(ctx, teamID, false, "")
+	return set, err
+}
+
+// Explain resolves the team's rule set like Resolve and also returns a
+// decision for every rule considered: the organization rules, the team's
+// rules and the rules attached to the team
+func (s *Service) Explain(ctx context.Context, teamID string) (RuleSet, []Decision, error) {
+	return s.resolve(ctx, teamID, true, "")
+}
+
+// ExplainApproved is like Explain, but resolves the rule set as it will be
+// once the rule, in its current state, and its pending attachment to the
+// team are approved
+func (s *Service) ExplainApproved(ctx context.Context, teamID, ruleID string) (RuleSet, []Decision, error) {
+	return s.resolve(ctx, teamID, true, ruleID)
+}
+
+// resolve computes the team's rule set. Decisions are only collected when
+// explain is set, which also looks up rules of unapproved attachments. The
+// rule approvedID and its pending attachment count as approved.
+func (s *Service) resolve(ctx context.Context, teamID string, explain bool, approvedID string) (RuleSet, []Decision, error) {
+	team, err := s.teamDB.GetTeam(ctx, teamID)
+	if err != nil {
+		return RuleSet{}, nil, err
+	}
+
+	attachments, err := s.attachmentDB.ListByTeam(ctx, teamID)
+	if err != nil {
+		return RuleSet{}, nil, err
+	}
+	attached := make(map[string]domain.RuleAttachment)
+	for _, a := range attachments {
+		if a.Status == domain.AttachmentStatusApproved || (a.RuleID == approvedID && a.Status == domain.AttachmentStatusPending) {
+			attached[a.RuleID] = a
+		}
+	}
+
+	globalRules, err := s.ruleDB.ListGlobalRules(ctx)
+	if err != nil {
+		return RuleSet{}, nil, err
+	}
+	teamRules, err := s.ruleDB.ListRulesByTeam(ctx, teamID)
+	if err != nil {
+		return RuleSet{}, nil, err
+	}
+
+	set := RuleSet{TeamID: teamID, Targets: team.Settings.EnabledTargets()}
+	var decisions []Decision
+	decide := func(rule domain.Rule, included bool, reason string) {
+		if explain {
+			decisions = append(decisions, Decision{Rule: rule, Included: included, Reason: reason})
+		}
+	}
+	now := time.Now()
+	seen := make(map[string]bool)
+	add := func(rule domain.Rule, reason string) {
+		if seen[rule.ID] {
+			return
+		}
+		seen[rule.ID] = true
+		if rule.Status != domain.RuleStatusApproved && rule.ID != approvedID {
+			decide(rule, false, fmt.Sprintf("rule is %s", rule.Status))
+			return
+		}
+		if expired(rule, now) {
+			decide(rule, false, "effective end has passed")
+			return
+		}
+		if a, ok := attached[rule.ID]; ok {
+			rule.EnforcementMode = a.EnforcementMode
+			rule.TemporaryTimeoutHours = a.TemporaryTimeoutHours
+		}
+		set.Rules = append(set.Rules, rule)
+		decide(rule, true, reason)
+	}
+
+	for _, rule := range globalRules {
+		// Library rules have no team either but only reach teams through attachments
+		if !rule.IsEnterprise() {
+			continue
+		}
+		switch {
+		case rule.Force:
+			add(rule, "organization rule forced on every team")
+		case team.Settings.InheritGlobalRules:
+			add(rule, "organization rule inherited by the team")
+		default:
+			// An attached rule is decided with the attachments
+			if _, ok := attached[rule.ID]; !ok {
+				decide(rule, false, "organization rule is not forced and the team does not inherit organization rules")
+			}
+		}
+	}
+	for _, rule := range teamRules {
+		add(rule, "team rule")
+	}
+	for _, a := range attachments {
+		if seen[a.RuleID] {
+			continue
+		}
+		_, approved := attached[a.RuleID]
+		if !approved && !explain {
+			continue
+		}
+		rule, err := s.ruleDB.GetRule(ctx, a.RuleID)
+		if err != nil {
+			return RuleSet{}, nil, err
+		}
+		if !approved {
+			seen[a.RuleID] = true
+			decide(rule, false, fmt.Sprintf("attachment to the team is %s", a.Status))
+			continue
+		}
+		add(rule, "attached to the team")
+	}
+
+	set.Categories, err = s.referencedCategories(ctx, set.Rules)
+	if err != nil {
+		return RuleSet{}, nil, err
+	}
+	return set, decisions, nil
+}
+
+// referencedCategories returns the categories used by the rules, in display order
+func (s *Service) referencedCategories(ctx context.Context, rules []domain.Rule) ([]domain.Category, error) {
+	used := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.CategoryID != nil {
+			used[*rule.CategoryID] = true
+		}
+	}
+	if len(used) == 0 {
+		return nil, nil
+	}
+
+	categories, err := s.categoryDB.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []domain.Category
+	for _, c := range categories {
+		if used[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func expired(rule domain.Rule, now time.Time) bool {
+	return rule.EffectiveEnd != nil && now.After(*rule.EffectiveEnd)
+}
+
+// Since commits the team's current configuration and returns what an agent
+// at revision since needs to reach it: the changed and removed rules, or the
+// full rule set when since is unknown, too far behind, or older than the
+// change log. Committing first records changes that were not announced by an
+// event, like a rule reaching its effective end.
+func (s *Service) Since(ctx context.Context, teamID string, since int64) (Update, error) {
+	set, rev, err := s.commit(ctx, teamID)
+	if err != nil {
+		return Update{}, err
+	}
+	return s.update(ctx, set, rev, since)
+}
+
+// commit resolves the team's rule set and records it as a new revision when
+// it differs from the last committed one
+func (s *Service) commit(ctx context.Context, teamID string) (RuleSet, domain.ConfigRevision, error) {
+	set, err := s.Resolve(ctx, teamID)
+	if err != nil {
+		return RuleSet{}, domain.ConfigRevision{}, err
+	}
+	state, err := configState(set)
+	if err != nil {
+		return RuleSet{}, domain.ConfigRevision{}, err
+	}
+	rev, err := s.revisionDB.CommitConfig(ctx, teamID, state, ChangeLogRetention)
+	if err != nil {
+		return RuleSet{}, domain.ConfigRevision{}, err
+	}
+	return set, rev, nil
+}
+
+func (s *Service) update(ctx context.Context, set RuleSet, rev domain.ConfigRevision, since int64) (Update, error) {
+	update := Update{
+		TeamID:     set.TeamID,
+		Revision:   rev.Revision,
+		Since:      since,
+		Categories: set.Categories,
+		Targets:    set.Targets,
+	}
+	full := func() Update {
+		update.Full = true
+		update.Since = 0
+		update.Rules = set.Rules
+		return update
+	}
+
+	if since <= 0 || since > rev.Revision || since < rev.PrunedThrough || rev.Revision-since > MaxDeltaRevisions {
+		return full(), nil
+	}
+	if since == rev.Revision {
+		return update, nil
+	}
+
+	changes, err := s.revisionDB.ListConfigChanges(ctx, set.TeamID, since)
+	if err != nil {
+		return Update{}, err
+	}
+	upserted, removed := domain.CollapseChanges(changes)
+	if len(set.Rules) > 0 && len(upserted)+len(removed) > len(set.Rules) {
+		// The delta would not be smaller than the rule set itself
+		return full(), nil
+	}
+
+	rules := make(map[string]domain.Rule, len(set.Rules))
+	for _, r := range set.Rules {
+		rules[r.ID] = r
+	}
+	for _, ruleID := range upserted {
+		if r, ok := rules[ruleID]; ok {
+			update.Rules = append(update.Rules, r)
+		} else {
+			// Removed by a commit after the one being served
+			removed = append(removed, ruleID)
+		}
+	}
+	update.RemovedRuleIDs = removed
+	return update, nil
+}
+
+// configState checksums each rule as delivered to agents, and the categories
+// and targets that apply to all of them
+func configState(set RuleSet) (domain.TeamConfigState, error) {
+	state := domain.TeamConfigState{Rules: make(map[string]string, len(set.Rules))}
+	for _, r := range set.Rules {
+		sum, err := checksum(r)
+		if err != nil {
+			return domain.TeamConfigState{}, err
+		}
+		state.Rules[r.ID] = sum
+	}
+
+	settings, err := checksum(struct {
+		Categories []domain.Category `json:"categories"`
+		Targets    []string          `json:"targets"`
+	}{set.Categories, set.Targets})
+	if err != nil {
+		return domain.TeamConfigState{}, err
+	}
+	state.Settings = settings
+	return state, nil
+}
+
+func checksum(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,430 @@
+package rulesets_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+type mockRuleDB struct {
+	rules map[string]domain.Rule
+}
+
+func (m *mockRuleDB) GetRule(ctx context.Context, id string) (domain.Rule, error) {
+	if rule, ok := m.rules[id]; ok {
+		return rule, nil
+	}
+	return domain.Rule{}, errors.New("rule not found")
+}
+
+func (m *mockRuleDB) ListRulesByTeam(ctx context.Context, teamID string) ([]domain.Rule, error) {
+	var result []domain.Rule
+	for _, rule := range m.rules {
+		if rule.TeamID != nil && *rule.TeamID == teamID {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRuleDB) ListGlobalRules(ctx context.Context) ([]domain.Rule, error) {
+	var result []domain.Rule
+	for _, rule := range m.rules {
+		if rule.IsGlobal() {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+type mockAttachmentDB struct {
+	attachments []domain.RuleAttachment
+}
+
+func (m *mockAttachmentDB) ListByTeam(ctx context.Context, teamID string) ([]domain.RuleAttachment, error) {
+	var result []domain.RuleAttachment
+	for _, a := range m.attachments {
+		if a.TeamID == teamID {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+type mockCategoryDB struct {
+	categories []domain.Category
+}
+
+func (m *mockCategoryDB) ListAll(ctx context.Context) ([]domain.Category, error) {
+	return m.categories, nil
+}
+
+type mockTeamDB struct {
+	teams map[string]domain.Team
+}
+
+func (m *mockTeamDB) GetTeam(ctx context.Context, id string) (domain.Team, error) {
+	if team, ok := m.teams[id]; ok {
+		return team, nil
+	}
+	return domain.Team{}, errors.New("team not found")
+}
+
+// mockRevisionDB commits configurations in memory like the postgres adapter
+type mockRevisionDB struct {
+	revision      int64
+	prunedThrough int64
+	state         domain.TeamConfigState
+	changes       []domain.ConfigChange
+}
+
+func newMockRevisionDB() *mockRevisionDB {
+	return &mockRevisionDB{}
+}
+
+func (m *mockRevisionDB) CommitConfig(ctx context.Context, teamID string, state domain.TeamConfigState, retention int64) (domain.ConfigRevision, error) {
+	result := domain.ConfigRevision{TeamID: teamID, Revision: m.revision, Previous: m.revision, PrunedThrough: m.prunedThrough}
+	changes, settingsChanged := state.Diff(m.state)
+	if len(changes) == 0 && !settingsChanged {
+		return result, nil
+	}
+	m.revision++
+	for _, c := range changes {
+		c.TeamID = teamID
+		c.Revision = m.revision
+		m.changes = append(m.changes, c)
+	}
+	m.state = state
+	if retention > 0 && m.revision-retention > m.prunedThrough {
+		m.prunedThrough = m.revision - retention
+	}
+	result.Revision = m.revision
+	result.PrunedThrough = m.prunedThrough
+	return result, nil
+}
+
+func (m *mockRevisionDB) ListConfigChanges(ctx context.Context, teamID string, since int64) ([]domain.ConfigChange, error) {
+	var result []domain.ConfigChange
+	for _, c := range m.changes {
+		if c.TeamID == teamID && c.Revision > since {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func approved(rule domain.Rule) domain.Rule {
+	rule.Status = domain.RuleStatusApproved
+	return rule
+}
+
+func ruleIDs(rules []domain.Rule) map[string]domain.Rule {
+	ids := make(map[string]domain.Rule)
+	for _, r := range rules {
+		ids[r.ID] = r
+	}
+	return ids
+}
+
+func TestResolve(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	categoryID := "cat-security"
+
+	team := approved(domain.NewRule("Team rule", domain.TargetLayerTeam, "a", nil, "team-1"))
+	team.CategoryID = &categoryID
+	draft := domain.NewRule("Draft rule", domain.TargetLayerTeam, "b", nil, "team-1")
+	other := approved(domain.NewRule("Other team", domain.TargetLayerTeam, "c", nil, "team-2"))
+	expiredRule := approved(domain.NewRule("Expired", domain.TargetLayerProject, "d", nil, "team-1"))
+	expiredRule.EffectiveEnd = &past
+	upcoming := approved(domain.NewRule("Upcoming", domain.TargetLayerProject, "e", nil, "team-1"))
+	upcoming.EffectiveStart = &future
+	forced := approved(domain.NewGlobalRule("Forced", "f", true))
+	inherited := approved(domain.NewGlobalRule("Inherited", "g", false))
+	attachedRule := approved(domain.NewLibraryRule("Attached", domain.TargetLayerProject, "h", nil, "admin"))
+	pendingAttached := approved(domain.NewLibraryRule("Pending", domain.TargetLayerProject, "i", nil, "admin"))
+
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{}}
+	for _, r := range []domain.Rule{team, draft, other, expiredRule, upcoming, forced, inherited, attachedRule, pendingAttached} {
+		ruleDB.rules[r.ID] = r
+	}
+
+	attachment := domain.NewApprovedAttachment(attachedRule.ID, "team-1", domain.EnforcementModeWarning, "admin")
+	attachment.TemporaryTimeoutHours = 4
+	attachmentDB := &mockAttachmentDB{attachments: []domain.RuleAttachment{
+		attachment,
+		domain.NewRuleAttachment(pendingAttached.ID, "team-1", domain.EnforcementModeBlock, "admin"),
+	}}
+	categoryDB := &mockCategoryDB{categories: []domain.Category{
+		{ID: categoryID, Name: "Security", DisplayOrder: 1},
+		{ID: "cat-unused", Name: "Unused", DisplayOrder: 2},
+	}}
+
+	tests := []struct {
+		name    string
+		inherit bool
+		want    []string
+	}{
+		{"inherits global rules", true, []string{team.ID, upcoming.ID, forced.ID, inherited.ID, attachedRule.ID}},
+		{"forced global rules only", false, []string{team.ID, upcoming.ID, forced.ID, attachedRule.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teamDB := &mockTeamDB{teams: map[string]domain.Team{
+				"team-1": {ID: "team-1", Settings: domain.TeamSettings{
+					InheritGlobalRules: tt.inherit,
+					OutputTargets:      []string{"claude", "cursor"},
+				}},
+			}}
+			svc := rulesets.NewService(ruleDB, attachmentDB, categoryDB, teamDB, newMockRevisionDB())
+
+			set, err := svc.Resolve(context.Background(), "team-1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := ruleIDs(set.Rules)
+			if len(got) != len(tt.want) || len(set.Rules) != len(tt.want) {
+				t.Fatalf("expected %d rules, got %d", len(tt.want), len(set.Rules))
+			}
+			for _, id := range tt.want {
+				if _, ok := got[id]; !ok {
+					t.Errorf("expected rule %s in the rule set", id)
+				}
+			}
+
+			if r := got[attachedRule.ID]; r.EnforcementMode != domain.EnforcementModeWarning || r.TemporaryTimeoutHours != 4 {
+				t.Errorf("expected attachment enforcement to apply, got %s/%d", r.EnforcementMode, r.TemporaryTimeoutHours)
+			}
+			if len(set.Categories) != 1 || set.Categories[0].ID != categoryID {
+				t.Errorf("expected only the referenced category, got %+v", set.Categories)
+			}
+			if len(set.Targets) != 2 || set.Targets[1] != "cursor" {
+				t.Errorf("expected team targets, got %v", set.Targets)
+			}
+		})
+	}
+}
+
+func TestResolve_AttachedTeamRule(t *testing.T) {
+	rule := approved(domain.NewRule("Team rule", domain.TargetLayerTeam, "a", nil, "team-1"))
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{rule.ID: rule}}
+	attachmentDB := &mockAttachmentDB{attachments: []domain.RuleAttachment{
+		domain.NewApprovedAttachment(rule.ID, "team-1", domain.EnforcementModeTemporary, "admin"),
+	}}
+	teamDB := &mockTeamDB{teams: map[string]domain.Team{"team-1": {ID: "team-1"}}}
+	svc := rulesets.NewService(ruleDB, attachmentDB, &mockCategoryDB{}, teamDB, newMockRevisionDB())
+
+	set, err := svc.Resolve(context.Background(), "team-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Rules) != 1 {
+		t.Fatalf("expected rule once, got %d", len(set.Rules))
+	}
+	if set.Rules[0].EnforcementMode != domain.EnforcementModeTemporary {
+		t.Errorf("expected attachment enforcement mode, got %s", set.Rules[0].EnforcementMode)
+	}
+	if len(set.Targets) != 1 || set.Targets[0] != "claude" {
+		t.Errorf("expected default targets, got %v", set.Targets)
+	}
+}
+
+func TestResolve_UnknownTeam(t *testing.T) {
+	svc := rulesets.NewService(&mockRuleDB{}, &mockAttachmentDB{}, &mockCategoryDB{}, &mockTeamDB{}, newMockRevisionDB())
+
+	if _, err := svc.Resolve(context.Background(), "missing"); err == nil {
+		t.Error("expected error for unknown team")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	team := approved(domain.NewRule("Team rule", domain.TargetLayerTeam, "a", nil, "team-1"))
+	draft := domain.NewRule("Draft rule", domain.TargetLayerTeam, "b", nil, "team-1")
+	expiredRule := approved(domain.NewRule("Expired", domain.TargetLayerProject, "c", nil, "team-1"))
+	expiredRule.EffectiveEnd = &past
+	global := approved(domain.NewGlobalRule("Not inherited", "d", false))
+	attachedGlobal := approved(domain.NewGlobalRule("Attached global", "e", false))
+	pendingAttached := approved(domain.NewLibraryRule("Pending", domain.TargetLayerProject, "f", nil, "admin"))
+
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{}}
+	for _, r := range []domain.Rule{team, draft, expiredRule, global, attachedGlobal, pendingAttached} {
+		ruleDB.rules[r.ID] = r
+	}
+	attachmentDB := &mockAttachmentDB{attachments: []domain.RuleAttachment{
+		domain.NewApprovedAttachment(attachedGlobal.ID, "team-1", domain.EnforcementModeBlock, "admin"),
+		domain.NewRuleAttachment(pendingAttached.ID, "team-1", domain.EnforcementModeBlock, "admin"),
+	}}
+	teamDB := &mockTeamDB{teams: map[string]domain.Team{"team-1": {ID: "team-1"}}}
+	svc := rulesets.NewService(ruleDB, attachmentDB, &mockCategoryDB{}, teamDB, newMockRevisionDB())
+
+	set, decisions, err := svc.Explain(context.Background(), "team-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Rules) != 2 {
+		t.Errorf("expected the team rule and the attached rule, got %d rules", len(set.Rules))
+	}
+
+	want := map[string]string{
+		team.ID:            "team rule",
+		draft.ID:           "rule is draft",
+		expiredRule.ID:     "effective end has passed",
+		global.ID:          "organization rule is not forced and the team does not inherit organization rules",
+		attachedGlobal.ID:  "attached to the team",
+		pendingAttached.ID: "attachment to the team is pending",
+	}
+	if len(decisions) != len(want) {
+		t.Fatalf("expected one decision per rule, got %d", len(decisions))
+	}
+	for _, d := range decisions {
+		if d.Reason != want[d.Rule.ID] {
+			t.Errorf("rule %s: expected %q, got %q", d.Rule.Name, want[d.Rule.ID], d.Reason)
+		}
+		if d.Included != (d.Rule.ID == team.ID || d.Rule.ID == attachedGlobal.ID) {
+			t.Errorf("rule %s: unexpected included=%v", d.Rule.Name, d.Included)
+		}
+	}
+
+	// Once approved, the draft rule and the pending attachment apply
+	for _, id := range []string{draft.ID, pendingAttached.ID} {
+		set, _, err := svc.ExplainApproved(context.Background(), "team-1", id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := ruleIDs(set.Rules)[id]; !ok || len(set.Rules) != 3 {
+			t.Errorf("expected rule %s to apply once approved, got %d rules", ruleDB.rules[id].Name, len(set.Rules))
+		}
+	}
+}
+
+func newRevisionTestService(ruleDB *mockRuleDB, revisionDB *mockRevisionDB) *rulesets.Service {
+	teamDB := &mockTeamDB{teams: map[string]domain.Team{"team-1": {ID: "team-1"}}}
+	return rulesets.NewService(ruleDB, &mockAttachmentDB{}, &mockCategoryDB{}, teamDB, revisionDB)
+}
+
+func TestSince_CommitsRevisions(t *testing.T) {
+	a := approved(domain.NewRule("A", domain.TargetLayerTeam, "a", nil, "team-1"))
+	b := approved(domain.NewRule("B", domain.TargetLayerTeam, "b", nil, "team-1"))
+	c := approved(domain.NewRule("C", domain.TargetLayerTeam, "c", nil, "team-1"))
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{a.ID: a, b.ID: b, c.ID: c}}
+	svc := newRevisionTestService(ruleDB, newMockRevisionDB())
+	ctx := context.Background()
+
+	update, err := svc.Since(ctx, "team-1", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Revision != 1 || !update.Full || len(update.Rules) != 3 {
+		t.Fatalf("expected a full snapshot at revision 1, got %+v", update)
+	}
+
+	update, err = svc.Since(ctx, "team-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Revision != 1 || update.Full || len(update.Rules) != 0 {
+		t.Errorf("expected no new revision when nothing changed, got %+v", update)
+	}
+
+	a.Content = "a2"
+	ruleDB.rules[a.ID] = a
+	delete(ruleDB.rules, b.ID)
+
+	update, err = svc.Since(ctx, "team-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Revision != 2 || update.Full || update.Since != 1 {
+		t.Fatalf("expected delta from 1 to 2, got %+v", update)
+	}
+	if len(update.Rules) != 1 || update.Rules[0].Content != "a2" {
+		t.Errorf("expected updated rule in delta, got %+v", update.Rules)
+	}
+	if len(update.RemovedRuleIDs) != 1 || update.RemovedRuleIDs[0] != b.ID {
+		t.Errorf("expected removed rule in delta, got %v", update.RemovedRuleIDs)
+	}
+}
+
+func TestSince(t *testing.T) {
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{}}
+	var ids []string
+	for _, name := range []string{"A", "B", "C", "D"} {
+		r := approved(domain.NewRule(name, domain.TargetLayerTeam, name, nil, "team-1"))
+		ruleDB.rules[r.ID] = r
+		ids = append(ids, r.ID)
+	}
+	revisionDB := newMockRevisionDB()
+	svc := newRevisionTestService(ruleDB, revisionDB)
+	ctx := context.Background()
+
+	if _, err := svc.Since(ctx, "team-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	r := ruleDB.rules[ids[0]]
+	r.Content = "changed"
+	ruleDB.rules[ids[0]] = r
+	if _, err := svc.Since(ctx, "team-1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		since     int64
+		pruned    int64
+		wantFull  bool
+		wantRules int
+	}{
+		{"unknown revision", 0, 0, true, 4},
+		{"ahead of server", 7, 0, true, 4},
+		{"current", 2, 0, false, 0},
+		{"one behind", 1, 0, false, 1},
+		{"older than change log", 1, 2, true, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revisionDB.prunedThrough = tt.pruned
+
+			update, err := svc.Since(ctx, "team-1", tt.since)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if update.Revision != 2 {
+				t.Errorf("expected revision 2, got %d", update.Revision)
+			}
+			if update.Full != tt.wantFull || len(update.Rules) != tt.wantRules {
+				t.Errorf("expected full=%v with %d rules, got full=%v with %d", tt.wantFull, tt.wantRules, update.Full, len(update.Rules))
+			}
+		})
+	}
+}
+
+func TestSince_LargeGapFallsBackToFullSnapshot(t *testing.T) {
+	rule := approved(domain.NewRule("A", domain.TargetLayerTeam, "a", nil, "team-1"))
+	ruleDB := &mockRuleDB{rules: map[string]domain.Rule{rule.ID: rule}}
+	svc := newRevisionTestService(ruleDB, newMockRevisionDB())
+	ctx := context.Background()
+
+	if _, err := svc.Since(ctx, "team-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the only rule: two changes against a rule set of one
+	delete(ruleDB.rules, rule.ID)
+	other := approved(domain.NewRule("B", domain.TargetLayerTeam, "b", nil, "team-1"))
+	ruleDB.rules[other.ID] = other
+
+	update, err := svc.Since(ctx, "team-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !update.Full || len(update.Rules) != 1 || update.Rules[0].ID != other.ID {
+		t.Errorf("expected full snapshot, got %+v", update)
+	}
+}
@@ -0,0 +1,166 @@
+package ruleversions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kamilrybacki/edictflow/pkg/diff"
+	"github.com/kamilrybacki/edictflow/server/domain"
+)
+
+var (
+	ErrVersionNotFound = errors.New("rule version not found")
+	ErrRulePending     = errors.New("rule is pending approval")
+	ErrAlreadyCurrent  = errors.New("rule is already at this version")
+)
+
+// VersionDB reads the versions recorded each time a rule is approved
+type VersionDB interface {
+	ListVersions(ctx context.Context, ruleID string) ([]domain.RuleVersion, error)
+	GetVersion(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error)
+	CountAgentsByVersion(ctx context.Context, ruleID string) (map[int]int, error)
+}
+
+type RuleDB interface {
+	GetRule(ctx context.Context, id string) (domain.Rule, error)
+	UpdateRule(ctx context.Context, rule domain.Rule) error
+}
+
+// Approvals sends a rolled back rule through approval
+type Approvals interface {
+	ResetRule(ctx context.Context, ruleID string) error
+	SubmitRule(ctx context.Context, ruleID string) error
+}
+
+type AuditLogger interface {
+	LogUpdate(ctx context.Context, entityType domain.AuditEntityType, entityID string, actorID *string, changes map[string]*domain.ChangeValue, metadata map[string]interface{}) error
+}
+
+// Version is a rule version with the number of agents that last reported
+// applying it
+type Version struct {
+	domain.RuleVersion
+	Agents int
+}
+
+// Diff is what changed in a rule from one version to another
+type Diff struct {
+	RuleID string
+	From   int
+	To     int
+	// Changes are the changed fields other than the content
+	Changes map[string]*domain.ChangeValue
+	// Content is a unified diff of the content, empty when it is unchanged
+	Content string
+}
+
+type Service struct {
+	versionDB VersionDB
+	ruleDB    RuleDB
+	approvals Approvals
+	auditLog  AuditLogger
+}
+
+func NewService(versionDB VersionDB, ruleDB RuleDB, approvals Approvals) *Service {
+	return &Service{versionDB: versionDB, ruleDB: ruleDB, approvals: approvals}
+}
+
+func (s *Service) WithAuditLogger(logger AuditLogger) *Service {
+	s.auditLog = logger
+	return s
+}
+
+// List returns the rule's versions, newest first
+func (s *Service) List(ctx context.Context, ruleID string) ([]Version, error) {
+	if _, err := s.ruleDB.GetRule(ctx, ruleID); err != nil {
+		return nil, err
+	}
+	versions, err := s.versionDB.ListVersions(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.versionDB.CountAgentsByVersion(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Version, len(versions))
+	for i, v := range versions {
+		result[i] = Version{RuleVersion: v, Agents: agents[v.Version]}
+	}
+	return result, nil
+}
+
+func (s *Service) Get(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error) {
+	return s.versionDB.GetVersion(ctx, ruleID, version)
+}
+
+// Diff compares two versions of a rule
+func (s *Service) Diff(ctx context.Context, ruleID string, from, to int) (Diff, error) {
+	a, err := s.versionDB.GetVersion(ctx, ruleID, from)
+	if err != nil {
+		return Diff{}, err
+	}
+	b, err := s.versionDB.GetVersion(ctx, ruleID, to)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	return Diff{
+		RuleID:  ruleID,
+		From:    from,
+		To:      to,
+		Changes: a.Changes(b),
+		Content: diff.Unified(fmt.Sprintf("version %d", from), fmt.Sprintf("version %d", to), a.Content, b.Content),
+	}, nil
+}
+
+// Rollback restores a previous version of the rule and submits it for
+// approval. Once approved it becomes the rule's next version; until then the
+// rule is pending like any other revision.
+func (s *Service) Rollback(ctx context.Context, ruleID string, version int, actorID string) (domain.Rule, error) {
+	rule, err := s.ruleDB.GetRule(ctx, ruleID)
+	if err != nil {
+		return domain.Rule{}, err
+	}
+	if rule.Status == domain.RuleStatusPending {
+		return domain.Rule{}, ErrRulePending
+	}
+	if rule.Status == domain.RuleStatusApproved && rule.CurrentVersion == version {
+		return domain.Rule{}, ErrAlreadyCurrent
+	}
+
+	target, err := s.versionDB.GetVersion(ctx, ruleID, version)
+	if err != nil {
+		return domain.Rule{}, err
+	}
+	var changes map[string]*domain.ChangeValue
+	if current, err := s.versionDB.GetVersion(ctx, ruleID, rule.CurrentVersion); err == nil {
+		changes = current.Changes(target)
+	}
+
+	if err := s.approvals.ResetRule(ctx, ruleID); err != nil {
+		return domain.Rule{}, err
+	}
+	rule, err = s.ruleDB.GetRule(ctx, ruleID)
+	if err != nil {
+		return domain.Rule{}, err
+	}
+	target.Restore(&rule)
+	if err := s.ruleDB.UpdateRule(ctx, rule); err != nil {
+		return domain.Rule{}, err
+	}
+	if err := s.approvals.SubmitRule(ctx, ruleID); err != nil {
+		return domain.Rule{}, err
+	}
+
+	if s.auditLog != nil {
+		_ = s.auditLog.LogUpdate(ctx, domain.AuditEntityRule, ruleID, &actorID, changes, map[string]interface{}{
+			"rollback_from": rule.CurrentVersion,
+			"rollback_to":   version,
+		})
+	}
+
+	return s.ruleDB.GetRule(ctx, ruleID)
+}
@@ -0,0 +1,152 @@
+package ruleversions_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rules"
+	"github.com/kamilrybacki/edictflow/server/services/ruleversions"
+)
+
+type mockVersionDB struct {
+	versions map[int]domain.RuleVersion
+	agents   map[int]int
+}
+
+func (m *mockVersionDB) ListVersions(ctx context.Context, ruleID string) ([]domain.RuleVersion, error) {
+	var result []domain.RuleVersion
+	for n := len(m.versions); n > 0; n-- {
+		result = append(result, m.versions[n])
+	}
+	return result, nil
+}
+
+func (m *mockVersionDB) GetVersion(ctx context.Context, ruleID string, version int) (domain.RuleVersion, error) {
+	v, ok := m.versions[version]
+	if !ok {
+		return domain.RuleVersion{}, ruleversions.ErrVersionNotFound
+	}
+	return v, nil
+}
+
+func (m *mockVersionDB) CountAgentsByVersion(ctx context.Context, ruleID string) (map[int]int, error) {
+	return m.agents, nil
+}
+
+type mockRuleDB struct {
+	rule domain.Rule
+}
+
+func (m *mockRuleDB) GetRule(ctx context.Context, id string) (domain.Rule, error) {
+	if id != m.rule.ID {
+		return domain.Rule{}, rules.ErrRuleNotFound
+	}
+	return m.rule, nil
+}
+
+func (m *mockRuleDB) UpdateRule(ctx context.Context, rule domain.Rule) error {
+	m.rule = rule
+	return nil
+}
+
+type mockApprovals struct {
+	ruleDB *mockRuleDB
+}
+
+func (m *mockApprovals) ResetRule(ctx context.Context, ruleID string) error {
+	m.ruleDB.rule.ResetToDraft()
+	return nil
+}
+
+func (m *mockApprovals) SubmitRule(ctx context.Context, ruleID string) error {
+	m.ruleDB.rule.Submit()
+	return nil
+}
+
+func newTestService() (*ruleversions.Service, *mockRuleDB) {
+	versionDB := &mockVersionDB{
+		versions: map[int]domain.RuleVersion{
+			1: {RuleID: "rule-1", Version: 1, Name: "Secrets", Content: "Never commit secrets\n", EnforcementMode: domain.EnforcementModeWarning},
+			2: {RuleID: "rule-1", Version: 2, Name: "Secrets", Content: "Never commit secrets or keys\n", EnforcementMode: domain.EnforcementModeBlock},
+		},
+		agents: map[int]int{2: 3},
+	}
+	ruleDB := &mockRuleDB{rule: domain.Rule{
+		ID:              "rule-1",
+		Name:            "Secrets",
+		Content:         "Never commit secrets or keys\n",
+		Status:          domain.RuleStatusApproved,
+		EnforcementMode: domain.EnforcementModeBlock,
+		CurrentVersion:  2,
+	}}
+	return ruleversions.NewService(versionDB, ruleDB, &mockApprovals{ruleDB: ruleDB}), ruleDB
+}
+
+func TestService_List(t *testing.T) {
+	svc, _ := newTestService()
+
+	versions, err := svc.List(context.Background(), "rule-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Fatalf("expected versions newest first, got %+v", versions)
+	}
+	if versions[0].Agents != 3 || versions[1].Agents != 0 {
+		t.Errorf("unexpected agent counts: %d, %d", versions[0].Agents, versions[1].Agents)
+	}
+
+	if _, err := svc.List(context.Background(), "missing"); !errors.Is(err, rules.ErrRuleNotFound) {
+		t.Errorf("expected ErrRuleNotFound, got %v", err)
+	}
+}
+
+func TestService_Diff(t *testing.T) {
+	svc, _ := newTestService()
+
+	d, err := svc.Diff(context.Background(), "rule-1", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Changes) != 1 || d.Changes["enforcement_mode"] == nil {
+		t.Errorf("expected only the enforcement mode to change, got %v", d.Changes)
+	}
+	if !strings.Contains(d.Content, "-Never commit secrets\n+Never commit secrets or keys\n") {
+		t.Errorf("unexpected content diff:\n%s", d.Content)
+	}
+
+	if _, err := svc.Diff(context.Background(), "rule-1", 1, 5); !errors.Is(err, ruleversions.ErrVersionNotFound) {
+		t.Errorf("expected ErrVersionNotFound, got %v", err)
+	}
+}
+
+func TestService_RollbackGoesThroughApproval(t *testing.T) {
+	svc, ruleDB := newTestService()
+
+	rule, err := svc.Rollback(context.Background(), "rule-1", 1, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Status != domain.RuleStatusPending {
+		t.Errorf("expected the rollback to await approval, got %s", rule.Status)
+	}
+	if rule.Content != "Never commit secrets\n" || rule.EnforcementMode != domain.EnforcementModeWarning {
+		t.Errorf("expected version 1 to be restored, got %+v", rule)
+	}
+	if rule.RestoresVersion == nil || *rule.RestoresVersion != 1 {
+		t.Errorf("expected the rule to restore version 1, got %v", rule.RestoresVersion)
+	}
+
+	// A second rollback waits for the first to be decided
+	if _, err := svc.Rollback(context.Background(), "rule-1", 2, "user-1"); !errors.Is(err, ruleversions.ErrRulePending) {
+		t.Errorf("expected ErrRulePending, got %v", err)
+	}
+	ruleDB.rule.Approve()
+	ruleDB.rule.CurrentVersion = 3
+	if _, err := svc.Rollback(context.Background(), "rule-1", 3, "user-1"); !errors.Is(err, ruleversions.ErrAlreadyCurrent) {
+		t.Errorf("expected ErrAlreadyCurrent, got %v", err)
+	}
+}
@@ -455,6 +455,8 @@ func (m *MockRuleService) Create(ctx context.Context, req handlers.CreateRuleReq
 			Pattern:      t.Pattern,
 			ContextTypes: t.ContextTypes,
 			Tags:         t.Tags,
+			Branches:     t.Branches,
+			Negate:       t.Negate,
 		})
 	}
 	rule := domain.NewRule(req.Name, domain.TargetLayer(req.TargetLayer), req.Content, triggers, req.TeamID)
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultSlowConsumerTimeout is how long an agent's send buffer may stay full
+// before the agent is disconnected
+const DefaultSlowConsumerTimeout = 30 * time.Second
+
+// backlog tracks the messages an agent's full send buffer could not take
+type backlog struct {
+	mu sync.Mutex
+	// pending is the newest config update that did not fit. Each update
+	// brings the agent from its last sent revision to the current one, so it
+	// supersedes the update waiting before it.
+	pending         []byte
+	pendingRevision int64
+	// fullSince is when the buffer started refusing messages; zero while it
+	// accepts them
+	fullSince time.Time
+
+	dropped       atomic.Int64
+	disconnecting atomic.Bool
+}
+
+// SetSlowConsumerTimeout sets how long an agent's send buffer may stay full
+// before the agent is disconnected. Zero keeps slow agents connected.
+func (h *Hub) SetSlowConsumerTimeout(timeout time.Duration) {
+	h.slowConsumerTimeout = timeout
+}
+
+// deliver queues a message for the agent without blocking. A message that
+// does not fit is dropped and counted. Must be called with the lock held, or
+// from the agent's read loop.
+func (h *Hub) deliver(agent *AgentConn, data []byte) bool {
+	select {
+	case agent.Send <- data:
+		agent.backlog.mu.Lock()
+		agent.backlog.fullSince = time.Time{}
+		agent.backlog.mu.Unlock()
+		return true
+	default:
+		h.drop(agent, messageType(data))
+		return false
+	}
+}
+
+// deliverConfig queues a config update that brings the agent to revision. An
+// update that does not fit waits for room in the buffer, replacing the update
+// waiting before it. Must be called with the lock held.
+func (h *Hub) deliverConfig(agent *AgentConn, data []byte, revision int64) bool {
+	b := &agent.backlog
+	select {
+	case agent.Send <- data:
+		b.mu.Lock()
+		b.pending = nil
+		b.fullSince = time.Time{}
+		b.mu.Unlock()
+		return true
+	default:
+	}
+
+	b.mu.Lock()
+	b.pending, b.pendingRevision = data, revision
+	b.mu.Unlock()
+	h.drop(agent, messageType(data))
+	return false
+}
+
+// flushPending queues the config update that waited for room in the agent's
+// buffer. The write loop calls it after each write.
+func (h *Hub) flushPending(agent *AgentConn) {
+	b := &agent.backlog
+	b.mu.Lock()
+	waiting := b.pending != nil
+	b.mu.Unlock()
+	if !waiting {
+		return
+	}
+
+	// Holding the lock keeps the Send channel from being closed while
+	// sending
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.connections[agent.ID]; !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		return
+	}
+	select {
+	case agent.Send <- b.pending:
+		agent.revision = b.pendingRevision
+		b.pending = nil
+		b.fullSince = time.Time{}
+	default:
+	}
+}
+
+// clearPending discards a waiting config update that no longer applies,
+// such as one for the agent's previous team
+func (b *backlog) clearPending() {
+	b.mu.Lock()
+	b.pending = nil
+	b.mu.Unlock()
+}
+
+// drop counts a message the agent's buffer refused and disconnects the agent
+// once its buffer has stayed full for longer than the slow consumer timeout
+func (h *Hub) drop(agent *AgentConn, msgType string) {
+	b := &agent.backlog
+	total := b.dropped.Add(1)
+	h.metrics.RecordDroppedMessage(agent.identity(), agent.TeamID, msgType, total)
+
+	now := time.Now()
+	b.mu.Lock()
+	if b.fullSince.IsZero() {
+		b.fullSince = now
+	}
+	slow := h.slowConsumerTimeout > 0 && now.Sub(b.fullSince) >= h.slowConsumerTimeout
+	b.mu.Unlock()
+
+	if slow && b.disconnecting.CompareAndSwap(false, true) {
+		go h.disconnectSlow(agent, agent.identity(), agent.TeamID)
+	}
+}
+
+// disconnectSlow closes the connection of an agent that stopped keeping up.
+// Its queued messages are discarded; the agent syncs from the revision it has
+// cached when it reconnects.
+func (h *Hub) disconnectSlow(agent *AgentConn, agentID, teamID string) {
+	log.Printf("Disconnecting slow agent %s after %d dropped messages", agentID, agent.backlog.dropped.Load())
+	h.metrics.RecordAgentConnection(agentID, teamID, "slow_consumer")
+
+	h.Unregister(agent)
+	if agent.conn != nil {
+		_ = agent.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"),
+			time.Now().Add(time.Second))
+		agent.conn.Close()
+	}
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHub_CoalescesConfigUpdatesForSlowAgent(t *testing.T) {
+	hub := NewHub(nil)
+	rules := &fakeRuleSets{revision: 2}
+	hub.SetRuleSets(rules)
+
+	agent := &AgentConn{ID: "conn-1", TeamID: "team-1", Send: make(chan []byte, 1)}
+	agent.Send <- []byte(`{"type":"ack"}`)
+	hub.connections[agent.ID] = agent
+	hub.teamAgents["team-1"] = map[*AgentConn]struct{}{agent: {}}
+
+	if got := hub.broadcastUpdates("team-1"); got != 0 {
+		t.Fatalf("expected no recipients with a full buffer, got %d", got)
+	}
+	rules.revision = 3
+	hub.broadcastUpdates("team-1")
+
+	if agent.revision != 0 {
+		t.Errorf("expected the revision to wait for delivery, got %d", agent.revision)
+	}
+	if got := agent.backlog.dropped.Load(); got != 2 {
+		t.Errorf("expected 2 dropped messages, got %d", got)
+	}
+
+	// The buffer drains and only the newest update is sent
+	<-agent.Send
+	hub.flushPending(agent)
+	select {
+	case data := <-agent.Send:
+		var msg struct {
+			Payload struct {
+				Version int `json:"version"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Payload.Version != 3 {
+			t.Errorf("expected the update to version 3, got %d", msg.Payload.Version)
+		}
+	default:
+		t.Fatal("expected the waiting update to be sent")
+	}
+	if agent.revision != 3 {
+		t.Errorf("expected revision 3 after delivery, got %d", agent.revision)
+	}
+
+	hub.flushPending(agent)
+	if len(agent.Send) != 0 {
+		t.Error("expected the update to be sent once")
+	}
+}
+
+func TestHub_DisconnectsAgentWhoseBufferStaysFull(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetSlowConsumerTimeout(time.Millisecond)
+
+	agent := &AgentConn{ID: "conn-1", AgentID: "agent-1", Send: make(chan []byte, 1)}
+	hub.handleRegister(agent)
+
+	if !hub.deliver(agent, []byte(`{"type":"command"}`)) {
+		t.Fatal("expected the first message to fit")
+	}
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+	time.Sleep(5 * time.Millisecond)
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+
+	select {
+	case got := <-hub.unregister:
+		if got != agent {
+			t.Errorf("expected conn-1 to be disconnected, got %s", got.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the slow agent to be disconnected")
+	}
+
+	infos := hub.ListAgents()
+	if len(infos) != 1 || infos[0].DroppedMessages != 2 {
+		t.Errorf("expected 2 dropped messages to be reported, got %+v", infos)
+	}
+}
+
+func TestHub_KeepsAgentThatCatchesUp(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetSlowConsumerTimeout(time.Millisecond)
+
+	agent := &AgentConn{ID: "conn-1", AgentID: "agent-1", Send: make(chan []byte, 1)}
+	hub.handleRegister(agent)
+
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+	time.Sleep(5 * time.Millisecond)
+	<-agent.Send
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+	hub.deliver(agent, []byte(`{"type":"command"}`))
+
+	select {
+	case <-hub.unregister:
+		t.Fatal("expected an agent whose buffer drained to stay connected")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/kamilrybacki/edictflow/server/events"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
+	"github.com/redis/go-redis/v9"
+)
+
+// subscribeDirect opens the subscription carrying broadcast events and the
+// direct messages of agents connected to this worker. With the event stream,
+// broadcast events arrive through the stream instead.
+func (h *Hub) subscribeDirect() {
+	if h.redisClient == nil {
+		return
+	}
+
+	var sub *redis.PubSub
+	if h.streamGroup != "" {
+		sub = h.redisClient.Subscribe(h.ctx)
+	} else {
+		sub = h.redisClient.Subscribe(h.ctx, events.ChannelBroadcast)
+		h.metrics.RecordRedisSubscription(events.ChannelBroadcast, "subscribe")
+	}
+
+	h.mu.Lock()
+	h.direct = sub
+	// Agents identified before the subscription was opened
+	for agentID := range h.agents {
+		h.subscribeToAgent(agentID)
+	}
+	h.mu.Unlock()
+
+	go h.listenDirect(sub)
+}
+
+func (h *Hub) listenDirect(sub *redis.PubSub) {
+	for msg := range sub.Channel() {
+		if agentID, ok := events.AgentFromChannel(msg.Channel); ok {
+			h.sendToAgent(agentID, []byte(msg.Payload))
+			if messageType([]byte(msg.Payload)) == agents.MessageTypeAgentRevoked {
+				h.disconnectAgent(agentID)
+			}
+			continue
+		}
+
+		event, err := events.UnmarshalEvent([]byte(msg.Payload))
+		if err != nil {
+			log.Printf("Failed to unmarshal event: %v", err)
+			continue
+		}
+		h.broadcastToAll(event)
+	}
+}
+
+// SetAgentID records the agent ID an agent reported, making the agent
+// reachable through its direct channel. An agent's ID does not change once
+// set.
+func (h *Hub) SetAgentID(agent *AgentConn, agentID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if agentID == "" || agent.AgentID != "" {
+		return
+	}
+	agent.AgentID = agentID
+	if _, ok := h.connections[agent.ID]; !ok {
+		return
+	}
+
+	if _, ok := h.agents[agentID]; !ok {
+		h.subscribeToAgent(agentID)
+	}
+	h.agents[agentID] = agent
+}
+
+// releaseAgentID hands the agent's ID over to another connection of the same
+// agent, or unsubscribes from its direct channel when there is none. Must be
+// called with the lock held, after the connection was removed.
+func (h *Hub) releaseAgentID(agent *AgentConn) {
+	if agent.AgentID == "" || h.agents[agent.AgentID] != agent {
+		return
+	}
+	for _, other := range h.connections {
+		if other.AgentID == agent.AgentID {
+			h.agents[agent.AgentID] = other
+			return
+		}
+	}
+	delete(h.agents, agent.AgentID)
+	h.unsubscribeFromAgent(agent.AgentID)
+}
+
+func (h *Hub) subscribeToAgent(agentID string) {
+	if h.direct == nil {
+		return
+	}
+	channel := events.ChannelForAgent(agentID)
+	if err := h.direct.Subscribe(h.ctx, channel); err != nil {
+		log.Printf("Failed to subscribe to %s: %v", channel, err)
+		return
+	}
+	h.metrics.RecordRedisSubscription(channel, "subscribe")
+}
+
+func (h *Hub) unsubscribeFromAgent(agentID string) {
+	if h.direct == nil {
+		return
+	}
+	channel := events.ChannelForAgent(agentID)
+	if err := h.direct.Unsubscribe(h.ctx, channel); err != nil {
+		log.Printf("Failed to unsubscribe from %s: %v", channel, err)
+		return
+	}
+	h.metrics.RecordRedisSubscription(channel, "unsubscribe")
+}
+
+// sendToAgent forwards a message published to an agent's direct channel to
+// every connection of the agent on this worker
+func (h *Hub) sendToAgent(agentID string, data []byte) (recipients int) {
+	msgType := messageType(data)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, agent := range h.connections {
+		if agent.AgentID != agentID {
+			continue
+		}
+		if !agent.accepts(msgType) {
+			log.Printf("Not sending %s to agent %s, which does not support it", msgType, agentID)
+			continue
+		}
+		if h.deliver(agent, data) {
+			recipients++
+		}
+	}
+	return recipients
+}
+
+// disconnectAgent closes every connection of the agent on this worker. The
+// messages already queued for them are written before the connections close.
+func (h *Hub) disconnectAgent(agentID string) {
+	h.mu.RLock()
+	var conns []*AgentConn
+	for _, agent := range h.connections {
+		if agent.AgentID == agentID {
+			conns = append(conns, agent)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, agent := range conns {
+		h.Unregister(agent)
+	}
+}
+
+func messageType(data []byte) string {
+	var msg struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(data, &msg)
+	return msg.Type
+}
+
+// broadcastToAll delivers a broadcast event to the agents of every team
+// connected to this worker
+func (h *Hub) broadcastToAll(event events.Event) {
+	h.mu.RLock()
+	teams := make([]string, 0, len(h.teamAgents))
+	for teamID := range h.teamAgents {
+		teams = append(teams, teamID)
+	}
+	h.mu.RUnlock()
+
+	for _, teamID := range teams {
+		h.broadcastToTeam(teamID, event)
+	}
+}
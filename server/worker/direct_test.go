@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"testing"
+)
+
+func TestHub_SendToAgent(t *testing.T) {
+	hub := NewHub(nil)
+
+	first := &AgentConn{ID: "conn-1", Send: make(chan []byte, 1)}
+	second := &AgentConn{ID: "conn-2", Send: make(chan []byte, 1)}
+	other := &AgentConn{ID: "conn-3", Send: make(chan []byte, 1)}
+	for _, agent := range []*AgentConn{first, second, other} {
+		hub.handleRegister(agent)
+	}
+	hub.SetAgentID(first, "agent-1")
+	hub.SetAgentID(second, "agent-1")
+	hub.SetAgentID(other, "agent-2")
+
+	// The ID is kept once set
+	hub.SetAgentID(other, "agent-1")
+	if other.AgentID != "agent-2" {
+		t.Errorf("expected agent ID to stay agent-2, got %s", other.AgentID)
+	}
+
+	if got := hub.sendToAgent("agent-1", []byte("hello")); got != 2 {
+		t.Errorf("expected 2 recipients, got %d", got)
+	}
+	if len(other.Send) != 0 {
+		t.Error("expected no message for another agent")
+	}
+	if got := hub.sendToAgent("agent-9", []byte("hello")); got != 0 {
+		t.Errorf("expected no recipients for an unknown agent, got %d", got)
+	}
+}
+
+func TestHub_UnregisterHandsAgentIDToRemainingConnection(t *testing.T) {
+	hub := NewHub(nil)
+
+	first := &AgentConn{ID: "conn-1", AgentID: "agent-1", Send: make(chan []byte, 1)}
+	second := &AgentConn{ID: "conn-2", AgentID: "agent-1", Send: make(chan []byte, 1)}
+	hub.handleRegister(first)
+	hub.handleRegister(second)
+
+	hub.handleUnregister(second)
+	if hub.agents["agent-1"] != first {
+		t.Fatal("expected the remaining connection to take over the agent ID")
+	}
+
+	hub.handleUnregister(first)
+	if _, ok := hub.agents["agent-1"]; ok {
+		t.Error("expected the agent ID to be released")
+	}
+}
+
+func TestHub_DisconnectAgentFlushesQueuedMessage(t *testing.T) {
+	hub := NewHub(nil)
+
+	revoked := &AgentConn{ID: "conn-1", Send: make(chan []byte, 1)}
+	other := &AgentConn{ID: "conn-2", Send: make(chan []byte, 1)}
+	hub.handleRegister(revoked)
+	hub.handleRegister(other)
+	hub.SetAgentID(revoked, "agent-1")
+	hub.SetAgentID(other, "agent-2")
+	go hub.Run()
+	defer hub.Stop()
+
+	hub.sendToAgent("agent-1", []byte(`{"type":"agent_revoked"}`))
+	hub.disconnectAgent("agent-1")
+	// A second unregister, as done when the read loop ends, is ignored
+	hub.Unregister(revoked)
+
+	if msg, ok := <-revoked.Send; !ok || string(msg) != `{"type":"agent_revoked"}` {
+		t.Fatalf("expected the queued message before the close, got %q", msg)
+	}
+	if _, ok := <-revoked.Send; ok {
+		t.Error("expected the revoked agent's connection to be closed")
+	}
+	if count, _, _ := hub.Stats(); count != 1 {
+		t.Errorf("expected 1 remaining connection, got %d", count)
+	}
+}
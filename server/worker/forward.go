@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+)
+
+// forwardTimeout bounds how long an agent's message waits for the master
+const forwardTimeout = 10 * time.Second
+
+// Forwarder hands agent messages the worker cannot process itself to the
+// master
+type Forwarder interface {
+	Forward(ctx context.Context, msg agentmessages.Message) error
+}
+
+// errNoMaster is returned for messages that need the master when the worker
+// has no forwarder, so agents keep them queued
+var errNoMaster = errors.New("no master configured")
+
+// SetForwarder makes the handler forward change and exception messages to
+// the master. Without one they are nacked for the agent to send again.
+func (h *Handler) SetForwarder(f Forwarder) {
+	h.forwarder = f
+}
+
+// forward passes a message to the master with the agent's identity
+func (h *Handler) forward(agent *AgentConn, id, msgType string, payload json.RawMessage) error {
+	if h.forwarder == nil {
+		return errNoMaster
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+	defer cancel()
+	return h.forwarder.Forward(ctx, agentmessages.Message{
+		ID:       id,
+		Type:     msgType,
+		AgentID:  agent.identity(),
+		UserID:   agent.UserID,
+		TeamID:   agent.TeamID,
+		Hostname: agent.Hostname,
+		Payload:  payload,
+	})
+}
+
+// sendNack tells the agent a message failed, and whether to send it again
+func (h *Handler) sendNack(agent *AgentConn, refID string, err error) {
+	nack, _ := ws.NewMessage(ws.TypeNack, ws.NackPayload{
+		RefID:  refID,
+		Reason: err.Error(),
+		Retry:  !errors.Is(err, agentmessages.ErrRejected),
+	})
+	data, _ := json.Marshal(nack)
+	// Without an ack the agent retries anyway
+	h.hub.deliver(agent, data)
+}
+
+// MasterClient forwards agent messages to the master's internal API
+type MasterClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewMasterClient creates a client for the master at baseURL, authenticating
+// with the shared internal token
+func NewMasterClient(baseURL, token string) *MasterClient {
+	return &MasterClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: forwardTimeout},
+	}
+}
+
+// Forward posts the message to the master. Messages the master refuses as
+// invalid return an error wrapping agentmessages.ErrRejected.
+func (c *MasterClient) Forward(ctx context.Context, msg agentmessages.Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/agent-messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.InternalTokenHeader, c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("master returned %d: %s", resp.StatusCode, strings.TrimSpace(string(reason)))
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return fmt.Errorf("%w: %v", agentmessages.ErrRejected, err)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+)
+
+type recordingForwarder struct {
+	err      error
+	messages []agentmessages.Message
+}
+
+func (f *recordingForwarder) Forward(ctx context.Context, msg agentmessages.Message) error {
+	f.messages = append(f.messages, msg)
+	return f.err
+}
+
+func TestHandler_ForwardsAgentMessages(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantType  string
+		wantRetry bool
+	}{
+		{name: "processed", wantType: "ack"},
+		{name: "master unavailable", err: errors.New("connection refused"), wantType: "nack", wantRetry: true},
+		{name: "rejected", err: fmt.Errorf("%w: rule not found", agentmessages.ErrRejected), wantType: "nack"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarder := &recordingForwarder{err: tt.err}
+			handler := NewHandler(nil)
+			handler.SetForwarder(forwarder)
+
+			agent := &AgentConn{UserID: "user-1", AgentID: "agent-1", TeamID: "team-1", Send: make(chan []byte, 1)}
+			handler.handleMessage(agent, []byte(`{"type":"change_detected","id":"msg-1","payload":{"rule_id":"rule-1"}}`))
+
+			if len(forwarder.messages) != 1 {
+				t.Fatalf("expected 1 forwarded message, got %d", len(forwarder.messages))
+			}
+			msg := forwarder.messages[0]
+			if msg.AgentID != "agent-1" || msg.UserID != "user-1" || msg.TeamID != "team-1" || msg.ID != "msg-1" {
+				t.Errorf("expected the connection identity, got %+v", msg)
+			}
+			if string(msg.Payload) != `{"rule_id":"rule-1"}` {
+				t.Errorf("unexpected payload: %s", msg.Payload)
+			}
+
+			var reply struct {
+				Type    string `json:"type"`
+				Payload struct {
+					RefID string `json:"ref_id"`
+					Retry bool   `json:"retry"`
+				} `json:"payload"`
+			}
+			if err := json.Unmarshal(<-agent.Send, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Type != tt.wantType || reply.Payload.RefID != "msg-1" || reply.Payload.Retry != tt.wantRetry {
+				t.Errorf("unexpected reply: %+v", reply)
+			}
+		})
+	}
+}
+
+func TestHandler_NacksMessagesWithoutMaster(t *testing.T) {
+	handler := NewHandler(nil)
+	agent := &AgentConn{UserID: "user-1", AgentID: "agent-1", Send: make(chan []byte, 1)}
+	handler.handleMessage(agent, []byte(`{"type":"change_detected","id":"msg-1","payload":{"rule_id":"rule-1"}}`))
+
+	var reply struct {
+		Type    string `json:"type"`
+		Payload struct {
+			RefID string `json:"ref_id"`
+			Retry bool   `json:"retry"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Type != "nack" || reply.Payload.RefID != "msg-1" || !reply.Payload.Retry {
+		t.Errorf("expected a nack to retry msg-1, got %+v", reply)
+	}
+}
+
+func TestMasterClient_Forward(t *testing.T) {
+	status := http.StatusNoContent
+	var received agentmessages.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/agent-messages" || r.Header.Get(middleware.InternalTokenHeader) != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewMasterClient(server.URL+"/", "s3cret")
+	msg := agentmessages.Message{ID: "msg-1", Type: "change_detected", AgentID: "agent-1", UserID: "user-1"}
+
+	if err := client.Forward(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.ID != "msg-1" || received.AgentID != "agent-1" {
+		t.Errorf("unexpected message at master: %+v", received)
+	}
+
+	status = http.StatusUnprocessableEntity
+	if err := client.Forward(context.Background(), msg); !errors.Is(err, agentmessages.ErrRejected) {
+		t.Errorf("expected a rejection, got %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	err := client.Forward(context.Background(), msg)
+	if err == nil || errors.Is(err, agentmessages.ErrRejected) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
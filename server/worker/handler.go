@@ -1,27 +1,61 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/kamilrybacki/edictflow/server/domain"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/services/agentmessages"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Writes are only compressed for agents that advertise it in their hello
+	EnableCompression: true,
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Configure for production
 	},
 }
 
+// DriftRecorder persists the drift reports agents send
+type DriftRecorder interface {
+	Record(ctx context.Context, report domain.AgentDrift) error
+}
+
+// ContextRecorder persists the project contexts and tags agents detect
+type ContextRecorder interface {
+	RecordProjectContext(ctx context.Context, project domain.AgentProject) error
+}
+
+// AgentRegistry checks connecting agents against the fleet registry and
+// records their heartbeats and why they were told to upgrade
+type AgentRegistry interface {
+	Authorize(ctx context.Context, agentID, userID string) error
+	RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat) error
+	RecordUpgradeRequired(ctx context.Context, agentID, userID, reason string) error
+}
+
 // Handler handles WebSocket connections for workers
 type Handler struct {
-	hub *Hub
+	hub       *Hub
+	drift     DriftRecorder
+	contexts  ContextRecorder
+	forwarder Forwarder
+	registry  AgentRegistry
+
+	// Agents older than minVersion are told to upgrade; empty accepts all
+	minVersion string
 }
 
 // NewHandler creates a new worker WebSocket handler
@@ -29,6 +63,25 @@ func NewHandler(hub *Hub) *Handler {
 	return &Handler{hub: hub}
 }
 
+// SetDriftRecorder sets where drift reports are stored. Reports are
+// acknowledged but dropped when no recorder is set.
+func (h *Handler) SetDriftRecorder(r DriftRecorder) {
+	h.drift = r
+}
+
+// SetContextRecorder sets where detected project contexts are stored.
+// Detections are acknowledged but dropped when no recorder is set.
+func (h *Handler) SetContextRecorder(r ContextRecorder) {
+	h.contexts = r
+}
+
+// SetRegistry sets the fleet registry. With one, agents must connect with
+// their registered ID. Without one, any agent ID reported in heartbeats is
+// trusted and revoked agents are not refused.
+func (h *Handler) SetRegistry(r AgentRegistry) {
+	h.registry = r
+}
+
 // ServeHTTP upgrades to WebSocket and manages the connection
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
@@ -37,10 +90,37 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get team ID from query or header
-	teamID := r.URL.Query().Get("team_id")
-	if teamID == "" {
-		teamID = r.Header.Get("X-Team-ID")
+	// The team comes from the token; a team the agent names must be the same
+	teamID := middleware.GetTeamID(r.Context())
+	requestedTeam := r.URL.Query().Get("team_id")
+	if requestedTeam == "" {
+		requestedTeam = r.Header.Get("X-Team-ID")
+	}
+	if requestedTeam != "" && requestedTeam != teamID {
+		http.Error(w, "team does not match the token", http.StatusForbidden)
+		return
+	}
+
+	// Agents identify themselves when connecting, so revoked ones are refused
+	// before the upgrade. An agent without a registered ID could not be.
+	agentID := r.URL.Query().Get("agent_id")
+	if agentID == "" {
+		agentID = r.Header.Get("X-Agent-ID")
+	}
+	if h.registry != nil {
+		if agentID == "" {
+			http.Error(w, "agent ID required, log in again to register the agent", http.StatusForbidden)
+			return
+		}
+		if err := h.registry.Authorize(r.Context(), agentID, userID); err != nil {
+			if errors.Is(err, agents.ErrAgentRevoked) || errors.Is(err, agents.ErrAgentNotFound) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			log.Printf("Failed to authorize agent %s: %v", agentID, err)
+			http.Error(w, "agent registry unavailable", http.StatusServiceUnavailable)
+			return
+		}
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -52,6 +132,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	agent := &AgentConn{
 		ID:         uuid.New().String(),
 		UserID:     userID,
+		AgentID:    agentID,
 		TeamID:     teamID,
 		Send:       make(chan []byte, 256),
 		conn:       conn,
@@ -82,6 +163,7 @@ func (h *Handler) readPump(agent *AgentConn) {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
+			agent.lost.Store(!websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway))
 			break
 		}
 
@@ -92,6 +174,7 @@ func (h *Handler) readPump(agent *AgentConn) {
 func (h *Handler) handleMessage(agent *AgentConn, data []byte) {
 	var msg struct {
 		Type    string          `json:"type"`
+		ID      string          `json:"id"`
 		Payload json.RawMessage `json:"payload"`
 	}
 
@@ -101,24 +184,66 @@ func (h *Handler) handleMessage(agent *AgentConn, data []byte) {
 	}
 
 	switch msg.Type {
+	case "hello":
+		var payload ws.HelloPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			log.Printf("Invalid hello from agent %s: %v", agent.ID, err)
+			break
+		}
+		if !h.checkVersion(agent, agent.AgentID, payload.AgentVersion) {
+			return
+		}
+		welcome, _ := ws.NewMessage(ws.TypeWelcome, h.hub.Negotiate(agent, payload))
+		data, _ := json.Marshal(welcome)
+		h.hub.deliver(agent, data)
+
 	case "heartbeat":
 		var payload struct {
-			AgentID     string `json:"agent_id"`
-			TeamID      string `json:"team_id"`
-			Hostname    string `json:"hostname"`
-			Version     string `json:"version"`
-			OS          string `json:"os"`
-			ConnectedAt string `json:"connected_at"`
+			AgentID       string `json:"agent_id"`
+			TeamID        string `json:"team_id"`
+			Hostname      string `json:"hostname"`
+			Version       string `json:"version"`
+			OS            string `json:"os"`
+			ConnectedAt   string `json:"connected_at"`
+			CachedVersion int    `json:"cached_version"`
+			// Agents that predate rule versions leave it out
+			RuleVersions map[string]int `json:"rule_versions"`
 		}
 		if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-			// Update agent info if provided
-			if payload.AgentID != "" && agent.AgentID == "" {
-				agent.AgentID = payload.AgentID
+			// Agents cannot move to another team than their token's
+			if payload.TeamID != "" && payload.TeamID != agent.TeamID {
+				log.Printf("Agent %s reported team %s, not its team %q", agent.identity(), payload.TeamID, agent.TeamID)
+				h.sendNack(agent, msg.ID, fmt.Errorf("%w: team %s is not the agent's team", agentmessages.ErrRejected, payload.TeamID))
+				return
+			}
+			// With a registry the agent keeps the ID it connected with
+			if h.registry != nil && payload.AgentID != "" && payload.AgentID != agent.AgentID {
+				log.Printf("Agent %s reported agent ID %s", agent.identity(), payload.AgentID)
+				h.sendNack(agent, msg.ID, fmt.Errorf("%w: agent %s is not the connected agent", agentmessages.ErrRejected, payload.AgentID))
+				return
 			}
-			if payload.TeamID != "" && agent.TeamID != payload.TeamID {
-				// Update team subscription without recreating channel
-				h.hub.UpdateTeam(agent, payload.TeamID)
+			// Agents that predate the hello only report their version here
+			agentID := payload.AgentID
+			if agentID == "" {
+				agentID = agent.AgentID
+			}
+			if !h.checkVersion(agent, agentID, payload.Version) {
+				return
 			}
+			agentID, revoked := h.recordHeartbeat(agent, agentID, domain.AgentHeartbeat{
+				TeamID:        agent.TeamID,
+				Hostname:      payload.Hostname,
+				OS:            payload.OS,
+				Version:       payload.Version,
+				CachedVersion: payload.CachedVersion,
+				RuleVersions:  payload.RuleVersions,
+			})
+			if revoked {
+				h.disconnectRevoked(agent, agentID)
+				return
+			}
+			// Update agent info if provided
+			h.hub.SetAgentID(agent, agentID)
 			// Update extended host info
 			if payload.Hostname != "" {
 				agent.Hostname = payload.Hostname
@@ -132,19 +257,129 @@ func (h *Handler) handleMessage(agent *AgentConn, data []byte) {
 			if payload.ConnectedAt != "" {
 				agent.ConnectedAt = payload.ConnectedAt
 			}
+			agent.ConfigVersion = payload.CachedVersion
+			h.hub.UpdatePresence(agent)
+		}
+
+	case "sync_request":
+		var payload struct {
+			SinceVersion int64 `json:"since_version"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			log.Printf("Invalid sync request from agent %s: %v", agent.ID, err)
+			break
+		}
+		go h.hub.SyncAgent(agent, payload.SinceVersion)
+
+	case "drift_report":
+		var payload struct {
+			Files []domain.FileDrift `json:"files"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			log.Printf("Invalid drift report from agent %s: %v", agent.ID, err)
+			break
+		}
+		if h.drift == nil {
+			break
+		}
+		report := domain.AgentDrift{
+			AgentID:    agent.identity(),
+			UserID:     agent.UserID,
+			TeamID:     agent.TeamID,
+			Hostname:   agent.Hostname,
+			ReportedAt: time.Now(),
+			Files:      payload.Files,
+		}
+		if err := h.drift.Record(context.Background(), report); err != nil {
+			log.Printf("Failed to record drift report from agent %s: %v", report.AgentID, err)
+		}
+
+	case "context_detected":
+		var payload struct {
+			ProjectPath     string   `json:"project_path"`
+			DetectedContext []string `json:"detected_context"`
+			DetectedTags    []string `json:"detected_tags"`
+			Repository      string   `json:"repository"`
+			Branch          string   `json:"branch"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			log.Printf("Invalid context detection from agent %s: %v", agent.ID, err)
+			break
+		}
+		if h.contexts == nil {
+			break
+		}
+		project := domain.AgentProject{
+			AgentID:          agent.identity(),
+			UserID:           agent.UserID,
+			TeamID:           agent.TeamID,
+			ProjectPath:      payload.ProjectPath,
+			DetectedContexts: payload.DetectedContext,
+			DetectedTags:     payload.DetectedTags,
+			Repository:       payload.Repository,
+			Branch:           payload.Branch,
+			DetectedAt:       time.Now(),
+		}
+		if err := h.contexts.RecordProjectContext(context.Background(), project); err != nil {
+			log.Printf("Failed to record context for %s from agent %s: %v", project.ProjectPath, project.AgentID, err)
 		}
 
 	default:
-		log.Printf("Unknown message type: %s", msg.Type)
+		if !agentmessages.Forwarded(msg.Type) {
+			log.Printf("Unknown message type: %s", msg.Type)
+			break
+		}
+		if err := h.forward(agent, msg.ID, msg.Type, msg.Payload); err != nil {
+			log.Printf("Failed to forward %s from agent %s: %v", msg.Type, agent.identity(), err)
+			h.sendNack(agent, msg.ID, err)
+			return
+		}
 	}
 
-	// Send ack
-	ack, _ := json.Marshal(map[string]string{"type": "ack"})
+	// Send ack referencing the message so the agent can drop it from its outbound queue
+	ack, _ := json.Marshal(map[string]interface{}{
+		"type":    "ack",
+		"payload": map[string]string{"ref_id": msg.ID},
+	})
+	// Without an ack the agent sends the message again
+	h.hub.deliver(agent, ack)
+}
+
+// recordHeartbeat stores the heartbeat in the registry and returns the agent
+// ID the connection may use, and whether the agent must be disconnected
+// because it was revoked or removed
+func (h *Handler) recordHeartbeat(agent *AgentConn, agentID string, heartbeat domain.AgentHeartbeat) (string, bool) {
+	if h.registry == nil {
+		return agentID, false
+	}
+
+	heartbeat.AgentID = agentID
+	heartbeat.UserID = agent.UserID
+	err := h.registry.RecordHeartbeat(context.Background(), heartbeat)
+	switch {
+	case err == nil:
+		return agentID, false
+	case errors.Is(err, agents.ErrAgentRevoked), errors.Is(err, agents.ErrAgentNotFound):
+		return agentID, true
+	default:
+		log.Printf("Failed to record heartbeat of agent %s: %v", agentID, err)
+		return agentID, false
+	}
+}
+
+// disconnectRevoked tells a revoked agent why it is being disconnected and
+// closes the connection
+func (h *Handler) disconnectRevoked(agent *AgentConn, agentID string) {
+	log.Printf("Disconnecting revoked agent %s", agentID)
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    agents.MessageTypeAgentRevoked,
+		"payload": map[string]string{"agent_id": agentID},
+	})
 	select {
-	case agent.Send <- ack:
+	case agent.Send <- data:
 	default:
-		// Buffer full, skip ack
 	}
+	h.hub.Unregister(agent)
 }
 
 func (h *Handler) writePump(agent *AgentConn) {
@@ -162,10 +397,12 @@ func (h *Handler) writePump(agent *AgentConn) {
 				_ = agent.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
+			agent.conn.EnableWriteCompression(agent.compress.Load())
 
 			if err := agent.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
+			h.hub.flushPending(agent)
 
 		case <-ticker.C:
 			_ = agent.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,7 +12,10 @@ import (
 
 	"github.com/gorilla/websocket"
 	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
+	"github.com/kamilrybacki/edictflow/server/domain"
 	"github.com/kamilrybacki/edictflow/server/entrypoints/api/middleware"
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/services/agents"
 )
 
 func TestHandler_ServeHTTP_Unauthorized(t *testing.T) {
@@ -58,8 +62,9 @@ func TestHandler_ServeHTTP_WithAuth(t *testing.T) {
 
 	// Create test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Inject user ID into context
+		// Inject user and team ID into context
 		ctx := context.WithValue(r.Context(), middleware.UserIDContextKey, "test-user")
+		ctx = context.WithValue(ctx, middleware.TeamIDContextKey, "test-team")
 		handler.ServeHTTP(w, r.WithContext(ctx))
 	}))
 	defer server.Close()
@@ -105,6 +110,7 @@ func TestHandler_HeartbeatMessage(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), middleware.UserIDContextKey, "test-user")
+		ctx = context.WithValue(ctx, middleware.TeamIDContextKey, "team-456")
 		handler.ServeHTTP(w, r.WithContext(ctx))
 	}))
 	defer server.Close()
@@ -119,6 +125,7 @@ func TestHandler_HeartbeatMessage(t *testing.T) {
 	// Send heartbeat with agent_id
 	heartbeat := map[string]interface{}{
 		"type": "heartbeat",
+		"id":   "msg-1",
 		"payload": map[string]string{
 			"agent_id": "agent-123",
 			"team_id":  "team-456",
@@ -134,9 +141,226 @@ func TestHandler_HeartbeatMessage(t *testing.T) {
 		t.Fatalf("failed to read ack: %v", err)
 	}
 
-	var ack map[string]string
+	var ack struct {
+		Type    string `json:"type"`
+		Payload struct {
+			RefID string `json:"ref_id"`
+		} `json:"payload"`
+	}
 	_ = json.Unmarshal(msg, &ack)
-	if ack["type"] != "ack" {
-		t.Errorf("expected ack, got %s", ack["type"])
+	if ack.Type != "ack" {
+		t.Errorf("expected ack, got %s", ack.Type)
+	}
+	if ack.Payload.RefID != "msg-1" {
+		t.Errorf("expected ack to reference msg-1, got %q", ack.Payload.RefID)
+	}
+}
+
+func TestHandler_ServeHTTP_RefusesForeignTeam(t *testing.T) {
+	handler := NewHandler(NewHub(nil))
+
+	for _, set := range []func(r *http.Request){
+		func(r *http.Request) { r.URL.RawQuery = "team_id=team-2" },
+		func(r *http.Request) { r.Header.Set("X-Team-ID", "team-2") },
+	} {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		set(req)
+		ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, "test-user")
+		ctx = context.WithValue(ctx, middleware.TeamIDContextKey, "team-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for another team, got %d", rec.Code)
+		}
+	}
+}
+
+func TestHandler_HeartbeatWithForeignTeamIsRejected(t *testing.T) {
+	hub := NewHub(nil)
+	handler := NewHandler(hub)
+	agent := &AgentConn{ID: "conn-1", UserID: "user-1", TeamID: "team-1", Send: make(chan []byte, 4)}
+
+	data := []byte(`{"type":"heartbeat","id":"msg-1","payload":{"agent_id":"user-1","team_id":"team-2"}}`)
+	handler.handleMessage(agent, data)
+
+	var nack struct {
+		Type    string         `json:"type"`
+		Payload ws.NackPayload `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &nack); err != nil {
+		t.Fatal(err)
+	}
+	if nack.Type != string(ws.TypeNack) || nack.Payload.RefID != "msg-1" || nack.Payload.Retry {
+		t.Errorf("expected a final nack for msg-1, got %+v", nack)
+	}
+	if agent.TeamID != "team-1" {
+		t.Errorf("expected the agent to stay in team-1, got %s", agent.TeamID)
+	}
+	if _, ok := hub.teamAgents["team-2"]; ok {
+		t.Error("expected no subscription to team-2")
+	}
+}
+
+type recordingDriftRecorder struct {
+	reports []domain.AgentDrift
+}
+
+func (r *recordingDriftRecorder) Record(ctx context.Context, report domain.AgentDrift) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func TestHandler_DriftReportRecordedWithAgentIdentity(t *testing.T) {
+	recorder := &recordingDriftRecorder{}
+	handler := NewHandler(nil)
+	handler.SetDriftRecorder(recorder)
+
+	agent := &AgentConn{
+		UserID:   "user-1",
+		AgentID:  "agent-1",
+		TeamID:   "team-1",
+		Hostname: "laptop",
+		Send:     make(chan []byte, 1),
+	}
+	data := []byte(`{"type":"drift_report","id":"msg-1","payload":{"files":[` +
+		`{"path":"/repo/CLAUDE.md","level":"project","status":"stale","expected_hash":"a","actual_hash":"b","rule_ids":["rule-1"]}]}}`)
+
+	handler.handleMessage(agent, data)
+
+	if len(recorder.reports) != 1 {
+		t.Fatalf("expected 1 recorded report, got %d", len(recorder.reports))
+	}
+	report := recorder.reports[0]
+	if report.AgentID != "agent-1" || report.UserID != "user-1" || report.TeamID != "team-1" || report.Hostname != "laptop" {
+		t.Errorf("expected report tagged with connection identity, got %+v", report)
+	}
+	if len(report.Files) != 1 || report.Files[0].Status != domain.DriftStatusStale || report.Files[0].RuleIDs[0] != "rule-1" {
+		t.Errorf("unexpected files: %+v", report.Files)
+	}
+	if len(agent.Send) != 1 {
+		t.Error("expected drift report to be acked")
+	}
+}
+
+type recordingContextRecorder struct {
+	projects []domain.AgentProject
+}
+
+func (r *recordingContextRecorder) RecordProjectContext(ctx context.Context, project domain.AgentProject) error {
+	r.projects = append(r.projects, project)
+	return nil
+}
+
+func TestHandler_ContextDetectedRecorded(t *testing.T) {
+	recorder := &recordingContextRecorder{}
+	handler := NewHandler(nil)
+	handler.SetContextRecorder(recorder)
+
+	// Agent has not sent a heartbeat yet, so the user ID identifies it
+	agent := &AgentConn{UserID: "user-1", TeamID: "team-1", Send: make(chan []byte, 1)}
+	data := []byte(`{"type":"context_detected","id":"msg-2","payload":` +
+		`{"project_path":"/repo","detected_context":["go","docker"],"detected_tags":["monorepo"],` +
+		`"repository":"github.com/acme/payments-api","branch":"main"}}`)
+
+	handler.handleMessage(agent, data)
+
+	if len(recorder.projects) != 1 {
+		t.Fatalf("expected 1 recorded project, got %d", len(recorder.projects))
+	}
+	project := recorder.projects[0]
+	if project.AgentID != "user-1" || project.ProjectPath != "/repo" {
+		t.Errorf("unexpected project identity: %+v", project)
+	}
+	if len(project.DetectedContexts) != 2 || project.DetectedTags[0] != "monorepo" {
+		t.Errorf("unexpected detection: %+v", project)
+	}
+	if project.Repository != "github.com/acme/payments-api" || project.Branch != "main" {
+		t.Errorf("expected the repository and branch, got %+v", project)
+	}
+}
+
+type stubRegistry struct {
+	authorizeErr error
+	// upgrades records the reasons agents were told to upgrade, by agent ID
+	upgrades map[string]string
+}
+
+func (r *stubRegistry) Authorize(ctx context.Context, agentID, userID string) error {
+	return r.authorizeErr
+}
+
+func (r *stubRegistry) RecordHeartbeat(ctx context.Context, heartbeat domain.AgentHeartbeat) error {
+	return nil
+}
+
+func (r *stubRegistry) RecordUpgradeRequired(ctx context.Context, agentID, userID, reason string) error {
+	if r.upgrades == nil {
+		r.upgrades = make(map[string]string)
+	}
+	r.upgrades[agentID] = reason
+	return nil
+}
+
+func TestHandler_ServeHTTP_RequiresAgentIDWithRegistry(t *testing.T) {
+	handler := NewHandler(NewHub(nil))
+	handler.SetRegistry(&stubRegistry{})
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, "test-user"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without an agent ID, got %d", rec.Code)
+	}
+}
+
+func TestHandler_HeartbeatWithAnotherAgentIDIsRejected(t *testing.T) {
+	handler := NewHandler(NewHub(nil))
+	handler.SetRegistry(&stubRegistry{})
+	agent := &AgentConn{ID: "conn-1", UserID: "user-1", AgentID: "agent-1", Send: make(chan []byte, 4)}
+
+	data := []byte(`{"type":"heartbeat","id":"msg-1","payload":{"agent_id":"user-1"}}`)
+	handler.handleMessage(agent, data)
+
+	var nack struct {
+		Type    string         `json:"type"`
+		Payload ws.NackPayload `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &nack); err != nil {
+		t.Fatal(err)
+	}
+	if nack.Type != string(ws.TypeNack) || nack.Payload.RefID != "msg-1" || nack.Payload.Retry {
+		t.Errorf("expected a final nack for msg-1, got %+v", nack)
+	}
+	if agent.AgentID != "agent-1" {
+		t.Errorf("expected the connection to stay agent-1, got %s", agent.AgentID)
+	}
+}
+
+func TestHandler_ServeHTTP_RefusesRevokedAgent(t *testing.T) {
+	hub := NewHub(nil)
+	handler := NewHandler(hub)
+
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{agents.ErrAgentRevoked, http.StatusForbidden},
+		{agents.ErrAgentNotFound, http.StatusForbidden},
+		{errors.New("connection refused"), http.StatusServiceUnavailable},
+	} {
+		handler.SetRegistry(&stubRegistry{authorizeErr: tc.err})
+
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.Header.Set("X-Agent-ID", "agent-1")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, "test-user"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.code, rec.Code)
+		}
 	}
 }
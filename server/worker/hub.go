@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	redisAdapter "github.com/kamilrybacki/edictflow/server/adapters/redis"
@@ -26,6 +28,31 @@ type AgentConn struct {
 	OS          string
 	ConnectedAt string
 	RemoteAddr  string
+	// ConfigVersion is the configuration version the agent last reported
+	ConfigVersion int
+
+	// lost is set when the connection dropped without a close frame
+	lost atomic.Bool
+
+	// protocol is what the agent understands, from its hello
+	protocol protocol
+	// compress enables compressed writes once the agent advertised them
+	compress atomic.Bool
+
+	// revision is the team configuration revision last sent to the agent
+	revision int64
+
+	// backlog counts the messages the agent was too slow to take
+	backlog backlog
+}
+
+// identity returns the agent ID reported in heartbeats, falling back to the
+// user ID for agents that have not sent one yet
+func (a *AgentConn) identity() string {
+	if a.AgentID != "" {
+		return a.AgentID
+	}
+	return a.UserID
 }
 
 // Hub manages agent connections and Redis subscriptions
@@ -44,6 +71,15 @@ type Hub struct {
 	// Active Redis subscriptions per team
 	subscriptions map[string]*redis.PubSub
 
+	// Subscription to the broadcast channel and the direct channels of
+	// agents connected here
+	direct *redis.PubSub
+
+	// Consumer group and name on the event stream; empty when subscribing to
+	// team channels
+	streamGroup    string
+	streamConsumer string
+
 	// Channels for goroutine communication
 	register   chan *AgentConn
 	unregister chan *AgentConn
@@ -51,6 +87,18 @@ type Hub struct {
 	// Metrics service for observability
 	metrics metrics.Service
 
+	// How long an agent's send buffer may stay full before it is
+	// disconnected; zero keeps slow agents connected
+	slowConsumerTimeout time.Duration
+
+	// Builds the rule updates pushed to agents; nil sends bare notifications
+	rules RuleSets
+
+	// Fleet-wide presence of the agents connected here; nil when disabled
+	presence   PresenceStore
+	workerID   string
+	presenceMu sync.Mutex // orders presence writes of the same connection
+
 	mu     sync.RWMutex
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -70,6 +118,8 @@ func NewHub(redisClient *redisAdapter.Client) *Hub {
 		metrics:       &metrics.NoOpService{},
 		ctx:           ctx,
 		cancel:        cancel,
+
+		slowConsumerTimeout: DefaultSlowConsumerTimeout,
 	}
 }
 
@@ -80,6 +130,14 @@ func (h *Hub) SetMetrics(m metrics.Service) {
 
 // Run starts the hub event loop
 func (h *Hub) Run() {
+	h.subscribeDirect()
+	if h.streamGroup != "" {
+		go h.consumeStream()
+	}
+	if h.presence != nil {
+		go h.runPresence()
+	}
+
 	for {
 		select {
 		case <-h.ctx.Done():
@@ -104,6 +162,9 @@ func (h *Hub) handleRegister(agent *AgentConn) {
 
 	// Add to agents map (for lookup by AgentID)
 	if agent.AgentID != "" {
+		if _, ok := h.agents[agent.AgentID]; !ok {
+			h.subscribeToAgent(agent.AgentID)
+		}
 		h.agents[agent.AgentID] = agent
 	}
 
@@ -130,13 +191,17 @@ func (h *Hub) handleUnregister(agent *AgentConn) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// A connection closed by the hub is unregistered again when its read
+	// loop ends
+	if _, ok := h.connections[agent.ID]; !ok {
+		return
+	}
+
 	// Remove from connections map
 	delete(h.connections, agent.ID)
 
 	// Remove from agents map
-	if agent.AgentID != "" {
-		delete(h.agents, agent.AgentID)
-	}
+	h.releaseAgentID(agent)
 
 	// Remove from team mapping
 	if agent.TeamID != "" {
@@ -154,17 +219,21 @@ func (h *Hub) handleUnregister(agent *AgentConn) {
 	// Record metrics
 	h.metrics.RecordAgentConnection(agent.AgentID, agent.TeamID, "disconnected")
 
-	// Safe close - only close if not already closed
-	select {
-	case <-agent.Send:
-		// Channel already closed
-	default:
-		close(agent.Send)
+	if h.presence != nil {
+		go h.removePresence(agent.ID, agent.lost.Load())
 	}
+
+	// Queued messages are still written before the close
+	close(agent.Send)
 	log.Printf("Agent unregistered: id=%s (remaining: %d)", agent.AgentID, len(h.connections))
 }
 
 func (h *Hub) subscribeToTeam(teamID string) {
+	if h.streamGroup != "" {
+		// The event stream carries every team's events
+		return
+	}
+
 	channel := events.ChannelForTeam(teamID)
 	sub := h.redisClient.Subscribe(h.ctx, channel)
 	h.subscriptions[teamID] = sub
@@ -203,11 +272,14 @@ func (h *Hub) listenToSubscription(teamID string, sub *redis.PubSub) {
 }
 
 func (h *Hub) broadcastToTeam(teamID string, event events.Event) {
-	h.mu.RLock()
-	agents := h.teamAgents[teamID]
-	h.mu.RUnlock()
+	if h.rules != nil {
+		recipientCount := h.broadcastUpdates(teamID)
+		h.metrics.RecordBroadcast(teamID, string(event.Type), recipientCount)
+		log.Printf("Broadcast %s to %d agents in team %s", event.Type, recipientCount, teamID)
+		return
+	}
 
-	// Convert event to WebSocket message format
+	// Without rule sets agents only learn that something changed
 	wsMsg := map[string]interface{}{
 		"type":      "config_update",
 		"event":     event.Type,
@@ -217,20 +289,21 @@ func (h *Hub) broadcastToTeam(teamID string, event events.Event) {
 	}
 	data, _ := json.Marshal(wsMsg)
 
+	h.mu.RLock()
+	agents := h.teamAgents[teamID]
+	total := len(agents)
 	recipientCount := 0
 	for agent := range agents {
-		select {
-		case agent.Send <- data:
+		if h.deliverConfig(agent, data, agent.revision) {
 			recipientCount++
-		default:
-			// Buffer full, skip
 		}
 	}
+	h.mu.RUnlock()
 
 	// Record broadcast metric
 	h.metrics.RecordBroadcast(teamID, string(event.Type), recipientCount)
 
-	log.Printf("Broadcast %s to %d agents in team %s", event.Type, len(agents), teamID)
+	log.Printf("Broadcast %s to %d agents in team %s", event.Type, total, teamID)
 }
 
 // Register adds an agent to the hub
@@ -256,6 +329,19 @@ func (h *Hub) cleanup() {
 	for _, sub := range h.subscriptions {
 		sub.Close()
 	}
+	if h.direct != nil {
+		h.direct.Close()
+	}
+
+	// Agents reconnect to other workers, so a shutdown is not reported as
+	// them going offline
+	if h.presence != nil {
+		ids := make([]string, 0, len(h.connections))
+		for id := range h.connections {
+			ids = append(ids, id)
+		}
+		h.removeAllPresence(ids)
+	}
 
 	// Close all agent connections
 	for _, agent := range h.connections {
@@ -285,6 +371,8 @@ type AgentInfo struct {
 	OS          string `json:"os,omitempty"`
 	ConnectedAt string `json:"connected_at,omitempty"`
 	RemoteAddr  string `json:"remote_addr,omitempty"`
+	// DroppedMessages counts the messages the agent's full buffer refused
+	DroppedMessages int64 `json:"dropped_messages"`
 }
 
 // ListAgents returns information about all connected agents
@@ -303,44 +391,9 @@ func (h *Hub) ListAgents() []AgentInfo {
 			OS:          agent.OS,
 			ConnectedAt: agent.ConnectedAt,
 			RemoteAddr:  agent.RemoteAddr,
+
+			DroppedMessages: agent.backlog.dropped.Load(),
 		})
 	}
 	return agents
 }
-
-// UpdateTeam changes an agent's team subscription without closing the Send channel
-func (h *Hub) UpdateTeam(agent *AgentConn, newTeam string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	oldTeam := agent.TeamID
-
-	// Remove from old team
-	if oldTeam != "" {
-		if agents, ok := h.teamAgents[oldTeam]; ok {
-			delete(agents, agent)
-			if len(agents) == 0 {
-				h.unsubscribeFromTeam(oldTeam)
-				delete(h.teamAgents, oldTeam)
-			}
-		}
-	}
-
-	// Update agent's team
-	agent.TeamID = newTeam
-
-	// Add to new team
-	if newTeam != "" {
-		if h.teamAgents[newTeam] == nil {
-			h.teamAgents[newTeam] = make(map[*AgentConn]struct{})
-		}
-		h.teamAgents[newTeam][agent] = struct{}{}
-
-		// Subscribe if first agent for this team
-		if len(h.teamAgents[newTeam]) == 1 {
-			h.subscribeToTeam(newTeam)
-		}
-	}
-
-	log.Printf("Agent %s moved from team %s to %s", agent.AgentID, oldTeam, newTeam)
-}
@@ -96,3 +96,42 @@ func TestHub_ReceivesBroadcast(t *testing.T) {
 		t.Error("timeout waiting for broadcast")
 	}
 }
+
+func TestHub_ReceivesDirectMessage(t *testing.T) {
+	client, err := redisAdapter.NewClient("redis://localhost:6379/0")
+	if err != nil {
+		t.Skip("Redis not available:", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skip("Redis not available:", err)
+	}
+
+	hub := NewHub(client)
+	go hub.Run()
+	defer hub.Stop()
+
+	agent := &AgentConn{
+		ID:     "conn-1",
+		TeamID: "team-1",
+		Send:   make(chan []byte, 256),
+	}
+
+	hub.Register(agent)
+	hub.SetAgentID(agent, "agent-direct")
+	time.Sleep(200 * time.Millisecond) // Wait for subscription
+
+	pub := publisher.NewRedisPublisher(client)
+	_ = pub.PublishToAgent(ctx, "agent-direct", []byte(`{"type":"change_approved"}`))
+
+	select {
+	case msg := <-agent.Send:
+		if string(msg) != `{"type":"change_approved"}` {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timeout waiting for direct message")
+	}
+}
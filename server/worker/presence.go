@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+)
+
+// PresenceStore records which agents are connected to this worker, so the
+// master can see the whole fleet
+type PresenceStore interface {
+	Put(ctx context.Context, entries []presence.Entry) error
+	Remove(ctx context.Context, connIDs ...string) error
+	MarkLost(ctx context.Context, connIDs ...string) error
+	TTL() time.Duration
+}
+
+// SetPresence enables presence tracking under the worker's ID. Must be called
+// before Run.
+func (h *Hub) SetPresence(store PresenceStore, workerID string) {
+	h.presence = store
+	h.workerID = workerID
+}
+
+// runPresence refreshes the presence of every local connection until the hub
+// stops. Entries not refreshed within the TTL count as gone.
+func (h *Hub) runPresence() {
+	ticker := time.NewTicker(h.presence.TTL() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.refreshPresence()
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *Hub) refreshPresence() {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	h.mu.RLock()
+	entries := make([]presence.Entry, 0, len(h.connections))
+	for _, agent := range h.connections {
+		entries = append(entries, h.presenceEntry(agent))
+	}
+	h.mu.RUnlock()
+
+	if err := h.presence.Put(h.ctx, entries); err != nil {
+		log.Printf("Failed to refresh presence: %v", err)
+	}
+}
+
+// UpdatePresence publishes the agent's current details, typically after a
+// heartbeat changed them
+func (h *Hub) UpdatePresence(agent *AgentConn) {
+	if h.presence == nil {
+		return
+	}
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	h.mu.RLock()
+	_, connected := h.connections[agent.ID]
+	entry := h.presenceEntry(agent)
+	h.mu.RUnlock()
+	if !connected {
+		return
+	}
+
+	if err := h.presence.Put(h.ctx, []presence.Entry{entry}); err != nil {
+		log.Printf("Failed to update presence of agent %s: %v", agent.identity(), err)
+	}
+}
+
+// removePresence drops a closed connection. A connection that was lost
+// without a close keeps its entry, expired, for the master to report.
+func (h *Hub) removePresence(connID string, lost bool) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var err error
+	if lost {
+		err = h.presence.MarkLost(ctx, connID)
+	} else {
+		err = h.presence.Remove(ctx, connID)
+	}
+	if err != nil {
+		log.Printf("Failed to remove presence of connection %s: %v", connID, err)
+	}
+}
+
+func (h *Hub) removeAllPresence(connIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.presence.Remove(ctx, connIDs...); err != nil {
+		log.Printf("Failed to remove presence: %v", err)
+	}
+}
+
+// presenceEntry must be called with the lock held
+func (h *Hub) presenceEntry(agent *AgentConn) presence.Entry {
+	return presence.Entry{
+		ConnectionID:  agent.ID,
+		AgentID:       agent.AgentID,
+		UserID:        agent.UserID,
+		WorkerID:      h.workerID,
+		TeamID:        agent.TeamID,
+		Hostname:      agent.Hostname,
+		Version:       agent.Version,
+		OS:            agent.OS,
+		ConfigVersion: agent.ConfigVersion,
+		ConnectedAt:   agent.ConnectedAt,
+		LastSeen:      time.Now(),
+	}
+}
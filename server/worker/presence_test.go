@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/services/presence"
+)
+
+type fakePresenceStore struct {
+	mu      sync.Mutex
+	entries map[string]presence.Entry
+	lost    []string
+	changed chan struct{}
+}
+
+func newFakePresenceStore() *fakePresenceStore {
+	return &fakePresenceStore{entries: map[string]presence.Entry{}, changed: make(chan struct{}, 10)}
+}
+
+func (s *fakePresenceStore) Put(ctx context.Context, entries []presence.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		s.entries[e.ConnectionID] = e
+	}
+	return nil
+}
+
+func (s *fakePresenceStore) Remove(ctx context.Context, connIDs ...string) error {
+	s.mu.Lock()
+	for _, id := range connIDs {
+		delete(s.entries, id)
+	}
+	s.mu.Unlock()
+	s.changed <- struct{}{}
+	return nil
+}
+
+func (s *fakePresenceStore) MarkLost(ctx context.Context, connIDs ...string) error {
+	s.mu.Lock()
+	s.lost = append(s.lost, connIDs...)
+	s.mu.Unlock()
+	s.changed <- struct{}{}
+	return nil
+}
+
+func (s *fakePresenceStore) TTL() time.Duration { return time.Minute }
+
+func (s *fakePresenceStore) waitForChange(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.changed:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for presence change")
+	}
+}
+
+func TestHub_UpdatePresence(t *testing.T) {
+	store := newFakePresenceStore()
+	hub := NewHub(nil)
+	hub.SetPresence(store, "worker-1")
+
+	agent := &AgentConn{ID: "conn-1", AgentID: "agent-1", Hostname: "laptop", Send: make(chan []byte, 1)}
+	hub.handleRegister(agent)
+	agent.ConfigVersion = 4
+	hub.UpdatePresence(agent)
+
+	store.mu.Lock()
+	entry, ok := store.entries["conn-1"]
+	store.mu.Unlock()
+	if !ok {
+		t.Fatal("expected a presence entry for the connection")
+	}
+	if entry.WorkerID != "worker-1" || entry.AgentID != "agent-1" || entry.ConfigVersion != 4 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	// A connection that already closed is not published again
+	hub.handleUnregister(agent)
+	store.waitForChange(t)
+	hub.UpdatePresence(agent)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.entries["conn-1"]; ok {
+		t.Error("expected no entry for a closed connection")
+	}
+}
+
+func TestHub_UnregisterMarksLostConnections(t *testing.T) {
+	store := newFakePresenceStore()
+	hub := NewHub(nil)
+	hub.SetPresence(store, "worker-1")
+
+	closed := &AgentConn{ID: "conn-1", Send: make(chan []byte, 1)}
+	lost := &AgentConn{ID: "conn-2", Send: make(chan []byte, 1)}
+	hub.handleRegister(closed)
+	hub.handleRegister(lost)
+	hub.refreshPresence()
+
+	hub.handleUnregister(closed)
+	store.waitForChange(t)
+	lost.lost.Store(true)
+	hub.handleUnregister(lost)
+	store.waitForChange(t)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.entries["conn-1"]; ok {
+		t.Error("expected a cleanly closed connection to be removed")
+	}
+	if _, ok := store.entries["conn-2"]; !ok {
+		t.Error("expected a lost connection to keep its entry")
+	}
+	if len(store.lost) != 1 || store.lost[0] != "conn-2" {
+		t.Errorf("expected conn-2 to be marked lost, got %v", store.lost)
+	}
+}
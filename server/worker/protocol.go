@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+)
+
+// protocol is what an agent said it understands in its hello. Agents that
+// connect without one get the message shapes every agent understands.
+type protocol struct {
+	version      int
+	capabilities map[string]bool
+}
+
+func (p protocol) supports(capability string) bool {
+	return p.capabilities[capability]
+}
+
+// requiredCapability maps the messages older agents cannot handle to the
+// capability they need
+var requiredCapability = map[string]string{
+	string(ws.TypeChangeRejected): ws.CapabilityRevert,
+}
+
+// Negotiate records what the agent understands and returns the capabilities
+// the hub will use, the ones both sides support
+func (h *Hub) Negotiate(agent *AgentConn, hello ws.HelloPayload) ws.WelcomePayload {
+	version := hello.ProtocolVersion
+	if version > ws.ProtocolVersion {
+		version = ws.ProtocolVersion
+	}
+
+	advertised := make(map[string]bool, len(hello.Capabilities))
+	for _, c := range hello.Capabilities {
+		advertised[c] = true
+	}
+	accepted := protocol{version: version, capabilities: make(map[string]bool)}
+	names := []string{}
+	for _, c := range ws.Capabilities {
+		if advertised[c] {
+			accepted.capabilities[c] = true
+			names = append(names, c)
+		}
+	}
+
+	h.mu.Lock()
+	agent.protocol = accepted
+	h.mu.Unlock()
+	agent.compress.Store(accepted.supports(ws.CapabilityCompression))
+
+	return ws.WelcomePayload{ProtocolVersion: version, Capabilities: names}
+}
+
+// accepts reports whether the agent can handle a message. Must be called
+// with the lock held.
+func (a *AgentConn) accepts(msgType string) bool {
+	capability, ok := requiredCapability[msgType]
+	return !ok || a.protocol.supports(capability)
+}
+
+// SetMinAgentVersion makes the handler turn away agents older than version.
+// An empty version accepts every agent.
+func (h *Handler) SetMinAgentVersion(version string) {
+	h.minVersion = version
+}
+
+// checkVersion turns the agent away when its version is below the minimum
+// or missing, and records the reason on the agent's registry entry. It
+// returns false when the agent was told to upgrade.
+func (h *Handler) checkVersion(agent *AgentConn, agentID, version string) bool {
+	if h.minVersion == "" {
+		return true
+	}
+	var reason string
+	switch {
+	case version == "":
+		reason = fmt.Sprintf("agent did not report its version, the minimum is %s", h.minVersion)
+	case versionBefore(version, h.minVersion):
+		reason = fmt.Sprintf("agent version %s is older than the minimum %s", version, h.minVersion)
+	default:
+		return true
+	}
+
+	log.Printf("Refusing agent %s: %s", agent.identity(), reason)
+	h.hub.metrics.RecordAgentConnection(agent.identity(), agent.TeamID, "upgrade_required")
+	if h.registry != nil && agentID != "" && agentID != agent.UserID {
+		if err := h.registry.RecordUpgradeRequired(context.Background(), agentID, agent.UserID, reason); err != nil {
+			log.Printf("Failed to record why agent %s must upgrade: %v", agentID, err)
+		}
+	}
+
+	msg, _ := ws.NewMessage(ws.TypeUpgradeRequired, ws.UpgradeRequiredPayload{
+		MinVersion:   h.minVersion,
+		AgentVersion: version,
+		Reason:       reason,
+	})
+	data, _ := json.Marshal(msg)
+	h.hub.deliver(agent, data)
+	// Queued messages are still written before the close
+	h.hub.Unregister(agent)
+	return false
+}
+
+// versionBefore reports whether version is older than min. Versions are
+// compared by their dot-separated numbers; a leading "v" and any pre-release
+// or build suffix are ignored, and parts that are not numbers count as 0.
+func versionBefore(version, min string) bool {
+	a, b := versionParts(version), versionParts(min)
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, f := range fields {
+		parts[i], _ = strconv.Atoi(f)
+	}
+	return parts
+}
@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+)
+
+var deltaProtocol = protocol{version: ws.ProtocolVersion, capabilities: map[string]bool{ws.CapabilityDelta: true}}
+
+func TestHub_Negotiate(t *testing.T) {
+	hub := NewHub(nil)
+	agent := &AgentConn{ID: "conn-1", Send: make(chan []byte, 1)}
+
+	welcome := hub.Negotiate(agent, ws.HelloPayload{
+		ProtocolVersion: ws.ProtocolVersion + 1,
+		AgentVersion:    "0.2.0",
+		Capabilities:    []string{ws.CapabilityCompression, "teleport", ws.CapabilityDelta},
+	})
+
+	if welcome.ProtocolVersion != ws.ProtocolVersion {
+		t.Errorf("expected the server's protocol version, got %d", welcome.ProtocolVersion)
+	}
+	if len(welcome.Capabilities) != 2 || welcome.Capabilities[0] != ws.CapabilityDelta || welcome.Capabilities[1] != ws.CapabilityCompression {
+		t.Errorf("expected delta and compression, got %v", welcome.Capabilities)
+	}
+	if !agent.compress.Load() {
+		t.Error("expected compressed writes")
+	}
+	if agent.accepts(string(ws.TypeChangeRejected)) {
+		t.Error("expected rejections to need revert support")
+	}
+	if !agent.accepts(string(ws.TypeChangeApproved)) {
+		t.Error("expected approvals to need no capability")
+	}
+}
+
+func TestHub_BroadcastUpdatesSendsSnapshotToLegacyAgents(t *testing.T) {
+	hub := NewHub(nil)
+	rules := &fakeRuleSets{revision: 4}
+	hub.SetRuleSets(rules)
+
+	modern := &AgentConn{ID: "conn-1", TeamID: "team-1", Send: make(chan []byte, 1), revision: 3, protocol: deltaProtocol}
+	legacy := &AgentConn{ID: "conn-2", TeamID: "team-1", Send: make(chan []byte, 1), revision: 3}
+	hub.teamAgents["team-1"] = map[*AgentConn]struct{}{modern: {}, legacy: {}}
+
+	if got := hub.broadcastUpdates("team-1"); got != 2 {
+		t.Fatalf("expected 2 recipients, got %d", got)
+	}
+	for _, tt := range []struct {
+		agent *AgentConn
+		delta bool
+	}{{modern, true}, {legacy, false}} {
+		var msg struct {
+			Payload struct {
+				Version int  `json:"version"`
+				Delta   bool `json:"delta"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(<-tt.agent.Send, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Payload.Version != 4 || msg.Payload.Delta != tt.delta {
+			t.Errorf("agent %s: expected version 4 with delta=%v, got %+v", tt.agent.ID, tt.delta, msg.Payload)
+		}
+	}
+}
+
+func TestHub_SendToAgentSkipsUnsupportedMessages(t *testing.T) {
+	hub := NewHub(nil)
+	legacy := &AgentConn{ID: "conn-1", AgentID: "agent-1", Send: make(chan []byte, 2)}
+	hub.handleRegister(legacy)
+
+	rejected, _ := json.Marshal(map[string]string{"type": string(ws.TypeChangeRejected)})
+	approved, _ := json.Marshal(map[string]string{"type": string(ws.TypeChangeApproved)})
+	if got := hub.sendToAgent("agent-1", rejected); got != 0 {
+		t.Errorf("expected a rejection not to reach an agent without revert support, got %d", got)
+	}
+	if got := hub.sendToAgent("agent-1", approved); got != 1 {
+		t.Errorf("expected the approval to be sent, got %d", got)
+	}
+}
+
+func TestVersionBefore(t *testing.T) {
+	tests := []struct {
+		version, min string
+		want         bool
+	}{
+		{"0.1.0", "0.2.0", true},
+		{"0.2.0", "0.2.0", false},
+		{"v0.10.0", "0.9.1", false},
+		{"1.0.0-rc1", "1.0.0", false},
+		{"1.0", "1.0.1", true},
+		{"dev", "0.1.0", true},
+	}
+	for _, tt := range tests {
+		if got := versionBefore(tt.version, tt.min); got != tt.want {
+			t.Errorf("versionBefore(%q, %q) = %v, want %v", tt.version, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_HelloIsAnsweredWithWelcome(t *testing.T) {
+	hub := NewHub(nil)
+	handler := NewHandler(hub)
+	agent := &AgentConn{ID: "conn-1", Send: make(chan []byte, 4)}
+	hub.handleRegister(agent)
+
+	hello, _ := ws.NewMessage(ws.TypeHello, ws.HelloPayload{
+		ProtocolVersion: ws.ProtocolVersion,
+		AgentVersion:    "0.2.0",
+		Capabilities:    []string{ws.CapabilityDelta, ws.CapabilityRevert},
+	})
+	data, _ := json.Marshal(hello)
+	handler.handleMessage(agent, data)
+
+	var welcome struct {
+		Type    string            `json:"type"`
+		Payload ws.WelcomePayload `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &welcome); err != nil {
+		t.Fatal(err)
+	}
+	if welcome.Type != string(ws.TypeWelcome) || len(welcome.Payload.Capabilities) != 2 {
+		t.Errorf("expected a welcome with 2 capabilities, got %+v", welcome)
+	}
+	if !agent.protocol.supports(ws.CapabilityRevert) {
+		t.Error("expected the agent's capabilities to be recorded")
+	}
+}
+
+func TestHandler_OutdatedAgentIsToldToUpgrade(t *testing.T) {
+	hub := NewHub(nil)
+	handler := NewHandler(hub)
+	handler.SetMinAgentVersion("0.2.0")
+	registry := &stubRegistry{}
+	handler.SetRegistry(registry)
+	agent := &AgentConn{ID: "conn-1", UserID: "user-1", AgentID: "agent-1", Send: make(chan []byte, 4)}
+	hub.handleRegister(agent)
+
+	hello, _ := ws.NewMessage(ws.TypeHello, ws.HelloPayload{ProtocolVersion: ws.ProtocolVersion, AgentVersion: "0.1.0"})
+	data, _ := json.Marshal(hello)
+	go handler.handleMessage(agent, data)
+
+	if got := <-hub.unregister; got != agent {
+		t.Fatalf("expected conn-1 to be disconnected, got %s", got.ID)
+	}
+	var msg struct {
+		Type    string                    `json:"type"`
+		Payload ws.UpgradeRequiredPayload `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != string(ws.TypeUpgradeRequired) || msg.Payload.MinVersion != "0.2.0" || msg.Payload.AgentVersion != "0.1.0" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if len(agent.Send) != 0 {
+		t.Error("expected no welcome for an outdated agent")
+	}
+	if registry.upgrades["agent-1"] != msg.Payload.Reason {
+		t.Errorf("expected the reason %q on agent-1's registry entry, got %v", msg.Payload.Reason, registry.upgrades)
+	}
+}
+
+func TestHandler_AgentWithoutVersionIsToldToUpgrade(t *testing.T) {
+	hub := NewHub(nil)
+	handler := NewHandler(hub)
+	handler.SetMinAgentVersion("0.2.0")
+	registry := &stubRegistry{}
+	handler.SetRegistry(registry)
+	agent := &AgentConn{ID: "conn-1", UserID: "user-1", AgentID: "agent-1", Send: make(chan []byte, 4)}
+	hub.handleRegister(agent)
+
+	heartbeat, _ := json.Marshal(map[string]interface{}{
+		"type":    "heartbeat",
+		"payload": map[string]interface{}{"agent_id": "agent-1"},
+	})
+	go handler.handleMessage(agent, heartbeat)
+
+	if got := <-hub.unregister; got != agent {
+		t.Fatalf("expected conn-1 to be disconnected, got %s", got.ID)
+	}
+	var msg struct {
+		Type    string                    `json:"type"`
+		Payload ws.UpgradeRequiredPayload `json:"payload"`
+	}
+	if err := json.Unmarshal(<-agent.Send, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != string(ws.TypeUpgradeRequired) || msg.Payload.AgentVersion != "" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if registry.upgrades["agent-1"] != msg.Payload.Reason {
+		t.Errorf("expected the reason %q on agent-1's registry entry, got %v", msg.Payload.Reason, registry.upgrades)
+	}
+}
@@ -0,0 +1,252 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/entrypoints/ws"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+// RuleSets tracks the revisions of each team's configuration and builds the
+// updates that bring agents to the current one
+type RuleSets interface {
+	Since(ctx context.Context, teamID string, since int64) (rulesets.Update, error)
+}
+
+// SetRuleSets makes the hub push each team's resolved rules to its agents
+// instead of a bare change notification
+func (h *Hub) SetRuleSets(r RuleSets) {
+	h.rules = r
+}
+
+// SyncAgent answers an agent's sync request with the changes since the
+// revision it has cached
+func (h *Hub) SyncAgent(agent *AgentConn, since int64) {
+	h.mu.Lock()
+	agent.revision = since
+	h.mu.Unlock()
+	h.pushUpdate(agent)
+}
+
+// pushUpdate sends the agent whatever it is missing from its team's current
+// configuration
+func (h *Hub) pushUpdate(agent *AgentConn) {
+	h.mu.RLock()
+	teamID, since, proto := agent.TeamID, agent.revision, agent.protocol
+	h.mu.RUnlock()
+	if h.rules == nil || teamID == "" {
+		return
+	}
+	if !proto.supports(ws.CapabilityDelta) {
+		since = 0
+	}
+
+	update, err := h.rules.Since(h.ctx, teamID, since)
+	if err != nil {
+		log.Printf("Failed to resolve rules of team %s: %v", teamID, err)
+		return
+	}
+	data, err := configUpdateMessage(update, proto)
+	if err != nil {
+		log.Printf("Failed to encode rules of team %s: %v", teamID, err)
+		return
+	}
+
+	// Holding the lock keeps the agent from being unregistered, and its
+	// Send channel closed, while sending
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.connections[agent.ID]; !ok || agent.TeamID != teamID {
+		return
+	}
+	if h.deliverConfig(agent, data, update.Revision) {
+		agent.revision = update.Revision
+	}
+}
+
+// broadcastUpdates brings every agent of the team to the team's current
+// revision. Agents are grouped by the revision they were last sent, so each
+// group gets one delta whichever worker committed the change. Agents that
+// cannot apply deltas get a full snapshot instead.
+func (h *Hub) broadcastUpdates(teamID string) (recipients int) {
+	h.mu.RLock()
+	// Revision -> whether an agent of the group cannot apply deltas
+	groups := make(map[int64]bool)
+	for agent := range h.teamAgents[teamID] {
+		groups[agent.revision] = groups[agent.revision] || !agent.protocol.supports(ws.CapabilityDelta)
+	}
+	h.mu.RUnlock()
+
+	var snapshot *rulesets.Update
+	messages := configMessages{}
+	for since, needsSnapshot := range groups {
+		update, err := h.rules.Since(h.ctx, teamID, since)
+		if err != nil {
+			log.Printf("Failed to resolve rules of team %s: %v", teamID, err)
+			return recipients
+		}
+		if update.Revision == since && !update.Full {
+			continue
+		}
+		if needsSnapshot && !update.Full && snapshot == nil {
+			full, err := h.rules.Since(h.ctx, teamID, 0)
+			if err != nil {
+				log.Printf("Failed to resolve rules of team %s: %v", teamID, err)
+				return recipients
+			}
+			snapshot = &full
+		}
+
+		delivered, missingSnapshot := h.deliverUpdate(teamID, since, update, snapshot, messages)
+		recipients += delivered
+		// An agent without delta support joined the group after it was
+		// looked at; the agents already updated left the group
+		if missingSnapshot {
+			full, err := h.rules.Since(h.ctx, teamID, 0)
+			if err != nil {
+				log.Printf("Failed to resolve rules of team %s: %v", teamID, err)
+				return recipients
+			}
+			snapshot = &full
+			delivered, _ = h.deliverUpdate(teamID, since, update, snapshot, messages)
+			recipients += delivered
+		}
+	}
+	return recipients
+}
+
+// deliverUpdate sends the update to the team's agents at revision since, and
+// the snapshot to those that cannot apply a delta. Agents that need the
+// snapshot when there is none are skipped and reported.
+func (h *Hub) deliverUpdate(teamID string, since int64, update rulesets.Update, snapshot *rulesets.Update, messages configMessages) (recipients int, missingSnapshot bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for agent := range h.teamAgents[teamID] {
+		if agent.revision != since {
+			continue
+		}
+		update := update
+		if !update.Full && !agent.protocol.supports(ws.CapabilityDelta) {
+			if snapshot == nil {
+				missingSnapshot = true
+				continue
+			}
+			update = *snapshot
+		}
+		data, err := messages.encode(update, agent.protocol)
+		if err != nil {
+			log.Printf("Failed to encode rules of team %s: %v", teamID, err)
+			continue
+		}
+		// An update that does not fit waits for the agent and is replaced
+		// by the next one
+		if h.deliverConfig(agent, data, update.Revision) {
+			agent.revision = update.Revision
+			recipients++
+		}
+	}
+	return recipients, missingSnapshot
+}
+
+// configMessages encodes each shape of a config update once
+type configMessages map[configShape][]byte
+
+type configShape struct {
+	revision int64
+	since    int64
+	full     bool
+	targets  bool
+}
+
+func (m configMessages) encode(update rulesets.Update, p protocol) ([]byte, error) {
+	shape := configShape{
+		revision: update.Revision,
+		since:    update.Since,
+		full:     update.Full,
+		targets:  p.supports(ws.CapabilityTargets),
+	}
+	if data, ok := m[shape]; ok {
+		return data, nil
+	}
+	data, err := configUpdateMessage(update, p)
+	if err != nil {
+		return nil, err
+	}
+	m[shape] = data
+	return data, nil
+}
+
+// configUpdateMessage encodes an update in the shape the agent understands
+func configUpdateMessage(update rulesets.Update, p protocol) ([]byte, error) {
+	payload := configUpdatePayload(update)
+	if !p.supports(ws.CapabilityTargets) {
+		payload.Targets = nil
+	}
+	msg, err := ws.NewMessage(ws.TypeConfigUpdate, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(msg)
+}
+
+func configUpdatePayload(update rulesets.Update) ws.ConfigUpdatePayload {
+	categoryNames := make(map[string]string)
+	categories := make([]ws.CategoryPayload, 0, len(update.Categories))
+	for _, c := range update.Categories {
+		categoryNames[c.ID] = c.Name
+		categories = append(categories, ws.CategoryPayload{
+			ID:           c.ID,
+			Name:         c.Name,
+			IsSystem:     c.IsSystem,
+			DisplayOrder: c.DisplayOrder,
+		})
+	}
+
+	rules := make([]ws.RulePayload, 0, len(update.Rules))
+	for _, r := range update.Rules {
+		triggers, _ := json.Marshal(r.Triggers)
+		rule := ws.RulePayload{
+			ID:                    r.ID,
+			Name:                  r.Name,
+			Content:               r.Content,
+			TargetLayer:           r.TargetLayer.AgentLayer(),
+			Triggers:              triggers,
+			PriorityWeight:        r.PriorityWeight,
+			Overridable:           r.Overridable,
+			EffectiveStart:        unixSeconds(r.EffectiveStart),
+			EffectiveEnd:          unixSeconds(r.EffectiveEnd),
+			EnforcementMode:       string(r.EnforcementMode),
+			TemporaryTimeoutHours: r.TemporaryTimeoutHours,
+			RuleVersion:           r.CurrentVersion,
+		}
+		if r.CategoryID != nil {
+			rule.CategoryID = *r.CategoryID
+			rule.CategoryName = categoryNames[*r.CategoryID]
+		}
+		rules = append(rules, rule)
+	}
+
+	payload := ws.ConfigUpdatePayload{
+		Rules:      rules,
+		Version:    int(update.Revision),
+		Categories: categories,
+		Targets:    update.Targets,
+	}
+	if !update.Full {
+		payload.Delta = true
+		payload.SinceVersion = int(update.Since)
+		payload.RemovedRuleIDs = update.RemovedRuleIDs
+	}
+	return payload
+}
+
+func unixSeconds(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	s := t.Unix()
+	return &s
+}
@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/domain"
+	"github.com/kamilrybacki/edictflow/server/services/rulesets"
+)
+
+func TestConfigUpdatePayload(t *testing.T) {
+	categoryID := "cat-security"
+	end := time.Unix(1900000000, 0)
+	rule := domain.NewGlobalRule("No secrets", "Never commit secrets", true)
+	rule.CategoryID = &categoryID
+	rule.PriorityWeight = 5
+	rule.EffectiveEnd = &end
+	rule.EnforcementMode = domain.EnforcementModeWarning
+	projectRule := domain.NewRule("Go style", domain.TargetLayerProject, "Use gofmt", []domain.Trigger{
+		{Type: domain.TriggerTypeContext, ContextTypes: []string{"go"}},
+	}, "team-1")
+
+	update := rulesets.Update{
+		TeamID:     "team-1",
+		Revision:   42,
+		Full:       true,
+		Rules:      []domain.Rule{rule, projectRule},
+		Categories: []domain.Category{{ID: categoryID, Name: "Security", DisplayOrder: 2}},
+		Targets:    []string{"claude", "agents"},
+	}
+
+	payload := configUpdatePayload(update)
+
+	if payload.Version != 42 || payload.Delta {
+		t.Errorf("expected full snapshot at version 42, got version %d delta=%v", payload.Version, payload.Delta)
+	}
+	if len(payload.Categories) != 1 || payload.Categories[0].Name != "Security" || payload.Categories[0].DisplayOrder != 2 {
+		t.Errorf("unexpected categories: %+v", payload.Categories)
+	}
+	if len(payload.Targets) != 2 {
+		t.Errorf("expected targets to be passed through, got %v", payload.Targets)
+	}
+	if len(payload.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(payload.Rules))
+	}
+
+	global := payload.Rules[0]
+	if global.TargetLayer != "enterprise" {
+		t.Errorf("expected organization rule on the enterprise layer, got %s", global.TargetLayer)
+	}
+	if global.CategoryID != categoryID || global.CategoryName != "Security" {
+		t.Errorf("expected category to be resolved, got %s/%s", global.CategoryID, global.CategoryName)
+	}
+	if global.PriorityWeight != 5 || global.EnforcementMode != "warning" {
+		t.Errorf("unexpected rule settings: %+v", global)
+	}
+	if global.EffectiveStart != nil || global.EffectiveEnd == nil || *global.EffectiveEnd != end.Unix() {
+		t.Errorf("expected effective end as Unix seconds, got %v-%v", global.EffectiveStart, global.EffectiveEnd)
+	}
+
+	project := payload.Rules[1]
+	if project.TargetLayer != "project" {
+		t.Errorf("expected project layer, got %s", project.TargetLayer)
+	}
+	var triggers []domain.Trigger
+	if err := json.Unmarshal(project.Triggers, &triggers); err != nil || len(triggers) != 1 {
+		t.Errorf("expected triggers to round-trip, got %s", project.Triggers)
+	}
+}
+
+func TestConfigUpdatePayload_Delta(t *testing.T) {
+	update := rulesets.Update{
+		TeamID:         "team-1",
+		Revision:       7,
+		Since:          5,
+		RemovedRuleIDs: []string{"rule-1"},
+	}
+
+	payload := configUpdatePayload(update)
+
+	if !payload.Delta || payload.SinceVersion != 5 || payload.Version != 7 {
+		t.Errorf("expected delta from 5 to 7, got %+v", payload)
+	}
+	if len(payload.RemovedRuleIDs) != 1 || payload.RemovedRuleIDs[0] != "rule-1" {
+		t.Errorf("expected removed rules, got %v", payload.RemovedRuleIDs)
+	}
+}
+
+// fakeRuleSets serves deltas of a team whose current revision is fixed
+type fakeRuleSets struct {
+	revision int64
+	calls    []int64
+	// resolving runs on every call, before the update is returned
+	resolving func()
+}
+
+func (f *fakeRuleSets) Since(ctx context.Context, teamID string, since int64) (rulesets.Update, error) {
+	f.calls = append(f.calls, since)
+	if f.resolving != nil {
+		f.resolving()
+	}
+	update := rulesets.Update{TeamID: teamID, Revision: f.revision, Since: since}
+	if since == 0 {
+		update.Full = true
+		update.Since = 0
+	}
+	return update, nil
+}
+
+func TestHub_BroadcastUpdatesGroupsAgentsByRevision(t *testing.T) {
+	hub := NewHub(nil)
+	rules := &fakeRuleSets{revision: 4}
+	hub.SetRuleSets(rules)
+
+	fresh := &AgentConn{ID: "conn-1", TeamID: "team-1", Send: make(chan []byte, 4), protocol: deltaProtocol}
+	behind := &AgentConn{ID: "conn-2", TeamID: "team-1", Send: make(chan []byte, 4), revision: 3, protocol: deltaProtocol}
+	further := &AgentConn{ID: "conn-4", TeamID: "team-1", Send: make(chan []byte, 4), revision: 2, protocol: deltaProtocol}
+	current := &AgentConn{ID: "conn-3", TeamID: "team-1", Send: make(chan []byte, 4), revision: 4, protocol: deltaProtocol}
+	hub.teamAgents["team-1"] = map[*AgentConn]struct{}{fresh: {}, behind: {}, further: {}, current: {}}
+
+	if got := hub.broadcastUpdates("team-1"); got != 3 {
+		t.Errorf("expected 3 recipients, got %d", got)
+	}
+	if len(rules.calls) != 4 {
+		t.Errorf("expected one resolution per revision, got %v", rules.calls)
+	}
+
+	for _, tt := range []struct {
+		agent *AgentConn
+		delta bool
+		since int
+	}{{fresh, false, 0}, {behind, true, 3}, {further, true, 2}} {
+		select {
+		case data := <-tt.agent.Send:
+			var msg struct {
+				Type    string `json:"type"`
+				Payload struct {
+					Version      int  `json:"version"`
+					Delta        bool `json:"delta"`
+					SinceVersion int  `json:"since_version"`
+				} `json:"payload"`
+			}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Type != "config_update" || msg.Payload.Version != 4 || msg.Payload.Delta != tt.delta ||
+				msg.Payload.SinceVersion != tt.since {
+				t.Errorf("agent %s: unexpected message %s", tt.agent.ID, data)
+			}
+		default:
+			t.Errorf("agent %s: expected an update", tt.agent.ID)
+		}
+		if tt.agent.revision != 4 {
+			t.Errorf("agent %s: expected revision 4, got %d", tt.agent.ID, tt.agent.revision)
+		}
+	}
+
+	select {
+	case data := <-current.Send:
+		t.Errorf("expected no update for an agent at the current revision, got %s", data)
+	default:
+	}
+}
+
+func TestHub_BroadcastUpdatesSnapshotsAgentJoiningDuringResolution(t *testing.T) {
+	hub := NewHub(nil)
+	rules := &fakeRuleSets{revision: 4}
+	hub.SetRuleSets(rules)
+
+	behind := &AgentConn{ID: "conn-1", TeamID: "team-1", Send: make(chan []byte, 4), revision: 3, protocol: deltaProtocol}
+	legacy := &AgentConn{ID: "conn-2", TeamID: "team-1", Send: make(chan []byte, 4), revision: 3}
+	hub.teamAgents["team-1"] = map[*AgentConn]struct{}{behind: {}}
+	// The agent without delta support joins after the groups were taken
+	rules.resolving = func() {
+		hub.mu.Lock()
+		hub.teamAgents["team-1"][legacy] = struct{}{}
+		hub.mu.Unlock()
+		rules.resolving = nil
+	}
+
+	if got := hub.broadcastUpdates("team-1"); got != 2 {
+		t.Errorf("expected 2 recipients, got %d", got)
+	}
+
+	var msg struct {
+		Payload struct {
+			Version int  `json:"version"`
+			Delta   bool `json:"delta"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(<-legacy.Send, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Payload.Version != 4 || msg.Payload.Delta {
+		t.Errorf("expected a snapshot at revision 4, got %+v", msg.Payload)
+	}
+	if behind.revision != 4 || legacy.revision != 4 {
+		t.Errorf("expected both agents at revision 4, got %d and %d", behind.revision, legacy.revision)
+	}
+}
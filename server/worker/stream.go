@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"log"
+	"time"
+
+	"github.com/kamilrybacki/edictflow/server/events"
+)
+
+const (
+	// streamBatchSize is the most entries read from the event stream at once
+	streamBatchSize = 100
+	// streamBlock is how long a read waits for new entries
+	streamBlock = 5 * time.Second
+	// streamRetryDelay is the pause after a failed read
+	streamRetryDelay = time.Second
+)
+
+// ConsumeStream makes the hub read events from the Redis event stream instead
+// of subscribing to team channels. Every worker needs every event, so each
+// reads with its own consumer group, named after workerID; a worker restarted
+// with the same ID resumes after the last entry it acknowledged. Call before
+// Run.
+func (h *Hub) ConsumeStream(workerID string) {
+	h.streamGroup = "worker:" + workerID
+	h.streamConsumer = workerID
+}
+
+// consumeStream reads the event stream until the hub stops
+func (h *Hub) consumeStream() {
+	stream := events.StreamEvents
+	h.metrics.RecordRedisSubscription(stream, "subscribe")
+	log.Printf("Consuming event stream %s as group %s", stream, h.streamGroup)
+
+	// Entries delivered before a restart but never acknowledged come first
+	id := "0"
+	ready := false
+	for h.ctx.Err() == nil {
+		if !ready {
+			if err := h.redisClient.CreateStreamGroup(h.ctx, stream, h.streamGroup); err != nil {
+				h.retryStream("Failed to create consumer group %s: %v", h.streamGroup, err)
+				continue
+			}
+			ready = true
+		}
+
+		messages, err := h.redisClient.ReadStreamGroup(h.ctx, stream, h.streamGroup, h.streamConsumer, id, streamBatchSize, streamBlock)
+		if err != nil {
+			// The group is gone if the stream was deleted
+			ready = false
+			h.retryStream("Failed to read event stream %s: %v", stream, err)
+			continue
+		}
+		if id != ">" && len(messages) == 0 {
+			id = ">"
+			continue
+		}
+
+		for _, msg := range messages {
+			h.handleStreamEvent(msg.Data)
+			if err := h.redisClient.AckStream(h.ctx, stream, h.streamGroup, msg.ID); err != nil {
+				log.Printf("Failed to acknowledge event %s: %v", msg.ID, err)
+			}
+		}
+	}
+	h.metrics.RecordRedisSubscription(stream, "unsubscribe")
+}
+
+func (h *Hub) handleStreamEvent(data []byte) {
+	event, err := events.UnmarshalEvent(data)
+	if err != nil {
+		log.Printf("Failed to unmarshal event: %v", err)
+		return
+	}
+	if event.TeamID == "" {
+		h.broadcastToAll(event)
+		return
+	}
+
+	// Agents connecting later sync on connect, so events of teams without
+	// agents here need no delivery
+	h.mu.RLock()
+	_, connected := h.teamAgents[event.TeamID]
+	h.mu.RUnlock()
+	if connected {
+		h.broadcastToTeam(event.TeamID, event)
+	}
+}
+
+func (h *Hub) retryStream(format string, args ...interface{}) {
+	if h.ctx.Err() != nil {
+		return
+	}
+	log.Printf(format, args...)
+	select {
+	case <-h.ctx.Done():
+	case <-time.After(streamRetryDelay):
+	}
+}
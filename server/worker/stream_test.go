@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/kamilrybacki/edictflow/server/events"
+)
+
+func TestHub_HandleStreamEvent(t *testing.T) {
+	hub := NewHub(nil)
+	hub.ConsumeStream("worker-1")
+	rules := &fakeRuleSets{revision: 2}
+	hub.SetRuleSets(rules)
+
+	agent := &AgentConn{ID: "conn-1", TeamID: "team-1", Send: make(chan []byte, 4)}
+	hub.teamAgents["team-1"] = map[*AgentConn]struct{}{agent: {}}
+
+	for _, teamID := range []string{"team-1", "team-2", ""} {
+		data, err := events.NewEvent(events.EventRuleUpdated, "rule-1", teamID).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hub.handleStreamEvent(data)
+	}
+	hub.handleStreamEvent([]byte("not json"))
+
+	// The team event and the broadcast both reach team-1, but the agent is
+	// current after the first
+	if len(rules.calls) != 2 {
+		t.Errorf("expected only the team with agents to be resolved, got %v", rules.calls)
+	}
+	if len(agent.Send) != 1 {
+		t.Errorf("expected 1 update for the agent, got %d", len(agent.Send))
+	}
+}
+
+func TestHub_ConsumeStreamSkipsTeamSubscriptions(t *testing.T) {
+	hub := NewHub(nil)
+	hub.ConsumeStream("worker-1")
+
+	if hub.streamGroup != "worker:worker-1" || hub.streamConsumer != "worker-1" {
+		t.Errorf("unexpected group %q and consumer %q", hub.streamGroup, hub.streamConsumer)
+	}
+
+	// Would dereference the nil Redis client when subscribing to a channel
+	hub.subscribeToTeam("team-1")
+
+	if _, _, subs := hub.Stats(); subs != 0 {
+		t.Errorf("expected no channel subscriptions, got %d", subs)
+	}
+}
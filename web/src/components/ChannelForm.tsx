@@ -23,6 +23,7 @@ const notificationEvents = [
   { value: 'change_auto_reverted', label: 'Change Auto-reverted' },
   { value: 'exception_granted', label: 'Exception Granted' },
   { value: 'exception_denied', label: 'Exception Denied' },
+  { value: 'agent_offline', label: 'Agent Offline' },
 ];
 
 export function ChannelForm({ channel, teamId, onSave, onCancel }: ChannelFormProps) {
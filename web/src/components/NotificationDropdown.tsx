@@ -19,6 +19,7 @@ const notificationTypeIcons: Record<NotificationType, string> = {
   change_auto_reverted: '↩️',
   exception_granted: '🔓',
   exception_denied: '🔒',
+  agent_offline: '📴',
 };
 
 const notificationTypeLabels: Record<NotificationType, string> = {
@@ -29,6 +30,7 @@ const notificationTypeLabels: Record<NotificationType, string> = {
   change_auto_reverted: 'Auto-Reverted',
   exception_granted: 'Exception Granted',
   exception_denied: 'Exception Denied',
+  agent_offline: 'Agent Offline',
 };
 
 const notificationTypeColors: Record<NotificationType, string> = {
@@ -39,6 +41,7 @@ const notificationTypeColors: Record<NotificationType, string> = {
   change_auto_reverted: 'text-orange-400',
   exception_granted: 'text-green-400',
   exception_denied: 'text-red-400',
+  agent_offline: 'text-red-400',
 };
 
 const notificationTypeBgColors: Record<NotificationType, string> = {
@@ -49,6 +52,7 @@ const notificationTypeBgColors: Record<NotificationType, string> = {
   change_auto_reverted: 'bg-orange-500/10 border-orange-500/20',
   exception_granted: 'bg-green-500/10 border-green-500/20',
   exception_denied: 'bg-red-500/10 border-red-500/20',
+  agent_offline: 'bg-red-500/10 border-red-500/20',
 };
 
 function formatTimeAgo(dateString: string): string {
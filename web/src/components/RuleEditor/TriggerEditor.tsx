@@ -3,6 +3,14 @@
 import { Trigger, TriggerType } from '@/domain/rule';
 import { triggerTypes } from './constants';
 
+const patternPlaceholders: Partial<Record<TriggerType, string>> = {
+  path: 'e.g., **/services/**',
+  glob: 'e.g., src/**/*.ts',
+  regex: 'e.g., ^/srv/[a-z]+-api$',
+  file_exists: 'e.g., go.mod',
+  repository: 'e.g., github.com/acme/payments-*',
+};
+
 interface TriggerEditorProps {
   triggers: Trigger[];
   onTriggersChange: (triggers: Trigger[]) => void;
@@ -17,7 +25,7 @@ export function TriggerEditor({ triggers, onTriggersChange }: TriggerEditorProps
     onTriggersChange(triggers.filter((_, i) => i !== index));
   };
 
-  const handleTriggerChange = (index: number, field: keyof Trigger, value: string | string[]) => {
+  const handleTriggerChange = (index: number, field: keyof Trigger, value: string | string[] | boolean) => {
     const newTriggers = [...triggers];
     const trigger = { ...newTriggers[index] };
 
@@ -27,12 +35,17 @@ export function TriggerEditor({ triggers, onTriggersChange }: TriggerEditorProps
       trigger.pattern = undefined;
       trigger.contextTypes = undefined;
       trigger.tags = undefined;
+      trigger.branches = undefined;
     } else if (field === 'pattern') {
       trigger.pattern = value as string;
     } else if (field === 'contextTypes') {
       trigger.contextTypes = (value as string).split(',').map((s) => s.trim()).filter(Boolean);
     } else if (field === 'tags') {
       trigger.tags = (value as string).split(',').map((s) => s.trim()).filter(Boolean);
+    } else if (field === 'branches') {
+      trigger.branches = (value as string).split(',').map((s) => s.trim()).filter(Boolean);
+    } else if (field === 'negate') {
+      trigger.negate = value as boolean;
     }
 
     newTriggers[index] = trigger;
@@ -73,12 +86,22 @@ export function TriggerEditor({ triggers, onTriggersChange }: TriggerEditorProps
                 ))}
               </select>
 
-              {trigger.type === 'path' && (
+              {trigger.type in patternPlaceholders && (
                 <input
                   type="text"
                   value={trigger.pattern || ''}
                   onChange={(e) => handleTriggerChange(index, 'pattern', e.target.value)}
-                  placeholder="e.g., *.tsx, src/**/*.ts"
+                  placeholder={patternPlaceholders[trigger.type]}
+                  className="flex-1 px-2 py-1 text-sm border border-zinc-300 dark:border-zinc-600 rounded bg-white dark:bg-zinc-700"
+                />
+              )}
+
+              {trigger.type === 'repository' && (
+                <input
+                  type="text"
+                  value={trigger.branches?.join(', ') || ''}
+                  onChange={(e) => handleTriggerChange(index, 'branches', e.target.value)}
+                  placeholder="Branches (optional), e.g., main, release/*"
                   className="flex-1 px-2 py-1 text-sm border border-zinc-300 dark:border-zinc-600 rounded bg-white dark:bg-zinc-700"
                 />
               )}
@@ -103,6 +126,15 @@ export function TriggerEditor({ triggers, onTriggersChange }: TriggerEditorProps
                 />
               )}
 
+              <label className="flex items-center gap-1 text-sm text-zinc-600 dark:text-zinc-400">
+                <input
+                  type="checkbox"
+                  checked={trigger.negate || false}
+                  onChange={(e) => handleTriggerChange(index, 'negate', e.target.checked)}
+                />
+                Not
+              </label>
+
               <button
                 type="button"
                 onClick={() => handleRemoveTrigger(index)}
@@ -6,7 +6,7 @@ export const targetLayers: { value: TargetLayer; label: string }[] = [
   { value: 'project', label: 'Project - Applies to a single repository' },
 ];
 
-export const triggerTypes: TriggerType[] = ['path', 'context', 'tag'];
+export const triggerTypes: TriggerType[] = ['path', 'glob', 'regex', 'file_exists', 'repository', 'context', 'tag'];
 
 export const enforcementModes: { value: EnforcementMode; label: string; description: string }[] = [
   { value: 'block', label: 'Block', description: 'Changes are immediately reverted and require admin approval to apply.' },
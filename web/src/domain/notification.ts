@@ -5,7 +5,8 @@ export type NotificationType =
   | 'change_rejected'
   | 'change_auto_reverted'
   | 'exception_granted'
-  | 'exception_denied';
+  | 'exception_denied'
+  | 'agent_offline';
 
 export interface Notification {
   id: string;
@@ -3,7 +3,7 @@ export type TargetLayer = 'organization' | 'team' | 'project';
 export type LegacyTargetLayer = 'enterprise' | 'user' | 'global' | 'local';
 export type AllTargetLayers = TargetLayer | LegacyTargetLayer;
 
-export type TriggerType = 'path' | 'context' | 'tag';
+export type TriggerType = 'path' | 'glob' | 'regex' | 'file_exists' | 'repository' | 'context' | 'tag';
 export type RuleStatus = 'draft' | 'pending' | 'approved' | 'rejected';
 export type EnforcementMode = 'block' | 'temporary' | 'warning';
 
@@ -12,6 +12,8 @@ export interface Trigger {
   pattern?: string;
   contextTypes?: string[];
   tags?: string[];
+  branches?: string[];
+  negate?: boolean;
 }
 
 export interface Category {
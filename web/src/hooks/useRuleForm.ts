@@ -139,6 +139,8 @@ export function useRuleForm({ teamId, rule, onSuccess }: UseRuleFormOptions): Us
           pattern: t.pattern,
           context_types: t.contextTypes,
           tags: t.tags,
+          branches: t.branches,
+          negate: t.negate || undefined,
         })),
         enforcement_mode: formData.enforcementMode,
         temporary_timeout_hours: formData.temporaryTimeoutHours,
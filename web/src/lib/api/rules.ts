@@ -20,6 +20,8 @@ export interface CreateRuleRequest {
     pattern?: string;
     context_types?: string[];
     tags?: string[];
+    branches?: string[];
+    negate?: boolean;
   }>;
   enforcement_mode?: string;
   temporary_timeout_hours?: number;